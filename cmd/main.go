@@ -2,20 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/cexll/swe/internal/budget"
 	"github.com/cexll/swe/internal/config"
 	"github.com/cexll/swe/internal/dispatcher"
 	"github.com/cexll/swe/internal/executor"
 	"github.com/cexll/swe/internal/github"
 	"github.com/cexll/swe/internal/provider"
+	_ "github.com/cexll/swe/internal/provider/all"
+	"github.com/cexll/swe/internal/provider/fallback"
+	"github.com/cexll/swe/internal/sandbox"
 	"github.com/cexll/swe/internal/taskstore"
 	"github.com/cexll/swe/internal/web"
 	"github.com/cexll/swe/internal/webhook"
+	"github.com/cexll/swe/internal/workspace"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
@@ -30,6 +40,13 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "budget" {
+		_ = loadDotEnv()
+		if err := runBudget(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("budget: %v", err)
+		}
+		return
+	}
 	if err := run(context.Background(), defaultListenServe); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
@@ -53,19 +70,10 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 	log.Printf("Dispatcher workers: %d, queue size: %d, max attempts: %d", cfg.DispatcherWorkers, cfg.DispatcherQueueSize, cfg.DispatcherMaxAttempts)
 
 	// Initialize SQLite task store for UI
-	dbPath := os.Getenv("TASKSTORE_DB_PATH")
-	if dbPath == "" {
-		dbPath = "./data/tasks.db"
-	}
-
-	// Ensure data directory exists
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
-	}
-
-	taskStore, err := newTaskStore(dbPath)
+	dbPath := taskStorePath()
+	taskStore, err := openTaskStore(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize task store: %w", err)
+		return err
 	}
 	defer func() {
 		if err := taskStore.Close(); err != nil {
@@ -73,6 +81,12 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 		}
 	}()
 
+	taskStore.SetArtifactRetention(taskstore.ArtifactRetention{
+		MaxAge:           time.Duration(cfg.ArtifactRetentionDays) * 24 * time.Hour,
+		MaxTotalBytes:    int64(cfg.ArtifactMaxTotalMB) << 20,
+		MaxArtifactBytes: int64(cfg.ArtifactMaxMB) << 20,
+	})
+
 	log.Printf("Task store initialized: %s", dbPath)
 
 	// Initialize GitHub App authentication
@@ -81,41 +95,86 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 		PrivateKey: cfg.GitHubPrivateKey,
 	}
 
-	// Initialize AI provider based on configuration
-	var aiProvider provider.Provider
-
-	switch cfg.Provider {
-	case "claude":
-		log.Printf("Claude model: %s", cfg.ClaudeModel)
-		aiProvider, err = newProvider(&provider.Config{
-			Name:         "claude",
-			ClaudeAPIKey: cfg.ClaudeAPIKey,
-			ClaudeModel:  cfg.ClaudeModel,
-		})
-	case "codex":
-		log.Printf("Codex model: %s", cfg.CodexModel)
-		if cfg.OpenAIBaseURL != "" {
-			log.Printf("Using custom OpenAI Base URL: %s", cfg.OpenAIBaseURL)
-		}
-		aiProvider, err = newProvider(&provider.Config{
-			Name:          "codex",
-			OpenAIAPIKey:  cfg.OpenAIAPIKey,
-			OpenAIBaseURL: cfg.OpenAIBaseURL,
-			CodexModel:    cfg.CodexModel,
-		})
-	default:
-		return fmt.Errorf("unsupported provider: %s", cfg.Provider)
+	sb, err := sandbox.New(sandbox.Config{
+		Mode:           cfg.SandboxMode,
+		AllowEnv:       cfg.SandboxAllowEnv,
+		CPUSeconds:     cfg.SandboxCPUSeconds,
+		MemoryMB:       cfg.SandboxMemoryMB,
+		Timeout:        time.Duration(cfg.SandboxTimeoutSeconds) * time.Second,
+		MaxOutputBytes: int64(cfg.SandboxMaxOutputMB) << 20,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize provider sandbox: %w", err)
 	}
+	log.Printf("Provider sandbox: %s", sb.Mode())
 
+	// Initialize AI provider based on configuration
+	if model := cfg.ProviderSettings.Get("MODEL"); model != "" {
+		log.Printf("Model: %s", model)
+	}
+	if baseURL := cfg.ProviderSettings.Get("BASE_URL"); baseURL != "" {
+		log.Printf("Using custom Base URL: %s", baseURL)
+	}
+	pc := providerConfigFor(cfg, cfg.Provider, "")
+	pc.Sandbox = sb
+	aiProvider, err := newProvider(pc)
 	if err != nil {
 		return fmt.Errorf("failed to initialize AI provider: %w", err)
 	}
 	log.Printf("AI Provider: %s", aiProvider.Name())
 
+	chain, err := newFallbackChain(cfg, sb, aiProvider)
+	if err != nil {
+		return err
+	}
+	if chain != nil {
+		aiProvider = chain
+	}
+
 	// Initialize executor
 	exec := executor.New(aiProvider, appAuth)
 	exec.WithStore(taskStore)
 	exec.WithDisallowedTools(cfg.DisallowedTools)
+	exec.WithChangeLimits(int64(cfg.ChangeMaxFileMB)<<20, int64(cfg.ChangeMaxTotalMB)<<20)
+	exec.WithPrices(cfg.Prices)
+	exec.WithSessionTTL(time.Duration(cfg.SessionTTLHours) * time.Hour)
+	exec.WithSandbox(sb)
+	budgets := budget.New(taskStore)
+	exec.WithBudgets(budgets)
+	exec.WithProviderFactory(func(name, model string) (provider.Provider, error) {
+		pc := providerConfigFor(cfg, name, model)
+		pc.Sandbox = sb
+		p, err := newProvider(pc)
+		if err != nil || chain == nil {
+			return p, err
+		}
+		return chain.WithPrimary(p), nil
+	})
+	exec.WithNamingTemplates(cfg.Naming)
+	if cfg.WorkspaceCacheDir != "" {
+		cache, err := workspace.New(workspace.Options{
+			Dir:      cfg.WorkspaceCacheDir,
+			MaxBytes: int64(cfg.WorkspaceCacheMaxMB) << 20,
+			MaxRepos: cfg.WorkspaceCacheMaxRepos,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize workspace cache: %w", err)
+		}
+		exec.WithCloneFunc(cache.Clone)
+		log.Printf("Workspace cache: %s", cfg.WorkspaceCacheDir)
+	}
+	exec.WithCommitSigning(executor.CommitSigning{
+		Mode:   cfg.CommitSigning,
+		Key:    cfg.CommitSigningKey,
+		APIURL: cfg.GitHubAPIURL,
+	})
+	if cfg.CommitSigning != "" {
+		log.Printf("Commit signing: %s", cfg.CommitSigning)
+	}
+	if !cfg.SecretScanEnabled {
+		log.Printf("Warning: secret scanning disabled via SECRET_SCAN_ENABLED=false")
+		exec.WithSecretScanner(nil)
+	}
 
 	// Initialize dispatcher (task queue with retries)
 	dispatcherConfig := dispatcher.Config{
@@ -131,6 +190,8 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 
 	// Initialize webhook handler
 	handler := webhook.NewHandler(cfg.GitHubWebhookSecret, cfg.TriggerKeyword, taskDispatcher, taskStore, appAuth)
+	handler.WithPullRequestListener(exec)
+	handler.WithBudgets(budgets)
 
 	// Initialize web UI handler
 	webHandler, err := newWebHandler(taskStore)
@@ -147,6 +208,7 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 	// Task UI endpoints
 	r.HandleFunc("/tasks", webHandler.ListTasks).Methods("GET")
 	r.HandleFunc("/tasks/{id}", webHandler.TaskDetail).Methods("GET")
+	r.HandleFunc("/tasks/{id}/artifacts/{kind}", webHandler.DownloadArtifact).Methods("GET")
 
 	// Health check endpoint
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -174,3 +236,129 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 
 	return nil
 }
+
+// newFallbackChain wraps the primary provider in the configured fallback chain.
+// It returns nil when PROVIDER_FALLBACK is empty.
+func newFallbackChain(cfg *config.Config, sb *sandbox.Sandbox, primary provider.Provider) (*fallback.Chain, error) {
+	if len(cfg.ProviderFallback) == 0 {
+		return nil, nil
+	}
+	var fallbacks []provider.Provider
+	for _, name := range cfg.ProviderFallback {
+		p, err := newProvider(&provider.Config{Name: name, Sandbox: sb})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize fallback provider %s: %w", name, err)
+		}
+		fallbacks = append(fallbacks, p)
+	}
+	switchOn, err := fallback.ParseClasses(cfg.ProviderFallbackOn)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Provider fallback: %s -> %s", primary.Name(), strings.Join(cfg.ProviderFallback, " -> "))
+	return fallback.New(primary, fallbacks, fallback.Options{
+		SwitchOn:         switchOn,
+		FailureThreshold: cfg.ProviderBreakerFailures,
+		Cooldown:         cfg.ProviderBreakerCooldown,
+	}), nil
+}
+
+// providerConfigFor builds provider settings for a repository-selected provider and model,
+// reusing the server settings when the repository keeps the server's provider.
+// Other providers load their settings from the environment.
+func providerConfigFor(cfg *config.Config, name, model string) *provider.Config {
+	pc := &provider.Config{Name: name, Model: model}
+	if name == cfg.Provider {
+		pc.Settings = cfg.ProviderSettings
+	}
+	return pc
+}
+
+// taskStorePath returns TASKSTORE_DB_PATH or the default database path.
+func taskStorePath() string {
+	if path := os.Getenv("TASKSTORE_DB_PATH"); path != "" {
+		return path
+	}
+	return "./data/tasks.db"
+}
+
+// openTaskStore creates the data directory and opens the task store.
+func openTaskStore(dbPath string) (*taskstore.Store, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	store, err := newTaskStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize task store: %w", err)
+	}
+	return store, nil
+}
+
+const budgetUsage = `usage:
+  swe-agent budget list
+  swe-agent budget set <installation|repo|user> <subject|*> <day|month> [-usd N] [-tokens N]
+  swe-agent budget delete <installation|repo|user> <subject|*> <day|month>`
+
+// runBudget manages the spend budgets kept in the task store.
+func runBudget(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", budgetUsage)
+	}
+	action, args := args[0], args[1:]
+	if (action == "set" || action == "delete") && len(args) < 3 {
+		return fmt.Errorf("%s needs a scope, subject and period\n%s", action, budgetUsage)
+	}
+
+	store, err := openTaskStore(taskStorePath())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch action {
+	case "list":
+		budgets, err := store.ListBudgets()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SCOPE\tSUBJECT\tPERIOD\tMAX USD\tMAX TOKENS")
+		for _, b := range budgets {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Scope, b.Subject, b.Period, budgetLimit(b.MaxUSD, "%.2f"), budgetLimit(float64(b.MaxTokens), "%.0f"))
+		}
+		return tw.Flush()
+	case "set":
+		b := taskstore.Budget{Scope: taskstore.BudgetScope(args[0]), Subject: args[1], Period: taskstore.BudgetPeriod(args[2])}
+		fs := flag.NewFlagSet("budget set", flag.ContinueOnError)
+		fs.SetOutput(out)
+		fs.Float64Var(&b.MaxUSD, "usd", 0, "USD limit per period, 0 = unlimited")
+		fs.IntVar(&b.MaxTokens, "tokens", 0, "token limit per period, 0 = unlimited")
+		if err := fs.Parse(args[3:]); err != nil {
+			return err
+		}
+		if err := store.SetBudget(b); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Budget set: %s %s per %s\n", b.Scope, b.Subject, b.Period)
+		return nil
+	case "delete":
+		deleted, err := store.DeleteBudget(taskstore.BudgetScope(args[0]), args[1], taskstore.BudgetPeriod(args[2]))
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fmt.Errorf("no %s budget for %s %s", args[2], args[0], args[1])
+		}
+		fmt.Fprintf(out, "Budget deleted: %s %s per %s\n", args[0], args[1], args[2])
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", action, budgetUsage)
+	}
+}
+
+func budgetLimit(limit float64, format string) string {
+	if limit == 0 {
+		return "-"
+	}
+	return fmt.Sprintf(format, limit)
+}
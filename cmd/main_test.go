@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cexll/swe/internal/config"
 	"github.com/cexll/swe/internal/provider"
 	"github.com/cexll/swe/internal/provider/claude"
 	"github.com/cexll/swe/internal/taskstore"
@@ -191,3 +193,82 @@ func (s *stubProvider) GenerateCode(ctx context.Context, req *claude.CodeRequest
 func (s *stubProvider) Name() string {
 	return s.name
 }
+
+func TestProviderConfigFor_RepoOverrides(t *testing.T) {
+	cfg := &config.Config{
+		Provider:         "claude",
+		ProviderSettings: provider.Settings{"API_KEY": "claude-key", "MODEL": "server-claude"},
+	}
+
+	pc := providerConfigFor(cfg, "codex", "gpt-5")
+	if pc.Name != "codex" || pc.Model != "gpt-5" || pc.Settings != nil {
+		t.Fatalf("providerConfigFor(codex) = %+v, want model override and settings from the environment", pc)
+	}
+
+	pc = providerConfigFor(cfg, "claude", "")
+	if pc.Model != "" || pc.Settings.Get("MODEL") != "server-claude" || pc.Settings.Get("API_KEY") != "claude-key" {
+		t.Fatalf("providerConfigFor(claude) = %+v, want server settings", pc)
+	}
+}
+
+func TestNewFallbackChain(t *testing.T) {
+	prevProvider := newProvider
+	defer func() { newProvider = prevProvider }()
+	newProvider = func(cfg *provider.Config) (provider.Provider, error) {
+		if cfg.Name == "broken" {
+			return nil, errors.New("missing key")
+		}
+		return &stubProvider{name: cfg.Name}, nil
+	}
+
+	chain, err := newFallbackChain(&config.Config{}, nil, &stubProvider{name: "claude"})
+	if err != nil || chain != nil {
+		t.Fatalf("newFallbackChain() = %v, %v, want no chain without PROVIDER_FALLBACK", chain, err)
+	}
+
+	cfg := &config.Config{ProviderFallback: []string{"codex", "openai"}, ProviderFallbackOn: []string{"rate_limit"}}
+	chain, err = newFallbackChain(cfg, nil, &stubProvider{name: "claude"})
+	if err != nil || chain == nil || chain.Name() != "claude" {
+		t.Fatalf("newFallbackChain() = %v, %v", chain, err)
+	}
+
+	cfg.ProviderFallback = []string{"broken"}
+	if _, err := newFallbackChain(cfg, nil, &stubProvider{name: "claude"}); err == nil || !strings.Contains(err.Error(), "fallback provider broken") {
+		t.Fatalf("error = %v, want fallback initialization error", err)
+	}
+}
+
+func TestRunBudget(t *testing.T) {
+	t.Setenv("TASKSTORE_DB_PATH", filepath.Join(t.TempDir(), "data", "tasks.db"))
+
+	var out bytes.Buffer
+	if err := runBudget([]string{"set", "user", "*", "day", "-usd", "5"}, &out); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := runBudget([]string{"set", "repo", "owner/repo", "month", "-tokens", "2000000"}, &out); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := runBudget([]string{"set", "team", "x", "day", "-usd", "1"}, &out); err == nil || !strings.Contains(err.Error(), "invalid budget scope") {
+		t.Fatalf("set with bad scope error = %v", err)
+	}
+
+	out.Reset()
+	if err := runBudget([]string{"list"}, &out); err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "owner/repo") || !strings.Contains(lines[1], "2000000") ||
+		!strings.Contains(lines[2], "user") || !strings.Contains(lines[2], "5.00") {
+		t.Fatalf("list output:\n%s", out.String())
+	}
+
+	if err := runBudget([]string{"delete", "user", "*", "day"}, &out); err != nil {
+		t.Fatalf("delete error = %v", err)
+	}
+	if err := runBudget([]string{"delete", "user", "*", "day"}, &out); err == nil {
+		t.Fatal("deleting a missing budget should fail")
+	}
+	if err := runBudget(nil, &out); err == nil || !strings.Contains(err.Error(), "usage:") {
+		t.Fatalf("missing command error = %v", err)
+	}
+}
@@ -8,6 +8,7 @@ require github.com/joho/godotenv v1.5.1
 
 require (
 	github.com/golang-jwt/jwt/v5 v5.3.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.39.1
 )
 
@@ -0,0 +1,164 @@
+// Package budget enforces the daily and monthly spend budgets kept in the
+// task store. Spend is the recorded usage of the tasks started in the
+// current period, so a task counts once it has finished generating.
+package budget
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// Subjects identifies who a task is charged to. Empty subjects are skipped.
+type Subjects struct {
+	Installation int64  // GitHub App installation ID
+	Repo         string // owner/name
+	User         string // login of the triggering user
+}
+
+func (s Subjects) of(scope taskstore.BudgetScope) string {
+	switch scope {
+	case taskstore.ScopeInstallation:
+		if s.Installation != 0 {
+			return strconv.FormatInt(s.Installation, 10)
+		}
+	case taskstore.ScopeRepo:
+		return s.Repo
+	case taskstore.ScopeUser:
+		return s.User
+	}
+	return ""
+}
+
+// Status is a budget that applies to a task with what its subject spent in
+// the current period.
+type Status struct {
+	taskstore.Budget
+	Subject     string    // the charged subject; Budget.Subject may be taskstore.AnySubject
+	Resets      time.Time // start of the next period
+	SpentUSD    float64
+	SpentTokens int
+}
+
+// Exceeded reports whether the spend reached either limit.
+func (s Status) Exceeded() bool {
+	return (s.MaxUSD > 0 && s.SpentUSD >= s.MaxUSD) || (s.MaxTokens > 0 && s.SpentTokens >= s.MaxTokens)
+}
+
+// Add returns the status after spending usd and tokens more.
+func (s Status) Add(usd float64, tokens int) Status {
+	s.SpentUSD += usd
+	s.SpentTokens += tokens
+	return s
+}
+
+// Describe explains the budget and its spend, e.g. "repository owner/repo
+// has used $51.20 of its $50.00 monthly budget".
+func (s Status) Describe() string {
+	name := map[taskstore.BudgetScope]string{
+		taskstore.ScopeInstallation: "installation",
+		taskstore.ScopeRepo:         "repository",
+		taskstore.ScopeUser:         "user",
+	}[s.Scope]
+	period := "daily"
+	if s.Period == taskstore.PeriodMonth {
+		period = "monthly"
+	}
+	if s.MaxUSD > 0 && (s.SpentUSD >= s.MaxUSD || s.MaxTokens == 0) {
+		return fmt.Sprintf("%s %s has used $%.2f of its $%.2f %s budget", name, s.Subject, s.SpentUSD, s.MaxUSD, period)
+	}
+	return fmt.Sprintf("%s %s has used %d of its %d token %s budget", name, s.Subject, s.SpentTokens, s.MaxTokens, period)
+}
+
+// Error describes an exceeded budget as a task failure.
+func (s Status) Error() string {
+	return "over budget: " + s.Describe()
+}
+
+// Enforcer looks up the budgets of a task and what their subjects spent.
+type Enforcer struct {
+	store *taskstore.Store
+	now   func() time.Time
+}
+
+// New creates an enforcer over the budgets and tasks in store.
+func New(store *taskstore.Store) *Enforcer {
+	return &Enforcer{store: store, now: time.Now}
+}
+
+// Statuses returns every budget that applies to the subjects. For each scope
+// and period a budget of the subject itself takes precedence over the
+// taskstore.AnySubject default.
+func (e *Enforcer) Statuses(subjects Subjects) ([]Status, error) {
+	budgets, err := e.store.ListBudgets()
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		scope  taskstore.BudgetScope
+		period taskstore.BudgetPeriod
+	}
+	applied := make(map[key]taskstore.Budget)
+	var order []key
+	for _, b := range budgets {
+		subject := subjects.of(b.Scope)
+		if subject == "" || (b.Subject != subject && b.Subject != taskstore.AnySubject) {
+			continue
+		}
+		k := key{b.Scope, b.Period}
+		current, seen := applied[k]
+		if !seen {
+			order = append(order, k)
+		}
+		if !seen || current.Subject == taskstore.AnySubject {
+			applied[k] = b
+		}
+	}
+
+	now := e.now().UTC()
+	statuses := make([]Status, 0, len(order))
+	for _, k := range order {
+		b := applied[k]
+		start, resets := periodBounds(b.Period, now)
+		subject := subjects.of(b.Scope)
+		usd, tokens, err := e.store.Spend(b.Scope, subject, start)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, Status{Budget: b, Subject: subject, Resets: resets, SpentUSD: usd, SpentTokens: tokens})
+	}
+	return statuses, nil
+}
+
+// Exceeded returns the first budget of the subjects that is used up, or nil.
+func (e *Enforcer) Exceeded(subjects Subjects) (*Status, error) {
+	statuses, err := e.Statuses(subjects)
+	if err != nil {
+		return nil, err
+	}
+	return FirstExceeded(statuses, 0, 0), nil
+}
+
+// FirstExceeded returns the first status that is used up after spending usd
+// and tokens more, or nil.
+func FirstExceeded(statuses []Status, usd float64, tokens int) *Status {
+	for _, s := range statuses {
+		if s = s.Add(usd, tokens); s.Exceeded() {
+			return &s
+		}
+	}
+	return nil
+}
+
+// periodBounds returns the start of the period containing now and of the next one.
+func periodBounds(period taskstore.BudgetPeriod, now time.Time) (time.Time, time.Time) {
+	if period == taskstore.PeriodMonth {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,109 @@
+package budget
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func newTestStore(t *testing.T) *taskstore.Store {
+	t.Helper()
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func addTask(t *testing.T, store *taskstore.Store, id, actor string, usd float64, tokens int) {
+	t.Helper()
+	task := &taskstore.Task{ID: id, Title: "t", Status: taskstore.StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 1, Actor: actor, InstallationID: 42}
+	if err := store.Create(task); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	store.AddUsage(id, taskstore.Usage{InputTokens: tokens, CostUSD: usd})
+}
+
+func TestStatuses_SubjectOverridesDefault(t *testing.T) {
+	store := newTestStore(t)
+	for _, b := range []taskstore.Budget{
+		{Scope: taskstore.ScopeUser, Subject: taskstore.AnySubject, Period: taskstore.PeriodDay, MaxUSD: 5},
+		{Scope: taskstore.ScopeUser, Subject: "alice", Period: taskstore.PeriodDay, MaxUSD: 20},
+		{Scope: taskstore.ScopeUser, Subject: "bob", Period: taskstore.PeriodMonth, MaxUSD: 1},
+		{Scope: taskstore.ScopeRepo, Subject: "owner/repo", Period: taskstore.PeriodMonth, MaxTokens: 1000},
+		{Scope: taskstore.ScopeInstallation, Subject: "42", Period: taskstore.PeriodMonth, MaxUSD: 100},
+	} {
+		if err := store.SetBudget(b); err != nil {
+			t.Fatalf("SetBudget() error = %v", err)
+		}
+	}
+	addTask(t, store, "t1", "alice", 6, 300)
+
+	e := New(store)
+	statuses, err := e.Statuses(Subjects{Installation: 42, Repo: "owner/repo", User: "alice"})
+	if err != nil {
+		t.Fatalf("Statuses() error = %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Statuses() = %+v, want installation, repo and alice's own user budget", statuses)
+	}
+	for _, s := range statuses {
+		if s.Scope == taskstore.ScopeUser && (s.Budget.Subject != "alice" || s.MaxUSD != 20 || s.SpentUSD != 6) {
+			t.Fatalf("user status = %+v, want alice's budget", s)
+		}
+	}
+	if exceeded, _ := e.Exceeded(Subjects{Installation: 42, Repo: "owner/repo", User: "alice"}); exceeded != nil {
+		t.Fatalf("Exceeded() = %+v, want nil", exceeded)
+	}
+
+	// carol falls back to the default user budget; the task store has no spend for her.
+	statuses, _ = e.Statuses(Subjects{User: "carol"})
+	if len(statuses) != 1 || statuses[0].Budget.Subject != taskstore.AnySubject || statuses[0].Subject != "carol" || statuses[0].SpentUSD != 0 {
+		t.Fatalf("Statuses(carol) = %+v", statuses)
+	}
+	if exceeded := FirstExceeded(statuses, 5, 0); exceeded == nil || exceeded.Error() != "over budget: user carol has used $5.00 of its $5.00 daily budget" {
+		t.Fatalf("FirstExceeded() = %+v", exceeded)
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.SetBudget(taskstore.Budget{Scope: taskstore.ScopeRepo, Subject: "owner/repo", Period: taskstore.PeriodMonth, MaxUSD: 10, MaxTokens: 1000}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	addTask(t, store, "t1", "alice", 1, 600)
+	addTask(t, store, "t2", "bob", 1, 600)
+
+	e := New(store)
+	e.now = func() time.Time { return time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC) }
+	exceeded, err := e.Exceeded(Subjects{Repo: "owner/repo", User: "carol"})
+	if err != nil || exceeded == nil {
+		t.Fatalf("Exceeded() = %+v, %v, want the repository budget", exceeded, err)
+	}
+	if got := exceeded.Describe(); got != "repository owner/repo has used 1200 of its 1000 token monthly budget" {
+		t.Fatalf("Describe() = %q", got)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC); !exceeded.Resets.Equal(want) {
+		t.Fatalf("Resets = %v, want %v", exceeded.Resets, want)
+	}
+
+	// Tasks of other repositories are not charged to this budget.
+	if exceeded, _ := e.Exceeded(Subjects{Repo: "owner/other", User: "alice"}); exceeded != nil {
+		t.Fatalf("Exceeded(other repo) = %+v, want nil", exceeded)
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	now := time.Date(2026, 12, 31, 15, 4, 5, 0, time.UTC)
+	start, next := periodBounds(taskstore.PeriodDay, now)
+	if !start.Equal(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("day bounds = %v, %v", start, next)
+	}
+	start, next = periodBounds(taskstore.PeriodMonth, now)
+	if !start.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("month bounds = %v, %v", start, next)
+	}
+}
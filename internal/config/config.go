@@ -2,11 +2,15 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/pricing"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/fallback"
 )
 
 // Config holds all configuration for the swe-agent service
@@ -19,23 +23,65 @@ type Config struct {
 	GitHubPrivateKey    string
 	GitHubWebhookSecret string
 
-	// AI Provider selection
-	Provider string // "claude" or "codex"
+	// AI Provider selection, one of provider.Names()
+	Provider string
+
+	// Settings of the selected provider, read from PROVIDER_<NAME>_* and the
+	// provider's older variables (ANTHROPIC_API_KEY, OPENAI_BASE_URL, ...)
+	ProviderSettings provider.Settings
 
-	// Claude settings
-	ClaudeAPIKey string
-	ClaudeModel  string
+	// Fallback chain: providers tried in order when the primary fails with
+	// one of ProviderFallbackOn (error classes, see the fallback package)
+	ProviderFallback        []string
+	ProviderFallbackOn      []string
+	ProviderBreakerFailures int           // consecutive failures that open a provider's circuit
+	ProviderBreakerCooldown time.Duration // how long an open circuit skips the provider
 
-	// Codex settings (uses OpenAI-compatible environment variables)
-	OpenAIAPIKey  string
-	OpenAIBaseURL string // Optional: custom API endpoint
-	CodexModel    string
+	// Token prices (USD per million tokens) for providers that report usage
+	// but no cost: the built-in table merged with PRICING_FILE when set
+	PricingFile string
+	Prices      pricing.Table
 
 	// Trigger settings
 	TriggerKeyword string
 
 	// Security settings
-	DisallowedTools string
+	DisallowedTools   string
+	SecretScanEnabled bool
+
+	// Commit signing: "" (off), "ssh", "gpg" or "api" (verified commits via the GitHub API)
+	CommitSigning    string
+	CommitSigningKey string // SSH private key path or GPG key ID
+	GitHubAPIURL     string // Optional: GitHub Enterprise API endpoint for api signing
+
+	// Workspace cache: bare mirror per repository plus per-task worktrees (disabled when empty)
+	WorkspaceCacheDir      string
+	WorkspaceCacheMaxMB    int // disk quota for mirrors, 0 = unlimited
+	WorkspaceCacheMaxRepos int // maximum cached repositories, 0 = unlimited
+
+	// Provider sandbox: "off", "env" (scrubbed env, temp HOME, limits) or "bwrap"
+	SandboxMode           string
+	SandboxAllowEnv       []string // extra variables passed to provider CLIs
+	SandboxCPUSeconds     int      // 0 = unlimited
+	SandboxMemoryMB       int      // 0 = unlimited
+	SandboxTimeoutSeconds int      // 0 = provider default
+	SandboxMaxOutputMB    int      // 0 = unlimited
+
+	// Size limits for generated changes, 0 = unlimited
+	ChangeMaxFileMB  int
+	ChangeMaxTotalMB int
+
+	// Task artifacts (diffs, prompts, provider output, verification logs)
+	ArtifactRetentionDays int // 0 = keep forever
+	ArtifactMaxTotalMB    int // 0 = unlimited
+	ArtifactMaxMB         int // per artifact; larger ones are truncated, 0 = unlimited
+
+	// Follow-up tasks on an issue or PR resume the provider session of the
+	// previous task when it ran within this many hours, 0 = never
+	SessionTTLHours int
+
+	// Naming templates (Go text/template) for branches, commits and PRs
+	Naming naming.Templates
 
 	// Dispatcher settings
 	DispatcherWorkers           int
@@ -51,18 +97,43 @@ func Load() (*Config, error) {
 	privateKey := normalizePrivateKey(os.Getenv("GITHUB_PRIVATE_KEY"))
 
 	cfg := &Config{
-		Port:                        getEnvInt("PORT", 8000),
-		GitHubAppID:                 os.Getenv("GITHUB_APP_ID"),
-		GitHubPrivateKey:            privateKey,
-		GitHubWebhookSecret:         os.Getenv("GITHUB_WEBHOOK_SECRET"),
-		Provider:                    getEnv("PROVIDER", "claude"),
-		ClaudeAPIKey:                os.Getenv("ANTHROPIC_API_KEY"),
-		ClaudeModel:                 getEnv("CLAUDE_MODEL", "claude-3-5-sonnet-20241022"),
-		OpenAIAPIKey:                os.Getenv("OPENAI_API_KEY"),
-		OpenAIBaseURL:               os.Getenv("OPENAI_BASE_URL"),
-		CodexModel:                  getEnv("CODEX_MODEL", "gpt-5-codex"),
-		TriggerKeyword:              getEnv("TRIGGER_KEYWORD", "/code"),
-		DisallowedTools:             getEnv("DISALLOWED_TOOLS", ""),
+		Port:                    getEnvInt("PORT", 8000),
+		GitHubAppID:             os.Getenv("GITHUB_APP_ID"),
+		GitHubPrivateKey:        privateKey,
+		GitHubWebhookSecret:     os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		Provider:                getEnv("PROVIDER", "claude"),
+		ProviderFallback:        splitList(os.Getenv("PROVIDER_FALLBACK")),
+		ProviderFallbackOn:      splitList(os.Getenv("PROVIDER_FALLBACK_ON")),
+		ProviderBreakerFailures: getEnvInt("PROVIDER_BREAKER_FAILURES", 3),
+		ProviderBreakerCooldown: time.Duration(getEnvInt("PROVIDER_BREAKER_COOLDOWN_SECONDS", 300)) * time.Second,
+		PricingFile:             os.Getenv("PRICING_FILE"),
+		TriggerKeyword:          getEnv("TRIGGER_KEYWORD", "/code"),
+		DisallowedTools:         getEnv("DISALLOWED_TOOLS", ""),
+		SecretScanEnabled:       getEnvBool("SECRET_SCAN_ENABLED", true),
+		CommitSigning:           strings.ToLower(strings.TrimSpace(os.Getenv("COMMIT_SIGNING"))),
+		CommitSigningKey:        os.Getenv("COMMIT_SIGNING_KEY"),
+		GitHubAPIURL:            os.Getenv("GITHUB_API_URL"),
+		WorkspaceCacheDir:       os.Getenv("WORKSPACE_CACHE_DIR"),
+		WorkspaceCacheMaxMB:     getEnvInt("WORKSPACE_CACHE_MAX_MB", 0),
+		WorkspaceCacheMaxRepos:  getEnvInt("WORKSPACE_CACHE_MAX_REPOS", 0),
+		SandboxMode:             strings.ToLower(strings.TrimSpace(getEnv("SANDBOX_MODE", "off"))),
+		SandboxAllowEnv:         splitList(os.Getenv("SANDBOX_ENV_ALLOW")),
+		SandboxCPUSeconds:       getEnvInt("SANDBOX_CPU_SECONDS", 0),
+		SandboxMemoryMB:         getEnvInt("SANDBOX_MEMORY_MB", 0),
+		SandboxTimeoutSeconds:   getEnvInt("SANDBOX_TIMEOUT_SECONDS", 0),
+		SandboxMaxOutputMB:      getEnvInt("SANDBOX_MAX_OUTPUT_MB", 0),
+		ChangeMaxFileMB:         getEnvInt("CHANGE_MAX_FILE_MB", 10),
+		ChangeMaxTotalMB:        getEnvInt("CHANGE_MAX_TOTAL_MB", 100),
+		ArtifactRetentionDays:   getEnvInt("ARTIFACT_RETENTION_DAYS", 30),
+		ArtifactMaxTotalMB:      getEnvInt("ARTIFACT_MAX_TOTAL_MB", 1024),
+		ArtifactMaxMB:           getEnvInt("ARTIFACT_MAX_MB", 10),
+		SessionTTLHours:         getEnvInt("SESSION_TTL_HOURS", 24),
+		Naming: naming.Templates{
+			Branch:  os.Getenv("BRANCH_TEMPLATE"),
+			Commit:  os.Getenv("COMMIT_TEMPLATE"),
+			PRTitle: os.Getenv("PR_TITLE_TEMPLATE"),
+			PRBody:  os.Getenv("PR_BODY_TEMPLATE"),
+		},
 		DispatcherWorkers:           getEnvInt("DISPATCHER_WORKERS", 4),
 		DispatcherQueueSize:         getEnvInt("DISPATCHER_QUEUE_SIZE", 16),
 		DispatcherMaxAttempts:       getEnvInt("DISPATCHER_MAX_ATTEMPTS", 3),
@@ -114,10 +185,68 @@ func (c *Config) validate() error {
 		return err
 	}
 
+	if err := c.loadPrices(); err != nil {
+		return err
+	}
+
+	if c.WorkspaceCacheMaxMB < 0 || c.WorkspaceCacheMaxRepos < 0 {
+		return fmt.Errorf("WORKSPACE_CACHE_MAX_MB and WORKSPACE_CACHE_MAX_REPOS must not be negative")
+	}
+
+	if c.ChangeMaxFileMB < 0 || c.ChangeMaxTotalMB < 0 {
+		return fmt.Errorf("CHANGE_MAX_FILE_MB and CHANGE_MAX_TOTAL_MB must not be negative")
+	}
+
+	if c.ArtifactRetentionDays < 0 || c.ArtifactMaxTotalMB < 0 || c.ArtifactMaxMB < 0 {
+		return fmt.Errorf("ARTIFACT_* limits must not be negative")
+	}
+
+	if c.SessionTTLHours < 0 {
+		return fmt.Errorf("SESSION_TTL_HOURS must not be negative")
+	}
+
+	if err := c.validateCommitSigning(); err != nil {
+		return err
+	}
+
+	if err := c.validateSandbox(); err != nil {
+		return err
+	}
+
+	if err := c.Naming.Validate(); err != nil {
+		return fmt.Errorf("invalid naming template: %w", err)
+	}
+
 	c.applyDispatcherDefaults()
 	return c.validateDispatcherConfig()
 }
 
+func (c *Config) validateCommitSigning() error {
+	switch c.CommitSigning {
+	case "", "gpg", "api":
+		return nil
+	case "ssh":
+		if strings.TrimSpace(c.CommitSigningKey) == "" {
+			return fmt.Errorf("COMMIT_SIGNING_KEY is required when COMMIT_SIGNING=ssh")
+		}
+		return nil
+	default:
+		return fmt.Errorf("COMMIT_SIGNING must be one of ssh, gpg or api (got %q)", c.CommitSigning)
+	}
+}
+
+func (c *Config) validateSandbox() error {
+	switch c.SandboxMode {
+	case "", "off", "env", "bwrap":
+	default:
+		return fmt.Errorf("SANDBOX_MODE must be one of off, env or bwrap (got %q)", c.SandboxMode)
+	}
+	if c.SandboxCPUSeconds < 0 || c.SandboxMemoryMB < 0 || c.SandboxTimeoutSeconds < 0 || c.SandboxMaxOutputMB < 0 {
+		return fmt.Errorf("SANDBOX_* limits must not be negative")
+	}
+	return nil
+}
+
 func (c *Config) validateGitHubCredentials() error {
 	if c.GitHubAppID == "" {
 		return fmt.Errorf("GITHUB_APP_ID is required")
@@ -132,21 +261,56 @@ func (c *Config) validateGitHubCredentials() error {
 }
 
 func (c *Config) validateProviderConfig() error {
-	switch c.Provider {
-	case "claude":
-		if c.ClaudeAPIKey == "" {
-			return fmt.Errorf("ANTHROPIC_API_KEY is required for claude provider")
+	if c.ProviderSettings != nil {
+		if err := provider.ValidateSettings(c.Provider, c.ProviderSettings); err != nil {
+			return err
 		}
-	case "codex":
-		if c.OpenAIAPIKey == "" {
-			log.Printf("Warning: OPENAI_API_KEY not set, using default OpenAI credentials")
+	} else {
+		settings, err := provider.LoadSettings(c.Provider, os.Getenv)
+		if err != nil {
+			return err
 		}
-	default:
-		return fmt.Errorf("invalid provider: %s (must be 'claude' or 'codex')", c.Provider)
+		c.ProviderSettings = settings
+	}
+	return c.validateFallback()
+}
+
+func (c *Config) validateFallback() error {
+	seen := map[string]bool{c.Provider: true}
+	for _, name := range c.ProviderFallback {
+		if seen[name] {
+			return fmt.Errorf("PROVIDER_FALLBACK: %s is listed twice or is the primary provider", name)
+		}
+		seen[name] = true
+		if _, err := provider.LoadSettings(name, os.Getenv); err != nil {
+			return fmt.Errorf("PROVIDER_FALLBACK: %w", err)
+		}
+	}
+	if _, err := fallback.ParseClasses(c.ProviderFallbackOn); err != nil {
+		return fmt.Errorf("PROVIDER_FALLBACK_ON: %w", err)
+	}
+	if c.ProviderBreakerFailures < 0 || c.ProviderBreakerCooldown < 0 {
+		return fmt.Errorf("PROVIDER_BREAKER_* settings must not be negative")
 	}
 	return nil
 }
 
+func (c *Config) loadPrices() error {
+	if c.Prices != nil {
+		return nil
+	}
+	if c.PricingFile == "" {
+		c.Prices = pricing.Default()
+		return nil
+	}
+	prices, err := pricing.Load(c.PricingFile)
+	if err != nil {
+		return fmt.Errorf("PRICING_FILE: %w", err)
+	}
+	c.Prices = prices
+	return nil
+}
+
 func (c *Config) applyDispatcherDefaults() {
 	if c.DispatcherWorkers <= 0 {
 		c.DispatcherWorkers = 4
@@ -198,6 +362,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList parses a comma-separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // getEnvInt gets environment variable as int with a default value
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -208,6 +383,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvFloat(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
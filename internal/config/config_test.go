@@ -2,9 +2,14 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/provider"
+	_ "github.com/cexll/swe/internal/provider/all"
 )
 
 func TestLoad(t *testing.T) {
@@ -27,6 +32,8 @@ func TestLoad(t *testing.T) {
 				"OPENAI_BASE_URL":       "https://api.example.com/v1",
 				"CODEX_MODEL":           "gpt-5-codex-plus",
 				"TRIGGER_KEYWORD":       "/test",
+				"ANTHROPIC_BASE_URL":    "http://proxy.local",
+				"TOOL_ALLOWED_COMMANDS": "go, make",
 			},
 			wantErr: false,
 			check: func(t *testing.T, cfg *Config) {
@@ -36,17 +43,11 @@ func TestLoad(t *testing.T) {
 				if cfg.GitHubAppID != "123456" {
 					t.Errorf("GitHubAppID = %s, want 123456", cfg.GitHubAppID)
 				}
-				if cfg.ClaudeModel != "claude-3-opus-20240229" {
-					t.Errorf("ClaudeModel = %s, want claude-3-opus-20240229", cfg.ClaudeModel)
-				}
-				if cfg.OpenAIAPIKey != "sk-openai-test" {
-					t.Errorf("OpenAIAPIKey = %s, want sk-openai-test", cfg.OpenAIAPIKey)
-				}
-				if cfg.OpenAIBaseURL != "https://api.example.com/v1" {
-					t.Errorf("OpenAIBaseURL = %s, want https://api.example.com/v1", cfg.OpenAIBaseURL)
+				if got := cfg.ProviderSettings.Get("MODEL"); got != "claude-3-opus-20240229" {
+					t.Errorf("MODEL = %s, want claude-3-opus-20240229", got)
 				}
-				if cfg.CodexModel != "gpt-5-codex-plus" {
-					t.Errorf("CodexModel = %s, want gpt-5-codex-plus", cfg.CodexModel)
+				if got := cfg.ProviderSettings.Get("API_KEY"); got != "sk-ant-test" {
+					t.Errorf("API_KEY = %s, want sk-ant-test", got)
 				}
 				if cfg.TriggerKeyword != "/test" {
 					t.Errorf("TriggerKeyword = %s, want /test", cfg.TriggerKeyword)
@@ -84,17 +85,8 @@ func TestLoad(t *testing.T) {
 				if cfg.Port != 8000 {
 					t.Errorf("Port = %d, want 8000 (default)", cfg.Port)
 				}
-				if cfg.ClaudeModel != "claude-3-5-sonnet-20241022" {
-					t.Errorf("ClaudeModel = %s, want default", cfg.ClaudeModel)
-				}
-				if cfg.CodexModel != "gpt-5-codex" {
-					t.Errorf("CodexModel = %s, want gpt-5-codex (default)", cfg.CodexModel)
-				}
-				if cfg.OpenAIAPIKey != "" {
-					t.Errorf("OpenAIAPIKey = %s, want empty default", cfg.OpenAIAPIKey)
-				}
-				if cfg.OpenAIBaseURL != "" {
-					t.Errorf("OpenAIBaseURL = %s, want empty default", cfg.OpenAIBaseURL)
+				if got := cfg.ProviderSettings.Get("MODEL"); got != "claude-3-5-sonnet-20241022" {
+					t.Errorf("MODEL = %s, want default", got)
 				}
 				if cfg.TriggerKeyword != "/code" {
 					t.Errorf("TriggerKeyword = %s, want /code (default)", cfg.TriggerKeyword)
@@ -221,7 +213,7 @@ func TestConfigValidateDefaultsApplied(t *testing.T) {
 		GitHubPrivateKey:            "key",
 		GitHubWebhookSecret:         "secret",
 		Provider:                    "claude",
-		ClaudeAPIKey:                "api",
+		ProviderSettings:            provider.Settings{"API_KEY": "api"},
 		DispatcherWorkers:           0,
 		DispatcherQueueSize:         0,
 		DispatcherMaxAttempts:       0,
@@ -257,7 +249,7 @@ func TestConfigValidateRetryWindow(t *testing.T) {
 		GitHubPrivateKey:            "key",
 		GitHubWebhookSecret:         "secret",
 		Provider:                    "claude",
-		ClaudeAPIKey:                "api",
+		ProviderSettings:            provider.Settings{"API_KEY": "api"},
 		DispatcherWorkers:           2,
 		DispatcherQueueSize:         4,
 		DispatcherMaxAttempts:       2,
@@ -272,6 +264,183 @@ func TestConfigValidateRetryWindow(t *testing.T) {
 	}
 }
 
+func TestConfigValidateNamingTemplates(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		Naming:              naming.Templates{Branch: "feature/{{.Number"},
+	}
+
+	err := cfg.validate()
+	if err == nil || !strings.Contains(err.Error(), "branch template") {
+		t.Fatalf("expected naming template error, got %v", err)
+	}
+
+	cfg.Naming.Branch = "feature/{{.Number}}-{{.TitleSlug}}"
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidateCommitSigning(t *testing.T) {
+	base := Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+	}
+
+	tests := []struct {
+		mode, key, wantErr string
+	}{
+		{"", "", ""},
+		{"api", "", ""},
+		{"gpg", "", ""},
+		{"ssh", "/keys/id_ed25519", ""},
+		{"ssh", "", "COMMIT_SIGNING_KEY"},
+		{"x509", "", "COMMIT_SIGNING must be one of"},
+	}
+	for _, tt := range tests {
+		cfg := base
+		cfg.CommitSigning, cfg.CommitSigningKey = tt.mode, tt.key
+		err := cfg.validate()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validate(%q) error = %v", tt.mode, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validate(%q) error = %v, want %q", tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigValidateWorkspaceCache(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:            "app",
+		GitHubPrivateKey:       "key",
+		GitHubWebhookSecret:    "secret",
+		Provider:               "claude",
+		ProviderSettings:       provider.Settings{"API_KEY": "api"},
+		WorkspaceCacheDir:      "/var/cache/swe",
+		WorkspaceCacheMaxRepos: -1,
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "WORKSPACE_CACHE_MAX_REPOS") {
+		t.Fatalf("expected workspace cache error, got %v", err)
+	}
+
+	cfg.WorkspaceCacheMaxRepos = 20
+	cfg.WorkspaceCacheMaxMB = 10240
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidateProviderFallback(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "")
+	t.Setenv("PROVIDER_OPENAI_MODEL", "")
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		ProviderFallback:    []string{"codex", "claude"},
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "claude is listed twice or is the primary provider") {
+		t.Fatalf("expected duplicate provider error, got %v", err)
+	}
+
+	cfg.ProviderFallback = []string{"codex", "openai"}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "PROVIDER_FALLBACK: openai: PROVIDER_OPENAI_MODEL or OPENAI_MODEL is required") {
+		t.Fatalf("expected fallback settings error, got %v", err)
+	}
+
+	cfg.ProviderFallback = []string{"codex"}
+	cfg.ProviderFallbackOn = []string{"rate_limit", "quota"}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "PROVIDER_FALLBACK_ON") {
+		t.Fatalf("expected error class error, got %v", err)
+	}
+
+	cfg.ProviderFallbackOn = []string{"rate_limit", "auth"}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidateSandbox(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		SandboxMode:         "docker",
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "SANDBOX_MODE") {
+		t.Fatalf("expected sandbox mode error, got %v", err)
+	}
+
+	cfg.SandboxMode = "env"
+	cfg.SandboxMemoryMB = -1
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Fatalf("expected sandbox limit error, got %v", err)
+	}
+
+	cfg.SandboxMemoryMB = 2048
+	cfg.SandboxTimeoutSeconds = 900
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidatePricingFile(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if _, ok := cfg.Prices.Lookup("claude-sonnet-4-5"); !ok {
+		t.Fatal("expected default prices")
+	}
+
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	if err := os.WriteFile(path, []byte("local-model: {input: 0.5, output: 1}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Prices = nil
+	cfg.PricingFile = path
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if _, ok := cfg.Prices.Lookup("local-model"); !ok {
+		t.Fatal("expected prices from PRICING_FILE")
+	}
+
+	cfg.Prices = nil
+	cfg.PricingFile = filepath.Join(t.TempDir(), "missing.yaml")
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "PRICING_FILE") {
+		t.Fatalf("expected pricing file error, got %v", err)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" AWS_*, ,GOPROXY,")
+	if strings.Join(got, "|") != "AWS_*|GOPROXY" {
+		t.Fatalf("splitList() = %q", got)
+	}
+	if splitList("") != nil {
+		t.Fatal("splitList(\"\") should be nil")
+	}
+}
+
 func TestGetEnvFloat(t *testing.T) {
 	t.Setenv("TEST_FLOAT", "3.14")
 	if got := getEnvFloat("TEST_FLOAT", 1.0); got != 3.14 {
@@ -307,7 +476,7 @@ func TestConfig_validate(t *testing.T) {
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
 				Provider:            "claude",
-				ClaudeAPIKey:        "sk-ant-test",
+				ProviderSettings:    provider.Settings{"API_KEY": "sk-ant-test"},
 			},
 			wantErr: false,
 		},
@@ -316,7 +485,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
-				ClaudeAPIKey:        "sk-ant-test",
+				ProviderSettings:    provider.Settings{"API_KEY": "sk-ant-test"},
 			},
 			wantErr: true,
 			errMsg:  "GITHUB_APP_ID is required",
@@ -326,7 +495,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubAppID:         "123456",
 				GitHubWebhookSecret: "test-secret",
-				ClaudeAPIKey:        "sk-ant-test",
+				ProviderSettings:    provider.Settings{"API_KEY": "sk-ant-test"},
 			},
 			wantErr: true,
 			errMsg:  "GITHUB_PRIVATE_KEY is required",
@@ -336,7 +505,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubAppID:      "123456",
 				GitHubPrivateKey: "test-key",
-				ClaudeAPIKey:     "sk-ant-test",
+				ProviderSettings: provider.Settings{"API_KEY": "sk-ant-test"},
 			},
 			wantErr: true,
 			errMsg:  "GITHUB_WEBHOOK_SECRET is required",
@@ -348,9 +517,10 @@ func TestConfig_validate(t *testing.T) {
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
 				Provider:            "claude",
+				ProviderSettings:    provider.Settings{},
 			},
 			wantErr: true,
-			errMsg:  "ANTHROPIC_API_KEY is required for claude provider",
+			errMsg:  "claude: PROVIDER_CLAUDE_API_KEY or ANTHROPIC_API_KEY is required",
 		},
 		{
 			name: "valid codex config with OpenAI key",
@@ -359,7 +529,7 @@ func TestConfig_validate(t *testing.T) {
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
 				Provider:            "codex",
-				OpenAIAPIKey:        "sk-openai-test",
+				ProviderSettings:    provider.Settings{"API_KEY": "sk-openai-test"},
 			},
 			wantErr: false,
 		},
@@ -370,7 +540,30 @@ func TestConfig_validate(t *testing.T) {
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
 				Provider:            "codex",
-				OpenAIAPIKey:        "", // Empty, should log warning but not fail
+				ProviderSettings:    provider.Settings{}, // Empty, should log warning but not fail
+			},
+			wantErr: false,
+		},
+		{
+			name: "openai provider without model",
+			cfg: &Config{
+				GitHubAppID:         "123456",
+				GitHubPrivateKey:    "test-key",
+				GitHubWebhookSecret: "test-secret",
+				Provider:            "openai",
+				ProviderSettings:    provider.Settings{"BASE_URL": "http://localhost:11434/v1"},
+			},
+			wantErr: true,
+			errMsg:  "openai: PROVIDER_OPENAI_MODEL or OPENAI_MODEL is required",
+		},
+		{
+			name: "openai provider with local endpoint",
+			cfg: &Config{
+				GitHubAppID:         "123456",
+				GitHubPrivateKey:    "test-key",
+				GitHubWebhookSecret: "test-secret",
+				Provider:            "openai",
+				ProviderSettings:    provider.Settings{"BASE_URL": "http://localhost:11434/v1", "MODEL": "qwen2.5-coder"},
 			},
 			wantErr: false,
 		},
@@ -383,7 +576,7 @@ func TestConfig_validate(t *testing.T) {
 				Provider:            "invalid-provider",
 			},
 			wantErr: true,
-			errMsg:  "invalid provider: invalid-provider (must be 'claude' or 'codex')",
+			errMsg:  "unknown provider: invalid-provider (supported: anthropic, claude, codex, command, openai)",
 		},
 		{
 			name: "empty provider (should default but validate will catch)",
@@ -394,7 +587,7 @@ func TestConfig_validate(t *testing.T) {
 				Provider:            "",
 			},
 			wantErr: true,
-			errMsg:  "invalid provider:  (must be 'claude' or 'codex')",
+			errMsg:  "unknown provider:  (supported: anthropic, claude, codex, command, openai)",
 		},
 	}
 
@@ -513,3 +706,88 @@ func TestGetEnvInt(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvBool(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue bool
+		want         bool
+	}{
+		{name: "unset uses default", envValue: "", defaultValue: true, want: true},
+		{name: "false", envValue: "false", defaultValue: true, want: false},
+		{name: "numeric true", envValue: "1", defaultValue: false, want: true},
+		{name: "invalid uses default", envValue: "maybe", defaultValue: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.envValue != "" {
+				os.Setenv("SECRET_SCAN_ENABLED", tt.envValue)
+			}
+
+			if got := getEnvBool("SECRET_SCAN_ENABLED", tt.defaultValue); got != tt.want {
+				t.Errorf("getEnvBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidateArtifactLimits(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		ArtifactMaxMB:       -1,
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "ARTIFACT_") {
+		t.Fatalf("expected artifact limit error, got %v", err)
+	}
+
+	cfg.ArtifactMaxMB = 10
+	cfg.ArtifactRetentionDays = 0
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidateSessionTTL(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		SessionTTLHours:     -1,
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "SESSION_TTL_HOURS") {
+		t.Fatalf("expected session TTL error, got %v", err)
+	}
+
+	cfg.SessionTTLHours = 0
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestConfigValidateChangeLimits(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ProviderSettings:    provider.Settings{"API_KEY": "api"},
+		ChangeMaxTotalMB:    -1,
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "CHANGE_MAX_TOTAL_MB") {
+		t.Fatalf("expected change limit error, got %v", err)
+	}
+
+	cfg.ChangeMaxTotalMB = 0
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
@@ -17,6 +17,12 @@ type TaskExecutor interface {
 	Execute(ctx context.Context, task *webhook.Task) error
 }
 
+// Finalizer is implemented by executors that keep state between attempts of a
+// task; Finalize is called once no further attempt will run.
+type Finalizer interface {
+	Finalize(task *webhook.Task)
+}
+
 // Config controls dispatcher behaviour
 type Config struct {
 	Workers           int
@@ -142,6 +148,7 @@ func (d *Dispatcher) process(item *queueItem) {
 		log.Printf("Task %s attempt %d failed: %v", key, item.attempt, err)
 		if executor.IsNonRetryable(err) {
 			log.Printf("Task %s attempt %d marked non-retryable; no further attempts", key, item.attempt)
+			d.finalize(task)
 			return
 		}
 		d.handleRetry(item, err)
@@ -149,11 +156,19 @@ func (d *Dispatcher) process(item *queueItem) {
 	}
 
 	log.Printf("Task %s attempt %d succeeded", key, item.attempt)
+	d.finalize(task)
+}
+
+func (d *Dispatcher) finalize(task *webhook.Task) {
+	if f, ok := d.executor.(Finalizer); ok {
+		f.Finalize(task)
+	}
 }
 
 func (d *Dispatcher) handleRetry(item *queueItem, execErr error) {
 	if item.attempt >= d.cfg.MaxAttempts {
 		log.Printf("Task %s#%d exceeded max attempts (%d): %v", item.task.Repo, item.task.Number, d.cfg.MaxAttempts, execErr)
+		d.finalize(item.task)
 		return
 	}
 
@@ -84,7 +84,7 @@ func TestDispatcherExecutorProviderIntegration(t *testing.T) {
 	})
 
 	restoreCmd := executor.StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
-		return exec.Command("bash", "-lc", "true")
+		return exec.Command("bash", "-c", "true")
 	})
 	defer restoreCmd()
 
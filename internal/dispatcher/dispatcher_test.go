@@ -246,3 +246,55 @@ func TestDispatcherEnqueueRetryStopsWhenClosed(t *testing.T) {
 	close(d.stopCh)
 	d.enqueueRetry(&queueItem{task: &webhook.Task{}, attempt: 2})
 }
+
+type finalizingExecutor struct {
+	mockExecutor
+	finalized chan int
+}
+
+func (f *finalizingExecutor) Finalize(task *webhook.Task) {
+	f.finalized <- task.Attempt
+}
+
+func TestDispatcherFinalizesAfterLastAttempt(t *testing.T) {
+	exec := &finalizingExecutor{
+		mockExecutor: mockExecutor{
+			fn: func(ctx context.Context, task *webhook.Task) error {
+				if task.Attempt == 1 {
+					return errors.New("first attempt fails")
+				}
+				return nil
+			},
+		},
+		finalized: make(chan int, 2),
+	}
+
+	d := New(exec, Config{
+		Workers:           1,
+		QueueSize:         2,
+		MaxAttempts:       3,
+		InitialBackoff:    10 * time.Millisecond,
+		BackoffMultiplier: 2,
+		MaxBackoff:        20 * time.Millisecond,
+	})
+	defer d.Shutdown(context.Background())
+
+	if err := d.Enqueue(&webhook.Task{Repo: "owner/repo", Number: 8}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+
+	select {
+	case attempt := <-exec.finalized:
+		if attempt != 2 {
+			t.Fatalf("Finalize called after attempt %d, want 2", attempt)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("Timed out waiting for Finalize")
+	}
+
+	select {
+	case attempt := <-exec.finalized:
+		t.Fatalf("Finalize called again after attempt %d", attempt)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
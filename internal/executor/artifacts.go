@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"log"
+
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// saveArtifact stores text as a task artifact with secrets redacted. Failures are
+// only logged; artifacts never fail a task.
+func (e *Executor) saveArtifact(task *webhook.Task, kind taskstore.ArtifactKind, text, token string) {
+	if e.store == nil || task == nil || task.ID == "" || text == "" {
+		return
+	}
+	if err := e.store.SaveArtifact(task.ID, kind, []byte(e.redactSecrets(text, token))); err != nil {
+		log.Printf("Warning: failed to save %s artifact for task %s: %v", kind, task.ID, err)
+	}
+}
+
+// recordProviderArtifacts keeps the prompts sent to the provider and its raw answer.
+func (e *Executor) recordProviderArtifacts(task *webhook.Task, result *claude.CodeResponse, token string) {
+	if result == nil {
+		return
+	}
+	e.saveArtifact(task, taskstore.ArtifactSystemPrompt, result.SystemPrompt, token)
+	e.saveArtifact(task, taskstore.ArtifactUserPrompt, result.UserPrompt, token)
+	e.saveArtifact(task, taskstore.ArtifactResponse, result.RawResponse, token)
+}
+
+// recordDiff keeps the unified diff of all changes about to be committed.
+func (e *Executor) recordDiff(task *webhook.Task, workdir, token string) {
+	if e.store == nil || task == nil || task.ID == "" {
+		return
+	}
+	diff, err := stagedDiff(workdir, false)
+	if err != nil {
+		log.Printf("Warning: failed to capture diff for task %s: %v", task.ID, err)
+		return
+	}
+	e.saveArtifact(task, taskstore.ArtifactDiff, diff, token)
+}
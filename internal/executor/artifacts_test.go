@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestExecute_RecordsArtifacts(t *testing.T) {
+	f := newResumeFixture(t)
+	os.Remove(f.marker)
+
+	seed := t.TempDir()
+	runGitIn(t, seed, "clone", "-q", "-b", "main", f.remote, ".")
+	writeTestFile(t, seed, ".swe-agent.yml", "verify:\n  - echo checking\n  - test -f lib/service.go\n")
+	runGitIn(t, seed, "add", ".")
+	runGitIn(t, seed, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "config")
+	runGitIn(t, seed, "push", "-q", "origin", "HEAD:main")
+
+	task := f.task(t, 1)
+	if err := f.executor(t).Execute(context.Background(), task); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := map[taskstore.ArtifactKind]string{
+		taskstore.ArtifactDiff:         "+++ b/lib/service.go",
+		taskstore.ArtifactResponse:     "<summary>Add service</summary>",
+		taskstore.ArtifactSystemPrompt: "system prompt",
+		taskstore.ArtifactUserPrompt:   "Add service",
+		taskstore.ArtifactVerification: "$ echo checking\nchecking\n$ test -f lib/service.go\n",
+	}
+	for kind, fragment := range want {
+		a, ok := f.store.GetArtifact(task.ID, kind)
+		if !ok {
+			t.Errorf("artifact %s missing", kind)
+			continue
+		}
+		if !strings.Contains(string(a.Data), fragment) {
+			t.Errorf("artifact %s = %q, want %q", kind, a.Data, fragment)
+		}
+	}
+}
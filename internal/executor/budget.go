@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/pricing"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// WithBudgets checks the task's budgets before the provider starts and caps
+// agent loops at the remaining budget while it runs.
+func (e *Executor) WithBudgets(budgets *budget.Enforcer) *Executor {
+	e.budgets = budgets
+	return e
+}
+
+// budgetGuard is the hard cap of a running task: the budgets that applied
+// when it started, charged with the task's usage so far. It also keeps the
+// usage of runs that fail, so they are recorded even without budgets.
+type budgetGuard struct {
+	statuses []budget.Status
+	prices   pricing.Table
+	provider string                  // provider the guard was created for
+	runs     map[string]claude.Usage // latest usage reported by each provider
+}
+
+// newBudgetGuard returns the guard of a task run by provider, or an error
+// when one of its budgets is already used up. The guard has no budgets when
+// they are disabled or cannot be read.
+func (e *Executor) newBudgetGuard(task *webhook.Task, provider string) (*budgetGuard, error) {
+	guard := &budgetGuard{prices: e.prices, provider: provider, runs: make(map[string]claude.Usage)}
+	if e.budgets == nil {
+		return guard, nil
+	}
+	statuses, err := e.budgets.Statuses(budget.Subjects{Installation: task.InstallationID, Repo: task.Repo, User: task.Username})
+	if err != nil {
+		log.Printf("Warning: Failed to check budgets for %s: %v (running without a cap)", task.Repo, err)
+		return guard, nil
+	}
+	if exceeded := budget.FirstExceeded(statuses, 0, 0); exceeded != nil {
+		return nil, exceeded
+	}
+	guard.statuses = statuses
+	return guard, nil
+}
+
+// check is the CodeRequest.Budget callback. Providers report their usage so
+// far, so with a fallback chain the runs of earlier providers are added to
+// that of the current one.
+func (g *budgetGuard) check(usage claude.Usage) error {
+	name := usage.Provider
+	if name == "" {
+		name = g.provider
+	}
+	g.runs[name] = usage
+	if len(g.statuses) == 0 {
+		return nil
+	}
+
+	var cost float64
+	var tokens, turns int
+	for _, run := range g.runs {
+		c, _ := g.prices.Cost(run)
+		cost += c
+		tokens += run.Tokens()
+		turns += run.Turns
+	}
+	if exceeded := budget.FirstExceeded(g.statuses, cost, tokens); exceeded != nil {
+		return fmt.Errorf("%w, stopped after %d turns", exceeded, turns)
+	}
+	return nil
+}
+
+// spent returns the usage reported by every provider other than except.
+// Failed runs count against the budgets like finished ones, so the caller
+// records them along with the result, if any.
+func (g *budgetGuard) spent(except string) []claude.Usage {
+	names := make([]string, 0, len(g.runs))
+	for name, usage := range g.runs {
+		if name != except && usage.Tokens() > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	runs := make([]claude.Usage, 0, len(names))
+	for _, name := range names {
+		runs = append(runs, g.runs[name])
+	}
+	return runs
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestGenerateCodeChanges_Budgets(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.SetBudget(taskstore.Budget{Scope: taskstore.ScopeRepo, Subject: "owner/repo", Period: taskstore.PeriodDay, MaxUSD: 1}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := store.Create(&taskstore.Task{ID: id, Title: "t", Status: taskstore.StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "alice"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	// The provider loops until the budget callback stops it; each turn costs $0.30.
+	turns := 0
+	p := &mockProvider{generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		for turns = 1; ; turns++ {
+			usage := claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 100_000 * turns, Turns: turns}
+			if err := req.CheckBudget(usage); err != nil {
+				return nil, err
+			}
+		}
+	}}
+	mockGH := github.NewMockGHClient()
+	e := NewWithClient(p, nil, mockGH).WithStore(store).WithBudgets(budget.New(store))
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7, Username: "alice"}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 7, "alice", mockGH)
+
+	_, err = e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, "")
+	if err == nil || !strings.Contains(err.Error(), "over budget: repository owner/repo has used $1.20 of its $1.00 daily budget, stopped after 4 turns") {
+		t.Fatalf("error = %v, want the loop stopped at the cap", err)
+	}
+	if !IsNonRetryable(err) {
+		t.Fatalf("budget errors must not be retried: %v", err)
+	}
+	stored, _ := store.Get("task-1")
+	if stored.Usage.Turns != 4 || stored.Usage.CostUSD < 1.19 {
+		t.Fatalf("stored usage = %+v, want the spend of the stopped run", stored.Usage)
+	}
+
+	// The next task of the repository is rejected before the provider runs.
+	turns = 0
+	task = &webhook.Task{ID: "task-2", Repo: "owner/repo", Number: 7, Username: "alice"}
+	_, err = e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, "")
+	if err == nil || !strings.HasPrefix(err.Error(), "over budget: repository owner/repo") || turns != 0 {
+		t.Fatalf("error = %v after %d turns, want rejection before generation", err, turns)
+	}
+}
+
+// fallbackRun mimics a fallback chain: the primary spends primaryTurns turns
+// and fails, then the backup runs until done or stopped by the budget.
+func fallbackRun(primaryTurns, backupTurns int) *mockProvider {
+	return &mockProvider{name: "claude", generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		for turn := 1; turn <= primaryTurns; turn++ {
+			if err := req.CheckBudget(claude.Usage{Provider: "claude", Model: "claude-sonnet-4-5", InputTokens: 100_000 * turn, Turns: turn}); err != nil {
+				return nil, err
+			}
+		}
+		var usage claude.Usage
+		for turn := 1; turn <= backupTurns; turn++ {
+			usage = claude.Usage{Provider: "backup", Model: "claude-sonnet-4-5", InputTokens: 100_000 * turn, Turns: turn}
+			if err := req.CheckBudget(usage); err != nil {
+				return nil, err
+			}
+		}
+		return &claude.CodeResponse{Provider: "backup", Usage: usage}, nil
+	}}
+}
+
+func TestGenerateCodeChanges_BudgetCountsEveryProvider(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.SetBudget(taskstore.Budget{Scope: taskstore.ScopeRepo, Subject: "owner/repo", Period: taskstore.PeriodDay, MaxUSD: 1}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if err := store.Create(&taskstore.Task{ID: "task-1", Title: "t", Status: taskstore.StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "alice"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The primary's $0.60 counts toward the cap of the backup's run.
+	mockGH := github.NewMockGHClient()
+	e := NewWithClient(fallbackRun(2, 10), nil, mockGH).WithStore(store).WithBudgets(budget.New(store))
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7, Username: "alice"}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 7, "alice", mockGH)
+
+	_, err = e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, "")
+	if err == nil || !strings.Contains(err.Error(), "has used $1.20 of its $1.00 daily budget, stopped after 4 turns") {
+		t.Fatalf("error = %v, want the backup stopped by the primary's spend", err)
+	}
+	stored, _ := store.Get("task-1")
+	if stored.Usage.Turns != 4 || stored.Usage.CostUSD < 1.19 {
+		t.Fatalf("stored usage = %+v, want both providers' runs", stored.Usage)
+	}
+}
+
+func TestGenerateCodeChanges_RecordsFailedProviderWithoutBudgets(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := store.Create(&taskstore.Task{ID: id, Title: "t", Status: taskstore.StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "alice"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	mockGH := github.NewMockGHClient()
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 7, "alice", mockGH)
+
+	// The backup succeeds: the primary's failed run is stored with it.
+	e := NewWithClient(fallbackRun(2, 1), nil, mockGH).WithStore(store)
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7, Username: "alice"}
+	if _, err := e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, ""); err != nil {
+		t.Fatalf("generateCodeChanges() error = %v", err)
+	}
+	stored, _ := store.Get("task-1")
+	if stored.Usage.Turns != 3 || stored.Usage.InputTokens != 300_000 {
+		t.Fatalf("stored usage = %+v, want primary and backup runs", stored.Usage)
+	}
+
+	// Every provider fails: what they spent is stored all the same.
+	e = NewWithClient(&mockProvider{generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		req.CheckBudget(claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 50_000, Turns: 1})
+		return nil, errors.New("provider crashed")
+	}}, nil, mockGH).WithStore(store)
+	task = &webhook.Task{ID: "task-2", Repo: "owner/repo", Number: 7, Username: "alice"}
+	if _, err := e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, ""); err == nil {
+		t.Fatal("generateCodeChanges() error = nil, want provider error")
+	}
+	stored, _ = store.Get("task-2")
+	if stored.Usage.Turns != 1 || stored.Usage.InputTokens != 50_000 {
+		t.Fatalf("stored usage = %+v, want the failed run", stored.Usage)
+	}
+}
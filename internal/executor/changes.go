@@ -0,0 +1,242 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/shared"
+)
+
+// changeLimitMessage prefixes errors for changes above the configured size limits.
+const changeLimitMessage = "change size limit exceeded"
+
+var errChangeLimit = errors.New(changeLimitMessage)
+
+// WithChangeLimits caps the size of a single changed file and of all changes of a
+// task in bytes. Zero disables a limit.
+func (e *Executor) WithChangeLimits(maxFileBytes, maxTotalBytes int64) *Executor {
+	e.maxFileBytes = maxFileBytes
+	e.maxChangeBytes = maxTotalBytes
+	return e
+}
+
+// changeBudget tracks the bytes of a change set against the executor limits.
+// Files are charged for what changed, not for their size, so a small edit to a
+// large file stays within the limits.
+type changeBudget struct {
+	maxFile, maxTotal, total int64
+}
+
+func (e *Executor) newChangeBudget() *changeBudget {
+	return &changeBudget{maxFile: e.maxFileBytes, maxTotal: e.maxChangeBytes}
+}
+
+func (b *changeBudget) add(path string, size int64) error {
+	if b.maxFile > 0 && size > b.maxFile {
+		return fmt.Errorf("%w: %s changes %d bytes (limit %d per file)", errChangeLimit, path, size, b.maxFile)
+	}
+	b.total += size
+	if b.maxTotal > 0 && b.total > b.maxTotal {
+		return fmt.Errorf("%w: changes exceed %d bytes in total at %s", errChangeLimit, b.maxTotal, path)
+	}
+	return nil
+}
+
+// contentChangeSize returns how many bytes of content differ from the file at
+// filePath, leaving out their common prefix and suffix. New files count in full.
+func contentChangeSize(filePath, content string) int64 {
+	current, err := os.ReadFile(filePath)
+	if err != nil {
+		return int64(len(content))
+	}
+	prefix := 0
+	for prefix < len(current) && prefix < len(content) && current[prefix] == content[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(current)-prefix && suffix < len(content)-prefix &&
+		current[len(current)-1-suffix] == content[len(content)-1-suffix] {
+		suffix++
+	}
+	return int64(max(len(current), len(content)) - prefix - suffix)
+}
+
+// diffSize returns the bytes a changed file adds to the change set: the size of
+// its diff against HEAD, or the size difference of the two blobs for binary
+// files. Files without diff statistics are new and count in full.
+func diffSize(workdir, relPath string, size int64, stat policy.Change, tracked bool) (int64, error) {
+	if !tracked {
+		return size, nil
+	}
+	if stat.Binary {
+		out, err := gitCommand(workdir, "", "cat-file", "-s", "HEAD:"+relPath).Output()
+		if err != nil {
+			return size, nil
+		}
+		base, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return size, nil
+		}
+		if size < base {
+			return base - size, nil
+		}
+		return size - base, nil
+	}
+
+	// The diff is counted as it streams; it is never held in memory.
+	cmd := gitCommand(workdir, "", "diff", "HEAD", "--no-ext-diff", "--no-textconv", "--no-color", "-U0", "--", relPath)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	n, copyErr := io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return 0, fmt.Errorf("git diff %s failed: %w", relPath, err)
+	}
+	return n, copyErr
+}
+
+// resolveEdits turns changes with edits into whole-file changes, applying the
+// edits to the workspace file or to an earlier change of the same file in the
+// response. The failed edits of all files are reported together.
+func resolveEdits(workdir string, changes []claude.FileChange) ([]claude.FileChange, error) {
+	resolved := make([]claude.FileChange, 0, len(changes))
+	latest := make(map[string]string) // content of files changed earlier in the response
+	var errs []error
+	for _, change := range changes {
+		cleanPath := filepath.Clean(change.Path)
+		if len(change.Edits) == 0 {
+			latest[cleanPath] = change.Content
+			resolved = append(resolved, change)
+			continue
+		}
+		if filepath.IsAbs(cleanPath) || cleanPath == ".." || strings.HasPrefix(cleanPath, ".."+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("path traversal detected for %s", change.Path)
+		}
+
+		current, ok := latest[cleanPath]
+		if !ok {
+			data, err := os.ReadFile(filepath.Join(workdir, cleanPath))
+			if err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("failed to read %s: %w", change.Path, err))
+				continue
+			}
+			current = string(data)
+		}
+		content, err := shared.ApplyEdits(change.Path, current, change.Edits)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Applied %d edits to %s", len(change.Edits), change.Path)
+		latest[cleanPath] = content
+		change.Content, change.Edits = content, nil
+		resolved = append(resolved, change)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+// writeFileChange writes a change to filePath. The executable bit of an existing
+// file is kept and set when the change requires it; changes stored as git blobs
+// are streamed from them.
+func writeFileChange(workdir, filePath string, change claude.FileChange) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if change.Executable {
+		mode |= 0o111
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if change.Blob != "" {
+		cmd := gitCommand(workdir, "", "cat-file", "blob", change.Blob)
+		cmd.Stdout = file
+		err = cmd.Run()
+	} else {
+		_, err = io.WriteString(file, change.Content)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// OpenFile only applies the mode to new files.
+	return os.Chmod(filePath, mode)
+}
+
+// readChangedFile describes a changed file for PR splitting. Its content is
+// stored as a git blob so it survives workspace resets without being held in
+// memory. ok is false when the file cannot be read.
+func readChangedFile(workdir, relPath string, stats map[string]policy.Change, budget *changeBudget) (change claude.FileChange, ok bool, err error) {
+	fullPath := filepath.Join(workdir, relPath)
+	info, statErr := os.Stat(fullPath)
+	if statErr != nil || info.IsDir() {
+		// File might be deleted, skip
+		log.Printf("Warning: Could not read %s: %v", relPath, statErr)
+		return change, false, nil
+	}
+
+	change = claude.FileChange{Path: relPath, Executable: info.Mode()&0o111 != 0}
+	stat, tracked := stats[relPath]
+	if tracked {
+		change.Binary, change.Lines = stat.Binary, stat.Lines
+	} else {
+		change.Lines, change.Binary = countFileLines(fullPath)
+	}
+
+	size, err := diffSize(workdir, relPath, info.Size(), stat, tracked)
+	if err != nil {
+		log.Printf("Warning: %v; counting the whole file", err)
+		size = info.Size()
+	}
+	if err := budget.add(relPath, size); err != nil {
+		return change, false, err
+	}
+
+	cmd := gitCommand(workdir, "", "hash-object", "-w", "--", relPath)
+	if out, err := cmd.Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+		change.Blob = strings.TrimSpace(string(out))
+		return change, true, nil
+	}
+	log.Printf("Warning: Could not store %s as a git blob, keeping it in memory", relPath)
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		log.Printf("Warning: Could not read %s: %v", relPath, err)
+		return change, false, nil
+	}
+	change.Content = string(content)
+	return change, true, nil
+}
+
+// isBinaryFile reports whether the start of the file contains a NUL byte.
+func isBinaryFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	sniff := make([]byte, binarySniffBytes)
+	n, _ := io.ReadFull(file, sniff)
+	return bytes.IndexByte(sniff[:n], 0) != -1
+}
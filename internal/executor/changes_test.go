@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestApplyChanges_PreservesExecutableBit(t *testing.T) {
+	workdir := t.TempDir()
+	script := filepath.Join(workdir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Executor{}
+	_, err := e.applyChanges(workdir, []claude.FileChange{
+		{Path: "run.sh", Content: "#!/bin/sh\necho updated\n"},
+		{Path: "bin/new.sh", Content: "#!/bin/sh\n", Executable: true},
+		{Path: "notes.txt", Content: "plain\n"},
+	})
+	if err != nil {
+		t.Fatalf("applyChanges() error = %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{"run.sh": 0o755, "bin/new.sh": 0o755, "notes.txt": 0o644} {
+		if got := fileMode(t, filepath.Join(workdir, path)); got != want {
+			t.Errorf("%s mode = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestApplyChanges_SizeLimits(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxFile, maxAll int64
+		want            string
+	}{
+		{"per file", 8, 0, "big.txt changes 10 bytes (limit 8 per file)"},
+		{"total", 0, 12, "changes exceed 12 bytes in total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workdir := t.TempDir()
+			e := (&Executor{}).WithChangeLimits(tt.maxFile, tt.maxAll)
+			_, err := e.applyChanges(workdir, []claude.FileChange{
+				{Path: "small.txt", Content: "1234"},
+				{Path: "big.txt", Content: "1234567890"},
+			})
+			if !errors.Is(err, errChangeLimit) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("applyChanges() error = %v, want %q", err, tt.want)
+			}
+			if _, statErr := os.Stat(filepath.Join(workdir, "small.txt")); !os.IsNotExist(statErr) {
+				t.Fatal("no file should be written when the limit is exceeded")
+			}
+			if !isNonRetryableTaskError(err.Error()) {
+				t.Fatal("size limit errors should not be retried")
+			}
+		})
+	}
+}
+
+func TestGetChangedFiles_BinaryAndLineStats(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{"big.go": strings.Repeat("line\n", 100)})
+	writeTestFile(t, workdir, "big.go", strings.Repeat("line\n", 99)+"changed\n")
+	writeTestFile(t, workdir, "new.txt", "a\nb\nc")
+	if err := os.WriteFile(filepath.Join(workdir, "logo.bin"), []byte{'P', 0, 1, 2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workdir, "tool.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := (&Executor{}).getChangedFiles(workdir)
+	if err != nil {
+		t.Fatalf("getChangedFiles() error = %v", err)
+	}
+	byPath := map[string]claude.FileChange{}
+	for _, c := range changes {
+		byPath[c.Path] = c
+	}
+
+	if c := byPath["big.go"]; c.Lines != 2 || c.LineCount() != 2 {
+		t.Errorf("big.go lines = %d, want 2 from numstat", c.Lines)
+	}
+	if c := byPath["new.txt"]; c.Lines != 3 {
+		t.Errorf("new.txt lines = %d, want 3", c.Lines)
+	}
+	bin := byPath["logo.bin"]
+	if !bin.Binary || bin.Blob == "" || bin.Content != "" || bin.LineCount() != 0 {
+		t.Errorf("logo.bin = %+v, want binary stored as blob", bin)
+	}
+	if c := byPath["new.txt"]; c.Blob == "" || c.Content != "" || changeContent(t, workdir, c) != "a\nb\nc" {
+		t.Errorf("new.txt = %+v, want text stored as blob", c)
+	}
+	if !byPath["tool.sh"].Executable || byPath["new.txt"].Executable {
+		t.Errorf("executable flags wrong: %+v", changes)
+	}
+}
+
+// changeContent returns the content of a change, reading it from its blob.
+func changeContent(t *testing.T, workdir string, change claude.FileChange) string {
+	t.Helper()
+	if change.Blob == "" {
+		return change.Content
+	}
+	content, err := gitOutputRaw(workdir, "cat-file", "blob", change.Blob)
+	if err != nil {
+		t.Fatalf("cat-file %s: %v", change.Blob, err)
+	}
+	return content
+}
+
+func TestGetChangedFiles_ChargesDiffNotFileSize(t *testing.T) {
+	large := strings.Repeat("0123456789abcdef\n", 1000)
+	workdir := initCommittedRepo(t, map[string]string{"large.txt": large})
+	if err := os.WriteFile(filepath.Join(workdir, "data.bin"), append([]byte{0}, make([]byte, 10_000)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitIn(t, workdir, "add", "data.bin")
+	runGitIn(t, workdir, "commit", "-q", "-m", "binary")
+
+	// A one-line edit to a large file and a small binary growth fit the limit.
+	writeTestFile(t, workdir, "large.txt", "changed\n"+large[17:])
+	if err := os.WriteFile(filepath.Join(workdir, "data.bin"), append([]byte{0}, make([]byte, 10_100)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := (&Executor{}).WithChangeLimits(1000, 0)
+	if _, err := e.getChangedFiles(workdir); err != nil {
+		t.Fatalf("getChangedFiles() error = %v, want small diffs within the limit", err)
+	}
+
+	// New files count in full.
+	writeTestFile(t, workdir, "new.txt", strings.Repeat("x", 2000))
+	if _, err := e.getChangedFiles(workdir); !errors.Is(err, errChangeLimit) || !strings.Contains(err.Error(), "new.txt") {
+		t.Fatalf("getChangedFiles() error = %v, want size limit for new.txt", err)
+	}
+}
+
+func TestApplyChanges_ChargesChangedBytes(t *testing.T) {
+	workdir := t.TempDir()
+	large := strings.Repeat("0123456789abcdef\n", 1000)
+	writeTestFile(t, workdir, "large.txt", large)
+
+	e := (&Executor{}).WithChangeLimits(100, 0)
+	if _, err := e.applyChanges(workdir, []claude.FileChange{{Path: "large.txt", Content: large[:8000] + "changed\n" + large[8017:]}}); err != nil {
+		t.Fatalf("applyChanges() error = %v, want a one-line edit within the limit", err)
+	}
+	if _, err := e.applyChanges(workdir, []claude.FileChange{{Path: "large.txt", Content: "replaced\n"}}); !errors.Is(err, errChangeLimit) {
+		t.Fatalf("applyChanges() error = %v, want size limit for a rewrite", err)
+	}
+}
+
+func TestCommitSubPR_RestoresBinaryAndMode(t *testing.T) {
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "base\n"})
+	runGitIn(t, workdir, "remote", "add", "origin", origin)
+
+	if err := os.WriteFile(filepath.Join(workdir, "logo.bin"), []byte{'P', 0, 1, 2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workdir, "tool.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewWithClient(nil, nil, github.NewMockGHClient())
+	changes, err := e.getChangedFiles(workdir)
+	if err != nil {
+		t.Fatalf("getChangedFiles() error = %v", err)
+	}
+	subPR := github.SubPR{Name: "Assets", Description: "Add assets", Files: changes}
+	if err := e.commitSubPR(workdir, "", "swe/assets", subPR, &webhook.Task{}, ""); err != nil {
+		t.Fatalf("commitSubPR() error = %v", err)
+	}
+
+	tree, err := gitOutput(origin, "ls-tree", "swe/assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tree, "100755 blob") || !strings.Contains(tree, "tool.sh") {
+		t.Fatalf("tree = %q, want executable tool.sh", tree)
+	}
+	data, err := gitOutputRaw(origin, "show", "swe/assets:logo.bin")
+	if err != nil || data != "P\x00\x01\x02" {
+		t.Fatalf("logo.bin = %q, %v", data, err)
+	}
+}
+
+func TestApplyChanges_Edits(t *testing.T) {
+	workdir := t.TempDir()
+	writeTestFile(t, workdir, "main.go", "package main\n\nfunc main() {\n\tprintln(\"a\")\n}\n")
+	writeTestFile(t, workdir, "util.go", "package main\n")
+
+	e := &Executor{}
+	applied, err := e.applyChanges(workdir, []claude.FileChange{
+		{Path: "main.go", Edits: []shared.Edit{{Search: "\tprintln(\"a\")", Replace: "\tprintln(\"b\")"}}},
+		{Path: "new.go", Content: "package main\n"},
+		{Path: "new.go", Edits: []shared.Edit{{Search: "package main", Replace: "package main\n\nvar x = 1"}}},
+	})
+	if err != nil {
+		t.Fatalf("applyChanges() error = %v", err)
+	}
+	for path, want := range map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tprintln(\"b\")\n}\n",
+		"new.go":  "package main\n\nvar x = 1\n",
+	} {
+		if data, _ := os.ReadFile(filepath.Join(workdir, path)); string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+	if len(applied) != 3 || applied[0].Edits != nil || !strings.Contains(applied[0].Content, "println(\"b\")") {
+		t.Fatalf("applyChanges() = %+v, want edits resolved into whole files", applied)
+	}
+
+	// One failing edit leaves every file unchanged.
+	_, err = e.applyChanges(workdir, []claude.FileChange{
+		{Path: "util.go", Content: "package util\n"},
+		{Path: "main.go", Edits: []shared.Edit{{Search: "func missing() {", Replace: ""}}},
+	})
+	var editErr *shared.EditError
+	if !errors.As(err, &editErr) || editErr.Path != "main.go" {
+		t.Fatalf("applyChanges() error = %v, want an edit error for main.go", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workdir, "util.go")); string(data) != "package main\n" {
+		t.Fatalf("util.go = %q, want it unchanged", data)
+	}
+
+	if _, err := e.applyChanges(workdir, []claude.FileChange{{Path: "../x.go", Edits: []shared.Edit{{Replace: "x"}}}}); err == nil {
+		t.Fatal("applyChanges() should reject edits outside the workdir")
+	}
+}
@@ -0,0 +1,272 @@
+package executor
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// retainedWorkspace is a workspace kept after a retryable failure so the next
+// attempt of the same task can continue in it.
+type retainedWorkspace struct {
+	workdir string
+	cleanup func()
+}
+
+// startCheckpoint returns the checkpoint for this attempt: the one left by a
+// previous attempt on retries, otherwise an empty one. Nil disables checkpoints.
+func (e *Executor) startCheckpoint(task *webhook.Task) *taskstore.Checkpoint {
+	if e.store == nil || task == nil || task.ID == "" {
+		return nil
+	}
+	if task.Attempt > 1 {
+		if cp, ok := e.store.GetCheckpoint(task.ID); ok {
+			log.Printf("Resuming task %s from phase %s", task.ID, cp.Phase)
+			e.addLog(task, "info", "Resuming from checkpoint: %s", cp.Phase)
+			return cp
+		}
+	}
+	return &taskstore.Checkpoint{TaskID: task.ID}
+}
+
+func (e *Executor) saveCheckpoint(cp *taskstore.Checkpoint, phase taskstore.Phase) {
+	if cp == nil {
+		return
+	}
+	cp.Phase = phase
+	if err := e.store.SaveCheckpoint(cp); err != nil {
+		log.Printf("Warning: failed to save checkpoint for task %s: %v", cp.TaskID, err)
+	}
+}
+
+// prepareWorkspace reuses the workspace of a failed attempt when it is still
+// around, otherwise clones a fresh one.
+func (e *Executor) prepareWorkspace(
+	task *webhook.Task,
+	tracker *github.CommentTracker,
+	token string,
+	contextMap map[string]string,
+	cp *taskstore.Checkpoint,
+) (workdir string, cleanup func(), branchName string, isNewBranch bool, err error) {
+	if ws, ok := e.takeWorkspace(task, cp); ok {
+		if resumableCommit(ws.workdir, cp) == "" {
+			// Drop partial edits of the failed attempt; generated changes are re-applied from the patch.
+			if err := resetWorktree(ws.workdir); err != nil {
+				log.Printf("Warning: cannot reset workspace %s: %v", ws.workdir, err)
+				ws.cleanup()
+				return e.prepareWorkspace(task, tracker, token, contextMap, cp)
+			}
+		}
+		if err := e.loadRepoConfig(task, ws.workdir, contextMap, token); err != nil {
+			ws.cleanup()
+			return "", nil, "", false, e.handleError(task, tracker, token, err.Error())
+		}
+		contextMap["claude_branch"] = cp.Branch
+		tracker.State.Context = cloneStringMap(contextMap)
+		tracker.ResumeTask("Clone repository")
+		log.Printf("Reusing workspace %s from previous attempt", ws.workdir)
+		e.addLog(task, "info", "Reusing workspace from previous attempt (branch %s)", cp.Branch)
+		return ws.workdir, ws.cleanup, cp.Branch, cp.IsNewBranch, nil
+	}
+
+	workdir, cleanup, branchName, isNewBranch, err = e.cloneAndPrepareWorkspace(task, tracker, token, contextMap)
+	if err != nil {
+		return "", nil, "", false, err
+	}
+	if cp != nil {
+		if cp.Phase == taskstore.PhaseCommitted {
+			// The commit lived in the lost workspace; it is recreated from the patch.
+			cp.Phase, cp.CommitSHA = taskstore.PhaseGenerated, ""
+		}
+		phase := cp.Phase
+		if phase == "" {
+			phase = taskstore.PhaseCloned
+		}
+		cp.Workdir, cp.Branch, cp.IsNewBranch = workdir, branchName, isNewBranch
+		e.saveCheckpoint(cp, phase)
+	}
+	return workdir, cleanup, branchName, isNewBranch, nil
+}
+
+// resumeGeneration restores the provider response of a previous attempt and
+// re-applies its changes unless they are already committed. It reports false
+// when the provider has to run again.
+func (e *Executor) resumeGeneration(task *webhook.Task, tracker *github.CommentTracker, workdir string, cp *taskstore.Checkpoint) (*claude.CodeResponse, bool) {
+	if cp == nil || cp.Response == "" || (cp.Phase != taskstore.PhaseGenerated && cp.Phase != taskstore.PhaseCommitted) {
+		return nil, false
+	}
+	var result claude.CodeResponse
+	if err := json.Unmarshal([]byte(cp.Response), &result); err != nil {
+		log.Printf("Warning: ignoring unreadable checkpoint response for task %s: %v", cp.TaskID, err)
+		return nil, false
+	}
+	if resumableCommit(workdir, cp) == "" {
+		if err := applyPatch(workdir, cp.Patch); err != nil {
+			log.Printf("Warning: cannot re-apply checkpoint patch for task %s: %v", cp.TaskID, err)
+			e.addLog(task, "info", "Checkpoint patch no longer applies, generating again")
+			return nil, false
+		}
+	}
+	tracker.ResumeTask("Generate code changes")
+	e.recordProvider(task, tracker, result.Provider)
+	// The previous attempt already added this usage to the store.
+	tracker.SetUsage(result.Usage)
+	e.addLog(task, "info", "Reusing provider response from previous attempt")
+	return &result, true
+}
+
+// recordGeneration checkpoints the provider response and the resulting changes.
+func (e *Executor) recordGeneration(cp *taskstore.Checkpoint, workdir string, result *claude.CodeResponse) {
+	if cp == nil {
+		return
+	}
+	response, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	patch, err := capturePatch(workdir)
+	if err != nil {
+		log.Printf("Warning: failed to capture changes for checkpoint: %v", err)
+		return
+	}
+	cp.Response, cp.Patch, cp.CommitSHA = string(response), patch, ""
+	e.saveCheckpoint(cp, taskstore.PhaseGenerated)
+}
+
+// resumableCommit returns the commit of a previous attempt that still has to be pushed.
+func resumableCommit(workdir string, cp *taskstore.Checkpoint) string {
+	if cp == nil || cp.Phase != taskstore.PhaseCommitted || cp.CommitSHA == "" {
+		return ""
+	}
+	if head, err := gitOutput(workdir, "rev-parse", "HEAD"); err != nil || head != cp.CommitSHA {
+		return ""
+	}
+	return cp.CommitSHA
+}
+
+// recordCommit checkpoints the local commit before it is pushed.
+func (e *Executor) recordCommit(cp *taskstore.Checkpoint, workdir string) {
+	if cp == nil {
+		return
+	}
+	sha, err := gitOutput(workdir, "rev-parse", "HEAD")
+	if err != nil {
+		return
+	}
+	cp.CommitSHA = sha
+	e.saveCheckpoint(cp, taskstore.PhaseCommitted)
+}
+
+// capturePatch returns a binary diff of all changes in the worktree, leaving the index untouched.
+func capturePatch(workdir string) (string, error) {
+	return stagedDiff(workdir, true)
+}
+
+// stagedDiff returns the diff of all changes in the worktree, including untracked
+// files, and resets the index afterwards.
+func stagedDiff(workdir string, binary bool) (string, error) {
+	if err := runGitCommand(workdir, []string{"git", "add", "-A"}, false); err != nil {
+		return "", err
+	}
+	args := []string{"diff", "--cached"}
+	if binary {
+		args = append(args, "--binary")
+	}
+	patch, err := gitOutputRaw(workdir, args...)
+	if resetErr := runGitCommand(workdir, []string{"git", "reset", "-q"}, false); err == nil {
+		err = resetErr
+	}
+	return patch, err
+}
+
+func resetWorktree(workdir string) error {
+	if err := runGitCommand(workdir, []string{"git", "reset", "-q", "--hard", "HEAD"}, false); err != nil {
+		return err
+	}
+	return runGitCommand(workdir, []string{"git", "clean", "-fdq"}, false)
+}
+
+func applyPatch(workdir, patch string) error {
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+	file, err := os.CreateTemp("", "swe-checkpoint-*.patch")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(patch); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return runGitCommand(workdir, []string{"git", "apply", "--binary", "--whitespace=nowarn", file.Name()}, false)
+}
+
+// finishAttempt decides what happens to the workspace and checkpoint once an
+// attempt returns: retryable failures keep both for the next attempt.
+func (e *Executor) finishAttempt(task *webhook.Task, cp *taskstore.Checkpoint, workdir string, cleanup func(), err error) {
+	if err != nil && !IsNonRetryable(err) && cp != nil && cp.Workdir == workdir {
+		e.workspacesMu.Lock()
+		if e.workspaces == nil {
+			e.workspaces = make(map[string]retainedWorkspace)
+		}
+		e.workspaces[task.ID] = retainedWorkspace{workdir: workdir, cleanup: cleanup}
+		e.workspacesMu.Unlock()
+		return
+	}
+	cleanup()
+	if cp != nil && (err == nil || IsNonRetryable(err)) {
+		e.store.DeleteCheckpoint(task.ID)
+	}
+}
+
+func (e *Executor) takeWorkspace(task *webhook.Task, cp *taskstore.Checkpoint) (retainedWorkspace, bool) {
+	if cp == nil {
+		return retainedWorkspace{}, false
+	}
+	e.workspacesMu.Lock()
+	ws, ok := e.workspaces[task.ID]
+	delete(e.workspaces, task.ID)
+	e.workspacesMu.Unlock()
+	if !ok {
+		return retainedWorkspace{}, false
+	}
+	if ws.workdir != cp.Workdir || cp.Branch == "" || !isDir(ws.workdir) {
+		ws.cleanup()
+		return retainedWorkspace{}, false
+	}
+	return ws, true
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(filepath.Clean(path))
+	return err == nil && info.IsDir()
+}
+
+// Finalize releases the workspace and checkpoint kept for a task. The dispatcher
+// calls it when no further attempt will run.
+func (e *Executor) Finalize(task *webhook.Task) {
+	if task == nil {
+		return
+	}
+	e.workspacesMu.Lock()
+	ws, ok := e.workspaces[task.ID]
+	delete(e.workspaces, task.ID)
+	e.workspacesMu.Unlock()
+	if ok {
+		ws.cleanup()
+	}
+	if e.store != nil && task.ID != "" {
+		e.store.DeleteCheckpoint(task.ID)
+	}
+}
@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// resumeFixture is a remote whose pushes are declined while the marker file exists.
+type resumeFixture struct {
+	remote      string
+	marker      string
+	clones      int
+	workdirs    []string
+	store       *taskstore.Store
+	gh          *github.MockGHClient
+	providerRun int
+}
+
+func newResumeFixture(t *testing.T) *resumeFixture {
+	t.Helper()
+	f := &resumeFixture{remote: t.TempDir(), marker: filepath.Join(t.TempDir(), "decline")}
+	runGitIn(t, f.remote, "init", "-q", "--bare")
+	seed := initCommittedRepo(t, map[string]string{"README.md": "# seed\n"})
+	runGitIn(t, seed, "push", "-q", f.remote, "HEAD:refs/heads/main")
+	serveGitHubRepo(t, "owner/repo", f.remote)
+
+	hook := "#!/bin/sh\nif [ -f " + f.marker + " ]; then echo declined >&2; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(f.remote, "hooks", "pre-receive"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f.marker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	f.store = store
+	f.gh = github.NewMockGHClient()
+	return f
+}
+
+func (f *resumeFixture) executor(t *testing.T) *Executor {
+	t.Helper()
+	p := &mockProvider{
+		name: "codegen",
+		generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+			f.providerRun++
+			return &claude.CodeResponse{
+				Files:        []claude.FileChange{{Path: "lib/service.go", Content: "package lib\n"}},
+				Summary:      "Add service",
+				SystemPrompt: "system prompt",
+				UserPrompt:   req.Prompt,
+				RawResponse:  "<summary>Add service</summary>",
+				CostUSD:      0.25,
+			}, nil
+		},
+	}
+	e := NewWithClient(p, &mockAppAuth{}, f.gh).WithStore(f.store)
+	e.cloneFn = func(repo, branch, token string) (string, func(), error) {
+		f.clones++
+		dir := t.TempDir()
+		runGitIn(t, dir, "clone", "-q", "--branch", branch, f.remote, ".")
+		f.workdirs = append(f.workdirs, dir)
+		return dir, func() { os.RemoveAll(dir) }, nil
+	}
+	return e
+}
+
+func (f *resumeFixture) task(t *testing.T, attempt int) *webhook.Task {
+	t.Helper()
+	task := &webhook.Task{ID: "task-resume", Repo: "owner/repo", Number: 9, Branch: "main", Prompt: "Add service", Username: "builder", Attempt: attempt}
+	if attempt == 1 {
+		if err := f.store.Create(&taskstore.Task{ID: task.ID, Title: task.Prompt, Status: taskstore.StatusPending, RepoOwner: "owner", RepoName: "repo", IssueNumber: task.Number, Actor: task.Username}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return task
+}
+
+func (f *resumeFixture) remoteBranches(t *testing.T) string {
+	t.Helper()
+	out, err := gitOutput(f.remote, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestExecute_ResumesCommittedCheckpoint(t *testing.T) {
+	f := newResumeFixture(t)
+	executor := f.executor(t)
+
+	if err := executor.Execute(context.Background(), f.task(t, 1)); err == nil {
+		t.Fatal("first attempt should fail while the remote declines pushes")
+	}
+	cp, ok := f.store.GetCheckpoint("task-resume")
+	if !ok || cp.Phase != taskstore.PhaseCommitted || cp.CommitSHA == "" {
+		t.Fatalf("checkpoint = %+v, want committed", cp)
+	}
+	if !isDir(f.workdirs[0]) {
+		t.Fatal("workspace of a retryable failure should be kept")
+	}
+
+	os.Remove(f.marker)
+	if err := executor.Execute(context.Background(), f.task(t, 2)); err != nil {
+		t.Fatalf("second attempt error = %v", err)
+	}
+	if f.providerRun != 1 || f.clones != 1 {
+		t.Fatalf("provider runs = %d, clones = %d; want both reused", f.providerRun, f.clones)
+	}
+	if stored, _ := f.store.Get("task-resume"); stored.Usage.CostUSD != 0.25 {
+		t.Fatalf("stored cost = %v, want the single provider run counted once", stored.Usage.CostUSD)
+	}
+	if sha, _ := gitOutput(f.remote, "rev-parse", cp.Branch); sha != cp.CommitSHA {
+		t.Fatalf("remote %s = %q, want checkpointed commit %s", cp.Branch, sha, cp.CommitSHA)
+	}
+	if _, ok := f.store.GetCheckpoint("task-resume"); ok {
+		t.Fatal("checkpoint should be deleted after success")
+	}
+	if isDir(f.workdirs[0]) {
+		t.Fatal("workspace should be removed after success")
+	}
+}
+
+func TestExecute_ResumesGeneratedChangesInFreshClone(t *testing.T) {
+	f := newResumeFixture(t)
+
+	if err := f.executor(t).Execute(context.Background(), f.task(t, 1)); err == nil {
+		t.Fatal("first attempt should fail while the remote declines pushes")
+	}
+
+	// A new executor has no retained workspace, as after a restart.
+	os.Remove(f.marker)
+	if err := f.executor(t).Execute(context.Background(), f.task(t, 2)); err != nil {
+		t.Fatalf("second attempt error = %v", err)
+	}
+	if f.providerRun != 1 || f.clones != 2 {
+		t.Fatalf("provider runs = %d, clones = %d; want provider reused and a fresh clone", f.providerRun, f.clones)
+	}
+	branches := f.remoteBranches(t)
+	var branch string
+	for _, b := range strings.Split(branches, "\n") {
+		if strings.HasPrefix(b, "swe/") {
+			branch = b
+		}
+	}
+	if branch == "" {
+		t.Fatalf("remote branches = %q, want swe branch", branches)
+	}
+	if content, err := gitOutput(f.remote, "show", branch+":lib/service.go"); err != nil || content != "package lib" {
+		t.Fatalf("lib/service.go = %q, %v", content, err)
+	}
+}
+
+func TestFinalize_ReleasesRetainedWorkspace(t *testing.T) {
+	f := newResumeFixture(t)
+	executor := f.executor(t)
+	task := f.task(t, 1)
+
+	if err := executor.Execute(context.Background(), task); err == nil {
+		t.Fatal("attempt should fail while the remote declines pushes")
+	}
+	executor.Finalize(task)
+
+	if isDir(f.workdirs[0]) {
+		t.Fatal("Finalize should remove the retained workspace")
+	}
+	if _, ok := f.store.GetCheckpoint(task.ID); ok {
+		t.Fatal("Finalize should delete the checkpoint")
+	}
+}
+
+func TestCapturePatch_RoundTrip(t *testing.T) {
+	src := initCommittedRepo(t, map[string]string{"keep.txt": "a\n", "gone.txt": "b\n"})
+	dst := initCommittedRepo(t, map[string]string{"keep.txt": "a\n", "gone.txt": "b\n"})
+
+	writeTestFile(t, src, "keep.txt", "a\nchanged\n")
+	writeTestFile(t, src, "new/file.txt", "new\n")
+	if err := os.WriteFile(filepath.Join(src, "blob.bin"), []byte{0, 1, 2, 0xff}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(src, "gone.txt"))
+
+	patch, err := capturePatch(src)
+	if err != nil {
+		t.Fatalf("capturePatch() error = %v", err)
+	}
+	if staged, _ := gitOutput(src, "diff", "--cached", "--name-only"); staged != "" {
+		t.Fatalf("capturePatch left staged files: %q", staged)
+	}
+	if err := applyPatch(dst, patch); err != nil {
+		t.Fatalf("applyPatch() error = %v", err)
+	}
+
+	want, _ := gitOutput(src, "status", "--porcelain")
+	got, _ := gitOutput(dst, "status", "--porcelain")
+	if got != want {
+		t.Fatalf("status after apply = %q, want %q", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "blob.bin")); string(data) != "\x00\x01\x02\xff" {
+		t.Fatalf("blob.bin = %q", data)
+	}
+}
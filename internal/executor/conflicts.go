@@ -0,0 +1,233 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// maxConflictRounds bounds how many rebase steps the provider may resolve.
+const maxConflictRounds = 5
+
+// MergeConflictError reports files that conflict when rebasing onto a branch
+// that was updated remotely while the task was running.
+type MergeConflictError struct {
+	Remote   string
+	Branch   string
+	Upstream string // remote commit the rebase targeted
+	Files    []string
+}
+
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("merge conflict rebasing onto %s/%s; conflicting files: %s", e.Remote, e.Branch, strings.Join(e.Files, ", "))
+}
+
+// gateError is the error of a change policy or secret scan that rejected a
+// conflict resolution. handleError has already reported it.
+type gateError struct{ err error }
+
+func (e *gateError) Error() string { return e.err.Error() }
+func (e *gateError) Unwrap() error { return e.err }
+
+// isNonFastForward reports whether a push was rejected because the remote branch moved.
+func isNonFastForward(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, github.ErrNotFastForward) {
+		return true
+	}
+	s := strings.ToLower(err.Error())
+	return strings.Contains(s, "non-fast-forward") ||
+		strings.Contains(s, "(fetch first)") ||
+		strings.Contains(s, "updates were rejected because the tip")
+}
+
+// rebaseOntoRemote fetches branchName from repo and replays local commits on top of it.
+// On conflict the rebase is aborted and a *MergeConflictError is returned.
+func rebaseOntoRemote(workdir, repo, branchName, token string) error {
+	remote := remoteURL(repo)
+	if err := runAuthGitCommand(workdir, token, []string{"git", "fetch", remote, branchName}, false); err != nil {
+		return err
+	}
+	upstream, err := gitOutput(workdir, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+
+	rebaseErr := runGitCommand(workdir, []string{"git", "rebase", upstream}, false)
+	if rebaseErr == nil {
+		return nil
+	}
+	files := conflictedFiles(workdir)
+	_ = runGitCommand(workdir, []string{"git", "rebase", "--abort"}, false)
+	if len(files) == 0 {
+		return rebaseErr
+	}
+	if repo != "" {
+		remote = repo
+	}
+	return &MergeConflictError{Remote: remote, Branch: branchName, Upstream: upstream, Files: files}
+}
+
+func conflictedFiles(workdir string) []string {
+	out, err := gitOutput(workdir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil || out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// resolveMergeConflict replays the rebase that failed in publishBranch, lets the
+// provider resolve each conflicting step and pushes the result. When the provider
+// cannot resolve the conflict, the original error is returned so the conflicting
+// files end up in the tracking comment. Every provider run is capped by the
+// task's budgets, and its usage is added to that of result. A resolution is
+// checked by the change policy and the secret scan like the original change,
+// and a rejection is returned as a *gateError.
+func (e *Executor) resolveMergeConflict(ctx context.Context, task *webhook.Task, tracker *github.CommentTracker, workdir, token string, conflict *MergeConflictError, result *claude.CodeResponse) error {
+	log.Printf("Push to %s rejected with conflicts in %s, asking provider to resolve", conflict.Branch, strings.Join(conflict.Files, ", "))
+	e.addLog(task, "info", "Branch %s changed remotely; resolving conflicts in %s", conflict.Branch, strings.Join(conflict.Files, ", "))
+
+	p, err := e.providerFor(task)
+	if err != nil {
+		return conflict
+	}
+	pushRepo, fork := pushTarget(task)
+
+	if err := runGitCommand(workdir, []string{"git", "rebase", conflict.Upstream}, false); err == nil {
+		return e.publishBranch(workdir, pushRepo, fork, conflict.Branch, false, token)
+	}
+
+	for round := 0; round < maxConflictRounds; round++ {
+		files := conflictedFiles(workdir)
+		if len(files) == 0 {
+			break
+		}
+		if binary := binaryFiles(workdir, files); len(binary) > 0 {
+			// Conflict markers cannot be resolved in binary content.
+			e.addLog(task, "error", "Cannot resolve conflicts in binary files %s", strings.Join(binary, ", "))
+			return abortRebase(workdir, conflict)
+		}
+
+		guard, err := e.newBudgetGuard(task, p.Name())
+		if err != nil {
+			e.addLog(task, "error", "Cannot resolve merge conflict: %v", err)
+			return abortRebase(workdir, conflict)
+		}
+		start := time.Now()
+		resolved, err := p.GenerateCode(ctx, &claude.CodeRequest{
+			Prompt:   conflictPrompt(task, conflict.Branch, files),
+			RepoPath: workdir,
+			Context:  e.buildExecutionContext(task),
+			Budget:   guard.check,
+		})
+		if err != nil {
+			for _, usage := range guard.spent("") {
+				e.addUsage(task, tracker, result, &claude.CodeResponse{Usage: usage}, time.Since(start))
+			}
+			e.addLog(task, "error", "%s could not resolve merge conflict: %v", p.Name(), err)
+			return abortRebase(workdir, conflict)
+		}
+		e.addUsage(task, tracker, result, resolved, time.Since(start))
+		resolvedBy := resolved.Provider
+		if resolvedBy == "" {
+			resolvedBy = p.Name()
+		}
+		for _, usage := range guard.spent(resolvedBy) {
+			e.addUsage(task, tracker, result, &claude.CodeResponse{Usage: usage}, 0)
+		}
+		if _, err := e.applyChanges(workdir, resolved.Files); err != nil {
+			return abortRebase(workdir, conflict)
+		}
+		if unresolved := filesWithConflictMarkers(workdir, files); len(unresolved) > 0 {
+			e.addLog(task, "error", "Conflict markers remain in %s", strings.Join(unresolved, ", "))
+			return abortRebase(workdir, &MergeConflictError{Remote: conflict.Remote, Branch: conflict.Branch, Upstream: conflict.Upstream, Files: unresolved})
+		}
+
+		reverted, err := e.enforcePolicy(task, workdir, tracker, token)
+		if err == nil {
+			result.Files = dropRevertedFiles(result.Files, reverted)
+			err = e.scanForSecrets(task, workdir, tracker, token)
+		}
+		if err != nil {
+			_ = runGitCommand(workdir, []string{"git", "rebase", "--abort"}, false)
+			return &gateError{err: err}
+		}
+
+		if err := runGitCommand(workdir, append([]string{"git", "add", "--"}, files...), false); err != nil {
+			return abortRebase(workdir, conflict)
+		}
+		err = runGitCommand(workdir, []string{"git", "-c", "core.editor=true", "rebase", "--continue"}, false)
+		if err == nil {
+			e.addLog(task, "info", "Resolved merge conflicts in %s", strings.Join(files, ", "))
+			return e.publishBranch(workdir, pushRepo, fork, conflict.Branch, false, token)
+		}
+		if len(conflictedFiles(workdir)) == 0 {
+			_ = runGitCommand(workdir, []string{"git", "rebase", "--abort"}, false)
+			return err
+		}
+	}
+	return abortRebase(workdir, conflict)
+}
+
+func abortRebase(workdir string, conflict *MergeConflictError) error {
+	_ = runGitCommand(workdir, []string{"git", "rebase", "--abort"}, false)
+	return conflict
+}
+
+func conflictPrompt(task *webhook.Task, branchName string, files []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Branch %s was updated remotely while you were working on the task below, and rebasing your commit onto it produced merge conflicts.\n\n", branchName)
+	b.WriteString("Resolve the conflict markers (<<<<<<<, =======, >>>>>>>) in these files, keeping the remote changes and the intent of your change:\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "- %s\n", f)
+	}
+	b.WriteString("\nDo not modify other files. Return the complete resolved content of each file.\n\n")
+	b.WriteString("Original task:\n")
+	b.WriteString(task.Prompt)
+	return b.String()
+}
+
+func binaryFiles(workdir string, files []string) []string {
+	var binary []string
+	for _, f := range files {
+		if isBinaryFile(filepath.Join(workdir, f)) {
+			binary = append(binary, f)
+		}
+	}
+	return binary
+}
+
+func filesWithConflictMarkers(workdir string, files []string) []string {
+	var unresolved []string
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(workdir, f))
+		if err != nil {
+			continue // deleted while resolving
+		}
+		if hasConflictMarkers(data) {
+			unresolved = append(unresolved, f)
+		}
+	}
+	return unresolved
+}
+
+func hasConflictMarkers(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("<<<<<<< ")) || bytes.HasPrefix(line, []byte(">>>>>>> ")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/secretscan"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// conflictProvider answers conflict prompts with fixed file contents.
+type conflictProvider struct {
+	files  []claude.FileChange
+	prompt string
+}
+
+func (p *conflictProvider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	p.prompt = req.Prompt
+	return &claude.CodeResponse{Files: p.files, Summary: "resolved"}, nil
+}
+
+func (p *conflictProvider) Name() string { return "claude" }
+
+// newDivergedBranch returns a bare origin and a clone on branch "feature" whose
+// remote gains a concurrent commit writing remoteContent to path.
+func newDivergedBranch(t *testing.T, path, remoteContent string) (origin, workdir string) {
+	t.Helper()
+	origin = t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+
+	seed := initCommittedRepo(t, map[string]string{"app.txt": "base\n", "other.txt": "one\n"})
+	runGitIn(t, seed, "checkout", "-q", "-b", "feature")
+	runGitIn(t, seed, "remote", "add", "origin", origin)
+	runGitIn(t, seed, "push", "-q", "origin", "feature")
+
+	workdir = t.TempDir()
+	runGitIn(t, workdir, "clone", "-q", "-b", "feature", origin, ".")
+
+	writeTestFile(t, seed, path, remoteContent)
+	runGitIn(t, seed, "commit", "-q", "-am", "concurrent change")
+	runGitIn(t, seed, "push", "-q", "origin", "feature")
+	serveGitHubRepo(t, "owner/repo", origin)
+	return origin, workdir
+}
+
+func TestPublishBranch_RebasesOnNonFastForward(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "other.txt", "two\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	executor := New(nil, nil)
+	if err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, ""); err != nil {
+		t.Fatalf("commitAndPush() error = %v", err)
+	}
+
+	log, err := gitOutput(origin, "log", "--format=%s", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != "Agent change\nconcurrent change\ninit" {
+		t.Fatalf("remote history = %q, want agent commit rebased on concurrent change", log)
+	}
+}
+
+func TestPublishBranch_ReportsConflicts(t *testing.T) {
+	_, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	executor := New(nil, nil)
+	err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, "")
+	var conflict *MergeConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want MergeConflictError", err)
+	}
+	if conflict.Branch != "feature" || strings.Join(conflict.Files, ",") != "app.txt" || conflict.Upstream == "" {
+		t.Fatalf("conflict = %+v", conflict)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, ".git", "rebase-merge")); !os.IsNotExist(err) {
+		t.Fatal("rebase left in progress")
+	}
+	if !isNonRetryableTaskError(err.Error()) {
+		t.Fatal("merge conflicts should not be retried")
+	}
+}
+
+func TestResolveMergeConflict_ProviderResolves(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	p := &conflictProvider{files: []claude.FileChange{{Path: "app.txt", Content: "remote\nagent\n"}}}
+	executor := NewWithClient(p, nil, github.NewMockGHClient())
+	task := &webhook.Task{Repo: "", Prompt: "append agent line"}
+
+	var conflict *MergeConflictError
+	if err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, ""); !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want conflict", err)
+	}
+	if err := executor.resolveMergeConflict(context.Background(), task, github.NewCommentTracker("owner/repo", 1, "user"), workdir, "", conflict, &claude.CodeResponse{}); err != nil {
+		t.Fatalf("resolveMergeConflict() error = %v", err)
+	}
+
+	if !strings.Contains(p.prompt, "- app.txt") || !strings.Contains(p.prompt, "append agent line") {
+		t.Fatalf("prompt = %q", p.prompt)
+	}
+	content, err := gitOutput(origin, "show", "feature:app.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "remote\nagent" {
+		t.Fatalf("remote app.txt = %q", content)
+	}
+}
+
+func TestResolveMergeConflict_UnresolvedMarkersReported(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+	before, _ := gitOutput(origin, "rev-parse", "feature")
+
+	p := &conflictProvider{} // leaves the markers in place
+	executor := NewWithClient(p, nil, github.NewMockGHClient())
+	task := &webhook.Task{Prompt: "change app"}
+
+	var conflict *MergeConflictError
+	if err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, ""); !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want conflict", err)
+	}
+	err := executor.resolveMergeConflict(context.Background(), task, github.NewCommentTracker("owner/repo", 1, "user"), workdir, "", conflict, &claude.CodeResponse{})
+	if err == nil || !strings.Contains(err.Error(), "conflicting files: app.txt") {
+		t.Fatalf("resolveMergeConflict() error = %v, want conflicting files", err)
+	}
+	if after, _ := gitOutput(origin, "rev-parse", "feature"); after != before {
+		t.Fatal("unresolved conflict must not be pushed")
+	}
+	if hints := deriveHelpfulHints(err.Error(), task); !strings.Contains(strings.Join(hints, " "), "Resolve the listed files") {
+		t.Fatalf("hints = %v", hints)
+	}
+}
+
+func TestHasConflictMarkers(t *testing.T) {
+	if !hasConflictMarkers([]byte("a\n<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> abc\n")) {
+		t.Fatal("markers not detected")
+	}
+	if hasConflictMarkers([]byte("// <<<<<<< in a comment\n")) {
+		t.Fatal("marker inside a line should not count")
+	}
+}
+
+func TestResolveMergeConflict_ChargesBudget(t *testing.T) {
+	_, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.SetBudget(taskstore.Budget{Scope: taskstore.ScopeRepo, Subject: "owner/repo", Period: taskstore.PeriodDay, MaxUSD: 1}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if err := store.Create(&taskstore.Task{ID: "task-1", Title: "t", Status: taskstore.StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "alice"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The task already spent $0.60; each conflict turn costs $0.30 more.
+	p := &mockProvider{generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		for turns := 1; ; turns++ {
+			usage := claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 100_000 * turns, Turns: turns}
+			if err := req.CheckBudget(usage); err != nil {
+				return nil, err
+			}
+		}
+	}}
+	mockGH := github.NewMockGHClient()
+	e := NewWithClient(p, nil, mockGH).WithStore(store).WithBudgets(budget.New(store))
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7, Username: "alice", Prompt: "change app"}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 7, "alice", mockGH)
+	result := &claude.CodeResponse{Usage: claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 200_000, Turns: 2}}
+	e.recordUsage(task, tracker, result, 0)
+
+	var conflict *MergeConflictError
+	if err := e.commitAndPush(workdir, "", "feature", "Agent change", false, ""); !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want conflict", err)
+	}
+	if err := e.resolveMergeConflict(context.Background(), task, tracker, workdir, "", conflict, result); !errors.As(err, &conflict) {
+		t.Fatalf("resolveMergeConflict() error = %v, want the conflict", err)
+	}
+
+	stored, _ := store.Get("task-1")
+	if stored.Usage.Turns != 4 || stored.Usage.InputTokens != 400_000 || stored.Usage.CostUSD < 1.19 {
+		t.Fatalf("stored usage = %+v, want the conflict run added to the task", stored.Usage)
+	}
+	if tracker.State.Usage != result.Usage {
+		t.Fatalf("tracker usage = %+v, want %+v", tracker.State.Usage, result.Usage)
+	}
+}
+
+func TestResolveMergeConflict_SecretScanBlocksResolution(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+	before, _ := gitOutput(origin, "rev-parse", "feature")
+
+	p := &conflictProvider{files: []claude.FileChange{{Path: "app.txt", Content: "remote\nagent\nAKIAABCDEFGHIJKLMNOP\n"}}}
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(p, nil, mockGH).WithSecretScanner(secretscan.New())
+	task := &webhook.Task{Prompt: "change app"}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	var conflict *MergeConflictError
+	if err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, ""); !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want conflict", err)
+	}
+	err := executor.resolveMergeConflict(context.Background(), task, tracker, workdir, "", conflict, &claude.CodeResponse{})
+	var gate *gateError
+	if !errors.As(err, &gate) || !strings.Contains(err.Error(), "Secret scan blocked push") {
+		t.Fatalf("resolveMergeConflict() error = %v, want the secret scan", err)
+	}
+	if after, _ := gitOutput(origin, "rev-parse", "feature"); after != before {
+		t.Fatal("resolution with a secret must not be pushed")
+	}
+	if _, err := os.Stat(filepath.Join(workdir, ".git", "rebase-merge")); !os.IsNotExist(err) {
+		t.Fatal("rebase left in progress")
+	}
+}
+
+func TestResolveMergeConflict_PolicyBlocksResolution(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+	before, _ := gitOutput(origin, "rev-parse", "feature")
+
+	p := &conflictProvider{files: []claude.FileChange{{Path: "app.txt", Content: "remote\nagent\n"}}}
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(p, nil, mockGH).WithPolicy(policy.Policy{Deny: []string{"app.txt"}})
+	task := &webhook.Task{Prompt: "change app"}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	var conflict *MergeConflictError
+	if err := executor.commitAndPush(workdir, "", "feature", "Agent change", false, ""); !errors.As(err, &conflict) {
+		t.Fatalf("commitAndPush() error = %v, want conflict", err)
+	}
+	err := executor.resolveMergeConflict(context.Background(), task, tracker, workdir, "", conflict, &claude.CodeResponse{})
+	var gate *gateError
+	if !errors.As(err, &gate) || !strings.Contains(tracker.State.ErrorDetails, "app.txt") {
+		t.Fatalf("resolveMergeConflict() error = %v (details %q), want the policy violation", err, tracker.State.ErrorDetails)
+	}
+	if after, _ := gitOutput(origin, "rev-parse", "feature"); after != before {
+		t.Fatal("resolution violating the policy must not be pushed")
+	}
+}
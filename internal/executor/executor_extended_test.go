@@ -101,7 +101,7 @@ func TestApplyChanges_ComprehensiveScenarios(t *testing.T) {
 				}
 			}
 
-			err := executor.applyChanges(tmpDir, tt.changes)
+			_, err := executor.applyChanges(tmpDir, tt.changes)
 			if err != nil {
 				t.Errorf("applyChanges() error = %v", err)
 				return
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// opensNewPR reports whether the task results in a new pull request rather
+// than commits on the branch of an existing one.
+func opensNewPR(task *webhook.Task) bool {
+	return !task.IsPR || task.PRState != "open" || forkWithoutEdits(task)
+}
+
+// forkWithoutEdits reports whether the task is on a fork PR the App cannot push to.
+func forkWithoutEdits(task *webhook.Task) bool {
+	return task.IsPR && task.IsFork() && !task.MaintainerCanModify
+}
+
+// prepareForkBranch checks out the head of an open fork PR. The fork is read
+// through the base repository's pull/<n>/head ref, so no fork credentials are
+// needed. With maintainer edits allowed, pushes go to the fork branch; otherwise
+// a new upstream branch is created for a follow-up PR.
+func (e *Executor) prepareForkBranch(workdir string, task *webhook.Task, token string) (string, bool, error) {
+	if strings.Count(task.HeadRepo, "/") != 1 || strings.Contains(task.HeadRepo, "..") {
+		return "", false, fmt.Errorf("invalid fork repository %q", task.HeadRepo)
+	}
+	pullRef := fmt.Sprintf("pull/%d/head", task.Number)
+	if err := runAuthGitCommand(workdir, token, []string{"git", "fetch", remoteURL(task.Repo), pullRef}, false); err != nil {
+		return "", false, err
+	}
+
+	if !task.MaintainerCanModify {
+		branchName, err := e.workingBranchName(task)
+		if err != nil {
+			return "", false, err
+		}
+		log.Printf("PR #%d is from fork %s without maintainer edits, creating upstream branch: %s", task.Number, task.HeadRepo, branchName)
+		e.addLog(task, "info", "Fork %s does not allow maintainer edits, opening a follow-up PR from %s", task.HeadRepo, branchName)
+		if err := runGitCommand(workdir, []string{"git", "checkout", "-b", branchName, "FETCH_HEAD"}, false); err != nil {
+			return "", false, err
+		}
+		return branchName, true, nil
+	}
+
+	branchName := task.PRBranch
+	log.Printf("PR #%d is from fork %s, pushing to its branch: %s", task.Number, task.HeadRepo, branchName)
+	e.addLog(task, "info", "PR is from fork %s, using its branch: %s", task.HeadRepo, branchName)
+
+	if err := runGitCommand(workdir, []string{"git", "checkout", "--detach", "FETCH_HEAD"}, false); err != nil {
+		return "", false, err
+	}
+	return branchName, false, nil
+}
+
+// pushTarget returns the repository the task's branch is pushed to and whether
+// it is the head repository of a fork PR. It only uses task data, never the
+// workspace's remotes, so the token cannot be sent elsewhere.
+func pushTarget(task *webhook.Task) (repo string, fork bool) {
+	if task.IsPR && task.PRState == "open" && task.PRBranch != "" && task.IsFork() && task.MaintainerCanModify {
+		return task.HeadRepo, true
+	}
+	return task.Repo, false
+}
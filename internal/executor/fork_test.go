@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// newForkPR returns a clone of origin (branch main) where PR #9 from a fork is
+// available as refs/pull/9/head, plus the fork's bare repository.
+func newForkPR(t *testing.T) (workdir, fork string) {
+	t.Helper()
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	fork = t.TempDir()
+	runGitIn(t, fork, "init", "-q", "--bare")
+
+	seed := initCommittedRepo(t, map[string]string{"README.md": "base\n"})
+	runGitIn(t, seed, "branch", "-M", "main")
+	runGitIn(t, seed, "push", "-q", origin, "main")
+	runGitIn(t, seed, "checkout", "-q", "-b", "patch-1")
+	writeTestFile(t, seed, "README.md", "contributor\n")
+	runGitIn(t, seed, "commit", "-q", "-am", "contributor change")
+	runGitIn(t, seed, "push", "-q", fork, "patch-1")
+	runGitIn(t, seed, "push", "-q", origin, "patch-1:refs/pull/9/head")
+
+	workdir = t.TempDir()
+	runGitIn(t, workdir, "clone", "-q", "-b", "main", origin, ".")
+	serveGitHubRepo(t, "owner/repo", origin)
+	serveGitHubRepo(t, "contributor/repo", fork)
+	return workdir, fork
+}
+
+func forkTask(canModify bool) *webhook.Task {
+	return &webhook.Task{
+		Repo:                "owner/repo",
+		Number:              9,
+		Branch:              "main",
+		IsPR:                true,
+		PRState:             "open",
+		PRBranch:            "patch-1",
+		HeadRepo:            "contributor/repo",
+		MaintainerCanModify: canModify,
+	}
+}
+
+func TestPrepareBranch_ForkWithMaintainerEdits(t *testing.T) {
+	workdir, forkDir := newForkPR(t)
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+	task := forkTask(true)
+
+	branch, isNew, err := executor.prepareBranch(workdir, task, "")
+	if err != nil {
+		t.Fatalf("prepareBranch() error = %v", err)
+	}
+	if branch != "patch-1" || isNew || opensNewPR(task) {
+		t.Fatalf("prepareBranch() = %q, %v; opensNewPR = %v", branch, isNew, opensNewPR(task))
+	}
+	if subject, _ := gitOutput(workdir, "log", "-1", "--format=%s"); subject != "contributor change" {
+		t.Fatalf("HEAD = %q, want fork PR head", subject)
+	}
+	repo, fork := pushTarget(task)
+	if repo != "contributor/repo" || !fork {
+		t.Fatalf("pushTarget() = %q, %v", repo, fork)
+	}
+
+	writeTestFile(t, workdir, "README.md", "maintainer fix\n")
+	if err := executor.commitLocal(workdir, "Fix typo"); err != nil {
+		t.Fatalf("commitLocal() error = %v", err)
+	}
+	if err := executor.publishBranch(workdir, repo, fork, branch, false, ""); err != nil {
+		t.Fatalf("publishBranch() error = %v", err)
+	}
+	if subject, _ := gitOutput(forkDir, "log", "-1", "--format=%s", "patch-1"); subject != "Fix typo" {
+		t.Fatalf("fork patch-1 = %q, want pushed commit", subject)
+	}
+}
+
+func TestPrepareBranch_ForkWithoutMaintainerEdits(t *testing.T) {
+	workdir, _ := newForkPR(t)
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+	task := forkTask(false)
+
+	branch, isNew, err := executor.prepareBranch(workdir, task, "")
+	if err != nil {
+		t.Fatalf("prepareBranch() error = %v", err)
+	}
+	if branch == "patch-1" || !isNew || !opensNewPR(task) {
+		t.Fatalf("prepareBranch() = %q, %v; want a new upstream branch", branch, isNew)
+	}
+	if subject, _ := gitOutput(workdir, "log", "-1", "--format=%s"); subject != "contributor change" {
+		t.Fatalf("HEAD = %q, want branch based on fork PR head", subject)
+	}
+	if repo, fork := pushTarget(task); repo != "owner/repo" || fork {
+		t.Fatalf("pushTarget() = %q, %v, want owner/repo", repo, fork)
+	}
+}
+
+func TestPrepareBranch_RejectsMalformedForkRepository(t *testing.T) {
+	workdir, _ := newForkPR(t)
+	task := forkTask(true)
+	task.HeadRepo = "../../etc"
+	if _, _, err := NewWithClient(nil, nil, github.NewMockGHClient()).prepareBranch(workdir, task, ""); err == nil {
+		t.Fatal("prepareBranch() should reject malformed fork repositories")
+	}
+}
@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// lifecycleMarker starts the pull request outcome section of a tracking comment.
+const lifecycleMarker = "<!-- swe-lifecycle -->"
+
+// trackingLabel marks issues with work in progress by the agent.
+const trackingLabel = "swe"
+
+// recordPRLink remembers the branch pushed for a task so later pull_request
+// events can be traced back to the issue and its tracking comment.
+func (e *Executor) recordPRLink(task *webhook.Task, tracker *github.CommentTracker, branch string) {
+	if e.store == nil || task.ID == "" {
+		return
+	}
+	link := &taskstore.PRLink{
+		Repo:        task.Repo,
+		Branch:      branch,
+		TaskID:      task.ID,
+		IssueNumber: task.Number,
+		CommentID:   tracker.CommentID,
+	}
+	if tracker.ReplyTo > 0 {
+		link.CommentID = 0 // review thread replies are not issue comments
+	}
+	if !opensNewPR(task) {
+		if _, ok := e.store.FindLink(task.Repo, branch); ok {
+			return // keep the issue and tracking comment of the task that opened the pull request
+		}
+		// Pushed to the pull request the task was started from.
+		link.PRNumber, link.State = task.Number, taskstore.LinkOpen
+	}
+	if err := e.store.SaveLink(link); err != nil {
+		log.Printf("Warning: Failed to record PR link for %s: %v", branch, err)
+		e.addLog(task, "error", "Failed to record PR link for %s: %v", branch, err)
+	}
+}
+
+// PullRequestChanged follows a pull request opened from a task branch: it
+// records the outcome on the task, notes it in the tracking comment and
+// clears the tracking label once the work is merged or abandoned.
+func (e *Executor) PullRequestChanged(update webhook.PullRequestUpdate) {
+	if e.store == nil {
+		return
+	}
+	e.lifecycleMu.Lock()
+	defer e.lifecycleMu.Unlock()
+
+	link, ok := e.store.FindLink(update.Repo, update.Branch)
+	if !ok {
+		return
+	}
+	state := taskstore.LinkOpen
+	switch {
+	case update.Merged:
+		state = taskstore.LinkMerged
+	case update.Action == "closed":
+		state = taskstore.LinkClosed
+	}
+	changed := state != link.State || update.Number != link.PRNumber
+	link.PRNumber, link.State = update.Number, state
+	if update.URL != "" {
+		link.PRURL = update.URL
+	}
+	if err := e.store.SaveLink(link); err != nil {
+		log.Printf("Warning: Failed to update PR link for %s: %v", update.Branch, err)
+		return
+	}
+	if !changed {
+		return // synchronize of a known pull request
+	}
+
+	task := &webhook.Task{ID: link.TaskID, Repo: link.Repo, Number: link.IssueNumber}
+	links := e.store.ListLinks(link.TaskID)
+	status := taskOutcome(links)
+	if current, ok := e.store.Get(link.TaskID); !ok || current.Status == status || !isFinished(current.Status) {
+		status = "" // unchanged, or a retry of the task is still in flight
+	}
+	switch state {
+	case taskstore.LinkMerged:
+		e.addLog(task, "success", "Pull request #%d merged", update.Number)
+	case taskstore.LinkClosed:
+		e.addLog(task, "info", "Pull request #%d closed without merging", update.Number)
+	default:
+		e.addLog(task, "info", "Pull request #%d opened from %s", update.Number, update.Branch)
+	}
+	if status != "" {
+		e.updateStatus(task, status)
+	}
+
+	installToken, err := e.appAuth.GetInstallationToken(link.Repo)
+	if err != nil {
+		log.Printf("Warning: Failed to authenticate for PR #%d: %v", update.Number, err)
+		e.addLog(task, "error", "Failed to authenticate for PR #%d: %v", update.Number, err)
+		return
+	}
+	token := installToken.Token
+
+	if link.CommentID > 0 {
+		if err := e.noteOutcome(link, links, token); err != nil {
+			log.Printf("Warning: Failed to update tracking comment: %v", err)
+			e.addLog(task, "error", "Failed to update tracking comment: %v", err)
+		}
+	}
+
+	var labelErr error
+	switch status {
+	case taskstore.StatusMerged, taskstore.StatusAbandoned:
+		labelErr = e.ghClient.RemoveLabel(link.Repo, link.IssueNumber, trackingLabel, token)
+	case taskstore.StatusCompleted:
+		labelErr = e.ghClient.AddLabel(link.Repo, link.IssueNumber, trackingLabel, token)
+	}
+	if labelErr != nil {
+		log.Printf("Warning: Failed to update %s label: %v", trackingLabel, labelErr)
+		e.addLog(task, "error", "Failed to update %s label: %v", trackingLabel, labelErr)
+	}
+}
+
+// isFinished reports whether the task produced its pull requests.
+func isFinished(status taskstore.TaskStatus) bool {
+	switch status {
+	case taskstore.StatusCompleted, taskstore.StatusMerged, taskstore.StatusAbandoned:
+		return true
+	}
+	return false
+}
+
+// taskOutcome derives the task status from the pull requests of all its
+// branches: merged once any is merged, abandoned once all are closed, and
+// completed while one is still open. "" leaves the status unchanged.
+func taskOutcome(links []*taskstore.PRLink) taskstore.TaskStatus {
+	open, closed := 0, 0
+	for _, l := range links {
+		switch l.State {
+		case taskstore.LinkMerged:
+			return taskstore.StatusMerged
+		case taskstore.LinkOpen:
+			open++
+		case taskstore.LinkClosed:
+			closed++
+		}
+	}
+	switch {
+	case open > 0:
+		return taskstore.StatusCompleted
+	case closed > 0 && closed == len(links):
+		return taskstore.StatusAbandoned
+	}
+	return ""
+}
+
+// noteOutcome rewrites the outcome section at the end of the tracking comment.
+func (e *Executor) noteOutcome(link *taskstore.PRLink, links []*taskstore.PRLink, token string) error {
+	body, err := e.ghClient.GetCommentBody(link.Repo, link.CommentID, token)
+	if err != nil {
+		return err
+	}
+	if i := strings.Index(body, lifecycleMarker); i >= 0 {
+		body = strings.TrimRight(body[:i], "\n")
+	}
+
+	var lines []string
+	for _, l := range links {
+		switch l.State {
+		case taskstore.LinkMerged:
+			lines = append(lines, fmt.Sprintf("🎉 Merged in #%d", l.PRNumber))
+		case taskstore.LinkClosed:
+			lines = append(lines, fmt.Sprintf("🚫 #%d was closed without merging", l.PRNumber))
+		case taskstore.LinkOpen:
+			lines = append(lines, fmt.Sprintf("🔗 Pull request #%d is open", l.PRNumber))
+		}
+	}
+	if len(lines) > 0 {
+		body += "\n\n" + lifecycleMarker + "\n---\n" + strings.Join(lines, "\n")
+	}
+	return e.ghClient.UpdateComment(link.Repo, link.CommentID, body, token)
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func newLifecycleExecutor(t *testing.T, branches ...string) (*Executor, *taskstore.Store, *github.MockGHClient, *string) {
+	t.Helper()
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Create(&taskstore.Task{ID: "task-1", Title: "t", Status: taskstore.StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	body := "✅ Done"
+	gh := github.NewMockGHClient()
+	gh.GetCommentBodyFunc = func(repo string, commentID int, token string) (string, error) { return body, nil }
+	gh.UpdateCommentFunc = func(repo string, commentID int, b, token string) error {
+		body = b
+		return nil
+	}
+
+	e := NewWithClient(nil, &mockAppAuth{}, gh).WithStore(store)
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7}
+	tracker := &github.CommentTracker{CommentID: 55}
+	for _, b := range branches {
+		e.recordPRLink(task, tracker, b)
+	}
+	return e, store, gh, &body
+}
+
+func taskStatus(t *testing.T, store *taskstore.Store) taskstore.TaskStatus {
+	t.Helper()
+	task, ok := store.Get("task-1")
+	if !ok {
+		t.Fatal("task not found")
+	}
+	return task.Status
+}
+
+func TestPullRequestChanged_Merged(t *testing.T) {
+	e, store, gh, body := newLifecycleExecutor(t, "swe/issue-7")
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 12, Branch: "swe/issue-7", Action: "opened"})
+	if !strings.HasSuffix(*body, "🔗 Pull request #12 is open") {
+		t.Fatalf("comment after open = %q", *body)
+	}
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 12, URL: "https://github.com/owner/repo/pull/12", Branch: "swe/issue-7", Action: "closed", Merged: true})
+	if got := taskStatus(t, store); got != taskstore.StatusMerged {
+		t.Fatalf("status = %q, want merged", got)
+	}
+	if want := "✅ Done\n\n" + lifecycleMarker + "\n---\n🎉 Merged in #12"; *body != want {
+		t.Fatalf("comment = %q, want %q", *body, want)
+	}
+	if len(gh.RemoveLabelCalls) != 1 || gh.RemoveLabelCalls[0].Number != 7 || gh.RemoveLabelCalls[0].Label != "swe" {
+		t.Fatalf("RemoveLabel calls = %+v", gh.RemoveLabelCalls)
+	}
+	if link, _ := store.FindLink("owner/repo", "swe/issue-7"); link.PRURL != "https://github.com/owner/repo/pull/12" {
+		t.Fatalf("link = %+v", link)
+	}
+
+	updates := len(gh.UpdateCommentCalls)
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 12, Branch: "swe/issue-7", Action: "closed", Merged: true})
+	if len(gh.UpdateCommentCalls) != updates {
+		t.Fatal("redelivered event should not update the comment again")
+	}
+}
+
+func TestPullRequestChanged_AbandonedAfterAllClosed(t *testing.T) {
+	e, store, gh, body := newLifecycleExecutor(t, "swe/docs-7", "swe/code-7")
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 20, Branch: "swe/docs-7", Action: "opened"})
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 21, Branch: "swe/code-7", Action: "opened"})
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 20, Branch: "swe/docs-7", Action: "closed"})
+	if got := taskStatus(t, store); got != taskstore.StatusCompleted {
+		t.Fatalf("status = %q, want completed while #21 is open", got)
+	}
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 21, Branch: "swe/code-7", Action: "closed"})
+	if got := taskStatus(t, store); got != taskstore.StatusAbandoned {
+		t.Fatalf("status = %q, want abandoned", got)
+	}
+	if !strings.Contains(*body, "🚫 #21 was closed without merging\n🚫 #20 was closed without merging") || strings.Count(*body, lifecycleMarker) != 1 {
+		t.Fatalf("comment = %q", *body)
+	}
+	if len(gh.RemoveLabelCalls) != 1 {
+		t.Fatalf("RemoveLabel calls = %d, want 1", len(gh.RemoveLabelCalls))
+	}
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 21, Branch: "swe/code-7", Action: "reopened"})
+	if got := taskStatus(t, store); got != taskstore.StatusCompleted {
+		t.Fatalf("status = %q, want completed after reopen", got)
+	}
+	if n := len(gh.AddLabelCalls); n != 1 || gh.AddLabelCalls[0].Label != "swe" {
+		t.Fatalf("AddLabel calls = %+v, want swe label restored", gh.AddLabelCalls)
+	}
+}
+
+func TestPullRequestChanged_IgnoresUnknownBranch(t *testing.T) {
+	e, store, gh, _ := newLifecycleExecutor(t, "swe/issue-7")
+
+	e.PullRequestChanged(webhook.PullRequestUpdate{Repo: "owner/repo", Number: 3, Branch: "feature/manual", Action: "closed", Merged: true})
+	if got := taskStatus(t, store); got != taskstore.StatusCompleted {
+		t.Fatalf("status = %q, want unchanged", got)
+	}
+	if len(gh.UpdateCommentCalls)+len(gh.RemoveLabelCalls) != 0 {
+		t.Fatal("unrelated pull requests must not touch the issue")
+	}
+}
+
+func TestRecordPRLink_KeepsLinkOfOpeningTask(t *testing.T) {
+	e, store, _, _ := newLifecycleExecutor(t, "swe/issue-7")
+
+	followup := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 12, IsPR: true, PRState: "open", PRBranch: "swe/issue-7"}
+	e.recordPRLink(followup, &github.CommentTracker{CommentID: 99}, "swe/issue-7")
+
+	link, ok := store.FindLink("owner/repo", "swe/issue-7")
+	if !ok || link.IssueNumber != 7 || link.CommentID != 55 {
+		t.Fatalf("link = %+v, want issue #7 and its tracking comment", link)
+	}
+}
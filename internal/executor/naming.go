@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// WithNamingTemplates sets the server-wide templates for branches, commits and PRs.
+// Repository templates from .swe-agent.yml take precedence field by field.
+func (e *Executor) WithNamingTemplates(templates naming.Templates) *Executor {
+	e.namingTemplates = templates
+	return e
+}
+
+func (e *Executor) templatesFor(task *webhook.Task) naming.Templates {
+	return e.namingTemplates.Merge(task.RepoConfig.Templates())
+}
+
+func namingData(task *webhook.Task, summary, category string) naming.Data {
+	data := naming.NewData(task.Repo, task.Number, task.IsPR, task.IssueTitle, task.Username, time.Now())
+	data.Summary = strings.TrimSpace(summary)
+	data.Category = category
+	return data
+}
+
+// workingBranchName returns the branch for a new change, validated against git ref rules.
+func (e *Executor) workingBranchName(task *webhook.Task) (string, error) {
+	templates := e.templatesFor(task)
+	if templates.Branch == "" {
+		return e.generateWorkingBranchName(task), nil
+	}
+	return naming.RenderUniqueBranch(templates.Branch, namingData(task, "", ""))
+}
+
+// subPRBranchName returns a branch for one sub-PR of a split plan. When the branch
+// template does not use the category, it is appended so sub-PR branches stay unique.
+func (e *Executor) subPRBranchName(task *webhook.Task, category string) (string, error) {
+	templates := e.templatesFor(task)
+	if templates.Branch == "" {
+		return generateSubPRBranchName(task.Number, category), nil
+	}
+
+	name, err := naming.RenderUniqueBranch(templates.Branch, namingData(task, "", category))
+	if err != nil {
+		return "", err
+	}
+	if segment := sanitizeBranchSegment(category); segment != "" && !strings.Contains(name, segment) {
+		name += "-" + segment
+		if err := naming.ValidateBranchName(name); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// commitMessage renders the commit template, falling back to the built-in format.
+func (e *Executor) commitMessage(summary string, task *webhook.Task, category string) string {
+	templates := e.templatesFor(task)
+	if templates.Commit == "" {
+		return e.formatCommitMessage(summary, task)
+	}
+
+	message, err := naming.Render("commit", templates.Commit, namingData(task, summary, category))
+	if err != nil || strings.TrimSpace(message) == "" {
+		log.Printf("Warning: commit template failed (%v), using default commit message", err)
+		e.addLog(task, "error", "Commit template failed, using default message: %v", err)
+		return e.formatCommitMessage(summary, task)
+	}
+	return strings.TrimSpace(message)
+}
+
+// pullRequestText renders the PR title and body templates. Without templates the
+// summary is used for both, matching the compare-link defaults.
+func (e *Executor) pullRequestText(task *webhook.Task, summary, category string) (string, string) {
+	templates := e.templatesFor(task)
+	data := namingData(task, summary, category)
+
+	title := summary
+	if templates.PRTitle != "" {
+		if rendered, err := naming.Render("pr_title", templates.PRTitle, data); err == nil && strings.TrimSpace(rendered) != "" {
+			title = strings.TrimSpace(rendered)
+		} else {
+			log.Printf("Warning: PR title template failed (%v), using summary", err)
+		}
+	}
+
+	body := title
+	if templates.PRBody != "" {
+		if rendered, err := naming.Render("pr_body", templates.PRBody, data); err == nil {
+			body = rendered
+		} else {
+			log.Printf("Warning: PR body template failed (%v), using title", err)
+		}
+	}
+	return title, body
+}
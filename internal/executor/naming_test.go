@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/repoconfig"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func namingTask(cfg *repoconfig.Config) *webhook.Task {
+	return &webhook.Task{Repo: "owner/repo", Number: 42, Username: "alice", IssueTitle: "Login fails on Safari", RepoConfig: cfg}
+}
+
+func TestWorkingBranchName_Templates(t *testing.T) {
+	executor := NewWithClient(nil, nil, github.NewMockGHClient()).
+		WithNamingTemplates(naming.Templates{Branch: "swe/{{.User}}-{{.Number}}"})
+
+	// Templates without a per-task value get the timestamp, so retries do
+	// not collide with the branch of an earlier attempt.
+	got, err := executor.workingBranchName(namingTask(nil))
+	if err != nil || !regexp.MustCompile(`^swe/alice-42-\d+$`).MatchString(got) {
+		t.Fatalf("workingBranchName() = %q, %v, want server template", got, err)
+	}
+
+	task := namingTask(&repoconfig.Config{BranchTemplate: "feature/JIRA-{{.Number}}-{{.TitleSlug}}"})
+	got, err = executor.workingBranchName(task)
+	if err != nil || !regexp.MustCompile(`^feature/JIRA-42-login-fails-on-safari-\d+$`).MatchString(got) {
+		t.Fatalf("workingBranchName() = %q, %v, want repository template", got, err)
+	}
+
+	task.RepoConfig.BranchTemplate = "feature/{{.Title}}"
+	_, err = executor.workingBranchName(task)
+	if err == nil || !strings.Contains(err.Error(), "invalid branch name") {
+		t.Fatalf("workingBranchName() error = %v, want invalid branch name", err)
+	}
+	if !isNonRetryableTaskError(err.Error()) {
+		t.Fatal("invalid branch name should be non-retryable")
+	}
+
+	got, err = NewWithClient(nil, nil, github.NewMockGHClient()).workingBranchName(namingTask(nil))
+	if err != nil || !strings.HasPrefix(got, "swe/issue-42-") {
+		t.Fatalf("workingBranchName() = %q, %v, want default format", got, err)
+	}
+}
+
+func TestSubPRBranchName_AppendsCategory(t *testing.T) {
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+
+	task := namingTask(&repoconfig.Config{BranchTemplate: "feature/{{.Number}}"})
+	if got, err := executor.subPRBranchName(task, "Backend"); err != nil || !regexp.MustCompile(`^feature/42-\d+-backend$`).MatchString(got) {
+		t.Fatalf("subPRBranchName() = %q, %v", got, err)
+	}
+
+	task.RepoConfig.BranchTemplate = "feature/{{.Number}}-{{lower .Category}}"
+	if got, err := executor.subPRBranchName(task, "Backend"); err != nil || !regexp.MustCompile(`^feature/42-backend-\d+$`).MatchString(got) {
+		t.Fatalf("subPRBranchName() = %q, %v, want category used once", got, err)
+	}
+
+	if got, err := executor.subPRBranchName(namingTask(nil), "docs"); err != nil || !strings.HasPrefix(got, "swe/docs-42-") {
+		t.Fatalf("subPRBranchName() = %q, %v, want default format", got, err)
+	}
+}
+
+func TestCommitMessageAndPRText_Templates(t *testing.T) {
+	executor := NewWithClient(nil, nil, github.NewMockGHClient()).
+		WithNamingTemplates(naming.Templates{PRTitle: "[{{.Number}}] {{firstLine .Summary}}"})
+	task := namingTask(&repoconfig.Config{
+		CommitTemplate: "fix: {{firstLine .Summary}}\n\nRefs #{{.Number}}",
+		PRBodyTemplate: "Closes #{{.Number}}\n\n{{.Summary}}",
+	})
+
+	if got := executor.commitMessage("Handle Safari cookies\n\nMore detail", task, ""); got != "fix: Handle Safari cookies\n\nRefs #42" {
+		t.Fatalf("commitMessage() = %q", got)
+	}
+
+	title, body := executor.pullRequestText(task, "Handle Safari cookies", "")
+	if title != "[42] Handle Safari cookies" || body != "Closes #42\n\nHandle Safari cookies" {
+		t.Fatalf("pullRequestText() = %q, %q", title, body)
+	}
+
+	link, err := executor.createPRLinkWithBody(task.Repo, "feature/x", "main", title, body)
+	if err != nil {
+		t.Fatalf("createPRLinkWithBody() error = %v", err)
+	}
+	parsed, _ := url.Parse(link)
+	if parsed.Query().Get("title") != title || parsed.Query().Get("body") != body {
+		t.Fatalf("createPRLinkWithBody() = %s", link)
+	}
+
+	task.RepoConfig.CommitTemplate = "{{.Missing}}"
+	if got := executor.commitMessage("Handle Safari cookies", task, ""); !strings.HasPrefix(got, "Handle Safari cookies") {
+		t.Fatalf("commitMessage() = %q, want default fallback", got)
+	}
+}
+
+func TestPullRequestText_Defaults(t *testing.T) {
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+	title, body := executor.pullRequestText(namingTask(nil), "Summary line", "")
+	if title != "Summary line" || body != "Summary line" {
+		t.Fatalf("pullRequestText() = %q, %q, want summary for both", title, body)
+	}
+}
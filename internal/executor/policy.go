@@ -0,0 +1,221 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// binarySniffBytes mirrors git's heuristic: a NUL in the first 8000 bytes marks a binary file.
+const binarySniffBytes = 8000
+
+// WithPolicy sets the change policy used when a repository does not define one.
+func (e *Executor) WithPolicy(p policy.Policy) *Executor {
+	e.changePolicy = p
+	return e
+}
+
+// enforcePolicy checks the working tree against the change policy.
+// In revert mode violating files are restored and their paths returned;
+// otherwise any violation fails the task.
+func (e *Executor) enforcePolicy(task *webhook.Task, workdir string, tracker *github.CommentTracker, token string) ([]string, error) {
+	p, err := e.loadPolicy(task, workdir, token)
+	if err != nil {
+		return nil, e.handleError(task, tracker, token, fmt.Sprintf("Policy violation: %v", err))
+	}
+
+	changes, err := collectPolicyChanges(workdir)
+	if err != nil {
+		return nil, e.handleError(task, tracker, token, fmt.Sprintf("Failed to check change policy: %v", err))
+	}
+
+	result := p.Evaluate(changes)
+	if result.OK() {
+		e.addLog(task, "info", "Change policy passed (%d files checked)", len(changes))
+		return nil, nil
+	}
+
+	log.Printf("Change policy found %d violation(s) in %s#%d", len(result.Files)+len(result.Limits), task.Repo, task.Number)
+
+	if p.Mode() != policy.ModeRevert || len(result.Limits) > 0 {
+		return nil, e.handleError(task, tracker, token, result.Error())
+	}
+
+	for _, v := range result.Files {
+		if err := revertPath(workdir, v.Path); err != nil {
+			return nil, e.handleError(task, tracker, token, fmt.Sprintf("Failed to revert %s after policy violation: %v", v.Path, err))
+		}
+		e.addLog(task, "hint", "Reverted %s", v)
+		tracker.AddNote(fmt.Sprintf("Reverted `%s`: %s", v.Path, v.Reason))
+	}
+
+	return result.Paths(), nil
+}
+
+// loadPolicy reads the repository policy from the default branch, falling back
+// to the executor default. A pull request cannot relax the policy it is checked against.
+func (e *Executor) loadPolicy(task *webhook.Task, workdir, token string) (policy.Policy, error) {
+	ref, err := defaultBranchRef(task, workdir, token)
+	if err != nil {
+		return policy.Policy{}, fmt.Errorf("cannot read %s: %w", policy.Path, err)
+	}
+	cmd := gitCommand(workdir, "", "show", ref+":"+policy.Path)
+	output, err := cmd.Output()
+	if err != nil {
+		return e.changePolicy, nil
+	}
+	p, err := policy.Parse(output)
+	if err != nil {
+		return policy.Policy{}, fmt.Errorf("invalid %s: %w", policy.Path, err)
+	}
+	return p, nil
+}
+
+// collectPolicyChanges lists every changed path, including deletions, with line counts.
+func collectPolicyChanges(workdir string) ([]policy.Change, error) {
+	cmd := gitCommand(workdir, "", "status", "--porcelain", "-z", "--untracked-files=all")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w", err)
+	}
+
+	stats, err := diffNumstat(workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []policy.Change
+	add := func(path string, deleted bool) {
+		change := policy.Change{Path: path, Deleted: deleted}
+		if stat, ok := stats[path]; ok {
+			change.Lines, change.Binary = stat.Lines, stat.Binary
+		} else if !deleted {
+			change.Lines, change.Binary = countFileLines(filepath.Join(workdir, path))
+		}
+		changes = append(changes, change)
+	}
+
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		code, path := entry[:2], entry[3:]
+		add(path, strings.Contains(code, "D"))
+		// Renames and copies are followed by the original path.
+		if (code[0] == 'R' || code[0] == 'C') && i+1 < len(entries) {
+			i++
+			if code[0] == 'R' {
+				add(entries[i], true)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// diffNumstat returns line statistics for tracked changes keyed by path.
+func diffNumstat(workdir string) (map[string]policy.Change, error) {
+	stats := make(map[string]policy.Change)
+	if !hasHeadCommit(workdir) {
+		return stats, nil
+	}
+
+	cmd := gitCommand(workdir, "", "diff", "HEAD", "--numstat", "--no-renames", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --numstat failed: %w", err)
+	}
+
+	for _, record := range strings.Split(string(output), "\x00") {
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "-" {
+			stats[fields[2]] = policy.Change{Path: fields[2], Binary: true}
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		removed, _ := strconv.Atoi(fields[1])
+		stats[fields[2]] = policy.Change{Path: fields[2], Lines: added + removed}
+	}
+	return stats, nil
+}
+
+// countFileLines counts lines in an untracked file and reports whether it looks
+// binary. The file is read in chunks, so large files are not held in memory.
+func countFileLines(path string) (int, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	lines, read := 0, 0
+	var last byte
+	for {
+		n, err := file.Read(buf)
+		chunk := buf[:n]
+		if read < binarySniffBytes && bytes.IndexByte(chunk[:min(n, binarySniffBytes-read)], 0) != -1 {
+			return 0, true
+		}
+		read += n
+		lines += bytes.Count(chunk, []byte("\n"))
+		if n > 0 {
+			last = chunk[n-1]
+		}
+		if err != nil {
+			break
+		}
+	}
+	if read > 0 && last != '\n' {
+		lines++
+	}
+	return lines, false
+}
+
+// revertPath restores a path to its base commit state, removing it if it is new.
+func revertPath(workdir, path string) error {
+	cmd := gitCommand(workdir, "", "cat-file", "-e", "HEAD:"+path)
+	if cmd.Run() == nil {
+		return runGitCommand(workdir, []string{"git", "checkout", "HEAD", "--", path}, false)
+	}
+
+	if err := runGitCommand(workdir, []string{"git", "rm", "-q", "--cached", "--ignore-unmatch", "--", path}, false); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(workdir, path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// dropRevertedFiles removes reverted paths from the provider's reported file list.
+func dropRevertedFiles(files []claude.FileChange, reverted []string) []claude.FileChange {
+	if len(reverted) == 0 {
+		return files
+	}
+	skip := make(map[string]bool, len(reverted))
+	for _, path := range reverted {
+		skip[path] = true
+	}
+	kept := files[:0:0]
+	for _, f := range files {
+		if !skip[filepath.ToSlash(filepath.Clean(f.Path))] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
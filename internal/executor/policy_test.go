@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestEnforcePolicy_DefaultFailsOnWorkflowChange(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{
+		".github/workflows/ci.yml": "on: push\n",
+		"main.go":                  "package main\n",
+	})
+	writeTestFile(t, workdir, ".github/workflows/ci.yml", "on: pull_request\n")
+	writeTestFile(t, workdir, "main.go", "package main\n\nfunc main() {}\n")
+
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(nil, nil, mockGH)
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	_, err := executor.enforcePolicy(&webhook.Task{Repo: "owner/repo", Number: 1}, workdir, tracker, "")
+	if err == nil {
+		t.Fatal("enforcePolicy() error = nil, want violation")
+	}
+	if !IsNonRetryable(err) {
+		t.Fatalf("enforcePolicy() error = %v, want non-retryable", err)
+	}
+	if !strings.Contains(tracker.State.ErrorDetails, `.github/workflows/ci.yml: path matches denied pattern ".github/workflows/**"`) {
+		t.Fatalf("ErrorDetails = %q, want workflow explanation", tracker.State.ErrorDetails)
+	}
+}
+
+func TestEnforcePolicy_RevertModeFromBaseCommit(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{
+		policy.Path:     `{"deny": ["vendor/**"], "forbid_binary": true, "on_violation": "revert"}`,
+		"vendor/lib.go": "package lib\n",
+		"main.go":       "package main\n",
+	})
+	writeTestFile(t, workdir, "vendor/lib.go", "package lib // edited\n")
+	writeTestFile(t, workdir, "assets/logo.png", "\x89PNG\x00\x01")
+	writeTestFile(t, workdir, "main.go", "package main\n\nfunc main() {}\n")
+	// Relaxing the policy in the working tree must have no effect.
+	writeTestFile(t, workdir, policy.Path, `{"deny": []}`)
+
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(nil, nil, mockGH)
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	reverted, err := executor.enforcePolicy(&webhook.Task{Repo: "owner/repo", Number: 1}, workdir, tracker, "")
+	if err != nil {
+		t.Fatalf("enforcePolicy() error = %v", err)
+	}
+	if got := strings.Join(reverted, ","); got != "assets/logo.png,vendor/lib.go" {
+		t.Fatalf("reverted = %s", got)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(workdir, "vendor/lib.go"))
+	if string(content) != "package lib\n" {
+		t.Fatalf("vendor/lib.go = %q, want restored", content)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "assets/logo.png")); !os.IsNotExist(err) {
+		t.Fatalf("assets/logo.png should be removed, stat err = %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(workdir, "main.go"))
+	if !strings.Contains(string(content), "func main") {
+		t.Fatal("main.go change should be kept")
+	}
+	if len(tracker.State.Notes) != 2 {
+		t.Fatalf("Notes = %v, want one note per reverted file", tracker.State.Notes)
+	}
+}
+
+func TestEnforcePolicy_PullRequestCannotRelaxPolicy(t *testing.T) {
+	origin := initCommittedRepo(t, map[string]string{
+		policy.Path:     `{"deny": ["vendor/**"]}`,
+		"vendor/lib.go": "package lib\n",
+	})
+	runGitIn(t, origin, "branch", "-M", "main")
+	runGitIn(t, origin, "checkout", "-q", "-b", "feature")
+	writeTestFile(t, origin, policy.Path, `{"deny": []}`)
+	runGitIn(t, origin, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-am", "relax policy")
+	runGitIn(t, origin, "checkout", "-q", "main")
+
+	// The PR head is checked out on top of a clone of the base branch.
+	workdir := t.TempDir()
+	runGitIn(t, workdir, "clone", "-q", "--branch", "main", origin, ".")
+	runGitIn(t, workdir, "fetch", "-q", "origin", "feature")
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature", "FETCH_HEAD")
+	writeTestFile(t, workdir, "vendor/lib.go", "package lib // edited\n")
+
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(nil, nil, mockGH)
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	task := &webhook.Task{Repo: "owner/repo", Number: 1, IsPR: true, Branch: "main", DefaultBranch: "main", PRBranch: "feature"}
+	if _, err := executor.enforcePolicy(task, workdir, tracker, ""); err == nil {
+		t.Fatal("enforcePolicy() error = nil, want the default branch policy to deny vendor/**")
+	}
+	if !strings.Contains(tracker.State.ErrorDetails, `vendor/lib.go: path matches denied pattern "vendor/**"`) {
+		t.Fatalf("ErrorDetails = %q, want vendor explanation", tracker.State.ErrorDetails)
+	}
+}
+
+func TestEnforcePolicy_LimitsFailEvenInRevertMode(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{
+		policy.Path: `{"max_lines": 2, "on_violation": "revert"}`,
+		"old.txt":   "a\nb\nc\n",
+	})
+	if err := os.Remove(filepath.Join(workdir, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	mockGH := github.NewMockGHClient()
+	executor := NewWithClient(nil, nil, mockGH)
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", mockGH)
+
+	_, err := executor.enforcePolicy(&webhook.Task{}, workdir, tracker, "")
+	if err == nil || !strings.Contains(err.Error(), "3 lines changed, limit is 2") {
+		t.Fatalf("enforcePolicy() error = %v, want line limit violation", err)
+	}
+}
+
+func TestEnforcePolicy_InvalidRepoPolicyFailsClosed(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{policy.Path: `{"on_violation": "ignore"}`})
+	writeTestFile(t, workdir, "a.txt", "a\n")
+
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 1, "tester", github.NewMockGHClient())
+
+	_, err := executor.enforcePolicy(&webhook.Task{}, workdir, tracker, "")
+	if err == nil || !strings.Contains(err.Error(), "invalid "+policy.Path) {
+		t.Fatalf("enforcePolicy() error = %v, want invalid policy error", err)
+	}
+}
+
+func TestDropRevertedFiles(t *testing.T) {
+	files := []claude.FileChange{{Path: "./a.go"}, {Path: "b.go"}}
+	kept := dropRevertedFiles(files, []string{"a.go"})
+	if len(kept) != 1 || kept[0].Path != "b.go" {
+		t.Fatalf("dropRevertedFiles() = %v, want only b.go", kept)
+	}
+	if len(files) != 2 {
+		t.Fatal("dropRevertedFiles() must not modify the input slice")
+	}
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// progressInterval is the minimum time between tracking comment updates
+// caused by provider progress; agents emit many events per minute and
+// GitHub rate limits comment edits.
+var progressInterval = 10 * time.Second
+
+// progressReporter applies provider progress events to the tracker and
+// updates the tracking comment at most once per interval. Events arrive on
+// the goroutine reading the provider output, so updates run on a timer. The
+// comment is rendered under mu and posted under posting, so that events are
+// not held up by GitHub.
+type progressReporter struct {
+	mu       sync.Mutex
+	posting  sync.Mutex
+	tracker  *github.CommentTracker
+	token    string
+	redact   func(string) string // applied to event text before it is shown
+	interval time.Duration
+	last     time.Time
+	timer    *time.Timer
+	stopped  bool
+}
+
+func newProgressReporter(tracker *github.CommentTracker, token string, redact func(string) string) *progressReporter {
+	return &progressReporter{tracker: tracker, token: token, redact: redact, interval: progressInterval}
+}
+
+// report is the CodeRequest.Progress callback. Tool details, paths and todo
+// texts come from the agent, so commands with credentials in them are
+// redacted before they reach the comment.
+func (r *progressReporter) report(ev claude.ProgressEvent) {
+	ev.Detail, ev.Path = r.redact(ev.Detail), r.redact(ev.Path)
+	if len(ev.Todos) > 0 {
+		todos := make([]claude.TodoItem, len(ev.Todos))
+		for i, todo := range ev.Todos {
+			todos[i] = claude.TodoItem{Text: r.redact(todo.Text), Status: todo.Status}
+		}
+		ev.Todos = todos
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	r.tracker.RecordProgress(ev)
+	if r.timer == nil {
+		r.timer = time.AfterFunc(max(0, r.interval-time.Since(r.last)), r.flush)
+	}
+}
+
+func (r *progressReporter) flush() {
+	r.posting.Lock()
+	defer r.posting.Unlock()
+
+	r.mu.Lock()
+	r.timer = nil
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	r.last = time.Now()
+	body := r.tracker.Render()
+	r.mu.Unlock()
+
+	if err := r.tracker.Publish(body, r.token); err != nil {
+		log.Printf("Warning: Failed to update progress: %v", err)
+	}
+}
+
+// stop cancels a pending update and waits for one being posted, so that it
+// cannot overwrite a later regular update. The progress stays in the tracker
+// state and is rendered by the next regular update.
+func (r *progressReporter) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.mu.Unlock()
+
+	r.posting.Lock()
+	defer r.posting.Unlock()
+}
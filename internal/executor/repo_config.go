@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/repoconfig"
+	"github.com/cexll/swe/internal/sandbox"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+const (
+	// verifyTimeout bounds each repository verification command.
+	verifyTimeout = 10 * time.Minute
+	// verifyOutputLimit is how much command output is kept for the error report.
+	verifyOutputLimit = 2000
+)
+
+// WithSandbox runs repository verification commands inside sb. A nil sandbox
+// only scrubs their environment.
+func (e *Executor) WithSandbox(sb *sandbox.Sandbox) *Executor {
+	e.sandbox = sb
+	return e
+}
+
+// ProviderFactory builds a provider for a repository-selected name and model.
+type ProviderFactory func(name, model string) (provider.Provider, error)
+
+// WithProviderFactory enables per-repository provider and model overrides.
+func (e *Executor) WithProviderFactory(factory ProviderFactory) *Executor {
+	e.providerFactory = factory
+	return e
+}
+
+// loadRepoConfig reads .swe-agent.yml from the default branch of the fresh clone
+// and stores it on the task. Invalid files fail the task so the error is reported on the issue.
+// So does an unreadable default branch: running with server defaults would drop
+// the repository's disallowed tools and verification commands.
+func (e *Executor) loadRepoConfig(task *webhook.Task, workdir string, contextMap map[string]string, token string) error {
+	task.RepoConfig = nil
+
+	ref, err := defaultBranchRef(task, workdir, token)
+	if err != nil {
+		return fmt.Errorf("cannot read %s from %s: %w", repoconfig.Path, task.DefaultBranch, err)
+	}
+
+	cmd := gitCommand(workdir, "", "show", ref+":"+repoconfig.Path)
+	output, err := cmd.Output()
+	if err != nil {
+		// No config file on the default branch
+		return nil
+	}
+
+	cfg, err := repoconfig.Parse(output)
+	if err != nil {
+		return fmt.Errorf("invalid repository configuration: %w", err)
+	}
+
+	task.RepoConfig = cfg
+	if tools := mergeDisallowedTools(contextMap["disallowed_tools"], cfg.DisallowedTools); tools != "" {
+		contextMap["disallowed_tools"] = tools
+	}
+
+	log.Printf("Loaded %s for %s", repoconfig.Path, task.Repo)
+	e.addLog(task, "info", "Loaded repository configuration from %s", repoconfig.Path)
+	return nil
+}
+
+// defaultBranchRef returns a ref to the tip of the task's default branch, where
+// the files that configure and guard the agent are read. HEAD is only used when
+// the default branch is unknown: for pull requests and resumed tasks it is the
+// task's own branch, which could relax them.
+func defaultBranchRef(task *webhook.Task, workdir, token string) (string, error) {
+	if task.DefaultBranch == "" {
+		return "HEAD", nil
+	}
+
+	// Workspace cache worktrees and clones of the default branch already have
+	// it; a shallow fetch in a worktree would truncate the shared mirror's history.
+	ref := "refs/remotes/origin/" + task.DefaultBranch
+	if err := gitCommand(workdir, "", "rev-parse", "--verify", "-q", ref).Run(); err == nil {
+		return ref, nil
+	}
+	cmd := gitCommand(workdir, token, "fetch", "--depth=1", remoteURL(task.Repo), task.DefaultBranch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to fetch default branch %s: %w\n%s", task.DefaultBranch, err, output)
+	}
+	return "FETCH_HEAD", nil
+}
+
+// mergeDisallowedTools appends repository tools to the server list without duplicates.
+func mergeDisallowedTools(server string, repo []string) string {
+	var tools []string
+	seen := make(map[string]bool)
+	for _, tool := range append(strings.Split(server, ","), repo...) {
+		tool = strings.TrimSpace(tool)
+		if tool == "" || seen[tool] {
+			continue
+		}
+		seen[tool] = true
+		tools = append(tools, tool)
+	}
+	return strings.Join(tools, ",")
+}
+
+// providerFor returns the provider selected by the repository config, or the default provider.
+func (e *Executor) providerFor(task *webhook.Task) (provider.Provider, error) {
+	cfg := task.RepoConfig
+	if cfg == nil || (cfg.Provider == "" && cfg.Model == "") {
+		return e.provider, nil
+	}
+
+	name := cfg.Provider
+	if name == "" {
+		name = e.provider.Name()
+	}
+	if e.providerFactory == nil {
+		return nil, fmt.Errorf("invalid repository configuration: provider override %s (model %q) is not enabled on this server", name, cfg.Model)
+	}
+
+	p, err := e.providerFactory(name, cfg.Model)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository configuration: cannot use provider %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// withRepoInstructions appends repository prompt instructions to the task prompt.
+func withRepoInstructions(prompt string, cfg *repoconfig.Config) string {
+	if cfg == nil || strings.TrimSpace(cfg.PromptInstructions) == "" {
+		return prompt
+	}
+	return strings.TrimRight(prompt, "\n") + "\n\n## Repository Instructions\n\n" + strings.TrimSpace(cfg.PromptInstructions) + "\n"
+}
+
+// runVerifyCommands runs the repository verification commands before commit.
+func (e *Executor) runVerifyCommands(task *webhook.Task, workdir string, tracker *github.CommentTracker, token string) error {
+	if task.RepoConfig == nil || len(task.RepoConfig.Verify) == 0 {
+		return nil
+	}
+
+	var transcript strings.Builder
+	defer func() { e.saveArtifact(task, taskstore.ArtifactVerification, transcript.String(), token) }()
+
+	for _, command := range task.RepoConfig.Verify {
+		log.Printf("Running verification command: %s", command)
+		e.addLog(task, "info", "Running verification: %s", command)
+
+		output, err := e.runVerifyCommand(workdir, command)
+		fmt.Fprintf(&transcript, "$ %s\n%s\n", command, strings.TrimRight(output, "\n"))
+		if err != nil {
+			fmt.Fprintf(&transcript, "[%v]\n", err)
+			return e.handleError(task, tracker, token, fmt.Sprintf("Verification failed: `%s`: %v\n%s", command, err, tailOutput(output, verifyOutputLimit)))
+		}
+	}
+
+	e.addLog(task, "success", "Verification passed (%d commands)", len(task.RepoConfig.Verify))
+	return nil
+}
+
+// runVerifyCommand runs a repository verification command in the provider
+// sandbox: the command comes from the repository and runs on generated code,
+// so it never sees the server environment.
+func (e *Executor) runVerifyCommand(workdir, command string) (string, error) {
+	cmd := execCommand("sh", "-c", command)
+	cmd.Dir = workdir
+	cleanup, err := e.sandbox.Wrap(cmd)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return runCmdWithTimeout(cmd, verifyTimeout)
+}
+
+// runCmdWithTimeout runs cmd and kills it once the timeout elapses.
+func runCmdWithTimeout(cmd *exec.Cmd, timeout time.Duration) (string, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return output.String(), err
+	case <-time.After(timeout):
+		_ = cmd.Process.Kill()
+		<-done
+		return output.String(), fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+func tailOutput(output string, limit int) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= limit {
+		return output
+	}
+	return "..." + output[len(output)-limit:]
+}
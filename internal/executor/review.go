@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// reviewThreadsHeading starts the review thread section of a follow-up prompt.
+const reviewThreadsHeading = "## Review Threads to Address"
+
+// reviewThread is a review comment with the replies posted in its thread.
+type reviewThread struct {
+	root    github.ReviewComment
+	replies []github.ReviewComment
+}
+
+// last returns the latest comment of the thread.
+func (t reviewThread) last() github.ReviewComment {
+	if len(t.replies) == 0 {
+		return t.root
+	}
+	return t.replies[len(t.replies)-1]
+}
+
+// unresolvedThreads groups review comments into threads and keeps the ones
+// still waiting for an answer. The REST API does not expose thread resolution,
+// so outdated threads and threads last answered by a bot count as resolved.
+func unresolvedThreads(comments []github.ReviewComment) []reviewThread {
+	byID := make(map[int64]*reviewThread)
+	var order []int64
+	for _, c := range comments {
+		if c.InReplyToID == 0 {
+			byID[c.ID] = &reviewThread{root: c}
+			order = append(order, c.ID)
+		}
+	}
+	for _, c := range comments {
+		if thread, ok := byID[c.InReplyToID]; ok && c.InReplyToID != 0 {
+			thread.replies = append(thread.replies, c)
+		}
+	}
+
+	var threads []reviewThread
+	for _, id := range order {
+		thread := byID[id]
+		sort.SliceStable(thread.replies, func(i, j int) bool {
+			return thread.replies[i].CreatedAt.Before(thread.replies[j].CreatedAt)
+		})
+		if thread.root.Line == 0 || thread.last().AuthorIsBot {
+			continue
+		}
+		threads = append(threads, *thread)
+	}
+	return threads
+}
+
+// formatReviewThreads renders the threads a follow-up task has to address.
+func formatReviewThreads(threads []reviewThread) string {
+	if len(threads) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString(reviewThreadsHeading + "\n")
+	for i, thread := range threads {
+		fmt.Fprintf(&builder, "\n### Thread %d: %s:%d\n", i+1, thread.root.Path, thread.root.Line)
+		if diff := strings.TrimSpace(thread.root.DiffHunk); diff != "" {
+			builder.WriteString("```diff\n" + diff + "\n```\n")
+		}
+		for _, c := range append([]github.ReviewComment{thread.root}, thread.replies...) {
+			fmt.Fprintf(&builder, "@%s: %s\n", c.Author, strings.TrimSpace(c.Body))
+		}
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// collectReviewThreads adds the unresolved review threads of the pull request
+// to the prompt of a review follow-up task and returns them for replying.
+func (e *Executor) collectReviewThreads(task *webhook.Task, token string) []reviewThread {
+	if task.ReviewRound == 0 {
+		return nil
+	}
+
+	comments, err := e.ghClient.ListReviewComments(task.Repo, task.Number, token)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch review threads: %v", err)
+		e.addLog(task, "error", "Failed to fetch review threads: %v", err)
+		return nil
+	}
+
+	// Retries reuse the task; drop the section added by the previous attempt.
+	if i := strings.Index(task.Prompt, "\n\n---\n\n"+reviewThreadsHeading); i >= 0 {
+		task.Prompt = task.Prompt[:i]
+	}
+	threads := unresolvedThreads(comments)
+	log.Printf("Review follow-up round %d: %d unresolved threads", task.ReviewRound, len(threads))
+	e.addLog(task, "info", "Review follow-up round %d: %d unresolved threads", task.ReviewRound, len(threads))
+	if section := formatReviewThreads(threads); section != "" {
+		task.Prompt = strings.TrimRight(task.Prompt, "\n") + "\n\n---\n\n" + section
+	}
+	return threads
+}
+
+// replyToReviewThreads answers the threads on files changed by the pushed
+// commit, so reviewers can see which feedback the follow-up addressed.
+func (e *Executor) replyToReviewThreads(task *webhook.Task, workdir, branchName string, threads []reviewThread, token string) {
+	if len(threads) == 0 {
+		return
+	}
+
+	out, err := gitOutput(workdir, "diff-tree", "--no-commit-id", "--name-only", "-r", "HEAD")
+	if err != nil {
+		log.Printf("Warning: Failed to list committed files: %v", err)
+		return
+	}
+	changed := make(map[string]bool)
+	for _, path := range strings.Split(out, "\n") {
+		changed[strings.TrimSpace(path)] = true
+	}
+
+	body := fmt.Sprintf("Addressed in the latest commit on `%s`.", branchName)
+	replied := 0
+	for _, thread := range threads {
+		if !changed[thread.root.Path] {
+			continue
+		}
+		if _, err := e.ghClient.ReplyToReviewComment(task.Repo, task.Number, thread.root.ID, body, token); err != nil {
+			log.Printf("Warning: Failed to reply to review thread %d: %v", thread.root.ID, err)
+			e.addLog(task, "error", "Failed to reply to review thread %d: %v", thread.root.ID, err)
+			continue
+		}
+		replied++
+	}
+	e.addLog(task, "info", "Replied to %d of %d review threads", replied, len(threads))
+}
+
+// postInlineReview submits the inline comments of a review-only response as a
+// pull request review. A failure is noted in the tracking comment, the summary
+// is still delivered there.
+func (e *Executor) postInlineReview(task *webhook.Task, tracker *github.CommentTracker, result *claude.CodeResponse, token string) {
+	review := github.PullRequestReview{Comments: make([]github.InlineComment, 0, len(result.Comments))}
+	for _, c := range result.Comments {
+		review.Comments = append(review.Comments, github.InlineComment{Path: c.Path, Line: c.Line, StartLine: c.StartLine, Body: c.Body})
+	}
+
+	note := fmt.Sprintf("_Posted %d inline review comments._", len(review.Comments))
+	if err := e.ghClient.CreateReview(task.Repo, task.Number, review, token); err != nil {
+		log.Printf("Warning: Failed to post inline review: %v", err)
+		e.addLog(task, "error", "Failed to post inline review: %v", err)
+		note = fmt.Sprintf("_Posting %d inline review comments failed: %v_", len(review.Comments), err)
+	} else {
+		e.addLog(task, "info", "Posted review with %d inline comments", len(review.Comments))
+	}
+	tracker.State.Summary = strings.TrimSpace(tracker.State.Summary + "\n\n" + note)
+}
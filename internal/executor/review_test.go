@@ -0,0 +1,137 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func reviewComments() []github.ReviewComment {
+	base := time.Date(2025, 10, 10, 10, 0, 0, 0, time.UTC)
+	return []github.ReviewComment{
+		{ID: 1, Author: "alice", Body: "Handle the error", Path: "main.go", Line: 12, DiffHunk: "@@ -1 +1 @@", CreatedAt: base},
+		{ID: 2, InReplyToID: 1, Author: "bob", Body: "+1", Path: "main.go", CreatedAt: base.Add(time.Minute)},
+		{ID: 3, Author: "alice", Body: "Rename this", Path: "util.go", Line: 4, CreatedAt: base},
+		{ID: 4, InReplyToID: 3, Author: "swe[bot]", AuthorIsBot: true, Body: "Addressed", Path: "util.go", CreatedAt: base.Add(time.Minute)},
+		{ID: 5, Author: "alice", Body: "Outdated", Path: "old.go", CreatedAt: base},
+		{ID: 6, Author: "alice", Body: "Add a test", Path: "docs.md", Line: 1, CreatedAt: base},
+	}
+}
+
+func TestUnresolvedThreads(t *testing.T) {
+	threads := unresolvedThreads(reviewComments())
+	if len(threads) != 2 || threads[0].root.ID != 1 || threads[1].root.ID != 6 {
+		t.Fatalf("threads = %+v, want 1 and 6", threads)
+	}
+	if len(threads[0].replies) != 1 || threads[0].replies[0].Author != "bob" {
+		t.Fatalf("replies = %+v", threads[0].replies)
+	}
+
+	section := formatReviewThreads(threads)
+	for _, want := range []string{reviewThreadsHeading, "### Thread 1: main.go:12", "```diff\n@@ -1 +1 @@\n```", "@bob: +1", "### Thread 2: docs.md:1"} {
+		if !strings.Contains(section, want) {
+			t.Fatalf("section missing %q:\n%s", want, section)
+		}
+	}
+}
+
+func TestCollectReviewThreads_AppendsOnce(t *testing.T) {
+	gh := github.NewMockGHClient()
+	gh.ListReviewCommentsFunc = func(repo string, number int, token string) ([]github.ReviewComment, error) {
+		return reviewComments(), nil
+	}
+	e := NewWithClient(nil, &mockAppAuth{}, gh)
+
+	task := &webhook.Task{Repo: "owner/repo", Number: 9, IsPR: true, Prompt: "Fix it"}
+	if threads := e.collectReviewThreads(task, "token"); threads != nil || len(gh.ListReviewCommentsCalls) != 0 {
+		t.Fatal("tasks without review round must not collect threads")
+	}
+
+	task.ReviewRound = 1
+	e.collectReviewThreads(task, "token")
+	threads := e.collectReviewThreads(task, "token")
+	if len(threads) != 2 {
+		t.Fatalf("threads = %d, want 2", len(threads))
+	}
+	if strings.Count(task.Prompt, reviewThreadsHeading) != 1 || !strings.HasPrefix(task.Prompt, "Fix it\n\n---\n\n") {
+		t.Fatalf("prompt = %q", task.Prompt)
+	}
+}
+
+func TestReplyToReviewThreads_OnlyChangedFiles(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{"main.go": "package main\n", "docs.md": "# docs\n"})
+	writeTestFile(t, workdir, "main.go", "package main\n\nfunc main() {}\n")
+	if err := commitAll(workdir); err != nil {
+		t.Fatal(err)
+	}
+
+	gh := github.NewMockGHClient()
+	e := NewWithClient(nil, &mockAppAuth{}, gh)
+	task := &webhook.Task{Repo: "owner/repo", Number: 9, ReviewRound: 1}
+	e.replyToReviewThreads(task, workdir, "swe/issue-7", unresolvedThreads(reviewComments()), "token")
+
+	if len(gh.ReplyToReviewCalls) != 1 {
+		t.Fatalf("replies = %+v, want one for main.go", gh.ReplyToReviewCalls)
+	}
+	call := gh.ReplyToReviewCalls[0]
+	if call.Number != 9 || call.CommentID != 1 || !strings.Contains(call.Body, "swe/issue-7") {
+		t.Fatalf("reply = %+v", call)
+	}
+}
+
+func commitAll(workdir string) error {
+	if err := runGitCommand(workdir, []string{"git", "add", "-A"}, false); err != nil {
+		return err
+	}
+	return runGitCommand(workdir, []string{"git", "commit", "-q", "-m", "fix"}, false)
+}
+
+func TestHandleResponseOnly_PostsInlineReview(t *testing.T) {
+	gh := github.NewMockGHClient()
+	e := NewWithClient(nil, &mockAppAuth{}, gh)
+	result := &claude.CodeResponse{
+		Summary:  "Two issues found",
+		Comments: []claude.ReviewComment{{Path: "main.go", Line: 8, StartLine: 6, Body: "```suggestion\nreturn err\n```"}},
+	}
+
+	issueTask := &webhook.Task{Repo: "owner/repo", Number: 3}
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 3, "alice", gh)
+	if err := e.handleResponseOnly(issueTask, tracker, "token", result); err != nil {
+		t.Fatalf("handleResponseOnly() error = %v", err)
+	}
+	if len(gh.CreateReviewCalls) != 0 {
+		t.Fatal("issues must not get a pull request review")
+	}
+
+	prTask := &webhook.Task{Repo: "owner/repo", Number: 4, IsPR: true}
+	tracker = github.NewCommentTrackerWithClient("owner/repo", 4, "alice", gh)
+	if err := e.handleResponseOnly(prTask, tracker, "token", result); err != nil {
+		t.Fatalf("handleResponseOnly() error = %v", err)
+	}
+	if len(gh.CreateReviewCalls) != 1 {
+		t.Fatalf("CreateReviewCalls = %d, want 1", len(gh.CreateReviewCalls))
+	}
+	want := github.InlineComment{Path: "main.go", Line: 8, StartLine: 6, Body: "```suggestion\nreturn err\n```"}
+	if call := gh.CreateReviewCalls[0]; call.Number != 4 || len(call.Review.Comments) != 1 || call.Review.Comments[0] != want {
+		t.Fatalf("review = %+v", call)
+	}
+	if !strings.Contains(tracker.State.Summary, "Posted 1 inline review comments") {
+		t.Fatalf("summary = %q", tracker.State.Summary)
+	}
+
+	gh.CreateReviewFunc = func(repo string, number int, review github.PullRequestReview, token string) error {
+		return fmt.Errorf("line outside diff")
+	}
+	tracker = github.NewCommentTrackerWithClient("owner/repo", 4, "alice", gh)
+	if err := e.handleResponseOnly(prTask, tracker, "token", result); err != nil {
+		t.Fatalf("handleResponseOnly() error = %v", err)
+	}
+	if !strings.Contains(tracker.State.Summary, "line outside diff") {
+		t.Fatalf("summary = %q, want failure note", tracker.State.Summary)
+	}
+}
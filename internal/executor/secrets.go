@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/secretscan"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// maxScannedFileBytes bounds how much of a new file is read for secret scanning.
+const maxScannedFileBytes = 2 << 20
+
+// sensitiveEnvVars are server credentials that must never end up in a commit or comment.
+var sensitiveEnvVars = []string{
+	"GITHUB_PRIVATE_KEY",
+	"GITHUB_WEBHOOK_SECRET",
+	"ANTHROPIC_API_KEY",
+	"ANTHROPIC_AUTH_TOKEN",
+	"OPENAI_API_KEY",
+}
+
+// WithSecretScanner replaces the secret scanner. Passing nil disables scanning.
+func (e *Executor) WithSecretScanner(scanner *secretscan.Scanner) *Executor {
+	e.secretScanner = scanner
+	return e
+}
+
+// knownSecretValues returns the credentials available to this process for the task.
+func knownSecretValues(token string) []string {
+	values := []string{token}
+	for _, key := range sensitiveEnvVars {
+		values = append(values, os.Getenv(key))
+	}
+	return values
+}
+
+// redactSecrets removes known credentials and secret-like values from user-visible text.
+func (e *Executor) redactSecrets(text, token string) string {
+	scanner := e.secretScanner
+	if scanner == nil {
+		scanner = secretscan.New()
+	}
+	return scanner.WithKnownSecrets(knownSecretValues(token)...).Redact(text)
+}
+
+// scanForSecrets blocks the commit when the working tree introduces secrets.
+func (e *Executor) scanForSecrets(task *webhook.Task, workdir string, tracker *github.CommentTracker, token string) error {
+	if e.secretScanner == nil {
+		return nil
+	}
+
+	scanner := e.secretScanner.
+		WithKnownSecrets(knownSecretValues(token)...).
+		WithAllowList(e.loadSecretAllowList(task, workdir, token))
+
+	lines, pathsOnly, err := collectAddedLines(workdir)
+	if err != nil {
+		return e.handleError(task, tracker, token, fmt.Sprintf("Failed to scan changes for secrets: %v", err))
+	}
+
+	findings := scanner.Scan(lines)
+	findings = append(findings, scanner.ScanPaths(pathsOnly)...)
+	if len(findings) == 0 {
+		e.addLog(task, "info", "Secret scan passed (%d added lines checked)", len(lines))
+		return nil
+	}
+
+	log.Printf("Secret scan found %d potential secret(s) in %s#%d", len(findings), task.Repo, task.Number)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Secret scan blocked push: found %d potential secret(s) in generated changes:", len(findings)))
+	for _, f := range findings {
+		builder.WriteString("\n- ")
+		builder.WriteString(f.String())
+	}
+	return e.handleError(task, tracker, token, builder.String())
+}
+
+// loadSecretAllowList reads allow rules from the default branch, so a pull request
+// cannot allow the secret it adds. Without the default branch nothing is allowed.
+func (e *Executor) loadSecretAllowList(task *webhook.Task, workdir, token string) secretscan.AllowList {
+	ref, err := defaultBranchRef(task, workdir, token)
+	if err != nil {
+		log.Printf("Warning: ignoring %s: %v", secretscan.AllowListPath, err)
+		e.addLog(task, "error", "Could not read %s from %s; no findings are allowed", secretscan.AllowListPath, task.DefaultBranch)
+		return nil
+	}
+	cmd := gitCommand(workdir, "", "show", ref+":"+secretscan.AllowListPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	allow, err := secretscan.ParseAllowList(string(output))
+	if err != nil {
+		log.Printf("Warning: ignoring invalid %s: %v", secretscan.AllowListPath, err)
+		e.addLog(task, "error", "Ignoring invalid %s: %v", secretscan.AllowListPath, err)
+		return nil
+	}
+	return allow
+}
+
+// collectAddedLines returns the lines added by tracked changes and new files.
+// Binary and oversized new files are returned by path only.
+func collectAddedLines(workdir string) ([]secretscan.AddedLine, []string, error) {
+	var lines []secretscan.AddedLine
+
+	if hasHeadCommit(workdir) {
+		cmd := gitCommand(workdir, "", "diff", "HEAD", "--unified=0", "--no-color", "--no-ext-diff")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, nil, fmt.Errorf("git diff failed: %w", err)
+		}
+		lines = append(lines, secretscan.ParseUnifiedDiff(string(output))...)
+	}
+
+	cmd := gitCommand(workdir, "", "ls-files", "--others", "--exclude-standard", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("git ls-files failed: %w", err)
+	}
+
+	var pathsOnly []string
+	for _, rel := range strings.Split(string(output), "\x00") {
+		if rel == "" {
+			continue
+		}
+		content, ok := readScannableFile(filepath.Join(workdir, rel))
+		if !ok {
+			pathsOnly = append(pathsOnly, rel)
+			continue
+		}
+		lines = append(lines, secretscan.FileLines(rel, content)...)
+	}
+
+	return lines, pathsOnly, nil
+}
+
+func hasHeadCommit(workdir string) bool {
+	cmd := gitCommand(workdir, "", "rev-parse", "--verify", "--quiet", "HEAD")
+	return cmd.Run() == nil
+}
+
+func readScannableFile(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > maxScannedFileBytes {
+		return "", false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content, 0) != -1 {
+		return "", false
+	}
+	return string(content), true
+}
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"log"
+	"time"
+
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// WithSessionTTL lets a task resume the provider session of the previous task
+// on the same issue or pull request when that ran within ttl. Zero disables
+// sessions; every task then starts from the discussion alone.
+func (e *Executor) WithSessionTTL(ttl time.Duration) *Executor {
+	e.sessionTTL = ttl
+	return e
+}
+
+// resumableSession returns the session the task continues, or "" for a new
+// one. Sessions of another provider, expired ones and those reset with
+// --new-session are forgotten.
+func (e *Executor) resumableSession(task *webhook.Task, providerName string) string {
+	if e.store == nil || e.sessionTTL <= 0 || task.Number == 0 {
+		return ""
+	}
+
+	session, err := e.store.GetSession(task.Repo, task.Number)
+	if err != nil {
+		log.Printf("Warning: Failed to look up session for %s#%d: %v", task.Repo, task.Number, err)
+		return ""
+	}
+	if session == nil {
+		return ""
+	}
+
+	switch {
+	case task.NewSession:
+		log.Printf("Starting a new session for %s#%d as requested", task.Repo, task.Number)
+	case session.Provider != providerName:
+		log.Printf("Not resuming %s session for %s#%d: the task uses %s", session.Provider, task.Repo, task.Number, providerName)
+	case time.Since(session.UpdatedAt) > e.sessionTTL:
+		log.Printf("Not resuming session for %s#%d: idle since %s", task.Repo, task.Number, session.UpdatedAt.Format(time.RFC3339))
+	default:
+		return session.SessionID
+	}
+	if err := e.store.DeleteSession(task.Repo, task.Number); err != nil {
+		log.Printf("Warning: Failed to delete session for %s#%d: %v", task.Repo, task.Number, err)
+	}
+	return ""
+}
+
+// saveSession keeps the session of a finished provider run for the next
+// task on the same number.
+func (e *Executor) saveSession(task *webhook.Task, result *claude.CodeResponse) {
+	if e.store == nil || e.sessionTTL <= 0 || task.Number == 0 || result.SessionID == "" {
+		return
+	}
+	err := e.store.SaveSession(&taskstore.Session{
+		Repo:      task.Repo,
+		Number:    task.Number,
+		Provider:  result.Provider,
+		SessionID: result.SessionID,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to save session for %s#%d: %v", task.Repo, task.Number, err)
+	}
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestGenerateCodeChanges_ResumesSessions(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	// The provider starts session s1 and keeps the session it resumes.
+	var resumed []string
+	p := &mockProvider{name: "claude", generateFunc: func(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		resumed = append(resumed, req.SessionID)
+		id := req.SessionID
+		if id == "" {
+			id = "s1"
+		}
+		return &claude.CodeResponse{Summary: "done", SessionID: id}, nil
+	}}
+	mockGH := github.NewMockGHClient()
+	e := NewWithClient(p, nil, mockGH).WithStore(store).WithSessionTTL(time.Hour)
+	tracker := github.NewCommentTrackerWithClient("owner/repo", 7, "alice", mockGH)
+	run := func(task *webhook.Task) {
+		t.Helper()
+		if _, err := e.generateCodeChanges(context.Background(), task, t.TempDir(), map[string]string{}, tracker, ""); err != nil {
+			t.Fatalf("generateCodeChanges() error = %v", err)
+		}
+	}
+
+	run(&webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7})
+	run(&webhook.Task{ID: "task-2", Repo: "owner/repo", Number: 7})
+	run(&webhook.Task{ID: "task-3", Repo: "owner/repo", Number: 8})
+	if len(resumed) != 3 || resumed[0] != "" || resumed[1] != "s1" || resumed[2] != "" {
+		t.Fatalf("resumed sessions = %q, want only the follow-up on #7 to resume", resumed)
+	}
+
+	// --new-session starts over.
+	run(&webhook.Task{ID: "task-4", Repo: "owner/repo", Number: 7, NewSession: true})
+	if resumed[3] != "" {
+		t.Fatalf("resumed = %q with --new-session", resumed[3])
+	}
+
+	// Sessions of another provider or past the TTL are dropped.
+	if err := store.SaveSession(&taskstore.Session{Repo: "owner/repo", Number: 7, Provider: "codex", SessionID: "c1"}); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+	run(&webhook.Task{ID: "task-5", Repo: "owner/repo", Number: 7})
+	e.WithSessionTTL(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	run(&webhook.Task{ID: "task-6", Repo: "owner/repo", Number: 7})
+	if resumed[4] != "" || resumed[5] != "" {
+		t.Fatalf("resumed = %q, want other providers' and expired sessions dropped", resumed[4:])
+	}
+
+	// Disabled sessions are neither resumed nor kept.
+	e.WithSessionTTL(0)
+	store.DeleteSession("owner/repo", 7)
+	run(&webhook.Task{ID: "task-7", Repo: "owner/repo", Number: 7})
+	if session, _ := store.GetSession("owner/repo", 7); session != nil {
+		t.Fatalf("session = %+v, want none with sessions disabled", session)
+	}
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// Commit signing modes.
+const (
+	SigningNone = ""    // plain git commit
+	SigningSSH  = "ssh" // git commit -S with an SSH key
+	SigningGPG  = "gpg" // git commit -S with a GPG key
+	SigningAPI  = "api" // commit through the Git Data API; GitHub signs it for the App
+)
+
+// CommitSigning configures how generated commits are signed.
+type CommitSigning struct {
+	Mode   string
+	Key    string // SSH key path or GPG key ID
+	APIURL string // GitHub API base URL for SigningAPI; empty uses api.github.com
+}
+
+// WithCommitSigning sets the commit signing mode.
+func (e *Executor) WithCommitSigning(signing CommitSigning) *Executor {
+	e.signing = signing
+	return e
+}
+
+// signingArgs returns the git options and commit flags for the configured
+// signing mode. They are passed per command because a cached worktree shares
+// its config with every other task on the repository.
+func (e *Executor) signingArgs() (options, flags []string) {
+	switch e.signing.Mode {
+	case SigningSSH:
+		return []string{"-c", "gpg.format=ssh", "-c", "user.signingkey=" + e.signing.Key}, []string{"-S"}
+	case SigningGPG:
+		options = []string{"-c", "gpg.format=openpgp"}
+		if e.signing.Key != "" {
+			options = append(options, "-c", "user.signingkey="+e.signing.Key)
+		}
+		return options, []string{"-S"}
+	default:
+		return nil, nil
+	}
+}
+
+// commitStaged commits the index with the configured signing mode.
+func (e *Executor) commitStaged(workdir, message string) error {
+	options, flags := e.signingArgs()
+	args := append(append([]string{"git"}, options...), "commit", "--no-verify")
+	args = append(append(args, flags...), "-m", message)
+	return runGitCommand(workdir, args, false)
+}
+
+// publishBranch pushes the local HEAD to branchName in repo, through the Git
+// Data API when API signing is enabled and with git push otherwise. fork marks
+// the head repository of a fork PR. When the branch moved meanwhile, the local
+// commits are rebased onto it and pushed again; conflicts are returned as a
+// *MergeConflictError.
+func (e *Executor) publishBranch(workdir, repo string, fork bool, branchName string, isNewBranch bool, token string) error {
+	push := func() error { return pushWithGit(workdir, repo, branchName, token) }
+	switch {
+	case e.signing.Mode != SigningAPI:
+	case fork:
+		// The installation token may push to the head branch of a fork PR
+		// with maintainer edits, but cannot create objects in the fork.
+		log.Printf("Pushing to fork %s with git: API commit signing only works in the installation's repositories", repo)
+	default:
+		push = func() error { return e.pushViaAPI(workdir, repo, branchName, isNewBranch, token) }
+	}
+
+	err := push()
+	if isNewBranch || !isNonFastForward(err) {
+		return err
+	}
+
+	// Someone pushed to the branch while the task ran: replay our commit on top and push once more.
+	log.Printf("Push to %s rejected as non-fast-forward, rebasing onto remote", branchName)
+	if err := rebaseOntoRemote(workdir, repo, branchName, token); err != nil {
+		return err
+	}
+	return push()
+}
+
+// pushWithGit pushes HEAD to branchName in repo, passing the installation
+// token per command so it is never written to the shared config. The local
+// branch is not used: PR branches are checked out detached so that tasks on
+// the same PR can share a mirror.
+func pushWithGit(workdir, repo, branchName, token string) error {
+	pushArgs := []string{"git", "push", "--no-verify", remoteURL(repo), "HEAD:refs/heads/" + branchName}
+	return pushWithRetry(workdir, token, pushArgs)
+}
+
+// pushWithRetry runs git push with small exponential backoff for transient network issues (KISS)
+func pushWithRetry(workdir, token string, pushArgs []string) error {
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			// 1s, 2s backoff
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err := runAuthGitCommand(workdir, token, pushArgs, false); err != nil {
+			lastErr = err
+			if shouldRetryPush(err) {
+				continue
+			}
+			return err
+		}
+		lastErr = nil
+		break
+	}
+	return lastErr
+}
+
+// pushViaAPI recreates the local HEAD commit on GitHub with the Git Data API.
+// The local commit is only used to compute the change set; the clone is discarded afterwards.
+func (e *Executor) pushViaAPI(workdir, repo, branchName string, isNewBranch bool, token string) error {
+	if strings.TrimSpace(token) == "" || strings.TrimSpace(repo) == "" {
+		return fmt.Errorf("api commit signing requires a repository and installation token")
+	}
+
+	parent, err := gitOutput(workdir, "rev-parse", "HEAD^")
+	if err != nil {
+		return fmt.Errorf("api commit signing requires a parent commit: %w", err)
+	}
+	baseTree, err := gitOutput(workdir, "rev-parse", "HEAD^^{tree}")
+	if err != nil {
+		return err
+	}
+	message, err := gitOutput(workdir, "log", "-1", "--format=%B")
+	if err != nil {
+		return err
+	}
+
+	client := github.NewGitDataClient(e.signing.APIURL, token)
+	entries, err := apiTreeEntries(workdir, repo, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := client.CreateTree(repo, baseTree, entries)
+	if err != nil {
+		return fmt.Errorf("failed to create tree: %w", err)
+	}
+	commit, err := client.CreateCommit(repo, message, tree, []string{parent})
+	if err != nil {
+		return fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	if isNewBranch {
+		err = client.CreateRef(repo, branchName, commit)
+	} else {
+		err = client.UpdateRef(repo, branchName, commit, false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update branch %s: %w", branchName, err)
+	}
+
+	log.Printf("Created verified commit %s on %s via GitHub API", commit, branchName)
+	return nil
+}
+
+// apiTreeEntries uploads the blobs changed by HEAD and returns matching tree entries.
+func apiTreeEntries(workdir, repo string, client *github.GitDataClient) ([]github.TreeEntry, error) {
+	raw, err := gitOutputRaw(workdir, "diff-tree", "-r", "-z", "--no-renames", "--raw", "HEAD^", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// Records are ":oldmode newmode oldsha newsha status\x00path\x00"
+	fields := strings.Split(strings.TrimSuffix(raw, "\x00"), "\x00")
+	var entries []github.TreeEntry
+	for i := 0; i+1 < len(fields); i += 2 {
+		meta := strings.Fields(strings.TrimPrefix(fields[i], ":"))
+		if len(meta) != 5 {
+			return nil, fmt.Errorf("unexpected diff-tree output: %q", fields[i])
+		}
+		oldMode, newMode, newSHA, status := meta[0], meta[1], meta[3], meta[4]
+		path := fields[i+1]
+
+		if status == "D" {
+			entries = append(entries, github.TreeEntry{Path: path, Mode: oldMode, Type: "blob"})
+			continue
+		}
+		if newMode == "160000" {
+			sha := newSHA
+			entries = append(entries, github.TreeEntry{Path: path, Mode: newMode, Type: "commit", SHA: &sha})
+			continue
+		}
+
+		cmd := gitCommand(workdir, "", "cat-file", "blob", newSHA)
+		content, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sha, err := client.CreateBlob(repo, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", path, err)
+		}
+		entries = append(entries, github.TreeEntry{Path: path, Mode: newMode, Type: "blob", SHA: &sha})
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no changes to commit via API")
+	}
+	return entries, nil
+}
+
+func gitOutput(workdir string, args ...string) (string, error) {
+	out, err := gitOutputRaw(workdir, args...)
+	return strings.TrimSpace(out), err
+}
+
+func gitOutputRaw(workdir string, args ...string) (string, error) {
+	cmd := gitCommand(workdir, "", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w\nOutput: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,310 @@
+package executor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+func TestCommitAndPush_SSHSigning(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %v\n%s", err, output)
+	}
+
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "remote", "add", "origin", origin)
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature/signed")
+	writeTestFile(t, workdir, "main.go", "package main\n")
+
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningSSH, Key: key})
+	if err := executor.commitAndPush(workdir, "", "feature/signed", "Signed change", true, ""); err != nil {
+		t.Fatalf("commitAndPush() error = %v", err)
+	}
+
+	commit, err := gitOutput(origin, "cat-file", "commit", "feature/signed")
+	if err != nil {
+		t.Fatalf("read pushed commit: %v", err)
+	}
+	if !strings.Contains(commit, "gpgsig -----BEGIN SSH SIGNATURE-----") {
+		t.Fatalf("pushed commit is not SSH-signed:\n%s", commit)
+	}
+	if format, _ := gitOutput(workdir, "config", "--get", "gpg.format"); format != "" {
+		t.Fatalf("gpg.format = %q written to the config", format)
+	}
+}
+
+func TestCommitLocal_LeavesConfigUntouched(t *testing.T) {
+	t.Setenv("SWE_AGENT_GIT_NAME", "Agent Bot")
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	before, _ := gitOutput(workdir, "config", "--list", "--local")
+	writeTestFile(t, workdir, "main.go", "package main\n")
+
+	if err := New(nil, nil).commitLocal(workdir, "Change"); err != nil {
+		t.Fatalf("commitLocal() error = %v", err)
+	}
+	if author, _ := gitOutput(workdir, "log", "-1", "--format=%an"); author != "Agent Bot" {
+		t.Fatalf("author = %q, want the bot identity", author)
+	}
+	if after, _ := gitOutput(workdir, "config", "--list", "--local"); after != before {
+		t.Fatalf("config changed:\n%s\nwant:\n%s", after, before)
+	}
+}
+
+func TestCommitAndPush_APISigning(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n", "old.txt": "bye\n"})
+	parent, _ := gitOutput(workdir, "rev-parse", "HEAD")
+	baseTree, _ := gitOutput(workdir, "rev-parse", "HEAD^{tree}")
+	writeTestFile(t, workdir, "README.md", "hello\n")
+	runGitIn(t, workdir, "rm", "-q", "old.txt")
+
+	var (
+		mu       sync.Mutex
+		requests []string
+		blobs    []string
+		tree     map[string]interface{}
+		commit   map[string]interface{}
+		ref      map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch r.URL.Path {
+		case "/repos/owner/repo/git/blobs":
+			content, _ := base64.StdEncoding.DecodeString(body["content"].(string))
+			blobs = append(blobs, string(content))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"blob-sha"}`))
+		case "/repos/owner/repo/git/trees":
+			tree = body
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/owner/repo/git/commits":
+			commit = body
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"commit-sha"}`))
+		case "/repos/owner/repo/git/refs":
+			ref = body
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningAPI, APIURL: server.URL})
+	if err := executor.commitAndPush(workdir, "owner/repo", "swe/issue-1", "Update readme\n\nFixes #1", true, "ghs_token"); err != nil {
+		t.Fatalf("commitAndPush() error = %v", err)
+	}
+
+	if len(blobs) != 1 || blobs[0] != "hello\n" {
+		t.Fatalf("uploaded blobs = %q, want only README.md content", blobs)
+	}
+	if tree["base_tree"] != baseTree {
+		t.Fatalf("base_tree = %v, want %s", tree["base_tree"], baseTree)
+	}
+	entries := tree["tree"].([]interface{})
+	if len(entries) != 2 {
+		t.Fatalf("tree entries = %v", entries)
+	}
+	deleted := entries[1].(map[string]interface{})
+	if deleted["path"] != "old.txt" || deleted["sha"] != nil {
+		t.Fatalf("deletion entry = %v", deleted)
+	}
+	parents := commit["parents"].([]interface{})
+	if commit["message"] != "Update readme\n\nFixes #1" || commit["tree"] != "tree-sha" || len(parents) != 1 || parents[0] != parent {
+		t.Fatalf("commit request = %v", commit)
+	}
+	if ref["ref"] != "refs/heads/swe/issue-1" || ref["sha"] != "commit-sha" {
+		t.Fatalf("ref request = %v", ref)
+	}
+	if len(requests) != 4 {
+		t.Fatalf("requests = %v, want blob, tree, commit and ref", requests)
+	}
+}
+
+func TestPublishBranch_APISigningRequiresToken(t *testing.T) {
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningAPI})
+	err := executor.publishBranch(t.TempDir(), "owner/repo", false, "feature/x", true, "")
+	if err == nil || !strings.Contains(err.Error(), "installation token") {
+		t.Fatalf("publishBranch() error = %v, want token error", err)
+	}
+}
+
+func TestApiTreeEntries_NoChanges(t *testing.T) {
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "commit", "-q", "--allow-empty", "-m", "empty")
+	if _, err := apiTreeEntries(workdir, "owner/repo", github.NewGitDataClient("http://127.0.0.1:0", "t")); err == nil {
+		t.Fatal("apiTreeEntries() error = nil, want no changes error")
+	}
+}
+
+// gitDataServer fakes the Git Data API of owner/repo. The first ref update is
+// rejected as not a fast forward when reject is set; commit parents are recorded.
+func gitDataServer(t *testing.T, reject bool) (*httptest.Server, *[]string) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		parents []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/repos/owner/repo/git/blobs", "/repos/owner/repo/git/trees":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"object-sha"}`))
+		case "/repos/owner/repo/git/commits":
+			parents = append(parents, body["parents"].([]interface{})[0].(string))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"commit-sha"}`))
+		case "/repos/owner/repo/git/refs/heads/feature":
+			if reject {
+				reject = false
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message":"Update is not a fast forward"}`))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &parents
+}
+
+func TestPublishBranch_APISigningRebasesOnNonFastForward(t *testing.T) {
+	origin, workdir := newDivergedBranch(t, "other.txt", "two\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	server, parents := gitDataServer(t, true)
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningAPI, APIURL: server.URL})
+	if err := executor.commitLocal(workdir, "Agent change"); err != nil {
+		t.Fatalf("commitLocal() error = %v", err)
+	}
+	if err := executor.publishBranch(workdir, "owner/repo", false, "feature", false, "ghs_token"); err != nil {
+		t.Fatalf("publishBranch() error = %v", err)
+	}
+
+	remoteTip, _ := gitOutput(origin, "rev-parse", "feature")
+	if len(*parents) != 2 || (*parents)[1] != remoteTip {
+		t.Fatalf("commit parents = %v, want the retry on top of %s", *parents, remoteTip)
+	}
+}
+
+func TestPublishBranch_APISigningReportsConflicts(t *testing.T) {
+	_, workdir := newDivergedBranch(t, "app.txt", "remote\n")
+	writeTestFile(t, workdir, "app.txt", "agent\n")
+
+	server, _ := gitDataServer(t, true)
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningAPI, APIURL: server.URL})
+	if err := executor.commitLocal(workdir, "Agent change"); err != nil {
+		t.Fatalf("commitLocal() error = %v", err)
+	}
+	err := executor.publishBranch(workdir, "owner/repo", false, "feature", false, "ghs_token")
+	var conflict *MergeConflictError
+	if !errors.As(err, &conflict) || strings.Join(conflict.Files, ",") != "app.txt" {
+		t.Fatalf("publishBranch() error = %v, want MergeConflictError", err)
+	}
+}
+
+func TestPublishBranch_APISigningPushesForksWithGit(t *testing.T) {
+	fork := t.TempDir()
+	runGitIn(t, fork, "init", "-q", "--bare")
+	serveGitHubRepo(t, "contributor/repo", fork)
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature")
+	writeTestFile(t, workdir, "main.go", "package main\n")
+	runGitIn(t, workdir, "add", ".")
+	runGitIn(t, workdir, "commit", "-q", "-m", "Agent change")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected API request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	executor := New(nil, nil).WithCommitSigning(CommitSigning{Mode: SigningAPI, APIURL: server.URL})
+	if err := executor.publishBranch(workdir, "contributor/repo", true, "feature", false, "ghs_token"); err != nil {
+		t.Fatalf("publishBranch() error = %v", err)
+	}
+	local, _ := gitOutput(workdir, "rev-parse", "HEAD")
+	if pushed, _ := gitOutput(fork, "rev-parse", "feature"); pushed != local {
+		t.Fatalf("fork feature = %q, want %s", pushed, local)
+	}
+}
+
+func TestCommitAndPush_IgnoresWorkspaceHooks(t *testing.T) {
+	t.Setenv("GITHUB_PRIVATE_KEY", "private-key")
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "remote", "add", "origin", origin)
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature/hooks")
+
+	// A provider can write hooks into the clone or point core.hooksPath elsewhere.
+	marker := filepath.Join(t.TempDir(), "ran")
+	hook := "#!/bin/sh\nenv >> " + marker + "\n"
+	for _, dir := range []string{filepath.Join(".git", "hooks"), "planted"} {
+		for _, name := range []string{"pre-commit", "commit-msg", "post-commit", "pre-push"} {
+			writeTestFile(t, workdir, filepath.Join(dir, name), hook)
+			if err := os.Chmod(filepath.Join(workdir, dir, name), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	runGitIn(t, workdir, "config", "core.hooksPath", filepath.Join(workdir, "planted"))
+
+	if err := New(nil, nil).commitAndPush(workdir, "", "feature/hooks", "Change", true, ""); err != nil {
+		t.Fatalf("commitAndPush() error = %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("workspace hook ran during commit or push")
+	}
+	if pushed, _ := gitOutput(origin, "rev-parse", "feature/hooks"); pushed == "" {
+		t.Fatal("branch was not pushed")
+	}
+}
+
+func TestGitEnv_DropsServerSecrets(t *testing.T) {
+	t.Setenv("GITHUB_PRIVATE_KEY", "private-key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "webhook-secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant")
+	t.Setenv("GIT_AUTHOR_NAME", "bot")
+
+	env := strings.Join(gitEnv("ghs_token"), "\n")
+	for _, secret := range []string{"private-key", "webhook-secret", "sk-ant"} {
+		if strings.Contains(env, secret) {
+			t.Fatalf("git env leaks %q:\n%s", secret, env)
+		}
+	}
+	for _, want := range []string{"PATH=", "GIT_AUTHOR_NAME=bot", "GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_KEY_0=http.extraHeader"} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("git env missing %q:\n%s", want, env)
+		}
+	}
+}
@@ -12,11 +12,19 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cexll/swe/internal/budget"
 	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/pricing"
 	"github.com/cexll/swe/internal/provider"
 	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/repoconfig"
+	"github.com/cexll/swe/internal/sandbox"
+	"github.com/cexll/swe/internal/secretscan"
 	"github.com/cexll/swe/internal/taskstore"
 	"github.com/cexll/swe/internal/webhook"
 )
@@ -45,7 +53,23 @@ type Executor struct {
 	ghClient        github.GHClient
 	cloneFn         CloneFunc
 	store           *taskstore.Store
-	disallowedTools string // Tools that are not allowed to be used
+	disallowedTools string              // Tools that are not allowed to be used
+	secretScanner   *secretscan.Scanner // nil disables the pre-commit secret scan
+	changePolicy    policy.Policy       // Default policy when the repo has none
+	providerFactory ProviderFactory     // Builds providers selected by .swe-agent.yml
+	namingTemplates naming.Templates    // Server-wide branch/commit/PR templates
+	signing         CommitSigning       // How generated commits are signed
+	maxFileBytes    int64               // Largest changed file, 0 = unlimited
+	maxChangeBytes  int64               // Largest change set per task, 0 = unlimited
+	prices          pricing.Table       // Prices tokens of providers that report no cost
+	budgets         *budget.Enforcer    // Spend budgets checked before and during generation
+	sessionTTL      time.Duration       // How long follow-up tasks resume a provider session, 0 = never
+	sandbox         *sandbox.Sandbox    // Runs repository verify commands
+
+	workspacesMu sync.Mutex
+	workspaces   map[string]retainedWorkspace // task ID -> workspace kept for the next attempt
+
+	lifecycleMu sync.Mutex // serializes pull_request lifecycle updates
 }
 
 // New creates a new executor
@@ -56,6 +80,9 @@ func New(p provider.Provider, appAuth github.AuthProvider) *Executor {
 		ghClient:        github.NewRealGHClient(),
 		cloneFn:         github.Clone,
 		disallowedTools: "", // Default: no restrictions
+		secretScanner:   secretscan.New(),
+		changePolicy:    policy.Default(),
+		prices:          pricing.Default(),
 	}
 }
 
@@ -68,10 +95,13 @@ func (e *Executor) WithDisallowedTools(tools string) *Executor {
 // NewWithClient creates a new executor with a custom gh client (useful for testing)
 func NewWithClient(p provider.Provider, appAuth github.AuthProvider, ghClient github.GHClient) *Executor {
 	return &Executor{
-		provider: p,
-		appAuth:  appAuth,
-		ghClient: ghClient,
-		cloneFn:  github.Clone,
+		provider:      p,
+		appAuth:       appAuth,
+		ghClient:      ghClient,
+		cloneFn:       github.Clone,
+		secretScanner: secretscan.New(),
+		changePolicy:  policy.Default(),
+		prices:        pricing.Default(),
 	}
 }
 
@@ -173,6 +203,7 @@ func (e *Executor) enrichPromptWithDiscussion(task *webhook.Task, token string)
 
 func (e *Executor) initializeTracker(task *webhook.Task, contextMap map[string]string, token string) *github.CommentTracker {
 	tracker := github.NewCommentTrackerWithClient(task.Repo, task.Number, task.Username, e.ghClient)
+	tracker.ReplyTo = task.ReviewCommentID
 	tracker.SetQueued()
 	if task.PromptSummary != "" {
 		tracker.State.OriginalBody = task.PromptSummary
@@ -186,7 +217,7 @@ func (e *Executor) initializeTracker(task *webhook.Task, contextMap map[string]s
 	tracker.AddTask("Clone repository")
 	tracker.AddTask("Generate code changes")
 	tracker.AddTask("Commit and push changes")
-	if !task.IsPR || task.PRState != "open" {
+	if opensNewPR(task) {
 		tracker.AddTask("Create pull request")
 	}
 
@@ -233,7 +264,7 @@ func extractTrackerID(raw string) int {
 }
 
 func (e *Executor) ensureTrackingLabel(task *webhook.Task, tracker *github.CommentTracker, token string) {
-	if err := e.ghClient.AddLabel(task.Repo, task.Number, "swe", token); err != nil {
+	if err := e.ghClient.AddLabel(task.Repo, task.Number, trackingLabel, token); err != nil {
 		log.Printf("Warning: Failed to add label: %v", err)
 		e.addLog(task, "error", "Failed to add swe label: %v", err)
 	}
@@ -259,7 +290,13 @@ func (e *Executor) cloneAndPrepareWorkspace(
 		return "", nil, "", false, e.handleError(task, tracker, token, fmt.Sprintf("Failed to clone repository: %v", err))
 	}
 
-	branchName, isNewBranch, err = e.prepareBranch(workdir, task)
+	if err := e.loadRepoConfig(task, workdir, contextMap, token); err != nil {
+		tracker.FailTask("Clone repository")
+		cleanup()
+		return "", nil, "", false, e.handleError(task, tracker, token, err.Error())
+	}
+
+	branchName, isNewBranch, err = e.prepareBranch(workdir, task, token)
 	if err != nil {
 		tracker.FailTask("Clone repository")
 		cleanup()
@@ -289,38 +326,93 @@ func (e *Executor) generateCodeChanges(
 		log.Printf("Warning: Failed to update progress: %v", err)
 	}
 
-	log.Printf("Calling %s provider (prompt length: %d chars)", e.provider.Name(), len(task.Prompt))
-	e.addLog(task, "info", "Calling %s provider", e.provider.Name())
+	p, err := e.providerFor(task)
+	if err != nil {
+		tracker.FailTask("Generate code changes")
+		return nil, e.handleError(task, tracker, token, err.Error())
+	}
+
+	guard, err := e.newBudgetGuard(task, p.Name())
+	if err != nil {
+		tracker.FailTask("Generate code changes")
+		return nil, e.handleError(task, tracker, token, err.Error())
+	}
+
+	prompt := withRepoInstructions(task.Prompt, task.RepoConfig)
+
+	log.Printf("Calling %s provider (prompt length: %d chars)", p.Name(), len(prompt))
+	e.addLog(task, "info", "Calling %s provider", p.Name())
+
+	sessionID := e.resumableSession(task, p.Name())
+	if sessionID != "" {
+		log.Printf("Resuming %s session %s for %s#%d", p.Name(), sessionID, task.Repo, task.Number)
+		e.addLog(task, "info", "Resuming %s session of the previous task", p.Name())
+	}
 
 	preStatus := captureGitStatus(workdir)
 
-	result, err := e.provider.GenerateCode(ctx, &claude.CodeRequest{
-		Prompt:   task.Prompt,
-		RepoPath: workdir,
-		Context:  cloneStringMap(contextMap),
+	progress := newProgressReporter(tracker, token, func(text string) string { return e.redactSecrets(text, token) })
+	start := time.Now()
+	result, err := p.GenerateCode(ctx, &claude.CodeRequest{
+		Prompt:    prompt,
+		RepoPath:  workdir,
+		Context:   cloneStringMap(contextMap),
+		Progress:  progress.report,
+		Budget:    guard.check,
+		SessionID: sessionID,
 	})
+	progress.stop()
 	if err != nil {
+		failed := &claude.CodeResponse{}
+		for _, usage := range guard.spent("") {
+			e.addUsage(task, tracker, failed, &claude.CodeResponse{Usage: usage}, time.Since(start))
+		}
 		tracker.FailTask("Generate code changes")
-		return nil, e.handleError(task, tracker, token, fmt.Sprintf("%s error: %v", e.provider.Name(), err))
+		return nil, e.handleError(task, tracker, token, fmt.Sprintf("%s error: %v", p.Name(), err))
 	}
 
 	tracker.CompleteTask("Generate code changes")
 
-	log.Printf("%s completed (cost: $%.4f)", e.provider.Name(), result.CostUSD)
-	e.addLog(task, "info", "%s completed (cost: $%.4f)", e.provider.Name(), result.CostUSD)
+	result.Summary = e.redactSecrets(result.Summary, token)
+	e.recordProviderArtifacts(task, result, token)
+
+	if result.Provider == "" {
+		result.Provider = p.Name()
+	}
+	e.recordProvider(task, tracker, result.Provider)
+	e.recordUsage(task, tracker, result, time.Since(start))
+	for _, usage := range guard.spent(result.Provider) {
+		// Runs of providers the fallback chain gave up on.
+		e.addUsage(task, tracker, result, &claude.CodeResponse{Usage: usage}, 0)
+	}
+	e.saveSession(task, result)
+
+	log.Printf("%s completed (%d tokens, cost: $%.4f)", result.Provider, result.Usage.Tokens(), result.CostUSD)
+	e.addLog(task, "info", "%s completed (%d tokens, cost: $%.4f)", result.Provider, result.Usage.Tokens(), result.CostUSD)
 
 	compareGitStatus(workdir, preStatus)
 
 	return result, nil
 }
 
+// recordProvider stores which provider produced the result. With a fallback
+// chain it can differ from the configured one.
+func (e *Executor) recordProvider(task *webhook.Task, tracker *github.CommentTracker, name string) {
+	if name == "" {
+		return
+	}
+	tracker.SetProvider(name)
+	if e.store != nil && task != nil && task.ID != "" {
+		e.store.SetProvider(task.ID, name)
+	}
+}
+
 func captureGitStatus(workdir string) string {
 	if os.Getenv("DEBUG_GIT_DETECTION") != "true" {
 		return ""
 	}
 
-	cmd := execCommand("git", "status", "--porcelain", "--untracked-files=all")
-	cmd.Dir = workdir
+	cmd := gitCommand(workdir, "", "status", "--porcelain", "--untracked-files=all")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return ""
@@ -339,8 +431,7 @@ func compareGitStatus(workdir, before string) {
 		return
 	}
 
-	cmd := execCommand("git", "status", "--porcelain", "--untracked-files=all")
-	cmd.Dir = workdir
+	cmd := gitCommand(workdir, "", "status", "--porcelain", "--untracked-files=all")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return
@@ -397,10 +488,24 @@ func (e *Executor) prepareChangePlan(
 		return nil, nil, false, e.handleError(task, tracker, token, fmt.Sprintf("Failed to get changed files: %v", err))
 	}
 
+	reverted, err := e.enforcePolicy(task, workdir, tracker, token)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(reverted) > 0 {
+		result.Files = dropRevertedFiles(result.Files, reverted)
+		if remaining, err := e.detectGitChanges(workdir); err != nil || !remaining {
+			return nil, nil, false, e.handleError(task, tracker, token, fmt.Sprintf("Policy violation: all generated changes were reverted (%s)", strings.Join(reverted, ", ")))
+		}
+		if changedFiles, err = e.getChangedFiles(workdir); err != nil {
+			return nil, nil, false, e.handleError(task, tracker, token, fmt.Sprintf("Failed to get changed files: %v", err))
+		}
+	}
+
 	log.Printf("Detected %d changed files", len(changedFiles))
 	e.addLog(task, "info", "Detected %d changed files", len(changedFiles))
 
-	splitter := github.NewPRSplitter(8, 300)
+	splitter := github.NewPRSplitter(task.RepoConfig.SplitThresholds(8, 300))
 	plan := splitter.Analyze(changedFiles, task.Prompt)
 
 	log.Printf("Split analysis: %d sub-PRs planned", len(plan.SubPRs))
@@ -410,6 +515,7 @@ func (e *Executor) prepareChangePlan(
 }
 
 func (e *Executor) executeSinglePRWorkflow(
+	ctx context.Context,
 	task *webhook.Task,
 	tracker *github.CommentTracker,
 	token string,
@@ -417,6 +523,7 @@ func (e *Executor) executeSinglePRWorkflow(
 	workdir string,
 	branchName string,
 	isNewBranch bool,
+	cp *taskstore.Checkpoint,
 ) error {
 	log.Printf("Using single-PR workflow")
 	e.addLog(task, "info", "Using single-PR workflow")
@@ -434,14 +541,35 @@ func (e *Executor) executeSinglePRWorkflow(
 		log.Printf("Warning: Failed to update progress: %v", err)
 	}
 
-	commitMsg := e.formatCommitMessage(result.Summary, task)
-	if err := e.commitAndPush(workdir, task.Repo, branchName, commitMsg, isNewBranch, token); err != nil {
+	pushRepo, fork := pushTarget(task)
+	var err error
+	if sha := resumableCommit(workdir, cp); sha != "" {
+		log.Printf("Pushing commit %s from previous attempt", sha)
+		e.addLog(task, "info", "Pushing commit %s from previous attempt", sha)
+		err = e.publishBranch(workdir, pushRepo, fork, branchName, isNewBranch, token)
+	} else if err = e.commitLocal(workdir, e.commitMessage(result.Summary, task, "")); err == nil {
+		e.recordCommit(cp, workdir)
+		err = e.publishBranch(workdir, pushRepo, fork, branchName, isNewBranch, token)
+	}
+	var conflict *MergeConflictError
+	if errors.As(err, &conflict) {
+		err = e.resolveMergeConflict(ctx, task, tracker, workdir, token, conflict, result)
+	}
+	var gate *gateError
+	if errors.As(err, &gate) {
+		tracker.FailTask("Commit and push changes")
+		if err := tracker.Update(token); err != nil {
+			log.Printf("Warning: Failed to update progress: %v", err)
+		}
+		return gate.err
+	}
+	if err != nil {
 		tracker.FailTask("Commit and push changes")
 		return e.handleError(task, tracker, token, fmt.Sprintf("Failed to commit/push: %v", err))
 	}
 	tracker.CompleteTask("Commit and push changes")
 
-	if !task.IsPR || task.PRState != "open" {
+	if opensNewPR(task) {
 		tracker.StartTask("Create pull request")
 		if err := tracker.Update(token); err != nil {
 			log.Printf("Warning: Failed to update progress: %v", err)
@@ -451,14 +579,18 @@ func (e *Executor) executeSinglePRWorkflow(
 	log.Printf("Creating PR from %s to %s", branchName, task.Branch)
 	e.addLog(task, "info", "Creating PR from %s to %s", branchName, task.Branch)
 
-	prURL, err := e.createPRLink(task.Repo, branchName, task.Branch, result.Summary)
+	prTitle, prBody := e.pullRequestText(task, result.Summary, "")
+	if forkWithoutEdits(task) {
+		prBody += fmt.Sprintf("\n\nFollow-up to #%d (%s:%s), which does not allow edits from maintainers.", task.Number, task.HeadRepo, task.PRBranch)
+	}
+	prURL, err := e.createPRLinkWithBody(task.Repo, branchName, task.Branch, prTitle, prBody)
 	if err != nil {
-		if !task.IsPR || task.PRState != "open" {
+		if opensNewPR(task) {
 			tracker.FailTask("Create pull request")
 		}
 		return e.handleError(task, tracker, token, fmt.Sprintf("Failed to create PR: %v", err))
 	}
-	if !task.IsPR || task.PRState != "open" {
+	if opensNewPR(task) {
 		tracker.CompleteTask("Create pull request")
 	}
 
@@ -476,6 +608,7 @@ func (e *Executor) executeSinglePRWorkflow(
 		log.Printf("Warning: Failed to update tracking comment: %v", err)
 		e.addLog(task, "error", "Failed to update tracking comment: %v", err)
 	}
+	e.recordPRLink(task, tracker, branchName)
 
 	log.Printf("Task completed successfully")
 	e.addLog(task, "success", "Task completed successfully")
@@ -484,7 +617,7 @@ func (e *Executor) executeSinglePRWorkflow(
 }
 
 // Execute executes a pilot task
-func (e *Executor) Execute(ctx context.Context, task *webhook.Task) error {
+func (e *Executor) Execute(ctx context.Context, task *webhook.Task) (err error) {
 	attempt := e.ensureAttempt(task)
 
 	e.updateStatus(task, taskstore.StatusRunning)
@@ -499,31 +632,36 @@ func (e *Executor) Execute(ctx context.Context, task *webhook.Task) error {
 	}
 
 	e.enrichPromptWithDiscussion(task, installToken.Token)
+	threads := e.collectReviewThreads(task, installToken.Token)
 
 	tracker := e.initializeTracker(task, contextMap, installToken.Token)
 
 	e.ensureTrackingLabel(task, tracker, installToken.Token)
 
-	workdir, cleanup, branchName, isNewBranch, err := e.cloneAndPrepareWorkspace(task, tracker, installToken.Token, contextMap)
+	cp := e.startCheckpoint(task)
+	workdir, cleanup, branchName, isNewBranch, err := e.prepareWorkspace(task, tracker, installToken.Token, contextMap, cp)
 	if err != nil {
 		return err
 	}
-	defer cleanup()
+	defer func() { e.finishAttempt(task, cp, workdir, cleanup, err) }()
 
-	result, err := e.generateCodeChanges(ctx, task, workdir, contextMap, tracker, installToken.Token)
-	if err != nil {
-		return err
+	result, resumed := e.resumeGeneration(task, tracker, workdir, cp)
+	if resumed && resumableCommit(workdir, cp) != "" {
+		// Only the push failed last time; checks already passed for this commit.
+		if err := e.executeSinglePRWorkflow(ctx, task, tracker, installToken.Token, result, workdir, branchName, isNewBranch, cp); err != nil {
+			return err
+		}
+		e.replyToReviewThreads(task, workdir, branchName, threads, installToken.Token)
+		return nil
 	}
-
-	if len(result.Files) > 0 {
-		log.Printf("%s returned %d file changes, applying them", e.provider.Name(), len(result.Files))
-		e.addLog(task, "info", "%s returned %d file changes, applying them", e.provider.Name(), len(result.Files))
-		if err := e.applyChanges(workdir, result.Files); err != nil {
-			return e.handleError(task, tracker, installToken.Token, fmt.Sprintf("Failed to apply changes: %v", err))
+	if !resumed {
+		if result, err = e.generateCodeChanges(ctx, task, workdir, contextMap, tracker, installToken.Token); err != nil {
+			return err
 		}
-	} else {
-		log.Printf("%s did not return file list, checking git status for direct modifications", e.provider.Name())
-		e.addLog(task, "info", "%s did not return file list, checking git status", e.provider.Name())
+		if err := e.applyGeneratedChanges(task, tracker, installToken.Token, workdir, result); err != nil {
+			return err
+		}
+		e.recordGeneration(cp, workdir, result)
 	}
 
 	plan, _, handled, err := e.prepareChangePlan(task, workdir, result, tracker, installToken.Token)
@@ -534,19 +672,72 @@ func (e *Executor) Execute(ctx context.Context, task *webhook.Task) error {
 		return nil
 	}
 
-	if len(plan.SubPRs) > 1 {
+	if err := e.scanForSecrets(task, workdir, tracker, installToken.Token); err != nil {
+		return err
+	}
+
+	if err := e.runVerifyCommands(task, workdir, tracker, installToken.Token); err != nil {
+		return err
+	}
+
+	e.recordDiff(task, workdir, installToken.Token)
+
+	// Review follow-ups always push to the branch under review.
+	if len(plan.SubPRs) > 1 && task.ReviewRound == 0 {
 		log.Printf("Using multi-PR workflow")
 		e.addLog(task, "info", "Using multi-PR workflow")
 		return e.executeMultiPR(ctx, task, workdir, plan, result, tracker, installToken.Token)
 	}
 
-	return e.executeSinglePRWorkflow(task, tracker, installToken.Token, result, workdir, branchName, isNewBranch)
+	if err := e.executeSinglePRWorkflow(ctx, task, tracker, installToken.Token, result, workdir, branchName, isNewBranch, cp); err != nil {
+		return err
+	}
+	e.replyToReviewThreads(task, workdir, branchName, threads, installToken.Token)
+	return nil
+}
+
+// applyGeneratedChanges writes the files returned by the provider; providers that
+// edit the workspace directly return none. The result is updated to hold the
+// whole files that were written in place of edits.
+func (e *Executor) applyGeneratedChanges(task *webhook.Task, tracker *github.CommentTracker, token, workdir string, result *claude.CodeResponse) error {
+	if len(result.Files) > 0 {
+		log.Printf("%s returned %d file changes, applying them", result.Provider, len(result.Files))
+		e.addLog(task, "info", "%s returned %d file changes, applying them", result.Provider, len(result.Files))
+		files, err := e.applyChanges(workdir, result.Files)
+		if err != nil {
+			return e.handleError(task, tracker, token, fmt.Sprintf("Failed to apply changes: %v", err))
+		}
+		result.Files = files
+	} else {
+		log.Printf("%s did not return file list, checking git status for direct modifications", result.Provider)
+		e.addLog(task, "info", "%s did not return file list, checking git status", result.Provider)
+	}
+
+	return nil
 }
 
-// applyChanges writes file changes to disk with enhanced validation and logging
-func (e *Executor) applyChanges(workdir string, changes []claude.FileChange) error {
+// applyChanges writes file changes to disk with enhanced validation and logging.
+// Edits are applied to the current files; when one fails nothing is written.
+// It returns the changes with edits resolved into whole files.
+func (e *Executor) applyChanges(workdir string, changes []claude.FileChange) ([]claude.FileChange, error) {
 	log.Printf("Applying %d file changes to %s", len(changes), workdir)
 
+	changes, err := resolveEdits(workdir, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := e.newChangeBudget()
+	for _, change := range changes {
+		size := int64(len(change.Content))
+		if cleanPath := filepath.Clean(change.Path); filepath.IsLocal(cleanPath) {
+			size = contentChangeSize(filepath.Join(workdir, cleanPath), change.Content)
+		}
+		if err := budget.add(change.Path, size); err != nil {
+			return nil, err
+		}
+	}
+
 	successCount := 0
 	for i, change := range changes {
 		if change.Path == "" {
@@ -557,22 +748,22 @@ func (e *Executor) applyChanges(workdir string, changes []claude.FileChange) err
 		cleanPath := filepath.Clean(change.Path)
 
 		if filepath.IsAbs(cleanPath) {
-			return fmt.Errorf("absolute paths are not allowed: %s", change.Path)
+			return nil, fmt.Errorf("absolute paths are not allowed: %s", change.Path)
 		}
 
 		if cleanPath == "." || cleanPath == ".." {
-			return fmt.Errorf("invalid file path %s: resolves outside workdir", change.Path)
+			return nil, fmt.Errorf("invalid file path %s: resolves outside workdir", change.Path)
 		}
 
 		filePath := filepath.Join(workdir, cleanPath)
 
 		relative, err := filepath.Rel(workdir, filePath)
 		if err != nil {
-			return fmt.Errorf("failed to resolve file path %s: %w", change.Path, err)
+			return nil, fmt.Errorf("failed to resolve file path %s: %w", change.Path, err)
 		}
 
 		if relative == ".." || strings.HasPrefix(relative, ".."+string(os.PathSeparator)) {
-			return fmt.Errorf("path traversal detected for %s", change.Path)
+			return nil, fmt.Errorf("path traversal detected for %s", change.Path)
 		}
 
 		// Debug logging
@@ -590,20 +781,20 @@ func (e *Executor) applyChanges(workdir string, changes []claude.FileChange) err
 		// Ensure directory exists
 		dir := filepath.Dir(filePath)
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory for %s: %w", change.Path, err)
+			return nil, fmt.Errorf("failed to create directory for %s: %w", change.Path, err)
 		}
 
 		// Write file
-		if err := os.WriteFile(filePath, []byte(change.Content), 0644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", change.Path, err)
+		if err := writeFileChange(workdir, filePath, change); err != nil {
+			return nil, fmt.Errorf("failed to write file %s: %w", change.Path, err)
 		}
 
-		// Verify file was written correctly
-		if writtenContent, err := os.ReadFile(filePath); err != nil {
-			return fmt.Errorf("failed to verify written file %s: %w", change.Path, err)
-		} else if string(writtenContent) != change.Content {
-			return fmt.Errorf("file content mismatch for %s: expected %d chars, got %d chars",
-				change.Path, len(change.Content), len(writtenContent))
+		// Verify file was written completely
+		if info, err := os.Stat(filePath); err != nil {
+			return nil, fmt.Errorf("failed to verify written file %s: %w", change.Path, err)
+		} else if change.Blob == "" && info.Size() != int64(len(change.Content)) {
+			return nil, fmt.Errorf("file content mismatch for %s: expected %d bytes, got %d bytes",
+				change.Path, len(change.Content), info.Size())
 		}
 
 		// Log successful write
@@ -616,15 +807,14 @@ func (e *Executor) applyChanges(workdir string, changes []claude.FileChange) err
 	}
 
 	log.Printf("File changes applied: %d successful out of %d requested", successCount, len(changes))
-	return nil
+	return changes, nil
 }
 
 // detectGitChanges checks if there are any uncommitted changes in the working directory
 // Enhanced with untracked files detection and debugging
 func (e *Executor) detectGitChanges(workdir string) (bool, error) {
 	// Use --untracked-files=all to include new files
-	cmd := execCommand("git", "status", "--porcelain", "--untracked-files=all")
-	cmd.Dir = workdir
+	cmd := gitCommand(workdir, "", "status", "--porcelain", "--untracked-files=all")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return false, fmt.Errorf("git status failed: %w\nOutput: %s", err, string(output))
@@ -705,20 +895,26 @@ func (e *Executor) formatCommitMessage(summary string, task *webhook.Task) strin
 	return strings.Join(parts, "\n\n")
 }
 
-func (e *Executor) prepareBranch(workdir string, task *webhook.Task) (string, bool, error) {
+// prepareBranch checks out the branch the task works on. token authenticates
+// fetches; it is passed per command and never stored in the workspace.
+func (e *Executor) prepareBranch(workdir string, task *webhook.Task, token string) (string, bool, error) {
+	if task.IsPR && task.PRState == "open" && task.PRBranch != "" && task.IsFork() {
+		return e.prepareForkBranch(workdir, task, token)
+	}
 	if task.IsPR && task.PRState == "open" && task.PRBranch != "" {
 		branchName := task.PRBranch
 		log.Printf("PR #%d is open, using existing branch: %s", task.Number, branchName)
 		e.addLog(task, "info", "PR is open, using existing branch: %s", branchName)
 
+		// Detached, so another task on the same PR can hold the branch in a
+		// worktree of the same mirror; publishBranch pushes HEAD to it.
 		commands := [][]string{
-			{"git", "fetch", "origin", branchName},
-			{"git", "checkout", branchName},
+			{"git", "fetch", remoteURL(task.Repo), branchName},
+			{"git", "checkout", "--detach", "FETCH_HEAD"},
 		}
 
 		for _, args := range commands {
-			cmd := execCommand(args[0], args[1:]...)
-			cmd.Dir = workdir
+			cmd := gitCommand(workdir, token, args[1:]...)
 			if output, err := cmd.CombinedOutput(); err != nil {
 				return "", false, fmt.Errorf("%s failed: %w\nOutput: %s", strings.Join(args, " "), err, string(output))
 			}
@@ -727,7 +923,10 @@ func (e *Executor) prepareBranch(workdir string, task *webhook.Task) (string, bo
 		return branchName, false, nil
 	}
 
-	branchName := e.generateWorkingBranchName(task)
+	branchName, err := e.workingBranchName(task)
+	if err != nil {
+		return "", false, err
+	}
 
 	switch {
 	case task.IsPR && task.PRState == "closed":
@@ -741,8 +940,7 @@ func (e *Executor) prepareBranch(workdir string, task *webhook.Task) (string, bo
 		e.addLog(task, "info", "Creating new branch for issue: %s", branchName)
 	}
 
-	cmd := execCommand("git", "checkout", "-b", branchName)
-	cmd.Dir = workdir
+	cmd := gitCommand(workdir, "", "checkout", "-b", branchName)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return "", false, fmt.Errorf("git checkout -b %s failed: %w\nOutput: %s", branchName, err, string(output))
 	}
@@ -787,66 +985,18 @@ func sanitizeBranchSegment(segment string) string {
 
 // commitAndPush commits changes and pushes to remote
 func (e *Executor) commitAndPush(workdir, repo, branchName, commitMessage string, isNewBranch bool, token string) error {
-	name, email := resolveGitIdentity()
-
-	setupCommands := [][]string{
-		{"git", "config", "user.name", name},
-		{"git", "config", "user.email", email},
-	}
-
-	for _, args := range setupCommands {
-		if err := runGitCommand(workdir, args, false); err != nil {
-			return err
-		}
-	}
-
-	commands := [][]string{
-		{"git", "add", "."},
-		{"git", "commit", "-m", commitMessage},
-	}
-
-	for _, args := range commands {
-		if err := runGitCommand(workdir, args, false); err != nil {
-			return err
-		}
-	}
-
-	cleanup := func() {}
-	if token != "" && repo != "" {
-		var err error
-		if cleanup, err = configurePushURL(workdir, repo, token); err != nil {
-			return err
-		}
-		defer cleanup()
-	}
-
-	pushArgs := []string{"git", "push", "origin", branchName}
-	if isNewBranch {
-		pushArgs = []string{"git", "push", "-u", "origin", branchName}
+	if err := e.commitLocal(workdir, commitMessage); err != nil {
+		return err
 	}
+	return e.publishBranch(workdir, repo, false, branchName, isNewBranch, token)
+}
 
-	// Push with small exponential backoff for transient network issues (KISS)
-	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			// 1s, 2s backoff
-			time.Sleep(time.Duration(attempt) * time.Second)
-		}
-		if err := runGitCommand(workdir, pushArgs, false); err != nil {
-			lastErr = err
-			if shouldRetryPush(err) {
-				continue
-			}
-			return err
-		}
-		lastErr = nil
-		break
-	}
-	if lastErr != nil {
-		return lastErr
+// commitLocal stages all changes and commits them with the bot identity
+func (e *Executor) commitLocal(workdir, commitMessage string) error {
+	if err := runGitCommand(workdir, []string{"git", "add", "."}, false); err != nil {
+		return err
 	}
-
-	return nil
+	return e.commitStaged(workdir, commitMessage)
 }
 
 // shouldRetryPush returns true for common transient network errors on git push
@@ -887,119 +1037,62 @@ func resolveGitIdentity() (string, string) {
 	return name, email
 }
 
-func configurePushURL(workdir, repo, token string) (func(), error) {
-	if strings.TrimSpace(token) == "" {
-		return func() {}, nil
+// remoteURL returns the URL the executor fetches from and pushes to for repo.
+// It is built from task data only: the token travels in an HTTP header (see
+// github.GitAuthEnv), so a remote or remote.pushDefault set in the workspace
+// must never decide where it goes. Without a repo, origin is used.
+func remoteURL(repo string) string {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		return "origin"
 	}
-
-	pushURL, err := resolvePushURL(workdir, repo, token)
-	if err != nil || pushURL == "" {
-		return func() {}, nil
-	}
-
-	if err := runGitCommand(workdir, []string{"git", "config", "remote.origin.pushurl", pushURL}, true); err != nil {
-		return nil, err
-	}
-
-	cleanup := func() {
-		if err := runGitCommand(workdir, []string{"git", "config", "--unset", "remote.origin.pushurl"}, false); err != nil {
-			if !strings.Contains(err.Error(), "No such section or key") {
-				log.Printf("Warning: cleanup of remote.origin.pushurl failed: %v", err)
-			}
-		}
-	}
-
-	return cleanup, nil
+	return fmt.Sprintf("https://github.com/%s.git", repo)
 }
 
-func resolvePushURL(workdir, repo, token string) (string, error) {
-	remoteURL, err := getRemoteOriginURL(workdir)
-	if err == nil {
-		// Prefer using existing HTTPS GitHub remote when possible
-		if url := injectToken(remoteURL, token); url != "" {
-			return url, nil
-		}
-		// If the existing remote is a GitHub SSH URL, configure a separate
-		// pushurl that uses HTTPS + token so we can push without SSH keys.
-		if looksLikeGitHubSSH(remoteURL) && strings.TrimSpace(repo) != "" {
-			fallback := fmt.Sprintf("https://github.com/%s", strings.TrimSpace(repo))
-			if url := injectToken(fallback, token); url != "" {
-				return url, nil
-			}
-		}
-		// Could not construct a tokenized push URL; return empty to skip override.
-		return "", nil
-	}
+// gitEnvAllow are the server variables the executor's git commands keep.
+// Everything else, such as the App key, webhook secret and provider API keys,
+// is dropped so a hook or filter in the workspace never sees it.
+var gitEnvAllow = []string{"PATH", "HOME", "GIT_*"}
 
-	if strings.TrimSpace(repo) == "" {
-		return "", nil
-	}
-
-	// Fallback to GitHub HTTPS URL when remote isn't available.
-	fallback := fmt.Sprintf("https://github.com/%s", strings.TrimSpace(repo))
-	return injectToken(fallback, token), nil
+// gitEnv returns the environment for the executor's git commands, with token
+// passed through github.GitAuthEnv when set.
+func gitEnv(token string) []string {
+	env := append(sandbox.FilterEnv(os.Environ(), gitEnvAllow), "GIT_TERMINAL_PROMPT=0")
+	return append(env, github.GitAuthEnv(token)...)
 }
 
-func looksLikeGitHubSSH(remote string) bool {
-	s := strings.TrimSpace(remote)
-	if s == "" {
-		return false
-	}
-	// Common SSH URL patterns for GitHub
-	if strings.HasPrefix(s, "git@github.com:") {
-		return true
-	}
-	if strings.HasPrefix(strings.ToLower(s), "ssh://git@github.com/") {
-		return true
-	}
-	return false
-}
-
-func getRemoteOriginURL(workdir string) (string, error) {
-	cmd := execCommand("git", "remote", "get-url", "origin")
+// gitCommand prepares git with args in workdir. Hooks are disabled: the
+// provider can write to .git/hooks, and the executor's commands run outside
+// the sandbox. The bot identity is passed per command rather than written to
+// the config, which cached worktrees share.
+func gitCommand(workdir, token string, args ...string) *exec.Cmd {
+	name, email := resolveGitIdentity()
+	options := []string{"-c", "core.hooksPath=/dev/null", "-c", "user.name=" + name, "-c", "user.email=" + email}
+	cmd := execCommand("git", append(options, args...)...)
 	cmd.Dir = workdir
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("git remote get-url origin failed: %w\nOutput: %s", err, strings.TrimSpace(string(output)))
-	}
-
-	return strings.TrimSpace(string(output)), nil
+	cmd.Env = gitEnv(token)
+	return cmd
 }
 
-func injectToken(rawURL, token string) string {
-	rawURL = strings.TrimSpace(rawURL)
-	if rawURL == "" {
-		return ""
-	}
-
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return ""
-	}
-
-	if parsed.Scheme != "https" {
-		return ""
-	}
-
-	host := strings.ToLower(parsed.Host)
-	if host == "" || !strings.Contains(host, "github.com") {
-		return ""
-	}
-
-	parsed.User = url.UserPassword("x-access-token", token)
-	return parsed.String()
+func runGitCommand(workdir string, args []string, sensitive bool) error {
+	return runAuthGitCommand(workdir, "", args, sensitive)
 }
 
-func runGitCommand(workdir string, args []string, sensitive bool) error {
+// runAuthGitCommand runs a git command that talks to the remote, passing token
+// through the environment (see github.GitAuthEnv).
+func runAuthGitCommand(workdir, token string, args []string, sensitive bool) error {
 	if len(args) == 0 {
 		return fmt.Errorf("git command is empty")
 	}
 
-	cmd := execCommand(args[0], args[1:]...)
-	cmd.Dir = workdir
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var cmd *exec.Cmd
+	if args[0] == "git" {
+		cmd = gitCommand(workdir, token, args[1:]...)
+	} else {
+		cmd = execCommand(args[0], args[1:]...)
+		cmd.Dir = workdir
+		cmd.Env = gitEnv(token)
+	}
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1017,11 +1110,15 @@ func runGitCommand(workdir string, args []string, sensitive bool) error {
 
 // createPRLink generates a GitHub URL for creating a PR
 func (e *Executor) createPRLink(repo, head, base, title string) (string, error) {
-	// Generate GitHub compare URL that allows user to create PR with prefilled title/body
+	// KISS: reuse title as body when a separate body isn't provided by caller
+	return e.createPRLinkWithBody(repo, head, base, title, title)
+}
+
+// createPRLinkWithBody generates a GitHub compare URL with a prefilled title and body
+func (e *Executor) createPRLinkWithBody(repo, head, base, title, body string) (string, error) {
 	// Format: https://github.com/owner/repo/compare/base...head?expand=1&quick_pull=1&title=...&body=...
 	t := url.QueryEscape(strings.TrimSpace(title))
-	// KISS: reuse title as body when a separate body isn't provided by caller
-	b := t
+	b := url.QueryEscape(strings.TrimSpace(body))
 	prURL := fmt.Sprintf("https://github.com/%s/compare/%s...%s?expand=1&quick_pull=1&title=%s",
 		repo, url.PathEscape(base), url.PathEscape(head), t)
 	prURL = prURL + "&body=" + b
@@ -1033,6 +1130,8 @@ func (e *Executor) createPRLink(repo, head, base, title string) (string, error)
 
 // handleError updates the tracking comment with error details and returns the error
 func (e *Executor) handleError(task *webhook.Task, tracker *github.CommentTracker, token, errorMsg string) error {
+	errorMsg = e.redactSecrets(errorMsg, token)
+
 	tracker.MarkEnd()
 	tracker.SetFailed(errorMsg)
 	e.updateStatus(task, taskstore.StatusFailed)
@@ -1067,6 +1166,20 @@ func isNonRetryableTaskError(msg string) bool {
 		return true
 	case strings.Contains(lower, "please run /login"):
 		return true
+	case strings.Contains(lower, "secret scan blocked"):
+		return true
+	case strings.Contains(lower, "policy violation"):
+		return true
+	case strings.Contains(lower, "invalid repository configuration"):
+		return true
+	case strings.Contains(lower, "invalid branch name"):
+		return true
+	case strings.Contains(lower, "merge conflict rebasing"):
+		return true
+	case strings.Contains(lower, changeLimitMessage):
+		return true
+	case strings.Contains(lower, "over budget:"):
+		return true
 	default:
 		return false
 	}
@@ -1094,9 +1207,9 @@ func deriveHelpfulHints(msg string, task *webhook.Task) []string {
 		hints = append(hints, "If this is a PR from a fork, ensure the workflow/app has permission to push to the branch.")
 	}
 
-	// No credential prompt allowed (common when token injection failed)
+	// No credential prompt allowed (the request went out without the token header)
 	if strings.Contains(s, "could not read username for 'https://github.com': terminal prompts disabled") {
-		hints = append(hints, "Token not injected into remote.pushurl. Confirm repository string is correct and pushurl configuration succeeded.")
+		hints = append(hints, "No installation token was sent with the request. The token is passed per command as an http.extraHeader; confirm the App installation token could be created for this repository.")
 	}
 
 	// Branch/ref issues
@@ -1109,6 +1222,34 @@ func deriveHelpfulHints(msg string, task *webhook.Task) []string {
 		hints = append(hints, "Transient network issue detected. A quick retry may succeed; check network connectivity.")
 	}
 
+	// Secret scanning gate
+	if strings.Contains(s, "secret scan blocked") {
+		hints = append(hints, "Remove the secret from the generated change, or add an allow rule to "+secretscan.AllowListPath+" on the base branch for false positives.")
+	}
+
+	// Change policy
+	if strings.Contains(s, "policy violation") {
+		hints = append(hints, "Adjust the request to stay within the repository policy, or update "+policy.Path+" on the base branch.")
+	}
+
+	// Repository configuration
+	if strings.Contains(s, "invalid repository configuration") {
+		hints = append(hints, "Fix "+repoconfig.Path+" on the default branch; unknown keys and invalid values are rejected.")
+	}
+	if strings.Contains(s, "invalid branch name") {
+		hints = append(hints, "Adjust branch_template (or BRANCH_TEMPLATE) so it renders a valid git ref; use .TitleSlug instead of .Title.")
+	}
+
+	// Concurrent pushes to the PR branch
+	if strings.Contains(s, "merge conflict rebasing") {
+		hints = append(hints, "The branch was updated while the task ran and the conflicts could not be resolved automatically. Resolve the listed files on the branch, then trigger the task again.")
+	}
+
+	// Oversized generated changes
+	if strings.Contains(s, changeLimitMessage) {
+		hints = append(hints, "Split the request into smaller tasks, or raise CHANGE_MAX_FILE_MB / CHANGE_MAX_TOTAL_MB on the server.")
+	}
+
 	// Generic guidance
 	if len(hints) == 0 {
 		hints = append(hints, "Review logs above for the failing step and verify GitHub permissions and branch setup.")
@@ -1124,6 +1265,10 @@ func (e *Executor) handleResponseOnly(task *webhook.Task, tracker *github.Commen
 	e.updateStatus(task, taskstore.StatusCompleted)
 	e.addLog(task, "success", "Task completed with response only")
 
+	if task != nil && task.IsPR && len(result.Comments) > 0 {
+		e.postInlineReview(task, tracker, result, token)
+	}
+
 	if err := tracker.Update(token); err != nil {
 		log.Printf("Warning: Failed to update tracking comment: %v", err)
 		e.addLog(task, "error", "Failed to update tracking comment: %v", err)
@@ -1313,13 +1458,18 @@ func (e *Executor) addLog(task *webhook.Task, level, format string, args ...inte
 
 // getChangedFiles gets list of changed files from git status
 func (e *Executor) getChangedFiles(workdir string) ([]claude.FileChange, error) {
-	cmd := execCommand("git", "status", "--porcelain")
-	cmd.Dir = workdir
+	cmd := gitCommand(workdir, "", "status", "--porcelain")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("git status failed: %w", err)
 	}
 
+	stats, err := diffNumstat(workdir)
+	if err != nil {
+		log.Printf("Warning: %v; estimating line counts from file contents", err)
+	}
+	budget := e.newChangeBudget()
+
 	var changes []claude.FileChange
 	// Don't TrimSpace the whole output - it will corrupt the first line!
 	// Just split by newline and handle empty lines
@@ -1357,37 +1507,28 @@ func (e *Executor) getChangedFiles(workdir string) ([]claude.FileChange, error)
 					return err
 				}
 
-				content, err := os.ReadFile(path)
-				if err != nil {
-					log.Printf("Warning: Could not read %s: %v", relPath, err)
-					return nil
+				change, ok, err := readChangedFile(workdir, relPath, stats, budget)
+				if ok {
+					changes = append(changes, change)
 				}
-
-				changes = append(changes, claude.FileChange{
-					Path:    relPath,
-					Content: string(content),
-				})
-				return nil
+				return err
 			})
 			if err != nil {
+				if errors.Is(err, errChangeLimit) {
+					return nil, err
+				}
 				log.Printf("Warning: Could not walk directory %s: %v", filePath, err)
 			}
 			continue
 		}
 
-		// Read file content
-		fullPath := filepath.Join(workdir, filePath)
-		content, err := os.ReadFile(fullPath)
+		change, ok, err := readChangedFile(workdir, filePath, stats, budget)
 		if err != nil {
-			// File might be deleted, skip
-			log.Printf("Warning: Could not read %s: %v", filePath, err)
-			continue
+			return nil, err
+		}
+		if ok {
+			changes = append(changes, change)
 		}
-
-		changes = append(changes, claude.FileChange{
-			Path:    filePath,
-			Content: string(content),
-		})
 	}
 
 	return changes, nil
@@ -1440,7 +1581,12 @@ func (e *Executor) executeMultiPR(
 		}
 
 		// Create branch for this sub-PR
-		branchName := generateSubPRBranchName(task.Number, string(subPR.Category))
+		branchName, err := e.subPRBranchName(task, string(subPR.Category))
+		if err != nil {
+			log.Printf("Warning: Failed to name sub-PR #%d: %v", idx, err)
+			e.addLog(task, "error", "Failed to name sub-PR #%d: %v", idx, err)
+			continue
+		}
 
 		// Commit only files from this sub-PR
 		if err := e.commitSubPR(workdir, task.Repo, branchName, subPR, task, token); err != nil {
@@ -1450,7 +1596,8 @@ func (e *Executor) executeMultiPR(
 		}
 
 		// Generate PR URL
-		prURL, _ := e.createPRLink(task.Repo, branchName, task.Branch, subPR.Name)
+		prTitle, prBody := e.pullRequestText(task, subPR.Name, string(subPR.Category))
+		prURL, _ := e.createPRLinkWithBody(task.Repo, branchName, task.Branch, prTitle, prBody)
 		branchURL := fmt.Sprintf("https://github.com/%s/tree/%s", task.Repo, url.PathEscape(branchName))
 
 		// Record created PR
@@ -1465,6 +1612,7 @@ func (e *Executor) executeMultiPR(
 		}
 		createdPRs = append(createdPRs, createdPR)
 		tracker.AddCreatedPR(createdPR)
+		e.recordPRLink(task, tracker, branchName)
 
 		// Update comment with progress
 		if err := tracker.Update(token); err != nil {
@@ -1494,15 +1642,13 @@ func (e *Executor) executeMultiPR(
 // commitSubPR commits only the files from a specific sub-PR
 func (e *Executor) commitSubPR(workdir, repo, branchName string, subPR github.SubPR, task *webhook.Task, token string) error {
 	// Reset to base branch first
-	resetCmd := execCommand("git", "reset", "--hard", "HEAD")
-	resetCmd.Dir = workdir
+	resetCmd := gitCommand(workdir, "", "reset", "--hard", "HEAD")
 	if output, err := resetCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git reset failed: %w\nOutput: %s", err, string(output))
 	}
 
 	// Clean untracked files
-	cleanCmd := execCommand("git", "clean", "-fd")
-	cleanCmd.Dir = workdir
+	cleanCmd := gitCommand(workdir, "", "clean", "-fd")
 	if output, err := cleanCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git clean failed: %w\nOutput: %s", err, string(output))
 	}
@@ -1517,59 +1663,22 @@ func (e *Executor) commitSubPR(workdir, repo, branchName string, subPR github.Su
 		}
 
 		// Write file
-		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
+		if err := writeFileChange(workdir, filePath, file); err != nil {
 			return fmt.Errorf("failed to write file %s: %w", file.Path, err)
 		}
 	}
 
 	// Create branch and commit
-	commitMsg := e.formatCommitMessage(subPR.Name+"\n\n"+subPR.Description, task)
-	name, email := resolveGitIdentity()
-	if err := runGitCommand(workdir, []string{"git", "config", "user.name", name}, false); err != nil {
-		return err
-	}
-	if err := runGitCommand(workdir, []string{"git", "config", "user.email", email}, false); err != nil {
-		return err
-	}
-
+	commitMsg := e.commitMessage(subPR.Name+"\n\n"+subPR.Description, task, string(subPR.Category))
 	if err := runGitCommand(workdir, []string{"git", "checkout", "-b", branchName}, false); err != nil {
 		return err
 	}
 	if err := runGitCommand(workdir, []string{"git", "add", "."}, false); err != nil {
 		return err
 	}
-	if err := runGitCommand(workdir, []string{"git", "commit", "-m", commitMsg}, false); err != nil {
+	if err := e.commitStaged(workdir, commitMsg); err != nil {
 		return err
 	}
 
-	cleanup := func() {}
-	if token != "" && repo != "" {
-		var err error
-		if cleanup, err = configurePushURL(workdir, repo, token); err != nil {
-			return err
-		}
-		defer cleanup()
-	}
-
-	// Push with small exponential backoff for transient network issues
-	var pushErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			time.Sleep(time.Duration(attempt) * time.Second)
-		}
-		if err := runGitCommand(workdir, []string{"git", "push", "-u", "origin", branchName}, false); err != nil {
-			pushErr = err
-			if shouldRetryPush(err) {
-				continue
-			}
-			return err
-		}
-		pushErr = nil
-		break
-	}
-	if pushErr != nil {
-		return pushErr
-	}
-
-	return nil
+	return e.publishBranch(workdir, repo, false, branchName, true, token)
 }
@@ -109,15 +109,15 @@ func TestExecutor_GetChangedFiles_BinaryFiles(t *testing.T) {
 	for _, change := range changes {
 		if change.Path == "text.go" {
 			foundText = true
-			if change.Content != "package main" {
+			if changeContent(t, tmpDir, change) != "package main" {
 				t.Errorf("text.go content incorrect")
 			}
 		}
 		if change.Path == "image.png" {
 			foundBinary = true
 			// Binary content should be read as-is
-			if len(change.Content) != len(binaryData) {
-				t.Errorf("image.png content length = %d, want %d", len(change.Content), len(binaryData))
+			if content := changeContent(t, tmpDir, change); content != string(binaryData) {
+				t.Errorf("image.png content length = %d, want %d", len(content), len(binaryData))
 			}
 		}
 	}
@@ -1,33 +1,64 @@
 package executor
 
 import (
-	"os/exec"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
-// Test that when origin is a GitHub SSH remote, resolvePushURL returns a
-// tokenized HTTPS pushurl for the given owner/repo.
-func TestResolvePushURL_GitHubSSHRemoteGeneratesTokenizedHTTPS(t *testing.T) {
-	workdir := t.TempDir()
-	token := "token-xyz"
-	repo := "owner/repo"
+func TestRemoteURL(t *testing.T) {
+	if got, want := remoteURL("owner/repo"), "https://github.com/owner/repo.git"; got != want {
+		t.Fatalf("remoteURL() = %q, want %q", got, want)
+	}
+	if got := remoteURL(" "); got != "origin" {
+		t.Fatalf("remoteURL() without repo = %q, want origin", got)
+	}
+}
 
-	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
-		if name == "git" && len(args) == 3 && args[0] == "remote" && args[1] == "get-url" && args[2] == "origin" {
-			return exec.Command("bash", "-lc", "printf 'git@github.com:owner/repo.git'")
-		}
-		// No other git commands are expected in this test path
-		return exec.Command("bash", "-lc", "printf ''")
-	})
-	defer restore()
+// The workspace decides nothing about where the token goes: remotes and
+// remote.pushDefault written by the provider are ignored.
+func TestPushWithGit_IgnoresWorkspaceRemotes(t *testing.T) {
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	serveGitHubRepo(t, "owner/repo", origin)
+	attacker := t.TempDir()
+	runGitIn(t, attacker, "init", "-q", "--bare")
+
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "remote", "add", "origin", attacker)
+	runGitIn(t, workdir, "remote", "add", "fork", attacker)
+	runGitIn(t, workdir, "config", "remote.pushDefault", "fork")
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature/token")
 
-	got, err := resolvePushURL(workdir, repo, token)
-	if err != nil {
-		t.Fatalf("resolvePushURL error = %v", err)
+	if err := pushWithGit(workdir, "owner/repo", "feature/token", "ghs_secret"); err != nil {
+		t.Fatalf("pushWithGit() error = %v", err)
+	}
+	if pushed, _ := gitOutput(origin, "rev-parse", "feature/token"); pushed == "" {
+		t.Fatal("branch was not pushed to owner/repo")
 	}
+	if leaked, _ := gitOutput(attacker, "for-each-ref"); leaked != "" {
+		t.Fatalf("push went to the workspace remote: %s", leaked)
+	}
+}
+
+func TestPushWithGit_NeverWritesToken(t *testing.T) {
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	serveGitHubRepo(t, "owner/repo", origin)
+	workdir := initCommittedRepo(t, map[string]string{"README.md": "hi\n"})
+	runGitIn(t, workdir, "checkout", "-q", "-b", "feature/token")
 
-	want := "https://x-access-token:" + token + "@github.com/" + repo
-	if got != want {
-		t.Fatalf("resolvePushURL = %q, want %q", got, want)
+	if err := pushWithGit(workdir, "owner/repo", "feature/token", "ghs_secret"); err != nil {
+		t.Fatalf("pushWithGit() error = %v", err)
 	}
+	if pushed, _ := gitOutput(origin, "rev-parse", "feature/token"); pushed == "" {
+		t.Fatal("branch was not pushed")
+	}
+	filepath.WalkDir(filepath.Join(workdir, ".git"), func(path string, d os.DirEntry, err error) error {
+		if data, _ := os.ReadFile(path); err == nil && !d.IsDir() && strings.Contains(string(data), "ghs_secret") {
+			t.Fatalf("token stored in %s", path)
+		}
+		return nil
+	})
 }
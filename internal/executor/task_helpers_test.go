@@ -18,7 +18,7 @@ func TestStubExecCommandForTest(t *testing.T) {
 	var calls int
 	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
 		calls++
-		return exec.Command("bash", "-lc", "true")
+		return exec.Command("bash", "-c", "true")
 	})
 
 	cmd := execCommand("git", "status")
@@ -126,8 +126,8 @@ func TestExecutorBuildExecutionContext(t *testing.T) {
 
 func TestExecutorPrepareChangePlan_ResponseOnly(t *testing.T) {
 	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
-		if name == "git" && len(args) > 0 && args[0] == "status" {
-			return exec.Command("bash", "-lc", "printf ''")
+		if sub := gitArgs(args); name == "git" && len(sub) > 0 && sub[0] == "status" {
+			return exec.Command("bash", "-c", "printf ''")
 		}
 		return exec.Command(name, args...)
 	})
@@ -182,8 +182,8 @@ func TestExecutorPrepareChangePlan_WithChangesTriggersPlan(t *testing.T) {
 	gitOutput := buildGitStatusOutput(paths)
 
 	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
-		if name == "git" && len(args) > 0 && args[0] == "status" {
-			return exec.Command("bash", "-lc", fmt.Sprintf("printf '%s'", escapeSingleQuotes(gitOutput)))
+		if sub := gitArgs(args); name == "git" && len(sub) > 0 && sub[0] == "status" {
+			return exec.Command("bash", "-c", fmt.Sprintf("printf '%s'", escapeSingleQuotes(gitOutput)))
 		}
 		return exec.Command(name, args...)
 	})
@@ -558,7 +558,7 @@ func TestExecutor_GetChangedFiles(t *testing.T) {
 	for _, change := range changes {
 		if change.Path == "existing.go" {
 			foundExisting = true
-			if !strings.Contains(change.Content, "modified") {
+			if !strings.Contains(changeContent(t, tmpDir, change), "modified") {
 				t.Error("existing.go content should contain 'modified'")
 			}
 		}
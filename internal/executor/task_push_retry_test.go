@@ -10,23 +10,23 @@ func TestCommitAndPush_RetryTransientPush_SucceedsOnThirdAttempt(t *testing.T) {
 
 	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
 		if name == "git" {
-			if len(args) > 0 && args[0] == "push" {
+			if args = gitArgs(args); len(args) > 0 && args[0] == "push" {
 				pushCalls++
 				if pushCalls < 3 {
 					// Simulate transient failure with stderr marker included in error string
-					return exec.Command("bash", "-lc", "echo 'eof' 1>&2; exit 1")
+					return exec.Command("bash", "-c", "echo 'eof' 1>&2; exit 1")
 				}
-				return exec.Command("bash", "-lc", "true")
+				return exec.Command("bash", "-c", "true")
 			}
 			// Succeed for all other git commands (config, add, commit, etc.)
-			return exec.Command("bash", "-lc", "true")
+			return exec.Command("bash", "-c", "true")
 		}
 		return exec.Command(name, args...)
 	})
 	defer restore()
 
 	exec := New(nil, nil)
-	// Empty repo/token to skip pushurl configuration complexity in test
+	// Without a repo the push goes to origin, which the stub answers
 	if err := exec.commitAndPush(t.TempDir(), "", "feature/test", "msg", false, ""); err != nil {
 		t.Fatalf("commitAndPush() error = %v, want success after retries", err)
 	}
@@ -40,12 +40,12 @@ func TestCommitAndPush_NonRetryablePush_FailsImmediately(t *testing.T) {
 
 	restore := StubExecCommandForTest(func(name string, args ...string) *exec.Cmd {
 		if name == "git" {
-			if len(args) > 0 && args[0] == "push" {
+			if args = gitArgs(args); len(args) > 0 && args[0] == "push" {
 				pushCalls++
 				// Simulate non-retryable permission error (403)
-				return exec.Command("bash", "-lc", "echo 'HTTP 403 Forbidden' 1>&2; exit 1")
+				return exec.Command("bash", "-c", "echo 'HTTP 403 Forbidden' 1>&2; exit 1")
 			}
-			return exec.Command("bash", "-lc", "true")
+			return exec.Command("bash", "-c", "true")
 		}
 		return exec.Command(name, args...)
 	})
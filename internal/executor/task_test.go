@@ -64,7 +64,7 @@ func TestApplyChangesDebugLogging(t *testing.T) {
 	defer os.Unsetenv("DEBUG_CLAUDE_PARSING")
 
 	change := claude.FileChange{Path: "debug.go", Content: "package debug\n"}
-	if err := executor.applyChanges(dir, []claude.FileChange{change}); err != nil {
+	if _, err := executor.applyChanges(dir, []claude.FileChange{change}); err != nil {
 		t.Fatalf("applyChanges with debug logging failed: %v", err)
 	}
 
@@ -130,7 +130,7 @@ func TestApplyChanges(t *testing.T) {
 			}
 
 			executor := &Executor{}
-			err := executor.applyChanges(testDir, tt.changes)
+			_, err := executor.applyChanges(testDir, tt.changes)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("applyChanges() error = %v, wantErr %v", err, tt.wantErr)
@@ -319,7 +319,7 @@ func TestApplyChanges_ErrorHandling(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			workdir := tt.setup()
-			err := executor.applyChanges(workdir, tt.changes)
+			_, err := executor.applyChanges(workdir, tt.changes)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("applyChanges() error = %v, wantErr %v", err, tt.wantErr)
@@ -1117,6 +1117,7 @@ func TestExecutor_Execute_SinglePRWorkflow(t *testing.T) {
 	tmpRoot := t.TempDir()
 	remoteDir := filepath.Join(tmpRoot, "remote.git")
 	runGit(t, "", "git", "init", "--bare", remoteDir)
+	serveGitHubRepo(t, "owner/repo", remoteDir)
 
 	seedDir := filepath.Join(tmpRoot, "seed")
 	runGit(t, "", "git", "clone", remoteDir, seedDir)
@@ -1225,7 +1226,7 @@ func TestExecutor_CommitSubPR_WritesFiles(t *testing.T) {
 	stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
 		cmd := append([]string{name}, args...)
 		commands = append(commands, cmd)
-		return exec.Command("bash", "-lc", "true")
+		return exec.Command("bash", "-c", "true")
 	})
 
 	tmpDir := t.TempDir()
@@ -1257,7 +1258,7 @@ func TestExecutor_CommitSubPR_WritesFiles(t *testing.T) {
 
 func TestExecutor_ExecuteMultiPR_CreatesAndSkips(t *testing.T) {
 	stubExecCommand(t, func(string, ...string) *exec.Cmd {
-		return exec.Command("bash", "-lc", "true")
+		return exec.Command("bash", "-c", "true")
 	})
 
 	mockGH := github.NewMockGHClient()
@@ -1340,10 +1341,10 @@ func TestExecutor_GetChangedFiles_DirectoryAndMissing(t *testing.T) {
 	}
 
 	stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
-		if name == "git" && len(args) >= 2 && args[0] == "status" {
-			return exec.Command("bash", "-lc", "printf '?? dir/\\n M top.txt\\n?? missing.txt\\n?? s\\n'")
+		if args = gitArgs(args); name == "git" && len(args) >= 2 && args[0] == "status" {
+			return exec.Command("bash", "-c", "printf '?? dir/\\n M top.txt\\n?? missing.txt\\n?? s\\n'")
 		}
-		return exec.Command("bash", "-lc", "true")
+		return exec.Command("bash", "-c", "true")
 	})
 
 	executor := &Executor{}
@@ -1383,6 +1384,14 @@ func stubExecCommand(t *testing.T, handler func(name string, args ...string) *ex
 	})
 }
 
+// gitArgs drops the options gitCommand puts before the git subcommand.
+func gitArgs(args []string) []string {
+	for len(args) >= 2 && args[0] == "-c" {
+		args = args[2:]
+	}
+	return args
+}
+
 func TestApplyChanges_AdditionalEdgeCases(t *testing.T) {
 	executor := &Executor{}
 
@@ -1446,7 +1455,7 @@ func TestApplyChanges_AdditionalEdgeCases(t *testing.T) {
 				defer tt.cleanup(workdir)
 			}
 
-			err = executor.applyChanges(workdir, tt.changes)
+			_, err = executor.applyChanges(workdir, tt.changes)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("applyChanges() error = %v, wantErr %v", err, tt.wantErr)
@@ -3017,7 +3026,7 @@ func TestApplyChangesEnhanced(t *testing.T) {
 				}
 			}
 
-			err := executor.applyChanges(testDir, tt.changes)
+			_, err := executor.applyChanges(testDir, tt.changes)
 
 			if (err != nil) != tt.wantError {
 				t.Errorf("applyChanges() error = %v, wantError %v", err, tt.wantError)
@@ -3187,7 +3196,7 @@ func TestPrepareBranchCreatesNewBranchForIssue(t *testing.T) {
 		IsPR:   false,
 	}
 
-	branchName, isNewBranch, err := executor.prepareBranch(tmpDir, task)
+	branchName, isNewBranch, err := executor.prepareBranch(tmpDir, task, "")
 	if err != nil {
 		t.Fatalf("prepareBranch returned error: %v", err)
 	}
@@ -3210,3 +3219,36 @@ func TestPrepareBranchCreatesNewBranchForIssue(t *testing.T) {
 		t.Fatalf("expected HEAD on %q, got %q", branchName, current)
 	}
 }
+
+func TestPrepareBranch_SamePRInTwoWorktrees(t *testing.T) {
+	origin := t.TempDir()
+	runGitIn(t, origin, "init", "-q", "--bare")
+	seed := initCommittedRepo(t, map[string]string{"README.md": "base\n"})
+	runGitIn(t, seed, "push", "-q", origin, "HEAD:refs/heads/main", "HEAD:refs/heads/feature")
+	serveGitHubRepo(t, "owner/repo", origin)
+
+	// Two tasks on the same PR get worktrees of one repository, like the workspace cache hands out.
+	first := t.TempDir()
+	runGitIn(t, first, "clone", "-q", "-b", "main", origin, ".")
+	second := filepath.Join(t.TempDir(), "second")
+	runGitIn(t, first, "worktree", "add", "-q", "--detach", second, "origin/main")
+
+	task := &webhook.Task{Repo: "owner/repo", Number: 5, IsPR: true, PRState: "open", PRBranch: "feature"}
+	executor := NewWithClient(nil, nil, github.NewMockGHClient())
+	for _, workdir := range []string{first, second} {
+		if branch, isNew, err := executor.prepareBranch(workdir, task, ""); err != nil || branch != "feature" || isNew {
+			t.Fatalf("prepareBranch(%s) = %q, %v, %v", workdir, branch, isNew, err)
+		}
+	}
+
+	writeTestFile(t, second, "README.md", "second task\n")
+	if err := executor.commitLocal(second, "Second task"); err != nil {
+		t.Fatalf("commitLocal() error = %v", err)
+	}
+	if err := executor.publishBranch(second, "owner/repo", false, "feature", false, ""); err != nil {
+		t.Fatalf("publishBranch() error = %v", err)
+	}
+	if subject, _ := gitOutput(origin, "log", "-1", "--format=%s", "feature"); subject != "Second task" {
+		t.Fatalf("origin feature = %q, want pushed commit", subject)
+	}
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"log"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/pricing"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// WithPrices sets the price table used for providers that report tokens but
+// no cost. The default is pricing.Default().
+func (e *Executor) WithPrices(prices pricing.Table) *Executor {
+	e.prices = prices
+	return e
+}
+
+// recordUsage completes the provider's usage with the measured duration,
+// prices it when the provider reported no cost of its own, shows it in the
+// tracking comment and adds it to the task store.
+func (e *Executor) recordUsage(task *webhook.Task, tracker *github.CommentTracker, result *claude.CodeResponse, elapsed time.Duration) {
+	e.priceUsage(result, elapsed)
+	tracker.SetUsage(result.Usage)
+	e.storeUsage(task, result.Usage, result.CostUSD)
+}
+
+// addUsage records a further provider run of the task, such as a conflict
+// resolution, by adding its usage and cost to those of result.
+func (e *Executor) addUsage(task *webhook.Task, tracker *github.CommentTracker, result, run *claude.CodeResponse, elapsed time.Duration) {
+	e.priceUsage(run, elapsed)
+	usage := &result.Usage
+	if usage.Model == "" {
+		usage.Model = run.Usage.Model
+	}
+	usage.InputTokens += run.Usage.InputTokens
+	usage.OutputTokens += run.Usage.OutputTokens
+	usage.CacheReadTokens += run.Usage.CacheReadTokens
+	usage.CacheWriteTokens += run.Usage.CacheWriteTokens
+	usage.Turns += run.Usage.Turns
+	usage.Duration += run.Usage.Duration
+	result.CostUSD += run.CostUSD
+	tracker.SetUsage(result.Usage)
+	e.storeUsage(task, run.Usage, run.CostUSD)
+}
+
+// priceUsage completes the usage of result with the measured duration and
+// prices it when the provider reported no cost of its own.
+func (e *Executor) priceUsage(result *claude.CodeResponse, elapsed time.Duration) {
+	usage := &result.Usage
+	if usage.Duration == 0 {
+		usage.Duration = elapsed
+	}
+	if result.CostUSD == 0 && usage.Tokens() > 0 {
+		if cost, ok := e.prices.Cost(*usage); ok {
+			result.CostUSD = cost
+		} else {
+			log.Printf("No price known for model %q, reporting zero cost", usage.Model)
+		}
+	}
+}
+
+// storeUsage adds the usage of one provider run to the task store. Every
+// attempt and extra run adds its own, so budgets see all the task spent.
+func (e *Executor) storeUsage(task *webhook.Task, usage claude.Usage, cost float64) {
+	if e.store == nil || task == nil || task.ID == "" {
+		return
+	}
+	e.store.AddUsage(task.ID, taskstore.Usage{
+		Model:            usage.Model,
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheReadTokens:  usage.CacheReadTokens,
+		CacheWriteTokens: usage.CacheWriteTokens,
+		Turns:            usage.Turns,
+		Duration:         usage.Duration,
+		CostUSD:          cost,
+	})
+}
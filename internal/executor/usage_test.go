@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/pricing"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestRecordUsage(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Create(&taskstore.Task{ID: "task-1", Title: "t", Status: taskstore.StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	e := NewWithClient(nil, &mockAppAuth{}, github.NewMockGHClient()).WithStore(store)
+	task := &webhook.Task{ID: "task-1", Repo: "owner/repo", Number: 7}
+	tracker := github.NewCommentTracker("owner/repo", 7, "a")
+
+	// Tokens without a reported cost are priced from the table.
+	result := &claude.CodeResponse{Usage: claude.Usage{Model: "gpt-5-codex", InputTokens: 1_000_000, CacheReadTokens: 1_000_000, OutputTokens: 100_000, Turns: 3}}
+	e.recordUsage(task, tracker, result, 90*time.Second)
+	if result.CostUSD != 1.25+0.125+1 {
+		t.Fatalf("CostUSD = %v, want priced from the defaults", result.CostUSD)
+	}
+	stored, _ := store.Get("task-1")
+	if stored.Usage.CostUSD != result.CostUSD || stored.Usage.Duration != 90*time.Second || stored.Usage.Turns != 3 || stored.Usage.Model != "gpt-5-codex" {
+		t.Fatalf("stored usage = %+v", stored.Usage)
+	}
+	if tracker.State.Usage.InputTokens != 1_000_000 {
+		t.Fatalf("tracker usage = %+v", tracker.State.Usage)
+	}
+
+	// A cost reported by the provider is kept; a configured table applies otherwise.
+	result = &claude.CodeResponse{CostUSD: 0.42, Usage: claude.Usage{Model: "claude-sonnet-4-5", OutputTokens: 10, Duration: time.Minute}}
+	e.recordUsage(task, tracker, result, time.Hour)
+	if result.CostUSD != 0.42 || result.Usage.Duration != time.Minute {
+		t.Fatalf("result = %+v, want reported cost and duration kept", result)
+	}
+	e.WithPrices(pricing.Table{"qwen": {Input: 1, Output: 2}})
+	result = &claude.CodeResponse{Usage: claude.Usage{Model: "qwen2.5-coder", InputTokens: 500_000, OutputTokens: 500_000}}
+	e.recordUsage(task, tracker, result, 0)
+	if result.CostUSD != 1.5 {
+		t.Fatalf("CostUSD = %v, want configured price", result.CostUSD)
+	}
+
+	// Every run adds to the task, so a retry never erases what earlier attempts spent.
+	stored, _ = store.Get("task-1")
+	if want := 1.25 + 0.125 + 1 + 0.42 + 1.5; stored.Usage.CostUSD < want-1e-9 || stored.Usage.CostUSD > want+1e-9 || stored.Usage.Turns != 3 {
+		t.Fatalf("stored usage = %+v, want the sum of all runs ($%.3f)", stored.Usage, want)
+	}
+}
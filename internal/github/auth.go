@@ -287,3 +287,51 @@ func (a *AppAuth) checkUserRepositoryPermission(token, repo, username string) (b
 	hasWritePermission := result.Permission == "admin" || result.Permission == "write" || result.Permission == "maintain"
 	return hasWritePermission, nil
 }
+
+// IsTeamMember checks whether a user is an active member of an organization team.
+// team is either "slug" (organization taken from repo owner) or "org/slug".
+func (a *AppAuth) IsTeamMember(repo, team, username string) (bool, error) {
+	org, slug, ok := strings.Cut(team, "/")
+	if !ok {
+		org, _, _ = strings.Cut(repo, "/")
+		slug = team
+	}
+
+	installationToken, err := a.GetInstallationToken(repo)
+	if err != nil {
+		return false, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/orgs/%s/teams/%s/memberships/%s", org, slug, username)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+installationToken.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check team membership: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.State == "active", nil
+}
@@ -676,3 +676,55 @@ func TestGetInstallationOwner_UsesHTTPTransport(t *testing.T) {
 		t.Fatalf("login = %q, want installer", login)
 	}
 }
+
+func TestAppAuth_IsTeamMember(t *testing.T) {
+	var membershipPaths []string
+	originalTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/installation"):
+			return mockResponse(http.StatusOK, `{"id":123}`), nil
+		case strings.Contains(req.URL.Path, "/access_tokens"):
+			return mockResponse(http.StatusCreated, `{"token":"test-token","expires_at":"2025-10-13T00:00:00Z"}`), nil
+		case strings.Contains(req.URL.Path, "/teams/"):
+			membershipPaths = append(membershipPaths, req.URL.Path)
+			switch {
+			case strings.HasSuffix(req.URL.Path, "/memberships/alice"):
+				return mockResponse(http.StatusOK, `{"state":"active"}`), nil
+			case strings.HasSuffix(req.URL.Path, "/memberships/bob"):
+				return mockResponse(http.StatusOK, `{"state":"pending"}`), nil
+			default:
+				return mockResponse(http.StatusNotFound, `{"message":"Not Found"}`), nil
+			}
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.String())
+		return nil, nil
+	})
+	t.Cleanup(func() {
+		http.DefaultTransport = originalTransport
+	})
+
+	auth := &AppAuth{AppID: "123456", PrivateKey: testPrivateKey}
+
+	tests := []struct {
+		team, user string
+		want       bool
+	}{
+		{"maintainers", "alice", true},
+		{"other-org/maintainers", "bob", false},
+		{"maintainers", "mallory", false},
+	}
+	for _, tt := range tests {
+		got, err := auth.IsTeamMember("owner/repo", tt.team, tt.user)
+		if err != nil {
+			t.Fatalf("IsTeamMember(%s, %s) error = %v", tt.team, tt.user, err)
+		}
+		if got != tt.want {
+			t.Errorf("IsTeamMember(%s, %s) = %v, want %v", tt.team, tt.user, got, tt.want)
+		}
+	}
+
+	if membershipPaths[0] != "/orgs/owner/teams/maintainers/memberships/alice" || membershipPaths[1] != "/orgs/other-org/teams/maintainers/memberships/bob" {
+		t.Fatalf("unexpected membership paths: %v", membershipPaths)
+	}
+}
@@ -1,6 +1,7 @@
 package github
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"os/exec"
@@ -27,6 +28,21 @@ var runRepoClone = func(repo, branch, token, dest string) error {
 	return nil
 }
 
+// GitAuthEnv passes token to a single git command as an http.extraHeader
+// config in the environment, so it is neither in the process arguments nor
+// written to a config file the agent could read.
+func GitAuthEnv(token string) []string {
+	if token == "" {
+		return nil
+	}
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.extraHeader",
+		"GIT_CONFIG_VALUE_0=" + header,
+	}
+}
+
 var (
 	nowFunc            = time.Now
 	issueNumberPattern = regexp.MustCompile(`(?i)issue[-_/](\d+)`)
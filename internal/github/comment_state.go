@@ -3,6 +3,8 @@ package github
 import (
 	"fmt"
 	"time"
+
+	"github.com/cexll/swe/internal/provider/claude"
 )
 
 // CommentStatus represents the execution status of a task
@@ -39,6 +41,8 @@ type CommentState struct {
 
 	// Execution metadata
 	CostUSD      float64
+	Usage        claude.Usage // tokens, model, duration and turns of the provider
+	Provider     string       // provider that produced the result, e.g. a fallback
 	Username     string
 	OriginalBody string
 	Context      map[string]string
@@ -47,6 +51,9 @@ type CommentState struct {
 	Summary       string
 	ModifiedFiles []string
 
+	// Notes are warnings shown on completion (e.g. files reverted by policy)
+	Notes []string
+
 	// Links
 	BranchName string
 	BranchURL  string
@@ -62,12 +69,17 @@ type CommentState struct {
 
 	// Task progress tracking (checkbox UI)
 	Tasks []TaskStep
+
+	// Live provider progress, shown while the task is working
+	Todos        []claude.TodoItem // the agent's own plan
+	TouchedFiles []string          // files the agent wrote so far
+	Activity     []string          // latest tool calls, oldest first
 }
 
 // TaskStep represents a step in the execution with checkbox status
 type TaskStep struct {
 	Name      string
-	Status    string // "pending", "running", "completed", "failed"
+	Status    string // "pending", "running", "completed", "resumed", "failed"
 	Timestamp time.Time
 }
 
@@ -93,6 +105,11 @@ func (s *CommentState) IsInProgress() bool {
 	return s.Status == StatusWorking || s.Status == StatusQueued
 }
 
+// HasProgress returns true once the provider reported progress
+func (s *CommentState) HasProgress() bool {
+	return len(s.Todos) > 0 || len(s.TouchedFiles) > 0 || len(s.Activity) > 0
+}
+
 // IsCompleted returns true if the task finished successfully
 func (s *CommentState) IsCompleted() bool {
 	return s.Status == StatusCompleted
@@ -2,9 +2,12 @@ package github
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/cexll/swe/internal/provider/claude"
 )
 
 // CommentTracker manages a single GitHub comment throughout task execution
@@ -14,6 +17,7 @@ type CommentTracker struct {
 	Repo      string
 	Number    int
 	CommentID int
+	ReplyTo   int64 // review comment whose thread holds the tracking comment, 0 for an issue comment
 	State     *CommentState
 	ghClient  GHClient
 }
@@ -51,7 +55,13 @@ func NewCommentTrackerWithClient(repo string, number int, username string, ghCli
 // Create creates the initial tracking comment
 func (t *CommentTracker) Create(token string) error {
 	body := t.renderBody()
-	commentID, err := t.ghClient.CreateComment(t.Repo, t.Number, body, token)
+	var commentID int
+	var err error
+	if t.ReplyTo > 0 {
+		commentID, err = t.ghClient.ReplyToReviewComment(t.Repo, t.Number, t.ReplyTo, body, token)
+	} else {
+		commentID, err = t.ghClient.CreateComment(t.Repo, t.Number, body, token)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create tracking comment: %w", err)
 	}
@@ -61,11 +71,23 @@ func (t *CommentTracker) Create(token string) error {
 
 // Update updates the existing tracking comment
 func (t *CommentTracker) Update(token string) error {
+	return t.Publish(t.renderBody(), token)
+}
+
+// Render returns the comment body for the current state. Together with
+// Publish it lets callers render under their own lock and post outside it.
+func (t *CommentTracker) Render() string {
+	return t.renderBody()
+}
+
+// Publish updates the tracking comment with a body returned by Render.
+func (t *CommentTracker) Publish(body, token string) error {
 	if t.CommentID <= 0 {
 		return fmt.Errorf("cannot update comment: not yet created")
 	}
-
-	body := t.renderBody()
+	if t.ReplyTo > 0 {
+		return t.ghClient.UpdateReviewComment(t.Repo, t.CommentID, body, token)
+	}
 	return t.ghClient.UpdateComment(t.Repo, t.CommentID, body, token)
 }
 
@@ -88,7 +110,11 @@ func (t *CommentTracker) renderBody() string {
 			message = fmt.Sprintf("SWE Agent is queued for @%s's task", username)
 		}
 
-		return message + " <img src=\"https://github.githubassets.com/images/spinners/octocat-spinner-32.gif\" width=\"20\" height=\"20\" alt=\"loading\" />"
+		message += " <img src=\"https://github.githubassets.com/images/spinners/octocat-spinner-32.gif\" width=\"20\" height=\"20\" alt=\"loading\" />"
+		if state.Status == StatusWorking && state.HasProgress() {
+			return message + "\n\n" + t.buildProgress()
+		}
+		return message
 	}
 
 	header := t.buildHeader()
@@ -110,6 +136,9 @@ func (t *CommentTracker) renderBody() string {
 		if len(state.ModifiedFiles) > 0 {
 			sections = append(sections, "", t.buildModifiedFilesList())
 		}
+		if len(state.Notes) > 0 {
+			sections = append(sections, "", t.buildNotes())
+		}
 		if state.SplitPlan != nil {
 			if splitSection := t.buildSplitPlanSection(); splitSection != "" {
 				sections = append(sections, "", splitSection)
@@ -236,16 +265,54 @@ func (t *CommentTracker) buildModifiedFilesList() string {
 	return strings.Join(lines, "\n")
 }
 
+// buildNotes builds the warning notes list
+func (t *CommentTracker) buildNotes() string {
+	lines := []string{"**Notes:**"}
+	for _, note := range t.State.Notes {
+		lines = append(lines, "> ⚠️ "+note)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // buildFooter builds the footer with metadata
 func (t *CommentTracker) buildFooter() string {
 	state := t.State
 
+	footer := "Generated with [SWE Agent](https://github.com/cexll/swe-agent)"
+	if state.IsCompleted() && state.Provider != "" {
+		footer += " • Provider: " + state.Provider
+		if state.Usage.Model != "" {
+			footer += " (" + state.Usage.Model + ")"
+		}
+	}
+	if state.IsCompleted() && state.Usage.Tokens() > 0 {
+		footer += " • Tokens: " + formatUsage(state.Usage)
+	}
 	// For completed tasks, show cost if available
 	if state.IsCompleted() && state.CostUSD > 0 {
-		return fmt.Sprintf("*Generated with [SWE Agent](https://github.com/cexll/swe-agent) • Cost: $%.4f*", state.CostUSD)
+		footer += fmt.Sprintf(" • Cost: $%.4f", state.CostUSD)
 	}
+	return "*" + footer + "*"
+}
 
-	return "*Generated with [SWE Agent](https://github.com/cexll/swe-agent)*"
+// formatUsage summarizes token counts, e.g. "12.3k in, 840 out, 45k cache"
+func formatUsage(u claude.Usage) string {
+	text := formatTokens(u.InputTokens) + " in, " + formatTokens(u.OutputTokens) + " out"
+	if cache := u.CacheReadTokens + u.CacheWriteTokens; cache > 0 {
+		text += ", " + formatTokens(cache) + " cache"
+	}
+	return text
+}
+
+func formatTokens(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 1_000_000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	}
 }
 
 // SetWorking sets the task status to working
@@ -266,6 +333,16 @@ func (t *CommentTracker) SetCompleted(summary string, modifiedFiles []string, co
 	t.State.CostUSD = costUSD
 }
 
+// SetUsage records the provider's token usage
+func (t *CommentTracker) SetUsage(usage claude.Usage) {
+	t.State.Usage = usage
+}
+
+// SetProvider records the provider that produced the result
+func (t *CommentTracker) SetProvider(name string) {
+	t.State.Provider = name
+}
+
 // SetFailed sets the task status to failed
 func (t *CommentTracker) SetFailed(errorDetails string) {
 	t.State.Status = StatusFailed
@@ -289,6 +366,11 @@ func (t *CommentTracker) SetPRURL(prURL string) {
 	t.State.PRURL = prURL
 }
 
+// AddNote records a warning that is shown once the task completes
+func (t *CommentTracker) AddNote(note string) {
+	t.State.Notes = append(t.State.Notes, note)
+}
+
 // SetJobURL sets the job/workflow run URL
 func (t *CommentTracker) SetJobURL(jobURL string) {
 	t.State.JobURL = jobURL
@@ -371,7 +453,7 @@ func (t *CommentTracker) buildSplitPlanSection() string {
 		// Calculate total lines for this sub-PR
 		totalLines := 0
 		for _, file := range subPR.Files {
-			totalLines += strings.Count(file.Content, "\n") + 1
+			totalLines += file.LineCount()
 		}
 
 		var status string
@@ -421,6 +503,105 @@ func escapeMarkdownLinkText(text string) string {
 	return replacer.Replace(text)
 }
 
+// Limits of the live progress view
+const (
+	maxActivity     = 5
+	maxTouchedFiles = 20
+	maxActivityLen  = 100
+)
+
+// RecordProgress applies a provider progress event to the working view
+func (t *CommentTracker) RecordProgress(ev claude.ProgressEvent) {
+	state := t.State
+	switch ev.Kind {
+	case claude.ProgressTodo:
+		state.Todos = ev.Todos
+	case claude.ProgressFile:
+		if ev.Path != "" && !slices.Contains(state.TouchedFiles, ev.Path) {
+			state.TouchedFiles = append(state.TouchedFiles, ev.Path)
+		}
+	case claude.ProgressTool:
+		line := ev.Tool
+		if detail, _, _ := strings.Cut(strings.TrimSpace(ev.Detail), "\n"); detail != "" {
+			if runes := []rune(detail); len(runes) > maxActivityLen {
+				detail = string(runes[:maxActivityLen]) + "…"
+			}
+			line += ": " + detail
+		}
+		state.Activity = append(state.Activity, line)
+		if len(state.Activity) > maxActivity {
+			state.Activity = state.Activity[len(state.Activity)-maxActivity:]
+		}
+	}
+}
+
+// buildProgress builds the checklist of the working view: the execution
+// steps with the agent's plan nested under the running one, the files
+// touched so far and the latest tool calls.
+func (t *CommentTracker) buildProgress() string {
+	state := t.State
+	var lines []string
+	todosShown := false
+	for _, step := range state.Tasks {
+		switch step.Status {
+		case "completed", "resumed":
+			lines = append(lines, "- [x] "+step.Name)
+		case "running":
+			lines = append(lines, "- [ ] **"+step.Name+"**")
+			lines = append(lines, todoLines("  ", state.Todos)...)
+			todosShown = true
+		default:
+			lines = append(lines, "- [ ] "+step.Name)
+		}
+	}
+	if !todosShown {
+		lines = append(lines, todoLines("", state.Todos)...)
+	}
+
+	if len(state.TouchedFiles) > 0 {
+		files := state.TouchedFiles
+		more := ""
+		if len(files) > maxTouchedFiles {
+			more = fmt.Sprintf(" and %d more", len(files)-maxTouchedFiles)
+			files = files[:maxTouchedFiles]
+		}
+		quoted := make([]string, len(files))
+		for i, file := range files {
+			quoted[i] = "`" + file + "`"
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "**Files touched:** "+strings.Join(quoted, ", ")+more)
+	}
+
+	if len(state.Activity) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "**Latest activity:**")
+		for _, activity := range state.Activity {
+			lines = append(lines, "- "+strings.ReplaceAll(activity, "`", "'"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func todoLines(indent string, todos []claude.TodoItem) []string {
+	lines := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		switch todo.Status {
+		case claude.TodoCompleted:
+			lines = append(lines, indent+"- [x] "+todo.Text)
+		case claude.TodoInProgress:
+			lines = append(lines, indent+"- [ ] **"+todo.Text+"**")
+		default:
+			lines = append(lines, indent+"- [ ] "+todo.Text)
+		}
+	}
+	return lines
+}
+
 // AddTask adds a new task step to the progress tracker
 func (t *CommentTracker) AddTask(name string) {
 	t.State.Tasks = append(t.State.Tasks, TaskStep{
@@ -452,6 +633,17 @@ func (t *CommentTracker) CompleteTask(name string) {
 	}
 }
 
+// ResumeTask marks a task as completed by a previous attempt of the same task
+func (t *CommentTracker) ResumeTask(name string) {
+	for i, task := range t.State.Tasks {
+		if task.Name == name {
+			t.State.Tasks[i].Status = "resumed"
+			t.State.Tasks[i].Timestamp = time.Now()
+			return
+		}
+	}
+}
+
 // FailTask marks a task as failed
 func (t *CommentTracker) FailTask(name string) {
 	for i, task := range t.State.Tasks {
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/cexll/swe/internal/provider/claude"
 )
 
 func TestNewCommentTracker(t *testing.T) {
@@ -299,13 +301,50 @@ func TestCommentTracker_BuildModifiedFilesList(t *testing.T) {
 	}
 }
 
+func TestCommentTracker_NotesRenderedOnCompletion(t *testing.T) {
+	tracker := NewCommentTracker("owner/repo", 123, "user")
+	tracker.AddNote("Reverted `.github/workflows/ci.yml` (policy)")
+
+	tracker.SetCompleted("Done", []string{"main.go"}, 0)
+	body := tracker.renderBody()
+	if !strings.Contains(body, "**Notes:**") || !strings.Contains(body, "> ⚠️ Reverted `.github/workflows/ci.yml` (policy)") {
+		t.Errorf("renderBody() missing notes:\n%s", body)
+	}
+
+	tracker.SetFailed("boom")
+	if body := tracker.renderBody(); strings.Contains(body, "**Notes:**") {
+		t.Errorf("renderBody() should not show notes for failed tasks:\n%s", body)
+	}
+}
+
 func TestCommentTracker_BuildFooter(t *testing.T) {
 	tests := []struct {
 		name         string
 		status       CommentStatus
 		costUSD      float64
+		provider     string
+		usage        claude.Usage
 		wantContains []string
 	}{
+		{
+			name:     "completed with usage",
+			status:   StatusCompleted,
+			costUSD:  0.1234,
+			provider: "claude",
+			usage:    claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 12345, OutputTokens: 840, CacheReadTokens: 1_500_000, CacheWriteTokens: 20000},
+			wantContains: []string{
+				"• Provider: claude (claude-sonnet-4-5) • Tokens: 12.3k in, 840 out, 1.5M cache • Cost: $0.1234*",
+			},
+		},
+		{
+			name:     "completed by fallback provider",
+			status:   StatusCompleted,
+			costUSD:  0.5,
+			provider: "codex",
+			wantContains: []string{
+				"• Provider: codex • Cost: $0.5000*",
+			},
+		},
 		{
 			name:    "completed with cost",
 			status:  StatusCompleted,
@@ -337,6 +376,8 @@ func TestCommentTracker_BuildFooter(t *testing.T) {
 			tracker := NewCommentTracker("owner/repo", 123, "user")
 			tracker.State.Status = tt.status
 			tracker.State.CostUSD = tt.costUSD
+			tracker.SetProvider(tt.provider)
+			tracker.SetUsage(tt.usage)
 
 			footer := tracker.buildFooter()
 
@@ -635,9 +676,15 @@ func TestCommentTracker_TaskLifecycle(t *testing.T) {
 		t.Fatalf("status after FailTask = %s, want failed", tracker.State.Tasks[1].Status)
 	}
 
+	tracker.AddTask("generate")
+	tracker.ResumeTask("generate")
+	if tracker.State.Tasks[2].Status != "resumed" {
+		t.Fatalf("status after ResumeTask = %s, want resumed", tracker.State.Tasks[2].Status)
+	}
+
 	// Calling StartTask on nonexistent task should be a no-op
 	tracker.StartTask("does-not-exist")
-	if len(tracker.State.Tasks) != 2 {
+	if len(tracker.State.Tasks) != 3 {
 		t.Fatalf("unexpected task mutation after StartTask on missing task: %d", len(tracker.State.Tasks))
 	}
 }
@@ -814,3 +861,30 @@ func TestCommentTracker_CreateAndUpdateFlow(t *testing.T) {
 		t.Errorf("Expected 1 Update call, got %d", len(mockClient.UpdateCommentCalls))
 	}
 }
+
+func TestCommentTracker_ReplyInReviewThread(t *testing.T) {
+	mockClient := NewMockGHClient()
+	tracker := NewCommentTrackerWithClient("flow/test", 12, "bob", mockClient)
+	tracker.ReplyTo = 77
+
+	if err := tracker.Create("token"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	tracker.SetCompleted("Done", nil, 0)
+	if err := tracker.Update("token"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if len(mockClient.CreateCommentCalls) != 0 || len(mockClient.UpdateCommentCalls) != 0 {
+		t.Fatalf("issue comment API used for a review thread: create=%d update=%d", len(mockClient.CreateCommentCalls), len(mockClient.UpdateCommentCalls))
+	}
+	if len(mockClient.ReplyToReviewCalls) != 1 || mockClient.ReplyToReviewCalls[0].CommentID != 77 {
+		t.Fatalf("ReplyToReviewCalls = %+v, want one reply to 77", mockClient.ReplyToReviewCalls)
+	}
+	if tracker.CommentID != 23456 {
+		t.Fatalf("CommentID = %d, want reply id 23456", tracker.CommentID)
+	}
+	if len(mockClient.UpdateReviewCalls) != 1 || mockClient.UpdateReviewCalls[0].CommentID != 23456 {
+		t.Fatalf("UpdateReviewCalls = %+v", mockClient.UpdateReviewCalls)
+	}
+}
@@ -55,9 +55,21 @@ type GHClient interface {
 	// ListReviewComments retrieves all review comments for the given PR
 	ListReviewComments(repo string, number int, token string) ([]ReviewComment, error)
 
+	// ReplyToReviewComment replies in the review thread of the given comment and returns the reply ID
+	ReplyToReviewComment(repo string, number int, commentID int64, body, token string) (int, error)
+
+	// UpdateReviewComment updates an existing review comment
+	UpdateReviewComment(repo string, commentID int, body, token string) error
+
+	// CreateReview submits a pull request review with inline comments
+	CreateReview(repo string, number int, review PullRequestReview, token string) error
+
 	// AddLabel adds a label to an issue/PR
 	AddLabel(repo string, number int, label, token string) error
 
+	// RemoveLabel removes a label from an issue/PR
+	RemoveLabel(repo string, number int, label, token string) error
+
 	// Clone clones a repository to a directory
 	Clone(repo, branch, destDir string) error
 
@@ -74,11 +86,30 @@ type IssueComment struct {
 
 // ReviewComment represents a GitHub pull request review comment
 type ReviewComment struct {
-	Author    string
-	Body      string
+	ID          int64
+	InReplyToID int64 // first comment of the thread, 0 for the first comment itself
+	Author      string
+	AuthorIsBot bool
+	Body        string
+	Path        string
+	Line        int // line in the current diff, 0 when the comment is outdated
+	DiffHunk    string
+	CreatedAt   time.Time
+}
+
+// PullRequestReview is a review submitted with the "COMMENT" event
+type PullRequestReview struct {
+	Body     string
+	Comments []InlineComment
+}
+
+// InlineComment is a review comment on lines of the pull request head.
+// StartLine is set for comments spanning several lines.
+type InlineComment struct {
 	Path      string
-	DiffHunk  string
-	CreatedAt time.Time
+	Line      int
+	StartLine int
+	Body      string
 }
 
 // RealGHClient is the production implementation using gh CLI
@@ -238,12 +269,16 @@ func (c *RealGHClient) ListReviewComments(repo string, number int, token string)
 			}
 
 			var raw []struct {
-				Body      string `json:"body"`
-				Path      string `json:"path"`
-				DiffHunk  string `json:"diff_hunk"`
-				CreatedAt string `json:"created_at"`
-				User      struct {
+				ID          int64  `json:"id"`
+				InReplyToID int64  `json:"in_reply_to_id"`
+				Body        string `json:"body"`
+				Path        string `json:"path"`
+				Line        *int   `json:"line"`
+				DiffHunk    string `json:"diff_hunk"`
+				CreatedAt   string `json:"created_at"`
+				User        struct {
 					Login string `json:"login"`
+					Type  string `json:"type"`
 				} `json:"user"`
 			}
 			if err := json.Unmarshal(output, &raw); err != nil {
@@ -256,12 +291,20 @@ func (c *RealGHClient) ListReviewComments(repo string, number int, token string)
 				if err != nil {
 					createdAt = time.Time{}
 				}
+				line := 0
+				if item.Line != nil {
+					line = *item.Line
+				}
 				comments = append(comments, ReviewComment{
-					Author:    item.User.Login,
-					Body:      item.Body,
-					Path:      item.Path,
-					DiffHunk:  item.DiffHunk,
-					CreatedAt: createdAt,
+					ID:          item.ID,
+					InReplyToID: item.InReplyToID,
+					Author:      item.User.Login,
+					AuthorIsBot: item.User.Type == "Bot",
+					Body:        item.Body,
+					Path:        item.Path,
+					Line:        line,
+					DiffHunk:    item.DiffHunk,
+					CreatedAt:   createdAt,
 				})
 			}
 			return nil
@@ -271,6 +314,119 @@ func (c *RealGHClient) ListReviewComments(repo string, number int, token string)
 	return comments, err
 }
 
+// ReplyToReviewComment replies in the review thread of the given comment and returns the reply ID
+func (c *RealGHClient) ReplyToReviewComment(repo string, number int, commentID int64, body, token string) (int, error) {
+	var replyID int
+	err := retryWithBackoff(func() error {
+		return withGitHubTokenEnv(token, func() error {
+			args := []string{
+				"api",
+				fmt.Sprintf("/repos/%s/pulls/%d/comments/%d/replies", repo, number, commentID),
+				"-X", "POST",
+				"-f", fmt.Sprintf("body=%s", body),
+			}
+
+			output, err := c.runner.Run("gh", args...)
+			if err != nil {
+				return fmt.Errorf("gh api reply to review comment failed: %w\nOutput: %s", err, string(output))
+			}
+
+			var result struct {
+				ID int `json:"id"`
+			}
+			if err := json.Unmarshal(output, &result); err != nil {
+				return fmt.Errorf("failed to parse review comment response: %w", err)
+			}
+
+			replyID = result.ID
+			return nil
+		})
+	})
+
+	return replyID, err
+}
+
+// UpdateReviewComment updates an existing review comment
+func (c *RealGHClient) UpdateReviewComment(repo string, commentID int, body, token string) error {
+	return retryWithBackoff(func() error {
+		return withGitHubTokenEnv(token, func() error {
+			args := []string{
+				"api",
+				fmt.Sprintf("/repos/%s/pulls/comments/%d", repo, commentID),
+				"-X", "PATCH",
+				"-f", fmt.Sprintf("body=%s", body),
+			}
+
+			output, err := c.runner.Run("gh", args...)
+			if err != nil {
+				return fmt.Errorf("gh api update review comment failed: %w\nOutput: %s", err, string(output))
+			}
+
+			return nil
+		})
+	})
+}
+
+// CreateReview submits a pull request review with inline comments. The nested
+// comment list cannot be expressed with -f flags, so the payload is passed as a file.
+func (c *RealGHClient) CreateReview(repo string, number int, review PullRequestReview, token string) error {
+	type inlineComment struct {
+		Path      string `json:"path"`
+		Line      int    `json:"line"`
+		Side      string `json:"side"`
+		StartLine int    `json:"start_line,omitempty"`
+		StartSide string `json:"start_side,omitempty"`
+		Body      string `json:"body"`
+	}
+	payload := struct {
+		Event    string          `json:"event"`
+		Body     string          `json:"body,omitempty"`
+		Comments []inlineComment `json:"comments"`
+	}{Event: "COMMENT", Body: review.Body, Comments: []inlineComment{}}
+	for _, comment := range review.Comments {
+		item := inlineComment{Path: comment.Path, Line: comment.Line, Side: "RIGHT", Body: comment.Body}
+		if comment.StartLine > 0 && comment.StartLine < comment.Line {
+			item.StartLine, item.StartSide = comment.StartLine, "RIGHT"
+		}
+		payload.Comments = append(payload.Comments, item)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode review: %w", err)
+	}
+	file, err := os.CreateTemp("", "swe-review-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write review payload: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write review payload: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write review payload: %w", err)
+	}
+
+	return retryWithBackoff(func() error {
+		return withGitHubTokenEnv(token, func() error {
+			args := []string{
+				"api",
+				fmt.Sprintf("/repos/%s/pulls/%d/reviews", repo, number),
+				"-X", "POST",
+				"--input", file.Name(),
+			}
+
+			output, err := c.runner.Run("gh", args...)
+			if err != nil {
+				return fmt.Errorf("gh api create review failed: %w\nOutput: %s", err, string(output))
+			}
+
+			return nil
+		})
+	})
+}
+
 // AddLabel adds a label to an issue/PR
 func (c *RealGHClient) AddLabel(repo string, number int, label, token string) error {
 	return retryWithBackoff(func() error {
@@ -291,6 +447,26 @@ func (c *RealGHClient) AddLabel(repo string, number int, label, token string) er
 	})
 }
 
+// RemoveLabel removes a label from an issue/PR
+func (c *RealGHClient) RemoveLabel(repo string, number int, label, token string) error {
+	return retryWithBackoff(func() error {
+		return withGitHubTokenEnv(token, func() error {
+			args := []string{
+				"issue", "edit", fmt.Sprintf("%d", number),
+				"--repo", repo,
+				"--remove-label", label,
+			}
+
+			output, err := c.runner.Run("gh", args...)
+			if err != nil {
+				return fmt.Errorf("gh issue edit failed: %w\nOutput: %s", err, string(output))
+			}
+
+			return nil
+		})
+	})
+}
+
 // Clone clones a repository to a directory
 func (c *RealGHClient) Clone(repo, branch, destDir string) error {
 	return retryWithBackoff(func() error {
@@ -334,7 +510,11 @@ type MockGHClient struct {
 	GetCommentBodyFunc     func(repo string, commentID int, token string) (string, error)
 	ListIssueCommentsFunc  func(repo string, number int, token string) ([]IssueComment, error)
 	ListReviewCommentsFunc func(repo string, number int, token string) ([]ReviewComment, error)
+	ReplyToReviewFunc      func(repo string, number int, commentID int64, body, token string) (int, error)
+	UpdateReviewFunc       func(repo string, commentID int, body, token string) error
+	CreateReviewFunc       func(repo string, number int, review PullRequestReview, token string) error
 	AddLabelFunc           func(repo string, number int, label, token string) error
+	RemoveLabelFunc        func(repo string, number int, label, token string) error
 	CloneFunc              func(repo, branch, destDir string) error
 	CreatePRFunc           func(workdir, repo, head, base, title, body string) (string, error)
 
@@ -366,12 +546,37 @@ type MockGHClient struct {
 		Number int
 		Token  string
 	}
+	ReplyToReviewCalls []struct {
+		Repo      string
+		Number    int
+		CommentID int64
+		Body      string
+		Token     string
+	}
+	UpdateReviewCalls []struct {
+		Repo      string
+		CommentID int
+		Body      string
+		Token     string
+	}
+	CreateReviewCalls []struct {
+		Repo   string
+		Number int
+		Review PullRequestReview
+		Token  string
+	}
 	AddLabelCalls []struct {
 		Repo   string
 		Number int
 		Label  string
 		Token  string
 	}
+	RemoveLabelCalls []struct {
+		Repo   string
+		Number int
+		Label  string
+		Token  string
+	}
 	CloneCalls []struct {
 		Repo    string
 		Branch  string
@@ -469,6 +674,55 @@ func (m *MockGHClient) ListReviewComments(repo string, number int, token string)
 	return nil, nil
 }
 
+// ReplyToReviewComment mock implementation
+func (m *MockGHClient) ReplyToReviewComment(repo string, number int, commentID int64, body, token string) (int, error) {
+	m.ReplyToReviewCalls = append(m.ReplyToReviewCalls, struct {
+		Repo      string
+		Number    int
+		CommentID int64
+		Body      string
+		Token     string
+	}{repo, number, commentID, body, token})
+
+	if m.ReplyToReviewFunc != nil {
+		return m.ReplyToReviewFunc(repo, number, commentID, body, token)
+	}
+
+	return 23456, nil // Default mock reply ID
+}
+
+// UpdateReviewComment mock implementation
+func (m *MockGHClient) UpdateReviewComment(repo string, commentID int, body, token string) error {
+	m.UpdateReviewCalls = append(m.UpdateReviewCalls, struct {
+		Repo      string
+		CommentID int
+		Body      string
+		Token     string
+	}{repo, commentID, body, token})
+
+	if m.UpdateReviewFunc != nil {
+		return m.UpdateReviewFunc(repo, commentID, body, token)
+	}
+
+	return nil
+}
+
+// CreateReview mock implementation
+func (m *MockGHClient) CreateReview(repo string, number int, review PullRequestReview, token string) error {
+	m.CreateReviewCalls = append(m.CreateReviewCalls, struct {
+		Repo   string
+		Number int
+		Review PullRequestReview
+		Token  string
+	}{repo, number, review, token})
+
+	if m.CreateReviewFunc != nil {
+		return m.CreateReviewFunc(repo, number, review, token)
+	}
+
+	return nil
+}
+
 // AddLabel mock implementation
 func (m *MockGHClient) AddLabel(repo string, number int, label, token string) error {
 	m.AddLabelCalls = append(m.AddLabelCalls, struct {
@@ -485,6 +739,22 @@ func (m *MockGHClient) AddLabel(repo string, number int, label, token string) er
 	return nil
 }
 
+// RemoveLabel mock implementation
+func (m *MockGHClient) RemoveLabel(repo string, number int, label, token string) error {
+	m.RemoveLabelCalls = append(m.RemoveLabelCalls, struct {
+		Repo   string
+		Number int
+		Label  string
+		Token  string
+	}{repo, number, label, token})
+
+	if m.RemoveLabelFunc != nil {
+		return m.RemoveLabelFunc(repo, number, label, token)
+	}
+
+	return nil
+}
+
 // Clone mock implementation
 func (m *MockGHClient) Clone(repo, branch, destDir string) error {
 	m.CloneCalls = append(m.CloneCalls, struct {
@@ -85,7 +85,8 @@ func TestRealGHClient_ListReviewComments(t *testing.T) {
 	runner := NewMockCommandRunner()
 	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
 		return []byte(`[
-			{"body":"nit","path":"main.go","diff_hunk":"@@","created_at":"2025-10-10T11:00:00Z","user":{"login":"bob"}}
+			{"id":5,"body":"nit","path":"main.go","line":12,"diff_hunk":"@@","created_at":"2025-10-10T11:00:00Z","user":{"login":"bob"}},
+			{"id":6,"in_reply_to_id":5,"body":"done","path":"main.go","line":null,"created_at":"2025-10-10T12:00:00Z","user":{"login":"swe[bot]","type":"Bot"}}
 		]`), nil
 	}
 
@@ -94,12 +95,90 @@ func TestRealGHClient_ListReviewComments(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ListReviewComments error: %v", err)
 	}
-	if len(comments) != 1 {
-		t.Fatalf("expected 1 comment, got %d", len(comments))
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(comments))
 	}
-	if comments[0].Author != "bob" || comments[0].Path != "main.go" || comments[0].DiffHunk != "@@" {
+	if comments[0].ID != 5 || comments[0].Author != "bob" || comments[0].Path != "main.go" || comments[0].Line != 12 || comments[0].DiffHunk != "@@" || comments[0].AuthorIsBot {
 		t.Fatalf("unexpected review comment: %+v", comments[0])
 	}
+	if comments[1].InReplyToID != 5 || comments[1].Line != 0 || !comments[1].AuthorIsBot {
+		t.Fatalf("unexpected reply: %+v", comments[1])
+	}
+}
+
+func TestRealGHClient_ReplyToReviewComment(t *testing.T) {
+	runner := NewMockCommandRunner()
+	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
+		expectArgs := []string{"api", "/repos/owner/repo/pulls/3/comments/5/replies", "-X", "POST", "-f", "body=fixed"}
+		if !reflect.DeepEqual(args, expectArgs) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		return []byte(`{"id":9}`), nil
+	}
+
+	client := newRealClientWithRunner(runner)
+	id, err := client.ReplyToReviewComment("owner/repo", 3, 5, "fixed", "token")
+	if err != nil {
+		t.Fatalf("ReplyToReviewComment error: %v", err)
+	}
+	if id != 9 {
+		t.Fatalf("expected reply id 9, got %d", id)
+	}
+}
+
+func TestRealGHClient_UpdateReviewComment(t *testing.T) {
+	runner := NewMockCommandRunner()
+	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
+		expectArgs := []string{"api", "/repos/owner/repo/pulls/comments/9", "-X", "PATCH", "-f", "body=done"}
+		if !reflect.DeepEqual(args, expectArgs) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		return []byte(""), nil
+	}
+
+	client := newRealClientWithRunner(runner)
+	if err := client.UpdateReviewComment("owner/repo", 9, "done", "token"); err != nil {
+		t.Fatalf("UpdateReviewComment error: %v", err)
+	}
+}
+
+func TestRealGHClient_CreateReview(t *testing.T) {
+	var payload string
+	runner := NewMockCommandRunner()
+	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
+		if len(args) != 6 || args[1] != "/repos/owner/repo/pulls/3/reviews" || args[4] != "--input" {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		data, err := os.ReadFile(args[5])
+		if err != nil {
+			t.Fatalf("failed to read payload: %v", err)
+		}
+		payload = string(data)
+		return []byte(`{"id":1}`), nil
+	}
+
+	client := newRealClientWithRunner(runner)
+	review := PullRequestReview{
+		Body: "Looks good overall",
+		Comments: []InlineComment{
+			{Path: "main.go", Line: 12, Body: "nit"},
+			{Path: "util.go", Line: 8, StartLine: 5, Body: "```suggestion\nreturn nil\n```"},
+		},
+	}
+	if err := client.CreateReview("owner/repo", 3, review, "token"); err != nil {
+		t.Fatalf("CreateReview error: %v", err)
+	}
+
+	for _, want := range []string{
+		`"event":"COMMENT"`,
+		`"body":"Looks good overall"`,
+		`{"path":"main.go","line":12,"side":"RIGHT","body":"nit"}`,
+		`"line":8,"side":"RIGHT","start_line":5,"start_side":"RIGHT"`,
+	} {
+		if !strings.Contains(payload, want) {
+			t.Fatalf("payload %s missing %s", payload, want)
+		}
+	}
 }
 
 func TestRealGHClient_AddLabel(t *testing.T) {
@@ -118,6 +197,22 @@ func TestRealGHClient_AddLabel(t *testing.T) {
 	}
 }
 
+func TestRealGHClient_RemoveLabel(t *testing.T) {
+	runner := NewMockCommandRunner()
+	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
+		expectArgs := []string{"issue", "edit", "7", "--repo", "owner/repo", "--remove-label", "swe"}
+		if !reflect.DeepEqual(args, expectArgs) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		return []byte(""), nil
+	}
+
+	client := newRealClientWithRunner(runner)
+	if err := client.RemoveLabel("owner/repo", 7, "swe", "token"); err != nil {
+		t.Fatalf("RemoveLabel error: %v", err)
+	}
+}
+
 func TestRealGHClient_Clone(t *testing.T) {
 	runner := NewMockCommandRunner()
 	runner.RunFunc = func(name string, args ...string) ([]byte, error) {
@@ -0,0 +1,147 @@
+package github
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the public GitHub REST endpoint.
+const DefaultAPIURL = "https://api.github.com"
+
+// ErrNotFastForward is returned by UpdateRef when the branch moved and the
+// update would drop the commits added to it.
+var ErrNotFastForward = errors.New("update is not a fast forward")
+
+// TreeEntry is one path in a Git Data API tree. A nil SHA deletes the path.
+type TreeEntry struct {
+	Path string  `json:"path"`
+	Mode string  `json:"mode"`
+	Type string  `json:"type"`
+	SHA  *string `json:"sha"`
+}
+
+// GitDataClient creates git objects through the GitHub Git Data API.
+// Commits created this way by a GitHub App are signed by GitHub and shown as verified.
+type GitDataClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewGitDataClient creates a client authenticated with an installation token.
+// An empty baseURL uses the public GitHub API.
+func NewGitDataClient(baseURL, token string) *GitDataClient {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &GitDataClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CreateBlob uploads file content and returns the blob SHA.
+func (c *GitDataClient) CreateBlob(repo string, content []byte) (string, error) {
+	req := map[string]string{
+		"content":  base64.StdEncoding.EncodeToString(content),
+		"encoding": "base64",
+	}
+	return c.createObject(repo, "blobs", req)
+}
+
+// CreateTree creates a tree on top of baseTree and returns its SHA.
+func (c *GitDataClient) CreateTree(repo, baseTree string, entries []TreeEntry) (string, error) {
+	req := map[string]interface{}{
+		"base_tree": baseTree,
+		"tree":      entries,
+	}
+	return c.createObject(repo, "trees", req)
+}
+
+// CreateCommit creates a commit and returns its SHA. Author and committer are
+// left to GitHub so the commit is attributed to, and signed for, the App.
+func (c *GitDataClient) CreateCommit(repo, message, tree string, parents []string) (string, error) {
+	req := map[string]interface{}{
+		"message": message,
+		"tree":    tree,
+		"parents": parents,
+	}
+	return c.createObject(repo, "commits", req)
+}
+
+// CreateRef creates refs/heads/<branch> pointing at sha.
+func (c *GitDataClient) CreateRef(repo, branch, sha string) error {
+	req := map[string]string{
+		"ref": "refs/heads/" + branch,
+		"sha": sha,
+	}
+	return c.do(http.MethodPost, fmt.Sprintf("/repos/%s/git/refs", repo), req, http.StatusCreated, nil)
+}
+
+// UpdateRef moves refs/heads/<branch> to sha. Without force the update must be a fast-forward.
+func (c *GitDataClient) UpdateRef(repo, branch, sha string, force bool) error {
+	req := map[string]interface{}{
+		"sha":   sha,
+		"force": force,
+	}
+	err := c.do(http.MethodPatch, fmt.Sprintf("/repos/%s/git/refs/heads/%s", repo, branch), req, http.StatusOK, nil)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "is not a fast forward") {
+		return fmt.Errorf("%w: %v", ErrNotFastForward, err)
+	}
+	return err
+}
+
+func (c *GitDataClient) createObject(repo, kind string, payload interface{}) (string, error) {
+	var result struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/repos/%s/git/%s", repo, kind), payload, http.StatusCreated, &result); err != nil {
+		return "", err
+	}
+	if result.SHA == "" {
+		return "", fmt.Errorf("GitHub API returned no sha for %s", kind)
+	}
+	return result.SHA, nil
+}
+
+func (c *GitDataClient) do(method, path string, payload interface{}, wantStatus int, out interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API error: %s %s: %d - %s", method, path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitDataClient_CreateObjectsAndRefs(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch r.URL.Path {
+		case "/repos/owner/repo/git/blobs":
+			if body["encoding"] != "base64" || body["content"] != "aGk=" {
+				t.Errorf("blob body = %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"blob1"}`))
+		case "/repos/owner/repo/git/trees":
+			tree := body["tree"].([]interface{})
+			if body["base_tree"] != "base" || len(tree) != 2 || tree[1].(map[string]interface{})["sha"] != nil {
+				t.Errorf("tree body = %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"tree1"}`))
+		case "/repos/owner/repo/git/commits":
+			if _, ok := body["author"]; ok {
+				t.Errorf("commit should not set author: %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"sha":"commit1"}`))
+		case "/repos/owner/repo/git/refs":
+			if body["ref"] != "refs/heads/feature/x" {
+				t.Errorf("ref body = %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+		case "/repos/owner/repo/git/refs/heads/feature/x":
+			if body["force"] != false {
+				t.Errorf("update ref body = %v", body)
+			}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message":"Update is not a fast forward"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewGitDataClient(server.URL+"/", "tok")
+	blob, err := client.CreateBlob("owner/repo", []byte("hi"))
+	if err != nil || blob != "blob1" {
+		t.Fatalf("CreateBlob() = %q, %v", blob, err)
+	}
+	tree, err := client.CreateTree("owner/repo", "base", []TreeEntry{
+		{Path: "a.txt", Mode: "100644", Type: "blob", SHA: &blob},
+		{Path: "old.txt", Mode: "100644", Type: "blob"},
+	})
+	if err != nil || tree != "tree1" {
+		t.Fatalf("CreateTree() = %q, %v", tree, err)
+	}
+	commit, err := client.CreateCommit("owner/repo", "msg", tree, []string{"parent"})
+	if err != nil || commit != "commit1" {
+		t.Fatalf("CreateCommit() = %q, %v", commit, err)
+	}
+	if err := client.CreateRef("owner/repo", "feature/x", commit); err != nil {
+		t.Fatalf("CreateRef() error = %v", err)
+	}
+	err = client.UpdateRef("owner/repo", "feature/x", commit, false)
+	if err == nil || !strings.Contains(err.Error(), "422") || !strings.Contains(err.Error(), "not a fast forward") {
+		t.Fatalf("UpdateRef() error = %v, want 422", err)
+	}
+
+	if len(requests) != 5 {
+		t.Fatalf("requests = %v", requests)
+	}
+}
@@ -4,3 +4,8 @@ package github
 func AddLabel(repo string, number int, label string, token string) error {
 	return defaultGHClient.AddLabel(repo, number, label, token)
 }
+
+// RemoveLabel removes a label from an issue or PR
+func RemoveLabel(repo string, number int, label string, token string) error {
+	return defaultGHClient.RemoveLabel(repo, number, label, token)
+}
@@ -128,13 +128,11 @@ func (s *PRSplitter) categorizeFile(path string) PRCategory {
 	}
 }
 
-// estimateTotalLines estimates total lines changed (rough approximation)
+// estimateTotalLines sums changed lines, using diff statistics when available
 func (s *PRSplitter) estimateTotalLines(files []claude.FileChange) int {
 	total := 0
 	for _, file := range files {
-		// Rough estimate: count newlines in content
-		lines := strings.Count(file.Content, "\n") + 1
-		total += lines
+		total += file.LineCount()
 	}
 	return total
 }
@@ -285,7 +283,11 @@ func (s *PRSplitter) generateSubPRDescription(category PRCategory, files []claud
 	// Add file-level details with line counts
 	lines = append(lines, "### Files Changed")
 	for _, file := range files {
-		lineCount := strings.Count(file.Content, "\n") + 1
+		if file.Binary {
+			lines = append(lines, fmt.Sprintf("- `%s` (binary)", file.Path))
+			continue
+		}
+		lineCount := file.LineCount()
 		totalLines += lineCount
 		lines = append(lines, fmt.Sprintf("- `%s` (%d lines)", file.Path, lineCount))
 	}
@@ -378,6 +378,25 @@ func TestEstimateTotalLines(t *testing.T) {
 	}
 }
 
+// TestEstimateTotalLines_DiffStats prefers numstat counts and skips binaries
+func TestEstimateTotalLines_DiffStats(t *testing.T) {
+	splitter := NewPRSplitter(8, 300)
+
+	files := []claude.FileChange{
+		{Path: "big.go", Content: strings.Repeat("line\n", 500), Lines: 4},
+		{Path: "logo.png", Binary: true, Blob: "abc123"},
+		{Path: "new.go", Content: "a\nb"},
+	}
+
+	if got := splitter.estimateTotalLines(files); got != 6 {
+		t.Errorf("expected 6 lines, got %d", got)
+	}
+	desc := splitter.generateSubPRDescription(CategoryCore, files)
+	if !strings.Contains(desc, "`logo.png` (binary)") || !strings.Contains(desc, "`big.go` (4 lines)") {
+		t.Errorf("unexpected description:\n%s", desc)
+	}
+}
+
 // TestGroupByCategory tests file grouping by category
 func TestGroupByCategory(t *testing.T) {
 	splitter := NewPRSplitter(8, 300)
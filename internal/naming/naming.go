@@ -0,0 +1,218 @@
+package naming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxSlugLength keeps title slugs short enough for branch names.
+const maxSlugLength = 40
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Templates holds Go templates for generated names. Empty fields use the built-in format.
+type Templates struct {
+	Branch  string
+	Commit  string
+	PRTitle string
+	PRBody  string
+}
+
+// Merge returns t with every non-empty field of override applied.
+func (t Templates) Merge(override Templates) Templates {
+	if override.Branch != "" {
+		t.Branch = override.Branch
+	}
+	if override.Commit != "" {
+		t.Commit = override.Commit
+	}
+	if override.PRTitle != "" {
+		t.PRTitle = override.PRTitle
+	}
+	if override.PRBody != "" {
+		t.PRBody = override.PRBody
+	}
+	return t
+}
+
+// Validate parses every template and returns all syntax errors.
+func (t Templates) Validate() error {
+	var problems []string
+	for _, field := range []struct{ name, text string }{
+		{"branch", t.Branch},
+		{"commit", t.Commit},
+		{"pr_title", t.PRTitle},
+		{"pr_body", t.PRBody},
+	} {
+		if field.text == "" {
+			continue
+		}
+		if _, err := parse(field.name, field.text); err != nil {
+			problems = append(problems, fmt.Sprintf("%s template: %v", field.name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Data is the set of variables available to templates.
+type Data struct {
+	Kind      string // "issue" or "pr"
+	Number    int
+	IsPR      bool
+	Title     string
+	TitleSlug string
+	User      string
+	Category  string // sub-PR category, empty for single-PR tasks
+	Repo      string
+	Summary   string // provider summary (commit message / PR title source)
+	Date      string // YYYY-MM-DD
+	Timestamp int64
+}
+
+// NewData fills the derived fields (kind, slug, date, timestamp).
+func NewData(repo string, number int, isPR bool, title, user string, now time.Time) Data {
+	kind := "issue"
+	if isPR {
+		kind = "pr"
+	}
+	return Data{
+		Kind:      kind,
+		Number:    number,
+		IsPR:      isPR,
+		Title:     title,
+		TitleSlug: Slugify(title, maxSlugLength),
+		User:      user,
+		Repo:      repo,
+		Date:      now.Format("2006-01-02"),
+		Timestamp: now.Unix(),
+	}
+}
+
+var funcs = template.FuncMap{
+	"slug":  func(s string) string { return Slugify(s, maxSlugLength) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"trunc": func(n int, s string) string {
+		if n < 0 || len(s) <= n {
+			return s
+		}
+		return s[:n]
+	},
+	"firstLine": func(s string) string {
+		line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+		return strings.TrimSpace(line)
+	},
+}
+
+func parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// Render executes a template against data.
+func Render(name, text string, data Data) (string, error) {
+	tmpl, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderBranch renders a branch template and validates the result as a git ref name.
+func RenderBranch(text string, data Data) (string, error) {
+	name, err := Render("branch", text, data)
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(name)
+	if err := ValidateBranchName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RenderUniqueBranch renders the branch of a new change. Templates without a
+// per-task value would name the same branch again on a retry or a later task
+// for the same issue, so the timestamp is appended unless the template uses it.
+func RenderUniqueBranch(text string, data Data) (string, error) {
+	name, err := RenderBranch(text, data)
+	if err != nil {
+		return "", err
+	}
+	later := data
+	later.Timestamp++
+	if other, err := RenderBranch(text, later); err == nil && other != name {
+		return name, nil
+	}
+	name = fmt.Sprintf("%s-%d", name, data.Timestamp)
+	if err := ValidateBranchName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Slugify lower-cases s and joins alphanumeric runs with "-", truncated to max characters.
+func Slugify(s string, max int) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if max > 0 && len(slug) > max {
+		slug = strings.TrimRight(slug[:max], "-")
+	}
+	return slug
+}
+
+// ValidateBranchName applies the rules of `git check-ref-format --branch`.
+func ValidateBranchName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid branch name %q: %s", name, reason)
+	}
+
+	switch {
+	case name == "":
+		return invalid("empty")
+	case name == "@":
+		return invalid(`cannot be "@"`)
+	case strings.HasPrefix(name, "-"):
+		return invalid(`cannot start with "-"`)
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid(`cannot start or end with "/"`)
+	case strings.HasSuffix(name, "."):
+		return invalid(`cannot end with "."`)
+	case strings.Contains(name, ".."):
+		return invalid(`cannot contain ".."`)
+	case strings.Contains(name, "//"):
+		return invalid(`cannot contain "//"`)
+	case strings.Contains(name, "@{"):
+		return invalid(`cannot contain "@{"`)
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return invalid("cannot contain control characters")
+		}
+		if strings.ContainsRune(" ~^:?*[\\", r) {
+			return invalid(fmt.Sprintf("cannot contain %q", r))
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return invalid(`path components cannot start with "."`)
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return invalid(`path components cannot end with ".lock"`)
+		}
+	}
+	return nil
+}
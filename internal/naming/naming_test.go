@@ -0,0 +1,120 @@
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testData() Data {
+	now := time.Date(2025, 10, 14, 9, 30, 0, 0, time.UTC)
+	data := NewData("acme/api", 123, false, "Fix: login fails with SSO (JIRA-9)!", "alice", now)
+	data.Summary = "Handle SSO redirect\n\nDetails follow."
+	return data
+}
+
+func TestNewData(t *testing.T) {
+	data := testData()
+	if data.Kind != "issue" || data.TitleSlug != "fix-login-fails-with-sso-jira-9" || data.Date != "2025-10-14" {
+		t.Fatalf("NewData() = %+v", data)
+	}
+	if pr := NewData("acme/api", 5, true, "", "", time.Now()); pr.Kind != "pr" || !pr.IsPR {
+		t.Fatalf("NewData(pr) = %+v", pr)
+	}
+}
+
+func TestRenderBranch(t *testing.T) {
+	tests := []struct {
+		name, template, want string
+	}{
+		{"jira style", "feature/JIRA-{{.Number}}-{{.TitleSlug}}", "feature/JIRA-123-fix-login-fails-with-sso-jira-9"},
+		{"user and date", "{{.User}}/{{.Date}}-{{.Kind}}-{{.Number}}", "alice/2025-10-14-issue-123"},
+		{"functions", "{{upper .Kind}}/{{trunc 8 .TitleSlug}}", "ISSUE/fix-logi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderBranch(tt.template, testData())
+			if err != nil {
+				t.Fatalf("RenderBranch() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("RenderBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := RenderBranch("feature/{{.Title}}", testData()); err == nil || !strings.Contains(err.Error(), "invalid branch name") {
+		t.Fatalf("RenderBranch() with raw title error = %v, want invalid branch name", err)
+	}
+	if _, err := RenderBranch("{{.Missing}}", testData()); err == nil {
+		t.Fatal("RenderBranch() with unknown field should fail")
+	}
+}
+
+func TestRenderUniqueBranch(t *testing.T) {
+	data := testData()
+	if got, err := RenderUniqueBranch("swe/issue-{{.Number}}", data); err != nil || got != fmt.Sprintf("swe/issue-123-%d", data.Timestamp) {
+		t.Fatalf("RenderUniqueBranch() = %q, %v, want the timestamp appended", got, err)
+	}
+	if got, err := RenderUniqueBranch("swe/{{.Number}}-{{.Timestamp}}", data); err != nil || got != fmt.Sprintf("swe/123-%d", data.Timestamp) {
+		t.Fatalf("RenderUniqueBranch() = %q, %v, want the template unchanged", got, err)
+	}
+	if _, err := RenderUniqueBranch("feature/{{.Title}}", data); err == nil {
+		t.Fatal("RenderUniqueBranch() with raw title should fail")
+	}
+}
+
+func TestRenderCommitTemplate(t *testing.T) {
+	text := "fix({{.Category | lower}}): {{firstLine .Summary}}\n\nRefs #{{.Number}}"
+	data := testData()
+	data.Category = "Backend"
+	got, err := Render("commit", text, data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "fix(backend): Handle SSO redirect\n\nRefs #123" {
+		t.Fatalf("Render() = %q", got)
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	valid := []string{"main", "feature/JIRA-1-x", "swe/issue-1-1700000000", "a.b/c_d"}
+	for _, name := range valid {
+		if err := ValidateBranchName(name); err != nil {
+			t.Errorf("ValidateBranchName(%q) error = %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "@", "-x", "/x", "x/", "x.", "a..b", "a//b", "a@{b", "a b", "a~1", "a^", "a:b", "a?", "a*", "a[b", "a\\b", ".hidden/x", "x/.y", "x.lock", "a/b.lock/c", "tab\tname"}
+	for _, name := range invalid {
+		if err := ValidateBranchName(name); err == nil {
+			t.Errorf("ValidateBranchName(%q) error = nil, want error", name)
+		}
+	}
+}
+
+func TestTemplatesMergeAndValidate(t *testing.T) {
+	server := Templates{Branch: "swe/{{.Number}}", Commit: "{{.Summary}}"}
+	merged := server.Merge(Templates{Branch: "feature/{{.Number}}"})
+	if merged.Branch != "feature/{{.Number}}" || merged.Commit != "{{.Summary}}" {
+		t.Fatalf("Merge() = %+v", merged)
+	}
+
+	err := Templates{Branch: "{{.Number", PRBody: "{{if}}"}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "branch template") || !strings.Contains(err.Error(), "pr_body template") {
+		t.Fatalf("Validate() error = %v, want branch and pr_body errors", err)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	if got := Slugify("  Hello,   World!  ", 0); got != "hello-world" {
+		t.Fatalf("Slugify() = %q", got)
+	}
+	if got := Slugify("abc def ghi", 5); got != "abc-d" {
+		t.Fatalf("Slugify() = %q", got)
+	}
+	if got := Slugify("abcd efgh", 5); got != "abcd" {
+		t.Fatalf("Slugify() = %q, want trailing dash trimmed", got)
+	}
+}
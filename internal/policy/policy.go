@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/secretscan"
+)
+
+// Path is the repository file that overrides the default policy.
+// It is always read from the base commit so generated changes cannot relax it.
+const Path = ".swe-agent/policy.json"
+
+// Mode controls what happens when a change violates the policy.
+type Mode string
+
+const (
+	// ModeFail aborts the task and reports the violations.
+	ModeFail Mode = "fail"
+	// ModeRevert discards violating files and continues with the rest.
+	ModeRevert Mode = "revert"
+)
+
+// Policy restricts which changes the agent may push.
+// A zero limit means "no limit".
+type Policy struct {
+	Deny         []string `json:"deny"`
+	Allow        []string `json:"allow"`
+	MaxFiles     int      `json:"max_files"`
+	MaxLines     int      `json:"max_lines"`
+	ForbidBinary bool     `json:"forbid_binary"`
+	OnViolation  Mode     `json:"on_violation"`
+}
+
+// Default returns the policy applied when a repository does not define one.
+// Workflow files are denied because the GitHub App cannot push them anyway.
+func Default() Policy {
+	return Policy{
+		Deny:        []string{".github/workflows/**"},
+		OnViolation: ModeFail,
+	}
+}
+
+// Parse decodes a JSON policy. Omitted fields keep their default values.
+func Parse(data []byte) (Policy, error) {
+	p := Default()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		return Policy{}, fmt.Errorf("invalid policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Validate checks limits and the violation mode.
+func (p Policy) Validate() error {
+	switch p.OnViolation {
+	case "", ModeFail, ModeRevert:
+	default:
+		return fmt.Errorf("invalid policy: on_violation must be %q or %q, got %q", ModeFail, ModeRevert, p.OnViolation)
+	}
+	if p.MaxFiles < 0 || p.MaxLines < 0 {
+		return fmt.Errorf("invalid policy: max_files and max_lines must not be negative")
+	}
+	for _, glob := range append(append([]string{}, p.Deny...), p.Allow...) {
+		if strings.TrimSpace(glob) == "" {
+			return fmt.Errorf("invalid policy: empty glob")
+		}
+	}
+	return nil
+}
+
+// Change describes one changed file in the working tree.
+type Change struct {
+	Path    string
+	Lines   int // added plus removed lines
+	Binary  bool
+	Deleted bool
+}
+
+// Violation explains why a single file is rejected.
+type Violation struct {
+	Path   string
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Reason)
+}
+
+// Result holds the outcome of evaluating a change set.
+type Result struct {
+	// Files are per-file violations; they can be resolved by reverting the file.
+	Files []Violation
+	// Limits are change set wide violations (too many files or lines).
+	Limits []string
+}
+
+// OK reports whether the change set satisfies the policy.
+func (r Result) OK() bool {
+	return len(r.Files) == 0 && len(r.Limits) == 0
+}
+
+// Paths returns the paths of violating files.
+func (r Result) Paths() []string {
+	paths := make([]string, 0, len(r.Files))
+	for _, v := range r.Files {
+		paths = append(paths, v.Path)
+	}
+	return paths
+}
+
+// Error formats all violations as a single message.
+func (r Result) Error() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Policy violation: %d problem(s) in generated changes:", len(r.Files)+len(r.Limits)))
+	for _, v := range r.Files {
+		builder.WriteString("\n- ")
+		builder.WriteString(v.String())
+	}
+	for _, limit := range r.Limits {
+		builder.WriteString("\n- ")
+		builder.WriteString(limit)
+	}
+	return builder.String()
+}
+
+// Mode returns the effective violation mode.
+func (p Policy) Mode() Mode {
+	if p.OnViolation == "" {
+		return ModeFail
+	}
+	return p.OnViolation
+}
+
+// CheckPath returns the reason a path is not allowed, or "" when it is.
+// Deny globs always win; when allow globs are set, other paths are rejected.
+func (p Policy) CheckPath(path string) string {
+	for _, glob := range p.Deny {
+		if secretscan.MatchGlob(glob, path) {
+			return fmt.Sprintf("path matches denied pattern %q", glob)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for _, glob := range p.Allow {
+		if secretscan.MatchGlob(glob, path) {
+			return ""
+		}
+	}
+	return "path is outside the allowed patterns"
+}
+
+// Evaluate checks the change set. Limits are computed only over files that do
+// not already violate a per-file rule, so reverting those files cannot turn a
+// passing change set into a failing one.
+func (p Policy) Evaluate(changes []Change) Result {
+	var result Result
+
+	sorted := append([]Change(nil), changes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	files, lines := 0, 0
+	for _, change := range sorted {
+		reason := p.CheckPath(change.Path)
+		if reason == "" && p.ForbidBinary && change.Binary && !change.Deleted {
+			reason = "binary files are not allowed"
+		}
+		if reason != "" {
+			result.Files = append(result.Files, Violation{Path: change.Path, Reason: reason})
+			continue
+		}
+		files++
+		lines += change.Lines
+	}
+
+	if p.MaxFiles > 0 && files > p.MaxFiles {
+		result.Limits = append(result.Limits, fmt.Sprintf("%d files changed, limit is %d", files, p.MaxFiles))
+	}
+	if p.MaxLines > 0 && lines > p.MaxLines {
+		result.Limits = append(result.Limits, fmt.Sprintf("%d lines changed, limit is %d", lines, p.MaxLines))
+	}
+
+	return result
+}
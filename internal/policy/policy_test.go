@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultDeniesWorkflows(t *testing.T) {
+	result := Default().Evaluate([]Change{
+		{Path: ".github/workflows/ci.yml", Lines: 3},
+		{Path: "main.go", Lines: 10},
+	})
+	if len(result.Files) != 1 || result.Files[0].Path != ".github/workflows/ci.yml" {
+		t.Fatalf("Evaluate() files = %v, want workflow violation", result.Files)
+	}
+	if len(result.Limits) != 0 {
+		t.Fatalf("Evaluate() limits = %v, want none", result.Limits)
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`{"allow": ["src/**", "docs/*.md"], "max_files": 2, "forbid_binary": true, "on_violation": "revert"}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if p.Mode() != ModeRevert {
+		t.Fatalf("Mode() = %s, want revert", p.Mode())
+	}
+	if len(p.Deny) != 1 || p.Deny[0] != ".github/workflows/**" {
+		t.Fatalf("Deny = %v, want default kept", p.Deny)
+	}
+
+	override, err := Parse([]byte(`{"deny": []}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(override.Deny) != 0 {
+		t.Fatalf("Deny = %v, want explicit empty list to override default", override.Deny)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	inputs := []string{
+		`{"on_violation": "ignore"}`,
+		`{"max_lines": -1}`,
+		`{"deny": [""]}`,
+		`{"denied": ["x"]}`,
+		`not json`,
+	}
+	for _, input := range inputs {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) error = nil, want error", input)
+		}
+	}
+}
+
+func TestEvaluate_AllowListBinaryAndLimits(t *testing.T) {
+	p := Policy{
+		Allow:        []string{"src/**"},
+		MaxFiles:     1,
+		MaxLines:     5,
+		ForbidBinary: true,
+	}
+	result := p.Evaluate([]Change{
+		{Path: "src/a.go", Lines: 4},
+		{Path: "src/b.go", Lines: 4},
+		{Path: "src/logo.png", Binary: true},
+		{Path: "src/old.bin", Binary: true, Deleted: true},
+		{Path: "Makefile", Lines: 1},
+	})
+
+	if got := strings.Join(result.Paths(), ","); got != "Makefile,src/logo.png" {
+		t.Fatalf("Paths() = %s, want Makefile,src/logo.png", got)
+	}
+	if len(result.Limits) != 2 {
+		t.Fatalf("Limits = %v, want files and lines limits", result.Limits)
+	}
+	if result.OK() {
+		t.Fatal("OK() = true, want false")
+	}
+
+	msg := result.Error()
+	for _, want := range []string{"4 problem(s)", "Makefile: path is outside the allowed patterns", "3 files changed, limit is 1", "8 lines changed, limit is 5"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error() = %q, missing %q", msg, want)
+		}
+	}
+}
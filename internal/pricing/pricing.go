@@ -0,0 +1,137 @@
+// Package pricing turns token usage into USD cost with a per-model price
+// table. The built-in list prices can be overridden or extended from a file.
+package pricing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// Price is the USD cost per million tokens.
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+}
+
+// Table maps model name prefixes to prices. The longest matching prefix wins,
+// so "gpt-5-mini" can differ from "gpt-5".
+type Table map[string]Price
+
+// anthropicPrice derives the cache prices Anthropic charges: writes cost 1.25
+// times and reads 0.1 times the input price.
+func anthropicPrice(input, output float64) Price {
+	return Price{Input: input, Output: output, CacheRead: input * 0.1, CacheWrite: input * 1.25}
+}
+
+// openAIPrice has no cache write surcharge; cached input is billed at cacheRead.
+func openAIPrice(input, output, cacheRead float64) Price {
+	return Price{Input: input, Output: output, CacheRead: cacheRead, CacheWrite: input}
+}
+
+// Default returns the built-in list prices.
+func Default() Table {
+	return Table{
+		"claude-opus-4":     anthropicPrice(15, 75),
+		"claude-sonnet-4":   anthropicPrice(3, 15),
+		"claude-haiku-4":    anthropicPrice(1, 5),
+		"claude-3-7-sonnet": anthropicPrice(3, 15),
+		"claude-3-5-sonnet": anthropicPrice(3, 15),
+		"claude-3-5-haiku":  anthropicPrice(0.8, 4),
+		"claude-3-opus":     anthropicPrice(15, 75),
+		"claude-3-haiku":    anthropicPrice(0.25, 1.25),
+		"gpt-5":             openAIPrice(1.25, 10, 0.125),
+		"gpt-5-mini":        openAIPrice(0.25, 2, 0.025),
+		"gpt-5-nano":        openAIPrice(0.05, 0.4, 0.005),
+		"gpt-4.1":           openAIPrice(2, 8, 0.5),
+		"gpt-4.1-mini":      openAIPrice(0.4, 1.6, 0.1),
+		"gpt-4o":            openAIPrice(2.5, 10, 1.25),
+		"gpt-4o-mini":       openAIPrice(0.15, 0.6, 0.075),
+		"o3":                openAIPrice(2, 8, 0.5),
+		"o4-mini":           openAIPrice(1.1, 4.4, 0.275),
+	}
+}
+
+// filePrice is an entry of a price file. Missing cache prices fall back to
+// the input price.
+type filePrice struct {
+	Input      *float64 `yaml:"input"`
+	Output     *float64 `yaml:"output"`
+	CacheRead  *float64 `yaml:"cache_read"`
+	CacheWrite *float64 `yaml:"cache_write"`
+}
+
+// Load reads a YAML or JSON price file and returns the default table with
+// its entries added or replaced:
+//
+//	claude-sonnet-4: {input: 3, output: 15, cache_read: 0.3, cache_write: 3.75}
+//	qwen2.5-coder: {input: 0, output: 0}
+//
+// An empty path returns the defaults.
+func Load(path string) (Table, error) {
+	table := Default()
+	if path == "" {
+		return table, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price file: %w", err)
+	}
+	var entries map[string]filePrice
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid price file %s: %w", path, err)
+	}
+
+	for model, entry := range entries {
+		model = strings.TrimSpace(model)
+		if model == "" || entry.Input == nil || entry.Output == nil {
+			return nil, fmt.Errorf("invalid price file %s: %q needs input and output prices", path, model)
+		}
+		price := Price{Input: *entry.Input, Output: *entry.Output, CacheRead: *entry.Input, CacheWrite: *entry.Input}
+		if entry.CacheRead != nil {
+			price.CacheRead = *entry.CacheRead
+		}
+		if entry.CacheWrite != nil {
+			price.CacheWrite = *entry.CacheWrite
+		}
+		if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheWrite < 0 {
+			return nil, fmt.Errorf("invalid price file %s: %q has a negative price", path, model)
+		}
+		table[model] = price
+	}
+	return table, nil
+}
+
+// Lookup returns the price of model.
+func (t Table) Lookup(model string) (Price, bool) {
+	var match string
+	for prefix := range t {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return Price{}, false
+	}
+	return t[match], true
+}
+
+// Cost returns the USD cost of usage, false when its model has no price.
+func (t Table) Cost(usage claude.Usage) (float64, bool) {
+	p, ok := t.Lookup(usage.Model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CacheReadTokens)*p.CacheRead +
+		float64(usage.CacheWriteTokens)*p.CacheWrite
+	return cost / 1e6, true
+}
@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+func TestCost_Defaults(t *testing.T) {
+	table := Default()
+
+	// 1070 input, 310 output, 2000 cache write, 6500 cache read at $3/$15.
+	usage := claude.Usage{Model: "claude-sonnet-4-5", InputTokens: 1070, OutputTokens: 310, CacheWriteTokens: 2000, CacheReadTokens: 6500}
+	want := (1070*3 + 310*15 + 2000*3*1.25 + 6500*3*0.1) / 1e6
+	if cost, ok := table.Cost(usage); !ok || math.Abs(cost-want) > 1e-9 {
+		t.Fatalf("Cost() = %v, %v, want %v", cost, ok, want)
+	}
+
+	if cost, ok := table.Cost(claude.Usage{Model: "claude-opus-4-1-20250805", OutputTokens: 1e6}); !ok || cost != 75 {
+		t.Fatalf("Cost() = %v, want 75", cost)
+	}
+	// The longest prefix wins.
+	if cost, _ := table.Cost(claude.Usage{Model: "gpt-5-mini-2025-08-07", InputTokens: 1e6}); cost != 0.25 {
+		t.Fatalf("gpt-5-mini cost = %v, want 0.25", cost)
+	}
+	if _, ok := table.Cost(claude.Usage{Model: "local-model", InputTokens: 1000}); ok {
+		t.Fatal("Cost() should report unknown models")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	content := "claude-sonnet-4: {input: 2, output: 10, cache_read: 0.2}\nqwen2.5-coder: {input: 0.1, output: 0.3}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := table["claude-sonnet-4"]; got != (Price{Input: 2, Output: 10, CacheRead: 0.2, CacheWrite: 2}) {
+		t.Fatalf("override = %+v, want cache write defaulting to input", got)
+	}
+	if _, ok := table.Lookup("qwen2.5-coder:32b"); !ok {
+		t.Fatal("added model not found")
+	}
+	if _, ok := table.Lookup("claude-opus-4"); !ok {
+		t.Fatal("defaults should remain")
+	}
+
+	for _, bad := range []string{"gpt-5: {input: 1}", "gpt-5: {input: -1, output: 1}", "- not a map"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "invalid price file") {
+			t.Errorf("Load(%q) error = %v, want invalid price file", bad, err)
+		}
+	}
+}
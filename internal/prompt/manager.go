@@ -338,14 +338,38 @@ Add user authentication to handler.go
 Rules:
 - Replace all example values with real repository paths, code, and summaries.
 - Never return the literal strings "path/to/file.ext", "relative/path/to/file.go", "... full file content here ...", or "Brief description of changes made".
-- Include the complete file content for every modified file.
+- Include the complete file content for every new or rewritten file.
 - If multiple files change, include additional <file ...> blocks.
 
+Small changes to large existing files (EXAMPLE — replace placeholder values):
+<edit path="relative/path/to/file.go">
+<<<<<<< SEARCH
+exact lines of the current file
+=======
+replacement lines
+>>>>>>> REPLACE
+</edit>
+
+- SEARCH must copy the current lines exactly and match only one place in the file; add surrounding lines when needed.
+- An <edit> may contain several SEARCH/REPLACE blocks; they are applied in order.
+- A unified diff inside <patch>...</patch> is accepted as well; diffs outside <patch> are not applied.
+
 Analysis only (when no code changes are needed):
 <summary>
 Your analysis, recommendations, or answer here.
 You can include explanations, task lists, or any helpful information.
-</summary>`, taskPrompt)
+</summary>
+
+Inline review comments (pull request reviews only, EXAMPLE — replace placeholder values):
+<review_comment path="relative/path/to/file.go" start_line="10" line="12">
+Why these lines should change.
+<suggestion>
+replacement for lines 10-12 of the pull request head
+</suggestion>
+</review_comment>
+- Add <review_comment> blocks next to the <summary> when reviewing without code changes.
+- line and start_line refer to the new version of the file and must be inside the pull request diff; omit start_line for a single line.
+- <suggestion> is optional and replaces the commented lines exactly.`, taskPrompt)
 }
 
 // BuildInstructionChecklist exposes the shared execution checklist for use in status comments.
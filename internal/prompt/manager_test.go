@@ -254,4 +254,7 @@ func TestManager_BuildUserPrompt_Format(t *testing.T) {
 	if !strings.Contains(output, "<summary>") {
 		t.Fatalf("User prompt missing summary guidance:\n%s", output)
 	}
+	if !strings.Contains(output, "<review_comment path=") || !strings.Contains(output, "<suggestion>") {
+		t.Fatalf("User prompt missing inline review guidance:\n%s", output)
+	}
 }
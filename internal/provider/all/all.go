@@ -0,0 +1,12 @@
+// Package all registers every provider implementation. Import it for its side
+// effects where providers are constructed:
+//
+//	import _ "github.com/cexll/swe/internal/provider/all"
+package all
+
+import (
+	_ "github.com/cexll/swe/internal/provider/anthropic"
+	_ "github.com/cexll/swe/internal/provider/codex"
+	_ "github.com/cexll/swe/internal/provider/command"
+	_ "github.com/cexll/swe/internal/provider/openai"
+)
@@ -0,0 +1,370 @@
+// Package anthropic implements a provider that talks to the Anthropic Messages
+// API directly and runs the agent loop in process, with tools scoped to the
+// task's working directory.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+const (
+	// DefaultBaseURL is the public Anthropic API endpoint.
+	DefaultBaseURL = "https://api.anthropic.com"
+
+	apiVersion       = "2023-06-01"
+	defaultMaxTokens = 8192
+	defaultMaxTurns  = 50
+	requestTimeout   = 10 * time.Minute
+)
+
+// toolInstructions tells the model how to work with the in-process tools.
+const toolInstructions = `
+
+## Tools
+You can inspect and edit the repository with the provided tools. Prefer write_file for edits; you do not need to repeat edited files as <file> blocks. Do not commit or push, the service does that. When you are done, reply with a <summary> of the changes without calling further tools.`
+
+var promptManager = prompt.NewManager()
+
+// Provider calls the Messages API and executes tool calls locally.
+type Provider struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	maxTokens int
+	maxTurns  int
+	commands  []string
+	client    *http.Client
+	sandbox   *sandbox.Sandbox
+}
+
+// NewProvider creates a Messages API provider. An empty baseURL uses DefaultBaseURL.
+func NewProvider(apiKey, baseURL, model string) *Provider {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Provider{
+		apiKey:    apiKey,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		model:     model,
+		maxTokens: defaultMaxTokens,
+		maxTurns:  defaultMaxTurns,
+		client:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "anthropic"
+}
+
+// WithSandbox runs commands started by the run_command tool inside sb.
+func (p *Provider) WithSandbox(sb *sandbox.Sandbox) *Provider {
+	p.sandbox = sb
+	return p
+}
+
+// WithCommands sets the programs the run_command tool may start. An empty
+// list uses shared.DefaultCommands.
+func (p *Provider) WithCommands(commands []string) *Provider {
+	p.commands = commands
+	return p
+}
+
+// WithMaxTurns limits the number of model calls per task.
+func (p *Provider) WithMaxTurns(turns int) *Provider {
+	if turns > 0 {
+		p.maxTurns = turns
+	}
+	return p
+}
+
+// APIError is an error response of the Messages API.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("anthropic API error (status %d, %s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Retryable reports whether the request may succeed later: rate limits,
+// overload and server errors.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+type contentBlock struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	IsError      bool            `json:"is_error,omitempty"`
+	CacheControl *cacheControl   `json:"cache_control,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type toolDefinition struct {
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	InputSchema  map[string]any `json:"input_schema"`
+	CacheControl *cacheControl  `json:"cache_control,omitempty"`
+}
+
+type messagesRequest struct {
+	Model     string           `json:"model"`
+	MaxTokens int              `json:"max_tokens"`
+	System    []contentBlock   `json:"system"`
+	Messages  []message        `json:"messages"`
+	Tools     []toolDefinition `json:"tools,omitempty"`
+}
+
+// Usage is the token usage reported by the Messages API.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u *Usage) add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+}
+
+// usage converts the Messages API usage of a task.
+func (p *Provider) usage(u Usage, turns int, duration time.Duration) claude.Usage {
+	return claude.Usage{
+		Model:            p.model,
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+		Turns:            turns,
+		Duration:         duration,
+	}
+}
+
+type messagesResponse struct {
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      Usage          `json:"usage"`
+}
+
+// GenerateCode runs the agent loop until the model stops calling tools.
+func (p *Provider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	log.Printf("[Anthropic] Starting code generation (model %s, prompt length: %d chars)", p.model, len(req.Prompt))
+
+	if req.RepoPath == "" {
+		return nil, fmt.Errorf("repository path is required")
+	}
+	if _, err := os.Stat(req.RepoPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("repository path does not exist: %s", req.RepoPath)
+	}
+
+	files, err := promptManager.ListRepoFiles(req.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+	systemPrompt := promptManager.BuildDefaultSystemPrompt(files, req.Context) + toolInstructions
+	userPrompt := promptManager.BuildUserPrompt(req.Prompt)
+
+	toolbox := shared.NewToolbox(req.RepoPath, p.commands, p.sandbox)
+	if disallowed := req.Context["disallowed_tools"]; disallowed != "" {
+		toolbox.Disable(strings.Split(disallowed, ",")...)
+	}
+
+	ctx, cancel := p.sandbox.WithTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	messages := []message{{Role: "user", Content: []contentBlock{{Type: "text", Text: userPrompt}}}}
+	var usage Usage
+	var finalText string
+	turns := 0
+	for {
+		if turns >= p.maxTurns {
+			return nil, fmt.Errorf("anthropic: no final answer after %d turns", p.maxTurns)
+		}
+		turns++
+
+		resp, err := p.createMessage(ctx, systemPrompt, messages, toolbox.Tools())
+		if err != nil {
+			return nil, err
+		}
+		usage.add(resp.Usage)
+		if err := req.CheckBudget(p.usage(usage, turns, time.Since(start))); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message{Role: "assistant", Content: resp.Content})
+
+		var results []contentBlock
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" {
+				continue
+			}
+			output, err := toolbox.Run(ctx, block.Name, block.Input)
+			result := contentBlock{Type: "tool_result", ToolUseID: block.ID, Content: output}
+			if err != nil {
+				result.Content, result.IsError = err.Error(), true
+			}
+			if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
+				log.Printf("[Anthropic] Tool %s: error=%v", block.Name, err)
+			}
+			results = append(results, result)
+		}
+		if len(results) == 0 {
+			finalText = textOf(resp.Content)
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		messages = append(messages, message{Role: "user", Content: results})
+	}
+
+	duration := time.Since(start)
+	log.Printf("[Anthropic] Finished after %d turns in %v: input=%d output=%d cache_write=%d cache_read=%d tokens",
+		turns, duration.Round(time.Millisecond), usage.InputTokens, usage.OutputTokens,
+		usage.CacheCreationInputTokens, usage.CacheReadInputTokens)
+
+	written := toolbox.Written()
+	response, err := parseResponse(finalText)
+	if err != nil {
+		if len(written) == 0 {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		// Edits were made through tools; the closing text is optional.
+		response = &claude.CodeResponse{Summary: "Updated " + strings.Join(written, ", ")}
+	}
+	response.Usage = p.usage(usage, turns, duration)
+	response.SystemPrompt, response.UserPrompt, response.RawResponse = systemPrompt, userPrompt, finalText
+
+	log.Printf("[Anthropic] %d files written through tools, %d file blocks in response", len(written), len(response.Files))
+	return response, nil
+}
+
+// createMessage sends one Messages API request. The system prompt, the tool
+// list and the latest message carry cache breakpoints, so every turn reuses
+// the prefix cached by the previous one.
+func (p *Provider) createMessage(ctx context.Context, system string, messages []message, tools []shared.Tool) (*messagesResponse, error) {
+	ephemeral := &cacheControl{Type: "ephemeral"}
+
+	request := messagesRequest{
+		Model:     p.model,
+		MaxTokens: p.maxTokens,
+		System:    []contentBlock{{Type: "text", Text: system, CacheControl: ephemeral}},
+		Messages:  append([]message(nil), messages...),
+	}
+	for _, tool := range tools {
+		request.Tools = append(request.Tools, toolDefinition{Name: tool.Name, Description: tool.Description, InputSchema: tool.Schema})
+	}
+	if n := len(request.Tools); n > 0 {
+		request.Tools[n-1].CacheControl = ephemeral
+	}
+	last := &request.Messages[len(request.Messages)-1]
+	last.Content = append([]contentBlock(nil), last.Content...)
+	last.Content[len(last.Content)-1].CacheControl = ephemeral
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", p.apiKey)
+	httpReq.Header.Set("Anthropic-Version", apiVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anthropic response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Type: "http_error", Message: truncate(string(data), 500)}
+		var envelope struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &envelope) == nil && envelope.Error.Message != "" {
+			apiErr.Type, apiErr.Message = envelope.Error.Type, envelope.Error.Message
+		}
+		return nil, apiErr
+	}
+
+	var result messagesResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+	return &result, nil
+}
+
+func parseResponse(text string) (*claude.CodeResponse, error) {
+	parsed, err := shared.ParseResponse("Anthropic", text)
+	if err != nil {
+		return nil, err
+	}
+	response := &claude.CodeResponse{Summary: parsed.Summary}
+	for _, file := range parsed.Files {
+		response.Files = append(response.Files, claude.FileChange{Path: file.Path, Content: file.Content, Edits: file.Edits})
+	}
+	for _, comment := range parsed.Comments {
+		response.Comments = append(response.Comments, claude.ReviewComment(comment))
+	}
+	return response, nil
+}
+
+func textOf(blocks []contentBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
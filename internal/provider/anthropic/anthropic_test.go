@@ -0,0 +1,174 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// scriptedServer answers Messages API calls with the given responses in order
+// and records the decoded requests.
+func scriptedServer(t *testing.T, responses ...string) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("X-Api-Key") != "test-key" || r.Header.Get("Anthropic-Version") != apiVersion {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, body)
+		if len(requests) > len(responses) {
+			t.Errorf("unexpected request #%d", len(requests))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[len(requests)-1]))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestGenerateCode_ToolLoop(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	server, requests := scriptedServer(t,
+		`{"content":[{"type":"text","text":"Reading first."},{"type":"tool_use","id":"t1","name":"read_file","input":{"path":"main.go"}}],
+		  "stop_reason":"tool_use","usage":{"input_tokens":1000,"output_tokens":100,"cache_creation_input_tokens":2000}}`,
+		`{"content":[{"type":"tool_use","id":"t2","name":"write_file","input":{"path":"main.go","content":"package main\n\nfunc main() {}\n"}},
+		              {"type":"tool_use","id":"t3","name":"read_file","input":{"path":"../secret"}}],
+		  "stop_reason":"tool_use","usage":{"input_tokens":50,"output_tokens":200,"cache_read_input_tokens":3000}}`,
+		`{"content":[{"type":"text","text":"<summary>Add main function</summary>"}],
+		  "stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":10,"cache_read_input_tokens":3500}}`,
+	)
+
+	p := NewProvider("test-key", server.URL, "claude-sonnet-4-5")
+	resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "Add main", RepoPath: repo})
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+
+	if resp.Summary != "Add main function" || len(resp.Files) != 0 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "main.go")); string(data) != "package main\n\nfunc main() {}\n" {
+		t.Fatalf("main.go = %q, want tool edit applied", data)
+	}
+	// Usage is summed over the turns; the executor prices it.
+	if u := resp.Usage; u.Model != "claude-sonnet-4-5" || u.InputTokens != 1070 || u.OutputTokens != 310 ||
+		u.CacheWriteTokens != 2000 || u.CacheReadTokens != 6500 || u.Turns != 3 || u.Duration <= 0 {
+		t.Fatalf("Usage = %+v", u)
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("requests = %d, want 3", len(*requests))
+	}
+	first := (*requests)[0]
+	if first["model"] != "claude-sonnet-4-5" {
+		t.Fatalf("model = %v", first["model"])
+	}
+	system := first["system"].([]any)[0].(map[string]any)
+	if system["cache_control"] == nil || !strings.Contains(system["text"].(string), "## Tools") {
+		t.Fatalf("system block = %v, want cached prompt with tool instructions", system)
+	}
+	tools := first["tools"].([]any)
+	if len(tools) != 5 || tools[len(tools)-1].(map[string]any)["cache_control"] == nil {
+		t.Fatalf("tools = %v, want 5 with a cache breakpoint on the last", tools)
+	}
+
+	// The third request carries the tool results of the second turn.
+	messages := (*requests)[2]["messages"].([]any)
+	results := messages[len(messages)-1].(map[string]any)["content"].([]any)
+	ok := results[0].(map[string]any)
+	escaped := results[1].(map[string]any)
+	if ok["tool_use_id"] != "t2" || ok["is_error"] != nil || escaped["is_error"] != true ||
+		!strings.Contains(escaped["content"].(string), "must be relative") {
+		t.Fatalf("tool results = %v", results)
+	}
+	if results[1].(map[string]any)["cache_control"] == nil {
+		t.Fatal("latest message should carry a cache breakpoint")
+	}
+	if prev := messages[len(messages)-3].(map[string]any)["content"].([]any); prev[0].(map[string]any)["cache_control"] != nil {
+		t.Fatal("earlier messages must not keep cache breakpoints")
+	}
+}
+
+func TestGenerateCode_DisallowedTools(t *testing.T) {
+	server, requests := scriptedServer(t, `{"content":[{"type":"text","text":"<summary>Nothing to do</summary>"}],"stop_reason":"end_turn","usage":{}}`)
+
+	p := NewProvider("test-key", server.URL, "claude-sonnet-4-5")
+	req := &claude.CodeRequest{Prompt: "Explain", RepoPath: t.TempDir(), Context: map[string]string{"disallowed_tools": "Bash,WebFetch"}}
+	if _, err := p.GenerateCode(context.Background(), req); err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	for _, tool := range (*requests)[0]["tools"].([]any) {
+		if tool.(map[string]any)["name"] == "run_command" {
+			t.Fatal("run_command offered although Bash is disallowed")
+		}
+	}
+}
+
+func TestGenerateCode_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("test-key", server.URL, "claude-sonnet-4-5")
+	_, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: t.TempDir()})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 || apiErr.Type != "overloaded_error" || !apiErr.Retryable() {
+		t.Fatalf("error = %v, want retryable overloaded APIError", err)
+	}
+}
+
+func TestGenerateCode_TurnLimit(t *testing.T) {
+	loop := `{"content":[{"type":"tool_use","id":"t","name":"list_dir","input":{"path":"."}}],"stop_reason":"tool_use","usage":{}}`
+	server, _ := scriptedServer(t, loop, loop)
+
+	p := NewProvider("test-key", server.URL, "claude-sonnet-4-5").WithMaxTurns(2)
+	_, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "after 2 turns") {
+		t.Fatalf("error = %v, want turn limit", err)
+	}
+}
+
+func TestGenerateCode_BudgetStopsLoop(t *testing.T) {
+	loop := `{"content":[{"type":"tool_use","id":"t","name":"list_dir","input":{"path":"."}}],"stop_reason":"tool_use","usage":{"input_tokens":500,"output_tokens":20,"cache_read_input_tokens":100}}`
+	server, requests := scriptedServer(t, loop, loop, loop)
+
+	var seen []claude.Usage
+	capErr := errors.New("over budget")
+	req := &claude.CodeRequest{Prompt: "x", RepoPath: t.TempDir(), Budget: func(u claude.Usage) error {
+		seen = append(seen, u)
+		if u.Tokens() > 1000 {
+			return capErr
+		}
+		return nil
+	}}
+	_, err := NewProvider("test-key", server.URL, "claude-sonnet-4-5").GenerateCode(context.Background(), req)
+	if !errors.Is(err, capErr) {
+		t.Fatalf("error = %v, want the budget error", err)
+	}
+	if len(*requests) != 2 || len(seen) != 2 {
+		t.Fatalf("requests = %d, checks = %d, want the loop stopped after the second turn", len(*requests), len(seen))
+	}
+	if last := seen[1]; last.Model != "claude-sonnet-4-5" || last.InputTokens != 1000 || last.CacheReadTokens != 200 || last.Turns != 2 {
+		t.Fatalf("usage = %+v", last)
+	}
+}
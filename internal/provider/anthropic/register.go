@@ -0,0 +1,33 @@
+package anthropic
+
+import (
+	"fmt"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+func init() {
+	provider.Register(provider.Registration{
+		Name: "anthropic",
+		Settings: []provider.Setting{
+			{Key: "API_KEY", Env: []string{"ANTHROPIC_API_KEY"}, Required: true, Description: "Anthropic API key"},
+			{Key: "BASE_URL", Env: []string{"ANTHROPIC_BASE_URL"}, Default: DefaultBaseURL, Description: "Messages API endpoint"},
+			{Key: "MODEL", Env: []string{"CLAUDE_MODEL"}, Default: "claude-3-5-sonnet-20241022", Description: "Model name"},
+			{Key: "TOOL_COMMANDS", Env: []string{"TOOL_ALLOWED_COMMANDS"}, Description: "Programs run_command may start, comma-separated"},
+			{Key: "MAX_TURNS", Default: fmt.Sprint(defaultMaxTurns), Description: "Model calls per task"},
+		},
+		Validate: func(s provider.Settings) error {
+			if s.Int("MAX_TURNS") <= 0 {
+				return fmt.Errorf("MAX_TURNS must be a positive number (got %q)", s.Get("MAX_TURNS"))
+			}
+			return nil
+		},
+		New: func(s provider.Settings, sb *sandbox.Sandbox) (provider.Provider, error) {
+			return NewProvider(s.Get("API_KEY"), s.Get("BASE_URL"), s.Get("MODEL")).
+				WithCommands(s.List("TOOL_COMMANDS")).
+				WithMaxTurns(s.Int("MAX_TURNS")).
+				WithSandbox(sb), nil
+		},
+	})
+}
@@ -1,23 +1,58 @@
 package claude
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/cexll/swe/internal/prompt"
 	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/sandbox"
 )
 
+// sandboxEnv are the variables the Claude CLI needs inside the sandbox.
+var sandboxEnv = []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_BASE_URL"}
+
 // FileChange represents a file modification
 type FileChange struct {
-	Path    string
-	Content string
+	Path       string
+	Content    string
+	Executable bool   // file has the executable bit set
+	Binary     bool   // binary file; its content is only available from Blob
+	Blob       string // git object ID of the content; Content is empty when set
+	Lines      int    // changed lines from git diff --numstat, 0 when unknown
+
+	// Edits to apply to the current file instead of replacing it with
+	// Content; the executor resolves them into Content before writing
+	Edits []shared.Edit
+}
+
+// LineCount returns the changed lines of the file: the diff statistics when
+// known, otherwise the lines of its content. Binary files count as zero.
+func (f FileChange) LineCount() int {
+	if f.Binary {
+		return 0
+	}
+	if f.Lines > 0 {
+		return f.Lines
+	}
+	return strings.Count(f.Content, "\n") + 1
+}
+
+// ReviewComment is an inline comment on a line range of a pull request
+type ReviewComment struct {
+	Path      string
+	Line      int
+	StartLine int    // first line of a multi-line comment, 0 otherwise
+	Body      string // may contain a ```suggestion block
 }
 
 // CodeRequest contains input for code generation
@@ -25,13 +60,69 @@ type CodeRequest struct {
 	Prompt   string            // User instruction
 	RepoPath string            // Repository path
 	Context  map[string]string // Additional context
+
+	// Progress receives events from providers that stream their output. It
+	// is called from the goroutine reading the output and should return quickly.
+	Progress func(ProgressEvent)
+
+	// Budget is called by agent loops after every turn with the usage so
+	// far. A non-nil error stops the task with that error.
+	Budget func(Usage) error
+
+	// SessionID continues an earlier session of the provider. Providers
+	// without sessions, or that cannot restore it, start a new one.
+	SessionID string
+}
+
+// CheckBudget passes usage to the request's budget callback, if any.
+func (r *CodeRequest) CheckBudget(usage Usage) error {
+	if r == nil || r.Budget == nil {
+		return nil
+	}
+	return r.Budget(usage)
 }
 
 // CodeResponse contains the AI-generated code changes
 type CodeResponse struct {
-	Files   []FileChange // Modified files
-	Summary string       // Summary of changes
-	CostUSD float64      // Cost in USD
+	Files    []FileChange    // Modified files
+	Comments []ReviewComment // Inline review comments for pull requests
+	Summary  string          // Summary of changes
+	CostUSD  float64         // Cost in USD, 0 when the provider only reports usage
+	Usage    Usage           // Token usage, model, duration and turns
+
+	// Provider that produced the response, set by wrappers such as the
+	// fallback chain; empty means the provider that was called
+	Provider string
+
+	// SessionID can be passed back in a later request to resume the session;
+	// empty when the provider has no sessions
+	SessionID string
+
+	// Rendered prompts and unparsed provider output, kept as task artifacts
+	SystemPrompt string
+	UserPrompt   string
+	RawResponse  string
+}
+
+// Usage is the resource use of a response. Providers fill in what they
+// know; zero values mean unknown.
+type Usage struct {
+	// Provider that reported the usage, set by wrappers such as the fallback
+	// chain; empty means the provider that was called
+	Provider string
+
+	Model            string
+	InputTokens      int // input tokens not read from the cache
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Turns            int // model requests
+	Duration         time.Duration
+}
+
+// Tokens returns all input and output tokens.
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
 }
 
 // CLIResult represents the result from Claude CLI
@@ -39,22 +130,23 @@ type CLIResult struct {
 	Result  string  `json:"result"`
 	IsError bool    `json:"isError"`
 	CostUSD float64 `json:"costUSD"`
+	Usage   Usage   `json:"-"` // from the stream-json result event
+
+	SessionID string `json:"session_id"`
 }
 
 // Provider implements the AI provider interface for Claude
 type Provider struct {
-	model string
+	model       string
+	apiKey      string
+	sandbox     *sandbox.Sandbox
+	transcripts shared.Transcripts
 }
 
 var promptManager = prompt.NewManager()
 
 // NewProvider creates a new Claude provider
 func NewProvider(apiKey, model string) *Provider {
-	// Set environment variables for Claude Code CLI
-	// Support both ANTHROPIC_API_KEY and ANTHROPIC_AUTH_TOKEN
-	os.Setenv("ANTHROPIC_API_KEY", apiKey)
-	os.Setenv("ANTHROPIC_AUTH_TOKEN", apiKey)
-
 	// Preserve ANTHROPIC_BASE_URL if already set in environment
 	// This allows using custom API endpoints
 	if baseURL := os.Getenv("ANTHROPIC_BASE_URL"); baseURL != "" {
@@ -62,7 +154,8 @@ func NewProvider(apiKey, model string) *Provider {
 	}
 
 	return &Provider{
-		model: model,
+		model:  model,
+		apiKey: apiKey,
 	}
 }
 
@@ -71,10 +164,60 @@ func (p *Provider) Name() string {
 	return "claude"
 }
 
+// WithSandbox runs the Claude CLI inside sb. A nil sandbox only scrubs the environment.
+func (p *Provider) WithSandbox(sb *sandbox.Sandbox) *Provider {
+	p.sandbox = sb
+	return p
+}
+
+// WithSessionDir keeps session transcripts in dir so that later requests can
+// resume them. An empty dir disables resuming.
+func (p *Provider) WithSessionDir(dir string) *Provider {
+	p.transcripts = shared.Transcripts{}
+	if dir != "" {
+		p.transcripts.Dir = filepath.Join(dir, "claude")
+	}
+	return p
+}
+
+// errSessionUnavailable marks a resume that failed because the CLI cannot
+// load the session; the request is then run in a new session.
+var errSessionUnavailable = errors.New("session unavailable")
+
+// cliSession is the session handling of a CLI run: resume is the session to
+// continue, transcripts keeps the run's session for later requests.
+type cliSession struct {
+	resume      string
+	transcripts shared.Transcripts
+}
+
 // callClaudeCLI calls the Claude CLI directly with proper working directory
 func callClaudeCLI(workDir, prompt, model, disallowedTools string) (*CLIResult, error) {
-	// Build command arguments
-	args := []string{"-p", "--output-format", "json"}
+	return runClaudeCLI(context.Background(), nil, nil, workDir, prompt, model, disallowedTools, cliSession{}, nil, nil)
+}
+
+// cliEnv returns the environment of the Claude CLI. The API key is only
+// passed to the command, never set in the server environment; the CLI reads
+// it as ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN depending on the endpoint.
+func (p *Provider) cliEnv() []string {
+	env := os.Environ()
+	if p.apiKey != "" {
+		env = append(env, "ANTHROPIC_API_KEY="+p.apiKey, "ANTHROPIC_AUTH_TOKEN="+p.apiKey)
+	}
+	return env
+}
+
+// runClaudeCLI calls the Claude CLI, optionally inside a sandbox, with env
+// (the server environment when nil) scrubbed by the sandbox. The output is
+// streamed; tool calls and todo updates are passed to progress as they arrive,
+// and the usage so far to budget after every assistant message. The CLI is
+// stopped as soon as budget returns an error, which is then returned.
+func runClaudeCLI(ctx context.Context, sb *sandbox.Sandbox, env []string, workDir, prompt, model, disallowedTools string, session cliSession, progress func(ProgressEvent), budget func(Usage) error) (*CLIResult, error) {
+	// Build command arguments (stream-json requires --verbose in print mode)
+	args := []string{"-p", "--output-format", "stream-json", "--verbose"}
+	if session.resume != "" {
+		args = append(args, "--resume", session.resume)
+	}
 	// Always use default model - don't pass --model parameter
 	// if model != "" {
 	//	args = append(args, "--model", model)
@@ -85,11 +228,51 @@ func callClaudeCLI(workDir, prompt, model, disallowedTools string) (*CLIResult,
 		log.Printf("[Claude CLI] Disallowed tools: %s", disallowedTools)
 	}
 
+	ctx, cancel := sb.WithTimeout(ctx)
+	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
 	// Create command
-	cmd := exec.Command("claude", args...)
+	cmd := exec.CommandContext(ctx, "claude", args...)
 	cmd.Dir = workDir // Critical: set working directory to cloned repo
+	cmd.Env = env
 	cmd.Stdin = strings.NewReader(prompt)
 
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdout := sb.LimitOutput(&stdoutBuf)
+	stderr := sb.LimitOutput(&stderrBuf)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	var meter usageMeter
+	var budgetErr error // set by the writer, read after cmd.Run has returned
+	if progress != nil || budget != nil {
+		events := shared.NewLineWriter(func(line []byte) {
+			if progress != nil {
+				streamProgress(line, workDir, progress)
+			}
+			if budget != nil && budgetErr == nil && meter.add(line) {
+				if budgetErr = budget(meter.usage); budgetErr != nil {
+					stop()
+				}
+			}
+		})
+		cmd.Stdout = io.MultiWriter(stdout, events)
+	}
+
+	cleanup, err := sb.Wrap(cmd, sandboxEnv...)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	projects := claudeProjectsDir(cmd.Env)
+	if session.resume != "" {
+		if err := restoreClaudeSession(session, projects, workDir); err != nil {
+			return nil, fmt.Errorf("%w: %v", errSessionUnavailable, err)
+		}
+	}
+
 	// Enable debug logging if requested
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
 		log.Printf("[Claude CLI] Working directory: %s", workDir)
@@ -99,21 +282,37 @@ func callClaudeCLI(workDir, prompt, model, disallowedTools string) (*CLIResult,
 
 	// Execute command
 	start := time.Now()
-	output, err := cmd.CombinedOutput()
+	err = cmd.Run()
+	output := stdoutBuf.Bytes()
 	duration := time.Since(start)
+	if budgetErr != nil {
+		log.Printf("[Claude CLI] Stopped after %v: %v", duration, budgetErr)
+		return nil, budgetErr
+	}
+	if sandbox.OutputExceeded(stdout) || sandbox.OutputExceeded(stderr) {
+		err = fmt.Errorf("%w (%v)", sandbox.ErrOutputLimit, err)
+	} else if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		err = fmt.Errorf("%w (%v)", ctxErr, err)
+	}
 
 	if err != nil {
-		outputPreview := truncateString(string(output), 1000)
+		outputPreview := truncateString(stderrBuf.String()+string(output), 1000)
 		log.Printf("[Claude CLI] Command failed after %v: %v", duration, err)
 		log.Printf("[Claude CLI] Output preview: %s", outputPreview)
+		if session.resume != "" && strings.Contains(outputPreview, "No conversation found") {
+			err = fmt.Errorf("%w (%v)", errSessionUnavailable, err)
+		} else if result, parseErr := parseStreamResult(output); parseErr == nil && result.IsError && result.Result != "" {
+			// The CLI reported why the run failed, for example an API error
+			return nil, fmt.Errorf("claude CLI error: %s (%w)", result.Result, err)
+		}
 		return nil, fmt.Errorf("claude CLI execution failed: %w (output preview: %s)", err, outputPreview)
 	}
 
 	log.Printf("[Claude CLI] Command completed in %v", duration)
 
-	// Parse JSON response
-	var result CLIResult
-	if err := json.Unmarshal(output, &result); err != nil {
+	// Parse the result event
+	result, err := parseStreamResult(output)
+	if err != nil {
 		outputPreview := truncateString(string(output), 1000)
 		log.Printf("[Claude CLI] Failed to parse JSON response: %v", err)
 		log.Printf("[Claude CLI] Raw output preview: %s", outputPreview)
@@ -124,7 +323,47 @@ func callClaudeCLI(workDir, prompt, model, disallowedTools string) (*CLIResult,
 		return nil, fmt.Errorf("claude CLI error: %s", result.Result)
 	}
 
-	return &result, nil
+	if result.SessionID != "" {
+		if err := session.transcripts.Save(projects, result.SessionID); err != nil {
+			log.Printf("[Claude CLI] Warning: Failed to keep session %s: %v", result.SessionID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// claudeProjectsDir returns the directory the CLI keeps its session
+// transcripts in, for a command environment.
+func claudeProjectsDir(env []string) string {
+	if dir := shared.Getenv(env, "CLAUDE_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "projects")
+	}
+	return filepath.Join(shared.Getenv(env, "HOME"), ".claude", "projects")
+}
+
+// restoreClaudeSession copies a saved transcript to where the CLI looks for
+// it: the project directory named after the working directory, which is a
+// different one for every task.
+func restoreClaudeSession(session cliSession, projects, workDir string) error {
+	src, _, err := session.transcripts.Find(session.resume)
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	return shared.CopyFile(src, filepath.Join(projects, claudeProjectName(workDir), session.resume+".jsonl"))
+}
+
+// claudeProjectName is the CLI's project directory name for a working
+// directory: every character other than a letter or digit becomes '-'.
+func claudeProjectName(workDir string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, workDir)
 }
 
 // GenerateCode generates code changes using Claude Code CLI
@@ -149,7 +388,8 @@ func (p *Provider) GenerateCode(ctx context.Context, req *CodeRequest) (*CodeRes
 	systemPrompt := promptManager.BuildDefaultSystemPrompt(files, req.Context)
 
 	// 3. Build full prompt with system and user content
-	fullPrompt := fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, promptManager.BuildUserPrompt(req.Prompt))
+	userPrompt := promptManager.BuildUserPrompt(req.Prompt)
+	fullPrompt := fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, userPrompt)
 
 	log.Printf("[Claude] Calling Claude CLI with default model in directory: %s", req.RepoPath)
 
@@ -161,8 +401,15 @@ func (p *Provider) GenerateCode(ctx context.Context, req *CodeRequest) (*CodeRes
 		}
 	}
 
-	// 5. Call Claude CLI with correct working directory
-	result, err := callClaudeCLI(req.RepoPath, fullPrompt, p.model, disallowedTools)
+	// 5. Call Claude CLI with correct working directory, resuming the
+	// session of an earlier request when it can still be loaded
+	session := cliSession{resume: req.SessionID, transcripts: p.transcripts}
+	result, err := runClaudeCLI(ctx, p.sandbox, p.cliEnv(), req.RepoPath, fullPrompt, p.model, disallowedTools, session, req.Progress, req.CheckBudget)
+	if err != nil && session.resume != "" && errors.Is(err, errSessionUnavailable) {
+		log.Printf("[Claude] Cannot resume session %s, starting a new one: %v", session.resume, err)
+		session.resume = ""
+		result, err = runClaudeCLI(ctx, p.sandbox, p.cliEnv(), req.RepoPath, fullPrompt, p.model, disallowedTools, session, req.Progress, req.CheckBudget)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Claude CLI error: %w", err)
 	}
@@ -181,8 +428,11 @@ func (p *Provider) GenerateCode(ctx context.Context, req *CodeRequest) (*CodeRes
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// Set cost
+	// Set cost; the CLI reports the billed amount
 	response.CostUSD = result.CostUSD
+	response.Usage = result.Usage
+	response.SessionID = result.SessionID
+	response.SystemPrompt, response.UserPrompt, response.RawResponse = systemPrompt, userPrompt, responseText
 
 	log.Printf("[Claude] Extracted %d file changes", len(response.Files))
 	return response, nil
@@ -210,8 +460,12 @@ func parseCodeResponse(response string) (*CodeResponse, error) {
 		result.Files = append(result.Files, FileChange{
 			Path:    file.Path,
 			Content: file.Content,
+			Edits:   file.Edits,
 		})
 	}
+	for _, comment := range parsed.Comments {
+		result.Comments = append(result.Comments, ReviewComment(comment))
+	}
 
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
 		log.Printf("[Parse] Found %d file changes", len(result.Files))
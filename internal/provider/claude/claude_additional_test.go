@@ -2,10 +2,13 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cexll/swe/internal/provider/shared"
 )
@@ -62,6 +65,9 @@ func TestGenerateCode_UsesClaudeCLI(t *testing.T) {
 	if resp.Summary != "done" {
 		t.Fatalf("Summary = %q, want done", resp.Summary)
 	}
+	if !strings.Contains(resp.RawResponse, "<summary>done</summary>") || !strings.Contains(resp.UserPrompt, "Add file") || !strings.Contains(resp.SystemPrompt, "main.go") {
+		t.Fatalf("artifacts not recorded: raw=%q user=%q", resp.RawResponse, resp.UserPrompt)
+	}
 }
 
 func TestGenerateCode_CLIFailure(t *testing.T) {
@@ -108,6 +114,168 @@ func TestGenerateCode_CLIReportedError(t *testing.T) {
 	}
 }
 
+func TestGenerateCode_StreamsProgress(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatalf("failed to write repo file: %v", err)
+	}
+
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Planning"},{"type":"tool_use","name":"TodoWrite","input":{"todos":[{"content":"Fix parser","status":"in_progress"},{"content":"Add tests","status":"pending"}]}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"` + filepath.Join(repoDir, "main.go") + `","old_string":"a","new_string":"b"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","content":"ok"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}`,
+		`{"type":"result","subtype":"success","is_error":false,"result":"<summary>done</summary>","total_cost_usd":0.25,"duration_ms":4200,"num_turns":3,"usage":{"input_tokens":12,"output_tokens":340,"cache_creation_input_tokens":5000,"cache_read_input_tokens":9000}}`,
+	}
+	script := "#!/bin/sh\ncat >/dev/null\ncat <<'JSON'\n" + strings.Join(lines, "\n") + "\nJSON\n"
+	cliDir := t.TempDir()
+	writeExecutable(t, cliDir, "claude", script)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+
+	var events []ProgressEvent
+	resp, err := NewProvider("fake", "claude-3").GenerateCode(context.Background(), &CodeRequest{
+		Prompt:   "Fix it",
+		RepoPath: repoDir,
+		Progress: func(ev ProgressEvent) { events = append(events, ev) },
+	})
+	if err != nil {
+		t.Fatalf("GenerateCode returned error: %v", err)
+	}
+	if resp.Summary != "done" || resp.CostUSD != 0.25 || resp.SessionID != "s1" {
+		t.Fatalf("response = %+v, want result event", resp)
+	}
+	want := Usage{Model: "claude-sonnet-4-5", InputTokens: 12, OutputTokens: 340, CacheWriteTokens: 5000, CacheReadTokens: 9000, Turns: 3, Duration: 4200 * time.Millisecond}
+	if resp.Usage != want {
+		t.Fatalf("Usage = %+v, want %+v", resp.Usage, want)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("events = %+v, want todo, edit, file and bash", events)
+	}
+	if ev := events[0]; ev.Kind != ProgressTodo || len(ev.Todos) != 2 || ev.Todos[0] != (TodoItem{Text: "Fix parser", Status: TodoInProgress}) {
+		t.Fatalf("todo event = %+v", ev)
+	}
+	if ev := events[1]; ev.Kind != ProgressTool || ev.Tool != "Edit" || ev.Detail != "main.go" {
+		t.Fatalf("tool event = %+v", ev)
+	}
+	if ev := events[2]; ev.Kind != ProgressFile || ev.Path != "main.go" {
+		t.Fatalf("file event = %+v", ev)
+	}
+	if ev := events[3]; ev.Tool != "Bash" || ev.Detail != "go test ./..." {
+		t.Fatalf("bash event = %+v", ev)
+	}
+}
+
+func TestGenerateCode_StopsAtBudget(t *testing.T) {
+	repoDir := t.TempDir()
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}`,
+		`{"type":"assistant","message":{"id":"m1","usage":{"input_tokens":10,"output_tokens":100,"cache_read_input_tokens":1000},"content":[{"type":"text","text":"Planning"}]}}`,
+		`{"type":"assistant","message":{"id":"m1","usage":{"input_tokens":10,"output_tokens":100,"cache_read_input_tokens":1000},"content":[{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"assistant","message":{"id":"m2","usage":{"input_tokens":20,"output_tokens":200,"cache_creation_input_tokens":500},"content":[{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`,
+	}
+	// exec keeps the CLI a single process, which the cancelled context kills
+	script := "#!/bin/sh\ncat >/dev/null\ncat <<'JSON'\n" + strings.Join(lines, "\n") + "\nJSON\nexec sleep 60\n"
+	cliDir := t.TempDir()
+	writeExecutable(t, cliDir, "claude", script)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+
+	var checked []Usage
+	errBudget := errors.New("budget exceeded")
+	start := time.Now()
+	_, err := NewProvider("fake", "claude-3").GenerateCode(context.Background(), &CodeRequest{
+		Prompt:   "Fix it",
+		RepoPath: repoDir,
+		Budget: func(usage Usage) error {
+			checked = append(checked, usage)
+			if usage.OutputTokens > 150 {
+				return errBudget
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, errBudget) {
+		t.Fatalf("GenerateCode error = %v, want the budget error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("GenerateCode took %v, want the CLI stopped", elapsed)
+	}
+	want := []Usage{
+		{Model: "claude-sonnet-4-5", InputTokens: 10, OutputTokens: 100, CacheReadTokens: 1000, Turns: 1},
+		{Model: "claude-sonnet-4-5", InputTokens: 30, OutputTokens: 300, CacheReadTokens: 1000, CacheWriteTokens: 500, Turns: 2},
+	}
+	if !reflect.DeepEqual(checked, want) {
+		t.Fatalf("checked usage = %+v, want %+v", checked, want)
+	}
+}
+
+func TestGenerateCode_ResumesSession(t *testing.T) {
+	// The fake CLI resumes a session only when its transcript is in the
+	// project directory; a new session writes one under another project.
+	cliDir := t.TempDir()
+	argsLog := filepath.Join(cliDir, "args.log")
+	script := `#!/bin/sh
+cat >/dev/null
+echo "$@" >> ` + argsLog + `
+if [ "$5" = "--resume" ]; then
+  if ! ls "$HOME"/.claude/projects/*/"$6".jsonl >/dev/null 2>&1; then
+    echo "No conversation found with session ID: $6" >&2
+    exit 1
+  fi
+  id=$6
+else
+  id=s-new
+  mkdir -p "$HOME/.claude/projects/-old"
+  echo '{}' > "$HOME/.claude/projects/-old/$id.jsonl"
+fi
+echo '{"type":"result","is_error":false,"result":"<summary>done</summary>","session_id":"'$id'"}'
+`
+	writeExecutable(t, cliDir, "claude", script)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+
+	sessionDir := t.TempDir()
+	provider := NewProvider("fake", "claude-3").WithSessionDir(sessionDir)
+	generate := func(sessionID string) *CodeResponse {
+		t.Helper()
+		// Every run gets a new HOME and working directory, like sandboxed tasks.
+		t.Setenv("HOME", t.TempDir())
+		resp, err := provider.GenerateCode(context.Background(), &CodeRequest{Prompt: "Fix it", RepoPath: t.TempDir(), SessionID: sessionID})
+		if err != nil {
+			t.Fatalf("GenerateCode(%q) error = %v", sessionID, err)
+		}
+		return resp
+	}
+
+	if resp := generate(""); resp.SessionID != "s-new" {
+		t.Fatalf("SessionID = %q, want s-new", resp.SessionID)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir, "claude", "-old", "s-new.jsonl")); err != nil {
+		t.Fatalf("transcript not kept: %v", err)
+	}
+
+	if resp := generate("s-new"); resp.SessionID != "s-new" {
+		t.Fatalf("resumed SessionID = %q, want s-new", resp.SessionID)
+	}
+	// Without a kept transcript the request runs in a new session.
+	if resp := generate("s-gone"); resp.SessionID != "s-new" {
+		t.Fatalf("SessionID = %q, want a new session", resp.SessionID)
+	}
+
+	data, _ := os.ReadFile(argsLog)
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 3 || !strings.HasSuffix(calls[1], "--resume s-new") || strings.Contains(calls[2], "--resume") {
+		t.Fatalf("CLI calls = %q, want a fresh, a resumed and a fresh run", calls)
+	}
+}
+
+func TestClaudeProjectName(t *testing.T) {
+	if got := claudeProjectName("/tmp/swe/owner_repo.2026"); got != "-tmp-swe-owner-repo-2026" {
+		t.Fatalf("claudeProjectName() = %q", got)
+	}
+}
+
 func TestParseMarkdownCodeBlocksVariants(t *testing.T) {
 	response := "```go handlers/login.go\npackage handlers\n```\n\n**docs/setup.md:**\n```md\n# Setup\n```"
 	parsed, err := shared.ParseResponse("ClaudeTest", response)
@@ -30,10 +30,12 @@ func TestNewProvider(t *testing.T) {
 		t.Errorf("model = %s, want %s", provider.model, model)
 	}
 
-	// Check that API key was set in environment
-	envKey := os.Getenv("ANTHROPIC_API_KEY")
-	if envKey != apiKey {
-		t.Errorf("ANTHROPIC_API_KEY = %s, want %s", envKey, apiKey)
+	// The API key is kept for the CLI command, not set in the server environment
+	if provider.apiKey != apiKey {
+		t.Errorf("apiKey = %s, want %s", provider.apiKey, apiKey)
+	}
+	if envKey := os.Getenv("ANTHROPIC_API_KEY"); envKey == apiKey {
+		t.Errorf("ANTHROPIC_API_KEY = %s, want the server environment unchanged", envKey)
 	}
 }
 
@@ -598,6 +600,39 @@ package test
 	}
 }
 
+func TestParseCodeResponse_ReviewComments(t *testing.T) {
+	response := `The change looks mostly good.
+
+<review_comment path="main.go" line="12">Handle the error here.</review_comment>
+<review_comment path="util.go" start_line="3" line="5">Simplify this loop:
+<suggestion>
+for _, v := range values {
+	total += v
+}
+</suggestion>
+</review_comment>
+<review_comment path="skip.go">Missing line is ignored.</review_comment>`
+
+	result, err := parseCodeResponse(response)
+	if err != nil {
+		t.Fatalf("parseCodeResponse() error = %v", err)
+	}
+
+	if result.Summary != "The change looks mostly good." {
+		t.Errorf("Summary = %q, want text outside the comments", result.Summary)
+	}
+	if len(result.Comments) != 2 {
+		t.Fatalf("Comments = %+v, want 2", result.Comments)
+	}
+	if got := result.Comments[0]; got.Path != "main.go" || got.Line != 12 || got.StartLine != 0 || got.Body != "Handle the error here." {
+		t.Errorf("Comments[0] = %+v", got)
+	}
+	want := "Simplify this loop:\n\n```suggestion\nfor _, v := range values {\n\ttotal += v\n}\n```"
+	if got := result.Comments[1]; got.Path != "util.go" || got.Line != 5 || got.StartLine != 3 || got.Body != want {
+		t.Errorf("Comments[1] = %+v, want body %q", got, want)
+	}
+}
+
 func TestListRepoFiles_ErrorConditions(t *testing.T) {
 	tests := []struct {
 		name    string
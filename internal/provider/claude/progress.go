@@ -0,0 +1,39 @@
+package claude
+
+// ProgressKind classifies a progress event
+type ProgressKind string
+
+const (
+	ProgressTool ProgressKind = "tool" // the agent started a tool call
+	ProgressFile ProgressKind = "file" // the agent wrote or edited a file
+	ProgressTodo ProgressKind = "todo" // the agent replaced its todo list
+)
+
+// ProgressEvent is a step reported by a streaming provider while it works
+type ProgressEvent struct {
+	Kind   ProgressKind
+	Tool   string     // tool name, e.g. Bash or command_execution
+	Detail string     // short description of the call: a command, a path, a pattern
+	Path   string     // file written or edited, for ProgressFile
+	Todos  []TodoItem // complete todo list, for ProgressTodo
+}
+
+// Todo item statuses
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+)
+
+// TodoItem is an entry of the agent's own plan
+type TodoItem struct {
+	Text   string
+	Status string // TodoPending, TodoInProgress or TodoCompleted
+}
+
+// Report passes ev to the request's progress callback, if any.
+func (r *CodeRequest) Report(ev ProgressEvent) {
+	if r != nil && r.Progress != nil {
+		r.Progress(ev)
+	}
+}
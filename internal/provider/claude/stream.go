@@ -0,0 +1,210 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// streamEvent is a line of `claude -p --output-format stream-json`
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		ID      string       `json:"id"`
+		Usage   *streamUsage `json:"usage"`
+		Content []struct {
+			Type  string          `json:"type"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	} `json:"message"`
+
+	// system init event
+	Model     string `json:"model"`
+	SessionID string `json:"session_id"` // also on result events
+
+	// result event
+	Result       string      `json:"result"`
+	IsError      bool        `json:"is_error"`
+	TotalCostUSD float64     `json:"total_cost_usd"`
+	CostUSD      float64     `json:"cost_usd"`
+	DurationMS   int64       `json:"duration_ms"`
+	NumTurns     int         `json:"num_turns"`
+	Usage        streamUsage `json:"usage"`
+}
+
+// streamUsage is the token usage of an assistant message or a whole run
+type streamUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toolInput holds the tool_use input fields shown as progress
+type toolInput struct {
+	FilePath     string `json:"file_path"`
+	NotebookPath string `json:"notebook_path"`
+	Path         string `json:"path"`
+	Command      string `json:"command"`
+	Description  string `json:"description"`
+	Pattern      string `json:"pattern"`
+	URL          string `json:"url"`
+	Query        string `json:"query"`
+	Todos        []struct {
+		Content string `json:"content"`
+		Status  string `json:"status"`
+	} `json:"todos"`
+}
+
+// fileTools write the file named in their input
+var fileTools = map[string]bool{"Edit": true, "MultiEdit": true, "Write": true, "NotebookEdit": true}
+
+// streamProgress turns an assistant event into progress events. Other lines,
+// including non-JSON output, are ignored.
+func streamProgress(line []byte, workDir string, report func(ProgressEvent)) {
+	var ev streamEvent
+	if json.Unmarshal(line, &ev) != nil || ev.Type != "assistant" {
+		return
+	}
+	for _, block := range ev.Message.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		var in toolInput
+		_ = json.Unmarshal(block.Input, &in)
+
+		if block.Name == "TodoWrite" {
+			todos := make([]TodoItem, 0, len(in.Todos))
+			for _, todo := range in.Todos {
+				todos = append(todos, TodoItem{Text: todo.Content, Status: todo.Status})
+			}
+			report(ProgressEvent{Kind: ProgressTodo, Tool: block.Name, Todos: todos})
+			continue
+		}
+
+		path := relPath(workDir, firstNonEmpty(in.FilePath, in.NotebookPath, in.Path))
+		report(ProgressEvent{
+			Kind:   ProgressTool,
+			Tool:   block.Name,
+			Detail: firstNonEmpty(path, in.Description, in.Command, in.Pattern, in.URL, in.Query),
+		})
+		if fileTools[block.Name] && path != "" {
+			report(ProgressEvent{Kind: ProgressFile, Tool: block.Name, Path: path})
+		}
+	}
+}
+
+// usageMeter sums the usage of the assistant messages of a stream as they
+// arrive, so that a budget can be checked before the result event. The CLI
+// repeats a message's usage on every content block, so each message counts once.
+type usageMeter struct {
+	seen  map[string]bool
+	usage Usage
+}
+
+// add counts the usage of an assistant event and reports whether it changed
+// the total. The model is taken from the system init event.
+func (m *usageMeter) add(line []byte) bool {
+	var ev streamEvent
+	if json.Unmarshal(line, &ev) != nil {
+		return false
+	}
+	if ev.Type == "system" && ev.Model != "" {
+		m.usage.Model = ev.Model
+	}
+	if ev.Type != "assistant" || ev.Message.Usage == nil {
+		return false
+	}
+	if ev.Message.ID != "" {
+		if m.seen[ev.Message.ID] {
+			return false
+		}
+		if m.seen == nil {
+			m.seen = make(map[string]bool)
+		}
+		m.seen[ev.Message.ID] = true
+	}
+	u := ev.Message.Usage
+	m.usage.InputTokens += u.InputTokens
+	m.usage.OutputTokens += u.OutputTokens
+	m.usage.CacheReadTokens += u.CacheReadInputTokens
+	m.usage.CacheWriteTokens += u.CacheCreationInputTokens
+	m.usage.Turns++
+	return true
+}
+
+// parseStreamResult finds the final result event of a stream-json output.
+// Output in the single-object json format is accepted too.
+func parseStreamResult(output []byte) (*CLIResult, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), len(output)+1)
+
+	var result *CLIResult
+	var model, sessionID string
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var ev streamEvent
+		if json.Unmarshal(line, &ev) != nil {
+			continue
+		}
+		if ev.Type == "system" && ev.Model != "" {
+			model = ev.Model
+		}
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
+		}
+		if ev.Type != "result" {
+			continue
+		}
+		cost := ev.TotalCostUSD
+		if cost == 0 {
+			cost = ev.CostUSD
+		}
+		result = &CLIResult{Result: ev.Result, IsError: ev.IsError, CostUSD: cost, Usage: Usage{
+			InputTokens:      ev.Usage.InputTokens,
+			OutputTokens:     ev.Usage.OutputTokens,
+			CacheReadTokens:  ev.Usage.CacheReadInputTokens,
+			CacheWriteTokens: ev.Usage.CacheCreationInputTokens,
+			Turns:            ev.NumTurns,
+			Duration:         time.Duration(ev.DurationMS) * time.Millisecond,
+		}}
+	}
+	if result != nil {
+		result.Usage.Model, result.SessionID = model, sessionID
+		return result, nil
+	}
+
+	var single CLIResult
+	if err := json.Unmarshal(output, &single); err != nil {
+		return nil, fmt.Errorf("no result event in stream: %w", err)
+	}
+	return &single, nil
+}
+
+// relPath shows paths inside the repository relative to it
+func relPath(workDir, path string) string {
+	if path == "" || workDir == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	if rel, err := filepath.Rel(workDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -5,16 +5,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/cexll/swe/internal/prompt"
 	"github.com/cexll/swe/internal/provider/claude"
 	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/sandbox"
 )
 
 const (
@@ -25,25 +29,33 @@ const (
 var execCommandContext = exec.CommandContext
 var promptManager = prompt.NewManager()
 
+// sandboxEnv are the variables the Codex CLI needs inside the sandbox.
+var sandboxEnv = []string{"OPENAI_API_KEY", "OPENAI_BASE_URL", "CODEX_HOME"}
+
 // Provider implements the AI provider interface for Codex MCP
 type Provider struct {
-	model   string
-	apiKey  string
-	baseURL string
+	model       string
+	apiKey      string
+	baseURL     string
+	sandbox     *sandbox.Sandbox
+	transcripts shared.Transcripts
 }
 
-// NewProvider creates a new Codex provider
-func NewProvider(apiKey, baseURL, model string) *Provider {
-	if apiKey != "" {
-		// OPENAI_API_KEY is used by Codex MCP, keep aligned with CLI expectation
-		os.Setenv("OPENAI_API_KEY", apiKey)
-	}
+// errSessionUnavailable marks a resume whose transcript cannot be restored;
+// the request is then run in a new session.
+var errSessionUnavailable = errors.New("session unavailable")
 
-	if baseURL != "" {
-		// OPENAI_BASE_URL allows custom API endpoints (e.g., proxies, local deployments)
-		os.Setenv("OPENAI_BASE_URL", baseURL)
-	}
+// codexRun is the outcome of a codex exec run.
+type codexRun struct {
+	output    string // agent messages
+	usage     claude.Usage
+	sessionID string
+}
 
+// NewProvider creates a new Codex provider
+func NewProvider(apiKey, baseURL, model string) *Provider {
+	// The key and endpoint are passed to each codex command (see
+	// buildCodexCommand), never set in the server environment.
 	return &Provider{
 		model:   model,
 		apiKey:  apiKey,
@@ -56,6 +68,22 @@ func (p *Provider) Name() string {
 	return "codex"
 }
 
+// WithSandbox runs the Codex CLI inside sb. A nil sandbox only scrubs the environment.
+func (p *Provider) WithSandbox(sb *sandbox.Sandbox) *Provider {
+	p.sandbox = sb
+	return p
+}
+
+// WithSessionDir keeps session transcripts in dir so that later requests can
+// resume them. An empty dir disables resuming.
+func (p *Provider) WithSessionDir(dir string) *Provider {
+	p.transcripts = shared.Transcripts{}
+	if dir != "" {
+		p.transcripts.Dir = filepath.Join(dir, "codex")
+	}
+	return p
+}
+
 // GenerateCode generates code changes using Codex MCP CLI
 func (p *Provider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
 	log.Printf("[Codex] Starting code generation (prompt length: %d chars)", len(req.Prompt))
@@ -70,45 +98,100 @@ func (p *Provider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*
 
 	fullPrompt := executionPrefix + systemPrompt + "\n\n" + userPrompt
 
-	responseText, cost, err := p.invokeCodex(ctx, fullPrompt, req.RepoPath)
+	run, err := p.invokeCodex(ctx, fullPrompt, req.RepoPath, req.SessionID, req.Progress, req.CheckBudget)
+	if err != nil && req.SessionID != "" && errors.Is(err, errSessionUnavailable) {
+		log.Printf("[Codex] Cannot resume session %s, starting a new one: %v", req.SessionID, err)
+		run, err = p.invokeCodex(ctx, fullPrompt, req.RepoPath, "", req.Progress, req.CheckBudget)
+	}
 	if err != nil {
 		return nil, err
 	}
+	responseText, usage := run.output, run.usage
 
 	response, err := parseCodeResponse(responseText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	response.CostUSD = cost
+	response.Usage = usage
+	response.SessionID = run.sessionID
+	response.SystemPrompt, response.UserPrompt, response.RawResponse = executionPrefix+systemPrompt, userPrompt, responseText
 
-	log.Printf("[Codex] Response length: %d characters, cost: $%.4f", len(responseText), response.CostUSD)
+	log.Printf("[Codex] Response length: %d characters, input=%d cached=%d output=%d tokens",
+		len(responseText), usage.InputTokens, usage.CacheReadTokens, usage.OutputTokens)
 	log.Printf("[Codex] Extracted %d file changes", len(response.Files))
 
 	return response, nil
 }
 
-func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath string) (string, float64, error) {
+// invokeCodex runs codex exec, resuming session resume when set. Its JSONL
+// events are passed to progress while the command runs; the token usage is
+// summed from the completed turns and passed to budget after each of them.
+// The command is stopped as soon as budget returns an error, which is then
+// returned.
+func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath, resume string, progress func(claude.ProgressEvent), budget func(claude.Usage) error) (*codexRun, error) {
+	ctx, cancelSandbox := p.sandbox.WithTimeout(ctx)
+	defer cancelSandbox()
 	ctx, cancel := ensureCodexTimeout(ctx)
 	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	cmd, stdout, stderr := p.buildCodexCommand(ctx, repoPath, prompt, resume)
+	stdoutLimit := p.sandbox.LimitOutput(stdout)
+	cmd.Stdout = stdoutLimit
+	cmd.Stderr = p.sandbox.LimitOutput(stderr)
+	streamed := claude.Usage{Model: p.model}
+	var budgetErr error // set by the writer, read after cmd.Run has returned
+	if progress != nil || budget != nil {
+		events := shared.NewLineWriter(func(line []byte) {
+			if progress != nil {
+				codexProgress(line, repoPath, progress)
+			}
+			if budget != nil && budgetErr == nil && addTurnUsage(&streamed, line) {
+				if budgetErr = budget(streamed); budgetErr != nil {
+					stop()
+				}
+			}
+		})
+		cmd.Stdout = io.MultiWriter(stdoutLimit, events)
+	}
+	cleanup, err := p.sandbox.Wrap(cmd, sandboxEnv...)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
 
-	cmd, stdout, stderr := p.buildCodexCommand(ctx, repoPath, prompt)
+	sessions := codexSessionsDir(cmd.Env)
+	if resume != "" {
+		if err := p.restoreSession(sessions, resume); err != nil {
+			return nil, fmt.Errorf("%w: %v", errSessionUnavailable, err)
+		}
+	}
 
 	log.Printf("[Codex] Executing: codex exec -m %s -c model_reasoning_effort=\"high\" --dangerously-bypass-approvals-and-sandbox -C %s", p.model, repoPath)
 	log.Printf("[Codex] Prompt length: %d characters", len(prompt))
 
 	startTime := time.Now()
-	if err := cmd.Run(); err != nil {
+	err = cmd.Run()
+	if budgetErr != nil {
+		log.Printf("[Codex] Stopped after %v: %v", time.Since(startTime), budgetErr)
+		return nil, budgetErr
+	}
+	if err != nil {
 		duration := time.Since(startTime)
 		log.Printf("[Codex] Command failed after %v", duration)
 
 		stderrPreview := summarizeCodexError(err, stdout, stderr)
+		if sandbox.OutputExceeded(stdoutLimit) || sandbox.OutputExceeded(cmd.Stderr) {
+			return nil, fmt.Errorf("codex CLI error: %w after %v", sandbox.ErrOutputLimit, duration)
+		}
 		if ctx.Err() == context.DeadlineExceeded {
-			return "", 0, fmt.Errorf("codex CLI timeout after %v: %s", duration, stderrPreview)
+			return nil, fmt.Errorf("codex CLI timeout after %v: %w: %s", duration, ctx.Err(), stderrPreview)
 		}
 
 		log.Printf("[Codex] Error: %s", stderrPreview)
-		return "", 0, fmt.Errorf("codex CLI error: %s", stderrPreview)
+		return nil, fmt.Errorf("codex CLI error: %s", stderrPreview)
 	}
 
 	duration := time.Since(startTime)
@@ -120,7 +203,35 @@ func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath string) (st
 
 	log.Printf("[Codex] Command completed in %v, output length: %d bytes", duration, len(output))
 
-	return parsedOutput, 0, nil
+	usage := codexUsage(output)
+	usage.Model, usage.Duration = p.model, duration
+
+	sessionID := codexThreadID(output)
+	if sessionID != "" {
+		if err := p.transcripts.Save(sessions, sessionID); err != nil {
+			log.Printf("[Codex] Warning: Failed to keep session %s: %v", sessionID, err)
+		}
+	}
+	return &codexRun{output: parsedOutput, usage: usage, sessionID: sessionID}, nil
+}
+
+// codexSessionsDir returns the directory the CLI keeps its session
+// transcripts in, for a command environment.
+func codexSessionsDir(env []string) string {
+	if dir := shared.Getenv(env, "CODEX_HOME"); dir != "" {
+		return filepath.Join(dir, "sessions")
+	}
+	return filepath.Join(shared.Getenv(env, "HOME"), ".codex", "sessions")
+}
+
+// restoreSession copies a saved transcript back to its dated directory,
+// where codex exec resume finds it by ID.
+func (p *Provider) restoreSession(sessions, id string) error {
+	src, rel, err := p.transcripts.Find(id)
+	if err != nil {
+		return err
+	}
+	return shared.CopyFile(src, filepath.Join(sessions, rel))
 }
 
 // parseCodeResponse extracts file changes and summary from Codex response
@@ -139,8 +250,12 @@ func parseCodeResponse(response string) (*claude.CodeResponse, error) {
 		result.Files = append(result.Files, claude.FileChange{
 			Path:    file.Path,
 			Content: file.Content,
+			Edits:   file.Edits,
 		})
 	}
+	for _, comment := range parsed.Comments {
+		result.Comments = append(result.Comments, claude.ReviewComment(comment))
+	}
 
 	return result, nil
 }
@@ -287,7 +402,7 @@ func ensureCodexTimeout(ctx context.Context) (context.Context, context.CancelFun
 	return context.WithTimeout(ctx, 10*time.Minute)
 }
 
-func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt string) (*exec.Cmd, *bytes.Buffer, *bytes.Buffer) {
+func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt, resume string) (*exec.Cmd, *bytes.Buffer, *bytes.Buffer) {
 	args := []string{
 		"exec",
 		"-m", p.model,
@@ -295,8 +410,11 @@ func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt strin
 		"--dangerously-bypass-approvals-and-sandbox",
 		"--json",
 		"-C", repoPath,
-		prompt,
 	}
+	if resume != "" {
+		args = append(args, "resume", resume)
+	}
+	args = append(args, prompt)
 
 	cmd := execCommandContext(ctx, codexCommand, args...)
 
@@ -55,10 +55,11 @@ Integration success
 </summary>
 `
 
-	raw, _, err := provider.invokeCodex(ctx, prompt, tmpDir)
+	run, err := provider.invokeCodex(ctx, prompt, tmpDir, "", nil, nil)
 	if err != nil {
 		t.Fatalf("invokeCodex() error: %v", err)
 	}
+	raw := run.output
 
 	if !strings.Contains(raw, `<file path="relative/path/to/file.go">`) {
 		t.Fatalf("live output missing placeholder path; raw response:\n%s", raw)
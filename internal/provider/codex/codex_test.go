@@ -2,6 +2,7 @@ package codex
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -81,7 +82,7 @@ func TestInvokeCodex_CommandConstruction(t *testing.T) {
 
 	// Call invokeCodex
 	ctx := context.Background()
-	_, _, _ = provider.invokeCodex(ctx, "test prompt", "/tmp/test")
+	_, _ = provider.invokeCodex(ctx, "test prompt", "/tmp/test", "", nil, nil)
 
 	// Verify command structure
 	expectedArgs := []string{
@@ -106,6 +107,90 @@ func TestInvokeCodex_CommandConstruction(t *testing.T) {
 	}
 }
 
+// TestInvokeCodex_StreamsProgress tests that JSONL item events become progress events
+func TestInvokeCodex_StreamsProgress(t *testing.T) {
+	provider := NewProvider("", "", "gpt-5-codex")
+
+	originalExec := execCommandContext
+	defer func() { execCommandContext = originalExec }()
+
+	lines := []string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"item.started","item":{"id":"item_0","type":"todo_list","items":[{"text":"Fix parser","completed":false}]}}`,
+		`{"type":"item.started","item":{"id":"item_1","type":"command_execution","command":"bash -lc 'go test ./...'","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc 'go test ./...'","status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_2","type":"file_change","changes":[{"path":"/repo/parser.go","kind":"update"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_3","type":"agent_message","text":"<summary>done</summary>"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1200,"cached_input_tokens":1000,"output_tokens":300}}`,
+	}
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "printf", "%s\\n", strings.Join(lines, "\n"))
+	}
+
+	var events []claude.ProgressEvent
+	run, err := provider.invokeCodex(context.Background(), "test prompt", "/repo", "", func(ev claude.ProgressEvent) {
+		events = append(events, ev)
+	}, nil)
+	if err != nil {
+		t.Fatalf("invokeCodex() error = %v", err)
+	}
+	out, usage := run.output, run.usage
+	if run.sessionID != "t1" {
+		t.Fatalf("sessionID = %q, want the thread ID", run.sessionID)
+	}
+	if !strings.Contains(out, "<summary>done</summary>") {
+		t.Fatalf("output = %q, want agent message", out)
+	}
+	if usage.Model != "gpt-5-codex" || usage.InputTokens != 200 || usage.CacheReadTokens != 1000 || usage.OutputTokens != 300 || usage.Turns != 1 {
+		t.Fatalf("usage = %+v, want turn usage with cached tokens split out", usage)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("events = %+v, want todo, command, change and file", events)
+	}
+	if ev := events[0]; ev.Kind != claude.ProgressTodo || len(ev.Todos) != 1 || ev.Todos[0].Status != claude.TodoPending {
+		t.Errorf("todo event = %+v", ev)
+	}
+	if ev := events[1]; ev.Kind != claude.ProgressTool || ev.Detail != "bash -lc 'go test ./...'" {
+		t.Errorf("command event = %+v", ev)
+	}
+	if ev := events[3]; ev.Kind != claude.ProgressFile || ev.Path != "parser.go" {
+		t.Errorf("file event = %+v", ev)
+	}
+}
+
+// TestInvokeCodex_StopsAtBudget tests that the command is stopped when the
+// budget rejects the usage of a completed turn
+func TestInvokeCodex_StopsAtBudget(t *testing.T) {
+	provider := NewProvider("", "", "gpt-5-codex")
+
+	originalExec := execCommandContext
+	defer func() { execCommandContext = originalExec }()
+
+	line := `{"type":"turn.completed","usage":{"input_tokens":1200,"cached_input_tokens":1000,"output_tokens":300}}`
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", "printf '%s\\n' \"$0\"; exec sleep 60", line)
+	}
+
+	var checked []claude.Usage
+	errBudget := errors.New("budget exceeded")
+	start := time.Now()
+	_, err := provider.invokeCodex(context.Background(), "test prompt", "/repo", "", nil, func(usage claude.Usage) error {
+		checked = append(checked, usage)
+		return errBudget
+	})
+	if !errors.Is(err, errBudget) {
+		t.Fatalf("invokeCodex() error = %v, want the budget error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("invokeCodex() took %v, want the command stopped", elapsed)
+	}
+	want := claude.Usage{Model: "gpt-5-codex", InputTokens: 200, CacheReadTokens: 1000, OutputTokens: 300, Turns: 1}
+	if len(checked) != 1 || checked[0] != want {
+		t.Fatalf("checked usage = %+v, want %+v", checked, want)
+	}
+}
+
 // TestInvokeCodex_Timeout tests that timeout is enforced
 func TestInvokeCodex_Timeout(t *testing.T) {
 	if testing.Short() {
@@ -128,7 +213,7 @@ func TestInvokeCodex_Timeout(t *testing.T) {
 	defer cancel()
 
 	start := time.Now()
-	_, _, err := provider.invokeCodex(ctx, "test prompt", "/tmp/test")
+	_, err := provider.invokeCodex(ctx, "test prompt", "/tmp/test", "", nil, nil)
 	duration := time.Since(start)
 
 	if err == nil {
@@ -144,6 +229,48 @@ func TestInvokeCodex_Timeout(t *testing.T) {
 	}
 }
 
+// TestInvokeCodex_ResumesSession tests that a kept transcript is restored
+// and the session resumed
+func TestInvokeCodex_ResumesSession(t *testing.T) {
+	codexHome := t.TempDir()
+	t.Setenv("CODEX_HOME", codexHome)
+	provider := NewProvider("", "", "gpt-5-codex").WithSessionDir(t.TempDir())
+	rel := filepath.Join("2026", "10", "18", "rollout-2026-10-18T10-00-00-t1.jsonl")
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(provider.transcripts.Dir, rel)), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(provider.transcripts.Dir, rel), []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	originalExec := execCommandContext
+	defer func() { execCommandContext = originalExec }()
+	var capturedArgs []string
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		capturedArgs = args
+		return exec.CommandContext(ctx, "echo", `{"type":"thread.started","thread_id":"t1"}`)
+	}
+
+	run, err := provider.invokeCodex(context.Background(), "follow up", "/repo", "t1", nil, nil)
+	if err != nil {
+		t.Fatalf("invokeCodex() error = %v", err)
+	}
+	if run.sessionID != "t1" {
+		t.Fatalf("sessionID = %q, want t1", run.sessionID)
+	}
+	if got := strings.Join(capturedArgs[len(capturedArgs)-3:], " "); got != "resume t1 follow up" {
+		t.Fatalf("args end with %q, want the resume subcommand", got)
+	}
+	if _, err := os.Stat(filepath.Join(codexHome, "sessions", rel)); err != nil {
+		t.Fatalf("transcript not restored: %v", err)
+	}
+
+	// An unknown session fails before codex runs; GenerateCode then starts a new one.
+	if _, err := provider.invokeCodex(context.Background(), "follow up", "/repo", "t2", nil, nil); !errors.Is(err, errSessionUnavailable) {
+		t.Fatalf("invokeCodex(t2) error = %v, want errSessionUnavailable", err)
+	}
+}
+
 // TestParseCodeResponse tests the response parsing logic
 func TestParseCodeResponse(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,132 @@
+package codex
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// codexEvent is a line of `codex exec --json`
+type codexEvent struct {
+	Type     string `json:"type"`      // thread.started, item.started, item.updated, item.completed, turn.completed, ...
+	ThreadID string `json:"thread_id"` // thread.started, the session to resume
+	Usage    *struct {
+		InputTokens       int `json:"input_tokens"`
+		CachedInputTokens int `json:"cached_input_tokens"` // part of InputTokens
+		OutputTokens      int `json:"output_tokens"`
+	} `json:"usage"`
+	Item *struct {
+		Type    string `json:"type"` // command_execution, file_change, mcp_tool_call, web_search, todo_list, ...
+		Command string `json:"command"`
+		Server  string `json:"server"`
+		Tool    string `json:"tool"`
+		Query   string `json:"query"`
+		Changes []struct {
+			Path string `json:"path"`
+			Kind string `json:"kind"`
+		} `json:"changes"`
+		Items []struct {
+			Text      string `json:"text"`
+			Completed bool   `json:"completed"`
+		} `json:"items"`
+	} `json:"item"`
+}
+
+// codexProgress turns an item event into progress events. Tool calls are
+// reported when they start, file changes once applied and the todo list on
+// every update. Other lines are ignored.
+func codexProgress(line []byte, repoPath string, report func(claude.ProgressEvent)) {
+	var ev codexEvent
+	if json.Unmarshal(line, &ev) != nil || ev.Item == nil {
+		return
+	}
+	item := ev.Item
+	started, completed := ev.Type == "item.started", ev.Type == "item.completed"
+
+	switch item.Type {
+	case "command_execution":
+		if started {
+			report(claude.ProgressEvent{Kind: claude.ProgressTool, Tool: item.Type, Detail: item.Command})
+		}
+	case "mcp_tool_call":
+		if started {
+			report(claude.ProgressEvent{Kind: claude.ProgressTool, Tool: item.Type, Detail: strings.Trim(item.Server+"."+item.Tool, ".")})
+		}
+	case "web_search":
+		if completed {
+			report(claude.ProgressEvent{Kind: claude.ProgressTool, Tool: item.Type, Detail: item.Query})
+		}
+	case "file_change":
+		if completed {
+			for _, change := range item.Changes {
+				path := relPath(repoPath, change.Path)
+				report(claude.ProgressEvent{Kind: claude.ProgressTool, Tool: item.Type, Detail: change.Kind + " " + path})
+				report(claude.ProgressEvent{Kind: claude.ProgressFile, Tool: item.Type, Path: path})
+			}
+		}
+	case "todo_list":
+		todos := make([]claude.TodoItem, 0, len(item.Items))
+		for _, todo := range item.Items {
+			status := claude.TodoPending
+			if todo.Completed {
+				status = claude.TodoCompleted
+			}
+			todos = append(todos, claude.TodoItem{Text: todo.Text, Status: status})
+		}
+		report(claude.ProgressEvent{Kind: claude.ProgressTodo, Tool: item.Type, Todos: todos})
+	}
+}
+
+// codexUsage sums the usage of the turn.completed events in output.
+func codexUsage(output string) claude.Usage {
+	var usage claude.Usage
+	for _, line := range strings.Split(output, "\n") {
+		addTurnUsage(&usage, []byte(line))
+	}
+	return usage
+}
+
+// addTurnUsage adds the usage of a turn.completed event to usage and reports
+// whether line was one.
+func addTurnUsage(usage *claude.Usage, line []byte) bool {
+	if !bytes.Contains(line, []byte(`"turn.completed"`)) {
+		return false
+	}
+	var ev codexEvent
+	if json.Unmarshal(line, &ev) != nil || ev.Type != "turn.completed" || ev.Usage == nil {
+		return false
+	}
+	usage.Turns++
+	usage.InputTokens += ev.Usage.InputTokens - ev.Usage.CachedInputTokens
+	usage.CacheReadTokens += ev.Usage.CachedInputTokens
+	usage.OutputTokens += ev.Usage.OutputTokens
+	return true
+}
+
+// codexThreadID returns the session ID from the thread.started event in output.
+func codexThreadID(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, `"thread.started"`) {
+			continue
+		}
+		var ev codexEvent
+		if json.Unmarshal([]byte(line), &ev) == nil && ev.Type == "thread.started" {
+			return ev.ThreadID
+		}
+	}
+	return ""
+}
+
+// relPath shows paths inside the repository relative to it
+func relPath(repoPath, path string) string {
+	if !filepath.IsAbs(path) || repoPath == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(repoPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,29 @@
+package codex
+
+import (
+	"log"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+func init() {
+	provider.Register(provider.Registration{
+		Name: "codex",
+		Settings: []provider.Setting{
+			{Key: "API_KEY", Env: []string{"OPENAI_API_KEY"}, Description: "OpenAI API key, the CLI's own credentials when empty"},
+			{Key: "BASE_URL", Env: []string{"OPENAI_BASE_URL"}, Description: "Custom API endpoint"},
+			{Key: "MODEL", Env: []string{"CODEX_MODEL"}, Default: "gpt-5-codex", Description: "Model name"},
+			{Key: "SESSION_DIR", Env: []string{"SESSION_DIR"}, Default: "./data/sessions", Description: "Directory keeping session transcripts for follow-up tasks"},
+		},
+		Validate: func(s provider.Settings) error {
+			if s.Get("API_KEY") == "" {
+				log.Printf("Warning: OPENAI_API_KEY not set, using default OpenAI credentials")
+			}
+			return nil
+		},
+		New: func(s provider.Settings, sb *sandbox.Sandbox) (provider.Provider, error) {
+			return NewProvider(s.Get("API_KEY"), s.Get("BASE_URL"), s.Get("MODEL")).WithSandbox(sb).WithSessionDir(s.Get("SESSION_DIR")), nil
+		},
+	})
+}
@@ -0,0 +1,366 @@
+// Package command runs an arbitrary agent CLI (aider, gemini-cli, opencode, a
+// custom script) as a provider. Arguments, environment and stdin are Go
+// templates; the output is parsed in the shared <file>/<summary> format or the
+// agent's changes are taken from the git working tree.
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+// Output formats
+const (
+	OutputAuto = "auto" // shared format when the output has it, git changes otherwise
+	OutputXML  = "xml"  // shared <file>/<summary> format, failing when absent
+	OutputGit  = "git"  // only git working-tree changes; stdout becomes the summary
+)
+
+const (
+	defaultTimeout   = 10 * time.Minute
+	maxSummaryLength = 4000
+	promptFileName   = "swe-agent-prompt.md"
+	usageFileName    = "swe-agent-usage.json"
+)
+
+var execCommandContext = exec.CommandContext
+var promptManager = prompt.NewManager()
+
+// Config describes the command. Args, Env values and Stdin are templates
+// rendered with TemplateData.
+type Config struct {
+	Command string
+	Args    []string
+	Env     []string // KEY=value
+	Stdin   string
+	Output  string
+	Model   string
+	Timeout time.Duration
+	PassEnv []string // server variables passed through the sandbox, e.g. API keys
+}
+
+// TemplateData is available to the argument, environment and stdin templates.
+type TemplateData struct {
+	Prompt       string // system prompt and task, as the built-in CLI providers send it
+	Task         string // the task prompt only, for agents with their own system prompt
+	SystemPrompt string
+	UserPrompt   string
+	PromptFile   string // file holding Prompt, outside the working tree
+	UsageFile    string // file the command may write its Usage to, outside the working tree
+	RepoPath     string
+	Model        string
+}
+
+// Usage is what a command reports in UsageFile as JSON. Fields it leaves out
+// are unknown; without a cost the executor prices the tokens by model.
+type Usage struct {
+	Model            string  `json:"model"`
+	InputTokens      int     `json:"input_tokens"`
+	OutputTokens     int     `json:"output_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	Turns            int     `json:"turns"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Provider runs the configured command.
+type Provider struct {
+	cfg     Config
+	args    []*template.Template
+	env     []*template.Template
+	stdin   *template.Template
+	sandbox *sandbox.Sandbox
+}
+
+// NewProvider parses the templates of cfg.
+func NewProvider(cfg Config) (*Provider, error) {
+	if strings.TrimSpace(cfg.Command) == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	switch cfg.Output {
+	case "":
+		cfg.Output = OutputAuto
+	case OutputAuto, OutputXML, OutputGit:
+	default:
+		return nil, fmt.Errorf("output must be one of auto, xml or git (got %q)", cfg.Output)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	p := &Provider{cfg: cfg}
+	for i, arg := range cfg.Args {
+		tmpl, err := parseTemplate(fmt.Sprintf("arg %d", i+1), arg)
+		if err != nil {
+			return nil, err
+		}
+		p.args = append(p.args, tmpl)
+	}
+	for _, entry := range cfg.Env {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("env entry %q must be KEY=value", entry)
+		}
+		tmpl, err := parseTemplate(key, value)
+		if err != nil {
+			return nil, err
+		}
+		p.env = append(p.env, tmpl)
+	}
+	if cfg.Stdin != "" {
+		tmpl, err := parseTemplate("stdin", cfg.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		p.stdin = tmpl
+	}
+	return p, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "command"
+}
+
+// WithSandbox runs the command inside sb. A nil sandbox only scrubs the environment.
+func (p *Provider) WithSandbox(sb *sandbox.Sandbox) *Provider {
+	p.sandbox = sb
+	return p
+}
+
+// GenerateCode runs the command in the repository and collects its result.
+// The usage the command writes to UsageFile is passed to the request's budget
+// callback once it exits; the command cannot be stopped in between. Progress
+// updates and session resumption are not supported and req.Progress and
+// req.SessionID are ignored.
+func (p *Provider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	label := filepath.Base(p.cfg.Command)
+	log.Printf("[Command] Starting %s (prompt length: %d chars)", label, len(req.Prompt))
+
+	files, err := promptManager.ListRepoFiles(req.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+	systemPrompt := promptManager.BuildDefaultSystemPrompt(files, req.Context)
+	userPrompt := promptManager.BuildUserPrompt(req.Prompt)
+
+	data := TemplateData{
+		Prompt:       systemPrompt + "\n\n" + userPrompt,
+		Task:         req.Prompt,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		RepoPath:     req.RepoPath,
+		Model:        p.cfg.Model,
+	}
+	promptFile, err := writeWorkFile(req.RepoPath, promptFileName, data.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(promptFile)
+	data.PromptFile = promptFile
+	usageFile, err := writeWorkFile(req.RepoPath, usageFileName, "")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(usageFile)
+	data.UsageFile = usageFile
+
+	start := time.Now()
+	output, err := p.run(ctx, label, &data)
+	usage, cost, reported := p.readUsage(usageFile, time.Since(start))
+	if reported {
+		// A failed run still spent tokens; the budget callback records them.
+		if budgetErr := req.CheckBudget(usage); budgetErr != nil && err == nil {
+			return nil, budgetErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.parseOutput(label, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	response.SystemPrompt, response.UserPrompt, response.RawResponse = systemPrompt, userPrompt, output
+	response.Usage, response.CostUSD = usage, cost
+
+	log.Printf("[Command] %s returned %d file changes", label, len(response.Files))
+	return response, nil
+}
+
+func (p *Provider) run(ctx context.Context, label string, data *TemplateData) (string, error) {
+	args := make([]string, len(p.args))
+	for i, tmpl := range p.args {
+		arg, err := render(tmpl, data)
+		if err != nil {
+			return "", err
+		}
+		args[i] = arg
+	}
+
+	ctx, cancelSandbox := p.sandbox.WithTimeout(ctx)
+	defer cancelSandbox()
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	cmd := execCommandContext(ctx, p.cfg.Command, args...)
+	cmd.Dir = data.RepoPath
+	cmd.Env = os.Environ()
+	passEnv := append([]string{}, p.cfg.PassEnv...)
+	for _, tmpl := range p.env {
+		value, err := render(tmpl, data)
+		if err != nil {
+			return "", err
+		}
+		cmd.Env = append(cmd.Env, tmpl.Name()+"="+value)
+		passEnv = append(passEnv, tmpl.Name())
+	}
+	if p.stdin != nil {
+		input, err := render(p.stdin, data)
+		if err != nil {
+			return "", err
+		}
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = p.sandbox.LimitOutput(&stdout)
+	cmd.Stderr = p.sandbox.LimitOutput(&stderr)
+	cleanup, err := p.sandbox.Wrap(cmd, passEnv...)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	log.Printf("[Command] Executing %s with %d arguments in %s", p.cfg.Command, len(args), data.RepoPath)
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		duration := time.Since(start)
+		if sandbox.OutputExceeded(cmd.Stdout) || sandbox.OutputExceeded(cmd.Stderr) {
+			return "", fmt.Errorf("%s error: %w after %v", label, sandbox.ErrOutputLimit, duration)
+		}
+		preview := tail(strings.TrimSpace(stderr.String()+"\n"+stdout.String()), 1000)
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s timeout after %v: %w: %s", label, duration, ctx.Err(), preview)
+		}
+		return "", fmt.Errorf("%s error: %v: %s", label, err, preview)
+	}
+	log.Printf("[Command] %s completed in %v, output length: %d bytes", label, time.Since(start), stdout.Len())
+	return stdout.String(), nil
+}
+
+// parseOutput applies the output format. In git mode, and in auto mode when
+// the output has no shared blocks, only the summary comes from stdout and the
+// executor picks the changes up from the working tree.
+func (p *Provider) parseOutput(label, output string) (*claude.CodeResponse, error) {
+	if p.cfg.Output != OutputGit {
+		parsed, err := shared.ParseResponse(label, output)
+		if err == nil && (p.cfg.Output == OutputXML || len(parsed.Files) > 0 || strings.Contains(output, "<summary>")) {
+			response := &claude.CodeResponse{Summary: parsed.Summary}
+			for _, file := range parsed.Files {
+				response.Files = append(response.Files, claude.FileChange{Path: file.Path, Content: file.Content, Edits: file.Edits})
+			}
+			for _, comment := range parsed.Comments {
+				response.Comments = append(response.Comments, claude.ReviewComment(comment))
+			}
+			return response, nil
+		}
+		if p.cfg.Output == OutputXML {
+			return nil, err
+		}
+	}
+
+	summary := tail(strings.TrimSpace(output), maxSummaryLength)
+	if summary == "" {
+		summary = fmt.Sprintf("%s finished without output", label)
+	}
+	return &claude.CodeResponse{Summary: summary}, nil
+}
+
+// readUsage reads the usage the command wrote to path. Without a report only
+// the model and duration are known.
+func (p *Provider) readUsage(path string, elapsed time.Duration) (claude.Usage, float64, bool) {
+	usage := claude.Usage{Model: p.cfg.Model, Duration: elapsed}
+	data, err := os.ReadFile(path)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return usage, 0, false
+	}
+	var reported Usage
+	if err := json.Unmarshal(data, &reported); err != nil {
+		log.Printf("[Command] Ignoring unreadable usage report: %v", err)
+		return usage, 0, false
+	}
+	if reported.Model != "" {
+		usage.Model = reported.Model
+	}
+	usage.InputTokens = reported.InputTokens
+	usage.OutputTokens = reported.OutputTokens
+	usage.CacheReadTokens = reported.CacheReadTokens
+	usage.CacheWriteTokens = reported.CacheWriteTokens
+	usage.Turns = reported.Turns
+	return usage, reported.CostUSD, true
+}
+
+func render(tmpl *template.Template, data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// writeWorkFile creates a file for the command in the git directory, which the
+// sandbox keeps writable and the change detection ignores. Outside a repository
+// the system temp directory is used.
+func writeWorkFile(repoPath, name, text string) (string, error) {
+	dir := os.TempDir()
+	if out, err := exec.Command("git", "-C", repoPath, "rev-parse", "--absolute-git-dir").Output(); err == nil {
+		dir = strings.TrimSpace(string(out))
+	}
+	file, err := os.CreateTemp(dir, "*-"+name)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return file.Name(), nil
+}
+
+// tail keeps the last max bytes of s, where agents print their conclusion.
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	start := len(s) - max
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return "..." + s[start:]
+}
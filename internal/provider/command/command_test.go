@@ -0,0 +1,220 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// script writes an executable shell script and returns its path.
+func script(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	return dir
+}
+
+func TestGenerateCode_TemplatesAndGitOutput(t *testing.T) {
+	repo := gitRepo(t)
+	agent := script(t, `
+printf '%s|%s|%s\n' "$1" "$2" "$AGENT_MODEL" > args.txt
+cat > stdin.txt
+head -c 9 "$3" > prompt-head.txt
+echo "Working..."
+echo "Added args.txt with the rendered arguments"
+`)
+
+	p, err := NewProvider(Config{
+		Command: agent,
+		Args:    []string{"--task", "{{.Task}}", "{{.PromptFile}}"},
+		Env:     []string{"AGENT_MODEL={{.Model}}"},
+		Stdin:   "repo={{.RepoPath}}",
+		Output:  OutputGit,
+		Model:   "gemini-2.5-pro",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "fix the bug", RepoPath: repo})
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if len(resp.Files) != 0 || !strings.HasSuffix(resp.Summary, "Added args.txt with the rendered arguments") {
+		t.Fatalf("response = %+v, want stdout summary and changes left in the working tree", resp)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(repo, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	if got := read("args.txt"); got != "--task|fix the bug|gemini-2.5-pro\n" {
+		t.Fatalf("args.txt = %q", got)
+	}
+	if got := read("stdin.txt"); got != "repo="+repo {
+		t.Fatalf("stdin.txt = %q", got)
+	}
+	if got := read("prompt-head.txt"); got == "" {
+		t.Fatal("prompt file should hold the rendered prompt")
+	}
+	if out, _ := exec.Command("git", "-C", repo, "status", "--porcelain").Output(); strings.Contains(string(out), "prompt") && !strings.Contains(string(out), "prompt-head.txt") {
+		t.Fatalf("prompt file leaked into the working tree:\n%s", out)
+	}
+}
+
+func TestGenerateCode_SharedFormat(t *testing.T) {
+	agent := script(t, `
+cat <<'EOF'
+Thinking about it.
+<file path="hello.go">
+<content>
+package hello
+</content>
+</file>
+<summary>Add hello package</summary>
+EOF
+`)
+	for _, output := range []string{OutputAuto, OutputXML} {
+		p, err := NewProvider(Config{Command: agent, Output: output})
+		if err != nil {
+			t.Fatalf("NewProvider() error = %v", err)
+		}
+		resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "add hello", RepoPath: gitRepo(t)})
+		if err != nil {
+			t.Fatalf("%s: GenerateCode() error = %v", output, err)
+		}
+		if resp.Summary != "Add hello package" || len(resp.Files) != 1 || resp.Files[0].Path != "hello.go" {
+			t.Fatalf("%s: response = %+v", output, resp)
+		}
+	}
+
+	// Plain output is not enough in xml mode.
+	p, _ := NewProvider(Config{Command: script(t, "echo"), Output: OutputXML})
+	if _, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: gitRepo(t)}); err == nil {
+		t.Fatal("GenerateCode() should fail without shared blocks in xml mode")
+	}
+}
+
+func TestGenerateCode_CommandFailure(t *testing.T) {
+	p, _ := NewProvider(Config{Command: script(t, "echo 'quota exhausted' >&2; exit 3")})
+	_, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: gitRepo(t)})
+	if err == nil || !strings.Contains(err.Error(), "agent.sh error: exit status 3: quota exhausted") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestGenerateCode_ReportsUsageToBudget(t *testing.T) {
+	agent := script(t, `
+echo '{"model": "claude-sonnet-4-5", "input_tokens": 1200, "output_tokens": 300, "turns": 2, "cost_usd": 0.25}' > "$1"
+echo "done"
+`)
+	p, err := NewProvider(Config{Command: agent, Args: []string{"{{.UsageFile}}"}, Output: OutputGit, Model: "sonnet"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	var reported []claude.Usage
+	req := &claude.CodeRequest{Prompt: "x", RepoPath: gitRepo(t), Budget: func(usage claude.Usage) error {
+		reported = append(reported, usage)
+		return nil
+	}}
+	resp, err := p.GenerateCode(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if resp.Usage.Model != "claude-sonnet-4-5" || resp.Usage.Tokens() != 1500 || resp.Usage.Turns != 2 || resp.CostUSD != 0.25 {
+		t.Fatalf("usage = %+v, cost %v, want the reported usage", resp.Usage, resp.CostUSD)
+	}
+	if len(reported) != 1 || reported[0].Tokens() != 1500 {
+		t.Fatalf("budget reports = %+v, want the reported usage", reported)
+	}
+
+	// A budget error fails the run.
+	req.Budget = func(claude.Usage) error { return errors.New("over budget") }
+	if _, err := p.GenerateCode(context.Background(), req); err == nil || err.Error() != "over budget" {
+		t.Fatalf("GenerateCode() error = %v, want the budget error", err)
+	}
+
+	// Failed runs report their usage too and keep their own error.
+	p, _ = NewProvider(Config{Command: script(t, `echo '{"output_tokens": 10}' > "$1"; exit 3`), Args: []string{"{{.UsageFile}}"}})
+	reported = nil
+	req.Budget = func(usage claude.Usage) error {
+		reported = append(reported, usage)
+		return errors.New("over budget")
+	}
+	if _, err := p.GenerateCode(context.Background(), req); err == nil || !strings.Contains(err.Error(), "exit status 3") || len(reported) != 1 {
+		t.Fatalf("GenerateCode() error = %v, reports %d, want the command error and one report", err, len(reported))
+	}
+}
+
+func TestGenerateCode_WithoutUsageReport(t *testing.T) {
+	p, _ := NewProvider(Config{Command: script(t, "echo done"), Output: OutputGit, Model: "sonnet"})
+	called := false
+	resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: gitRepo(t), Budget: func(claude.Usage) error {
+		called = true
+		return nil
+	}})
+	if err != nil || called || resp.Usage.Model != "sonnet" || resp.Usage.Tokens() != 0 {
+		t.Fatalf("GenerateCode() = %+v, %v, budget called %v, want model only", resp, err, called)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`--yes  --message "{{.Task}} now" 'it''s' a\ b ""`)
+	if err != nil {
+		t.Fatalf("splitArgs() error = %v", err)
+	}
+	want := []string{"--yes", "--message", "{{.Task}} now", "its", "a b", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitArgs() = %q, want %q", got, want)
+	}
+	if _, err := splitArgs(`--message "open`); err == nil {
+		t.Fatal("splitArgs() should reject an unterminated quote")
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	_, err := provider.NewProvider(&provider.Config{Name: "command", Settings: provider.Settings{}})
+	if err == nil || !strings.Contains(err.Error(), "PROVIDER_COMMAND_COMMAND is required") {
+		t.Fatalf("error = %v, want missing command", err)
+	}
+	_, err = provider.NewProvider(&provider.Config{Name: "command", Settings: provider.Settings{"COMMAND": "aider", "ARGS": "{{.Nope"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid arg 1 template") {
+		t.Fatalf("error = %v, want template error", err)
+	}
+	_, err = provider.NewProvider(&provider.Config{Name: "command", Settings: provider.Settings{"COMMAND": "aider", "OUTPUT": "json"}})
+	if err == nil || !strings.Contains(err.Error(), "output must be one of") {
+		t.Fatalf("error = %v, want output error", err)
+	}
+
+	p, err := provider.NewProvider(&provider.Config{Name: "command", Model: "sonnet", Settings: provider.Settings{
+		"COMMAND": "aider",
+		"ARGS":    `--yes-always --model {{.Model}} --message-file "{{.PromptFile}}"`,
+		"ENV":     "AIDER_DARK_MODE=true",
+	}})
+	if err != nil || p.Name() != "command" {
+		t.Fatalf("NewProvider() = %v, %v", p, err)
+	}
+}
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+func init() {
+	provider.Register(provider.Registration{
+		Name: "command",
+		Settings: []provider.Setting{
+			{Key: "COMMAND", Required: true, Description: "Agent CLI to run, e.g. aider"},
+			{Key: "ARGS", Description: `Argument templates, split like a shell command line, e.g. --yes-always --message-file "{{.PromptFile}}"`},
+			{Key: "ENV", Description: "Extra variables as KEY=template pairs, split like ARGS"},
+			{Key: "STDIN", Description: "Template written to the command's stdin"},
+			{Key: "OUTPUT", Default: OutputAuto, Description: "auto, xml (shared <file> format) or git (working-tree changes)"},
+			{Key: "MODEL", Description: "Model name available to the templates as {{.Model}}"},
+			{Key: "TIMEOUT_SECONDS", Default: fmt.Sprint(int(defaultTimeout.Seconds())), Description: "Wall-clock limit per run"},
+			{Key: "PASS_ENV", Description: "Server variables passed into the sandbox, comma-separated"},
+		},
+		Validate: func(s provider.Settings) error {
+			_, err := configFrom(s)
+			return err
+		},
+		New: func(s provider.Settings, sb *sandbox.Sandbox) (provider.Provider, error) {
+			cfg, err := configFrom(s)
+			if err != nil {
+				return nil, err
+			}
+			p, err := NewProvider(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return p.WithSandbox(sb), nil
+		},
+	})
+}
+
+func configFrom(s provider.Settings) (Config, error) {
+	args, err := splitArgs(s.Get("ARGS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("ARGS: %w", err)
+	}
+	env, err := splitArgs(s.Get("ENV"))
+	if err != nil {
+		return Config{}, fmt.Errorf("ENV: %w", err)
+	}
+	if s.Int("TIMEOUT_SECONDS") <= 0 {
+		return Config{}, fmt.Errorf("TIMEOUT_SECONDS must be a positive number (got %q)", s.Get("TIMEOUT_SECONDS"))
+	}
+	cfg := Config{
+		Command: s.Get("COMMAND"),
+		Args:    args,
+		Env:     env,
+		Stdin:   s.Get("STDIN"),
+		Output:  strings.ToLower(strings.TrimSpace(s.Get("OUTPUT"))),
+		Model:   s.Get("MODEL"),
+		Timeout: time.Duration(s.Int("TIMEOUT_SECONDS")) * time.Second,
+		PassEnv: s.List("PASS_ENV"),
+	}
+	// Parse the templates once so configuration errors show up at startup.
+	if _, err := NewProvider(cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// splitArgs splits a command line into words like a POSIX shell does for
+// quoting: single quotes are literal, double quotes and backslashes escape.
+// Nothing is expanded; templates are rendered per word afterwards.
+func splitArgs(line string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
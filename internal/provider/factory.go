@@ -1,61 +1,46 @@
 package provider
 
 import (
-	"fmt"
+	"os"
 
-	"github.com/cexll/swe/internal/provider/claude"
-	"github.com/cexll/swe/internal/provider/codex"
+	"github.com/cexll/swe/internal/sandbox"
 )
 
-// Config contains provider configuration
+// Config selects a registered provider
 type Config struct {
-	// Provider name: "claude", "codex", "gemini", "amp"
+	// Provider name, see Names()
 	Name string
 
-	// Claude configuration
-	ClaudeAPIKey string
-	ClaudeModel  string
+	// Model overrides the provider's MODEL setting (repository configuration)
+	Model string
 
-	// Codex configuration (uses OpenAI-compatible environment variables)
-	OpenAIAPIKey  string
-	OpenAIBaseURL string
-	CodexModel    string
+	// Settings of the provider; nil loads them from the environment
+	Settings Settings
 
-	// Future: Gemini configuration
-	// GeminiAPIKey string
-	// GeminiModel  string
+	// Sandbox for provider CLI processes and tool commands (nil only scrubs their environment)
+	Sandbox *sandbox.Sandbox
 }
 
 // NewProvider creates a provider based on configuration
-// This is a factory function that eliminates if-else branches
+// Providers register themselves, so adding one does not touch this factory
 func NewProvider(cfg *Config) (Provider, error) {
-	switch cfg.Name {
-	case "claude":
-		if cfg.ClaudeAPIKey == "" {
-			return nil, fmt.Errorf("claude: ANTHROPIC_API_KEY is required")
-		}
-		model := cfg.ClaudeModel
-		if model == "" {
-			model = "claude-3-5-sonnet-20241022"
-		}
-		return claude.NewProvider(cfg.ClaudeAPIKey, model), nil
-
-	case "codex":
-		model := cfg.CodexModel
-		if model == "" {
-			model = "gpt-5-codex"
-		}
-		return codex.NewProvider(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, model), nil
-
-	// Future providers can be added here without modifying existing code
-	// case "codex":
-	//     return codex.NewProvider(cfg.CodexAPIKey, cfg.CodexModel), nil
-	// case "gemini":
-	//     return gemini.NewProvider(cfg.GeminiAPIKey, cfg.GeminiModel), nil
-	// case "amp":
-	//     return amp.NewProvider(cfg.AMPConfig), nil
-
-	default:
-		return nil, fmt.Errorf("unknown provider: %s (supported: claude, codex)", cfg.Name)
+	r, err := lookup(cfg.Name)
+	if err != nil {
+		return nil, err
 	}
+
+	var settings Settings
+	if cfg.Settings == nil {
+		settings = r.resolve(os.Getenv)
+	} else {
+		settings = r.complete(cfg.Settings)
+	}
+	if cfg.Model != "" {
+		settings["MODEL"] = cfg.Model
+	}
+	if err := r.check(settings); err != nil {
+		return nil, err
+	}
+
+	return r.New(settings, cfg.Sandbox)
 }
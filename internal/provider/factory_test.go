@@ -1,167 +1,161 @@
-package provider
+package provider_test
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/cexll/swe/internal/provider"
+	_ "github.com/cexll/swe/internal/provider/all"
 )
 
 func TestNewProvider(t *testing.T) {
 	tests := []struct {
 		name        string
-		cfg         *Config
+		cfg         *provider.Config
 		wantErr     bool
 		errContains string
 		checkName   string
 	}{
 		{
 			name: "claude provider with all fields",
-			cfg: &Config{
-				Name:         "claude",
-				ClaudeAPIKey: "sk-ant-test-key",
-				ClaudeModel:  "claude-3-opus-20240229",
+			cfg: &provider.Config{
+				Name:     "claude",
+				Settings: provider.Settings{"API_KEY": "sk-ant-test-key", "MODEL": "claude-3-opus-20240229"},
 			},
-			wantErr:   false,
 			checkName: "claude",
 		},
 		{
-			name: "claude provider with default model",
-			cfg: &Config{
-				Name:         "claude",
-				ClaudeAPIKey: "sk-ant-test-key",
+			name: "claude provider with model override",
+			cfg: &provider.Config{
+				Name:     "claude",
+				Model:    "claude-3-opus-20240229",
+				Settings: provider.Settings{"API_KEY": "sk-ant-test-key"},
 			},
-			wantErr:   false,
 			checkName: "claude",
 		},
 		{
-			name: "claude provider missing API key",
-			cfg: &Config{
-				Name:        "claude",
-				ClaudeModel: "claude-3-opus-20240229",
+			name: "anthropic API provider",
+			cfg: &provider.Config{
+				Name:     "anthropic",
+				Settings: provider.Settings{"API_KEY": "sk-ant-test-key", "BASE_URL": "http://localhost:8080"},
+			},
+			checkName: "anthropic",
+		},
+		{
+			name: "anthropic provider missing API key",
+			cfg: &provider.Config{
+				Name:     "anthropic",
+				Settings: provider.Settings{},
 			},
 			wantErr:     true,
-			errContains: "ANTHROPIC_API_KEY is required",
+			errContains: "anthropic: PROVIDER_ANTHROPIC_API_KEY or ANTHROPIC_API_KEY is required",
 		},
 		{
-			name: "unknown provider",
-			cfg: &Config{
-				Name: "unknown",
+			name: "anthropic provider invalid max turns",
+			cfg: &provider.Config{
+				Name:     "anthropic",
+				Settings: provider.Settings{"API_KEY": "sk-ant-test-key", "MAX_TURNS": "many"},
 			},
 			wantErr:     true,
-			errContains: "unknown provider: unknown (supported: claude, codex)",
+			errContains: "anthropic: MAX_TURNS must be a positive number",
 		},
 		{
-			name: "empty provider name",
-			cfg: &Config{
-				Name: "",
+			name: "openai-compatible provider",
+			cfg: &provider.Config{
+				Name:     "openai",
+				Settings: provider.Settings{"BASE_URL": "http://localhost:11434/v1", "MODEL": "qwen2.5-coder"},
+			},
+			checkName: "openai",
+		},
+		{
+			name: "openai provider missing model",
+			cfg: &provider.Config{
+				Name:     "openai",
+				Settings: provider.Settings{"BASE_URL": "http://localhost:11434/v1"},
 			},
 			wantErr:     true,
-			errContains: "unknown provider",
+			errContains: "openai: PROVIDER_OPENAI_MODEL or OPENAI_MODEL is required",
 		},
 		{
-			name: "codex provider default model",
-			cfg: &Config{
-				Name: "codex",
+			name: "openai provider missing key and endpoint",
+			cfg: &provider.Config{
+				Name:     "openai",
+				Model:    "gpt-4o",
+				Settings: provider.Settings{},
 			},
-			wantErr:   false,
-			checkName: "codex",
+			wantErr:     true,
+			errContains: "openai: API_KEY or BASE_URL is required",
 		},
 		{
-			name: "gemini provider (not yet implemented)",
-			cfg: &Config{
-				Name: "gemini",
+			name: "claude provider missing API key",
+			cfg: &provider.Config{
+				Name:     "claude",
+				Settings: provider.Settings{"MODEL": "claude-3-opus-20240229"},
 			},
 			wantErr:     true,
-			errContains: "unknown provider: gemini (supported: claude, codex)",
+			errContains: "ANTHROPIC_API_KEY is required",
+		},
+		{
+			name:        "unknown provider",
+			cfg:         &provider.Config{Name: "unknown"},
+			wantErr:     true,
+			errContains: "unknown provider: unknown (supported: anthropic, claude, codex, command, openai)",
+		},
+		{
+			name:        "empty provider name",
+			cfg:         &provider.Config{Name: ""},
+			wantErr:     true,
+			errContains: "unknown provider",
 		},
 		{
-			name: "amp provider (not yet implemented)",
-			cfg: &Config{
-				Name: "amp",
+			name: "codex provider default model",
+			cfg: &provider.Config{
+				Name:     "codex",
+				Settings: provider.Settings{},
 			},
+			checkName: "codex",
+		},
+		{
+			name:        "gemini provider (not yet implemented)",
+			cfg:         &provider.Config{Name: "gemini"},
 			wantErr:     true,
-			errContains: "unknown provider: amp (supported: claude, codex)",
+			errContains: "unknown provider: gemini",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			provider, err := NewProvider(tt.cfg)
+			p, err := provider.NewProvider(tt.cfg)
 
-			// Check error
 			if (err != nil) != tt.wantErr {
-				t.Errorf("NewProvider() error = %v, wantErr %v", err, tt.wantErr)
-				return
+				t.Fatalf("NewProvider() error = %v, wantErr %v", err, tt.wantErr)
 			}
-
-			// Check error message
-			if tt.wantErr && err != nil {
-				if tt.errContains != "" && !contains(err.Error(), tt.errContains) {
-					t.Errorf("NewProvider() error = %v, want to contain %v", err.Error(), tt.errContains)
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("NewProvider() error = %v, want to contain %v", err, tt.errContains)
 				}
-			}
-
-			// Check provider name
-			if !tt.wantErr && provider != nil {
-				if provider.Name() != tt.checkName {
-					t.Errorf("Provider.Name() = %v, want %v", provider.Name(), tt.checkName)
+				if p != nil {
+					t.Errorf("NewProvider() provider = %v, want nil when error occurs", p)
 				}
+				return
 			}
-
-			// Ensure provider is nil when error occurs
-			if tt.wantErr && provider != nil {
-				t.Errorf("NewProvider() provider = %v, want nil when error occurs", provider)
+			if p.Name() != tt.checkName {
+				t.Errorf("Provider.Name() = %v, want %v", p.Name(), tt.checkName)
 			}
 		})
 	}
 }
 
-func TestConfig_Validation(t *testing.T) {
-	tests := []struct {
-		name string
-		cfg  *Config
-		want bool
-	}{
-		{
-			name: "valid claude config",
-			cfg: &Config{
-				Name:         "claude",
-				ClaudeAPIKey: "sk-ant-test",
-				ClaudeModel:  "claude-3-5-sonnet-20241022",
-			},
-			want: true,
-		},
-		{
-			name: "empty API key",
-			cfg: &Config{
-				Name:        "claude",
-				ClaudeModel: "claude-3-5-sonnet-20241022",
-			},
-			want: false,
-		},
-	}
+func TestNewProvider_LoadsEnvironment(t *testing.T) {
+	t.Setenv("PROVIDER_OPENAI_MODEL", "")
+	t.Setenv("OPENAI_MODEL", "")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewProvider(tt.cfg)
-			if tt.want && err != nil {
-				t.Errorf("NewProvider() should succeed but got error: %v", err)
-			}
-			if !tt.want && err == nil {
-				t.Errorf("NewProvider() should fail but succeeded")
-			}
-		})
+	if _, err := provider.NewProvider(&provider.Config{Name: "openai"}); err == nil {
+		t.Fatal("NewProvider() should fail without a model")
 	}
-}
-
-// Helper function to check if string contains substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && containsAt(s, substr))
-}
-
-func containsAt(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+	p, err := provider.NewProvider(&provider.Config{Name: "openai", Model: "llama3"})
+	if err != nil || p.Name() != "openai" {
+		t.Fatalf("NewProvider() = %v, %v, want model override to satisfy the requirement", p, err)
 	}
-	return false
 }
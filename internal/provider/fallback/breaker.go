@@ -0,0 +1,75 @@
+package fallback
+
+import (
+	"sync"
+	"time"
+)
+
+// breakers keeps one circuit per provider name. A circuit opens after
+// threshold consecutive failures and skips the provider for cooldown; the
+// first task after the cooldown is a trial that closes it again on success.
+type breakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{threshold: threshold, cooldown: cooldown, circuits: make(map[string]*circuit)}
+}
+
+func (b *breakers) get(name string) *circuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// allow reports whether the provider may be called. After the cooldown one
+// caller gets through; the others keep skipping it until the trial returns.
+func (b *breakers) allow(name string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(name)
+	if c.failures < b.threshold {
+		return true
+	}
+	if now.Before(c.openUntil) {
+		return false
+	}
+	c.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// openUntil returns when an open circuit lets the next trial through.
+func (b *breakers) openUntil(name string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(name).openUntil
+}
+
+func (b *breakers) succeed(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	*b.get(name) = circuit{}
+}
+
+func (b *breakers) fail(name string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(name)
+	c.failures++
+	if c.failures >= b.threshold {
+		c.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,148 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/provider/anthropic"
+	"github.com/cexll/swe/internal/provider/openai"
+)
+
+// Class groups provider errors by what switching providers can do about them.
+type Class string
+
+const (
+	ClassRateLimit Class = "rate_limit" // 429, quota or usage limits
+	ClassServer    Class = "server"     // 5xx and overload
+	ClassTimeout   Class = "timeout"    // deadline or CLI timeout
+	ClassAuth      Class = "auth"       // rejected or missing credentials
+	ClassBudget    Class = "budget"     // per-task token budget used up
+	ClassOther     Class = "other"      // everything else, usually caused by the task itself
+)
+
+// DefaultSwitchOn lists the classes that move a task to the next provider
+// when no rules are configured.
+var DefaultSwitchOn = []Class{ClassRateLimit, ClassServer, ClassTimeout}
+
+var classes = []Class{ClassRateLimit, ClassServer, ClassTimeout, ClassAuth, ClassBudget, ClassOther}
+
+// ParseClasses parses class names such as "rate_limit" or "server".
+func ParseClasses(names []string) ([]Class, error) {
+	var out []Class
+	for _, name := range names {
+		class := Class(strings.ToLower(strings.TrimSpace(name)))
+		valid := false
+		for _, known := range classes {
+			valid = valid || class == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown error class %q (supported: rate_limit, server, timeout, auth, budget, other)", name)
+		}
+		out = append(out, class)
+	}
+	return out, nil
+}
+
+// cliStatus matches the HTTP status in the errors the CLIs report for a
+// failed API call: "API Error: 429 ..." from Claude, "unexpected status 401
+// Unauthorized" or "exceeded retry limit, last status: 429 ..." from Codex.
+var cliStatus = regexp.MustCompile(`^(?:\S+ )?(?:error: )?(?:api error:|unexpected status|exceeded retry limit, last status:) (\d{3})\b`)
+
+// cliMessages are errors the CLIs report without a status, by their start.
+var cliMessages = []struct {
+	class  Class
+	prefix string
+}{
+	{ClassRateLimit, "claude ai usage limit reached"},
+	{ClassRateLimit, "credit balance is too low"},
+	{ClassAuth, "invalid api key"},
+}
+
+// cliErrorPrefixes start the errors the CLI providers return with the
+// message the CLI itself reported. Anything else, such as an output preview
+// with the model's own text, is never matched.
+var cliErrorPrefixes = []string{"claude cli error: ", "codex cli error: "}
+
+// Classify assigns an error of a provider to a class. Typed errors are
+// classified by their status; for the CLI providers only the error message
+// the CLI reported is checked.
+func Classify(err error) Class {
+	if err == nil {
+		return ""
+	}
+
+	var (
+		status     budget.Status
+		tokens     *openai.BudgetError
+		anthropicE *anthropic.APIError
+		openaiE    *openai.APIError
+		timeout    interface{ Timeout() bool }
+		retryable  interface{ Retryable() bool }
+	)
+	switch {
+	case errors.As(err, &status), errors.As(err, &tokens):
+		return ClassBudget
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &timeout) && timeout.Timeout():
+		return ClassTimeout
+	case errors.As(err, &anthropicE):
+		return classifyStatus(anthropicE.StatusCode)
+	case errors.As(err, &openaiE):
+		return classifyStatus(openaiE.StatusCode)
+	}
+
+	if class := classifyCLIMessage(err.Error()); class != "" {
+		return class
+	}
+
+	// Retryable errors without a recognised status are treated as server trouble.
+	if errors.As(err, &retryable) && retryable.Retryable() {
+		return ClassServer
+	}
+	return ClassOther
+}
+
+// classifyStatus assigns an HTTP status of an API error to a class.
+func classifyStatus(code int) Class {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ClassRateLimit
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ClassAuth
+	case code >= 500:
+		return ClassServer
+	}
+	return ClassOther
+}
+
+// classifyCLIMessage classifies the message a CLI reported, the text after
+// the last CLI error prefix in msg. It returns "" for other errors.
+func classifyCLIMessage(msg string) Class {
+	msg = strings.ToLower(msg)
+	i := -1
+	for _, prefix := range cliErrorPrefixes {
+		if j := strings.LastIndex(msg, prefix); j >= 0 && j+len(prefix) > i {
+			i = j + len(prefix)
+		}
+	}
+	if i < 0 {
+		return ""
+	}
+	reported := strings.TrimSpace(msg[i:])
+
+	if m := cliStatus.FindStringSubmatch(reported); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return classifyStatus(code)
+	}
+	for _, entry := range cliMessages {
+		if strings.HasPrefix(reported, entry.prefix) {
+			return entry.class
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+// Package fallback chains providers: when one fails with a rate limit, a
+// server error or another configured error class, the task moves on to the
+// next provider. A circuit breaker per provider skips providers that keep
+// failing until a cooldown has passed.
+package fallback
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// Options configures a chain. Zero values select the defaults.
+type Options struct {
+	SwitchOn         []Class       // error classes that move to the next provider, DefaultSwitchOn when empty
+	FailureThreshold int           // consecutive failures that open a circuit, default 3
+	Cooldown         time.Duration // how long an open circuit skips its provider, default 5m
+}
+
+// Chain is a provider that tries its providers in order.
+type Chain struct {
+	providers []provider.Provider
+	switchOn  map[Class]bool
+	breakers  *breakers
+	now       func() time.Time
+}
+
+var _ provider.Provider = (*Chain)(nil)
+
+// New returns a chain trying primary first and then each fallback.
+func New(primary provider.Provider, fallbacks []provider.Provider, opts Options) *Chain {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 5 * time.Minute
+	}
+	if len(opts.SwitchOn) == 0 {
+		opts.SwitchOn = DefaultSwitchOn
+	}
+
+	switchOn := make(map[Class]bool, len(opts.SwitchOn))
+	for _, class := range opts.SwitchOn {
+		switchOn[class] = true
+	}
+	return &Chain{
+		providers: append([]provider.Provider{primary}, fallbacks...),
+		switchOn:  switchOn,
+		breakers:  newBreakers(opts.FailureThreshold, opts.Cooldown),
+		now:       time.Now,
+	}
+}
+
+// WithPrimary returns a chain that tries p first, followed by the fallbacks
+// of c that have a different name. Both chains share their circuits, so a
+// repository selecting its own provider still benefits from failover.
+func (c *Chain) WithPrimary(p provider.Provider) *Chain {
+	providers := []provider.Provider{p}
+	for _, fb := range c.providers {
+		if fb.Name() != p.Name() {
+			providers = append(providers, fb)
+		}
+	}
+	return &Chain{providers: providers, switchOn: c.switchOn, breakers: c.breakers, now: c.now}
+}
+
+// Name returns the name of the primary provider.
+func (c *Chain) Name() string {
+	return c.providers[0].Name()
+}
+
+// GenerateCode calls the providers in order until one succeeds. Errors of a
+// class outside the switching rules are returned right away: a prompt that
+// breaks one provider usually breaks the next one too. Before a fallback runs,
+// the working tree is restored to its state before the first attempt.
+func (c *Chain) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	var (
+		failures []error
+		tree     string
+		attempts int
+	)
+
+	for _, p := range c.providers {
+		name := p.Name()
+		if !c.breakers.allow(name, c.now()) {
+			log.Printf("[Fallback] Skipping %s: circuit open until %s", name, c.breakers.openUntil(name).Format(time.RFC3339))
+			failures = append(failures, fmt.Errorf("%s: circuit open", name))
+			continue
+		}
+
+		if attempts == 0 && len(c.providers) > 1 {
+			var err error
+			if tree, err = snapshot(ctx, req.RepoPath); err != nil {
+				log.Printf("[Fallback] Warning: cannot snapshot %s, fallbacks will see earlier changes: %v", req.RepoPath, err)
+			}
+		}
+		if attempts > 0 {
+			if tree != "" {
+				if err := restore(ctx, req.RepoPath, tree); err != nil {
+					return nil, fmt.Errorf("cannot restore workspace for %s: %w (after %w)", name, err, &chainError{failures})
+				}
+			}
+			log.Printf("[Fallback] Switching to %s", name)
+		}
+		attempts++
+
+		attempt := *req
+		if name != c.Name() {
+			// The session belongs to the primary provider.
+			attempt.SessionID = ""
+		}
+		if req.Budget != nil {
+			// Each provider reports its own usage; the name lets the budget
+			// callback keep what earlier providers spent.
+			attempt.Budget = func(usage claude.Usage) error {
+				if usage.Provider == "" {
+					usage.Provider = name
+				}
+				return req.Budget(usage)
+			}
+		}
+		resp, err := p.GenerateCode(ctx, &attempt)
+		if err == nil {
+			c.breakers.succeed(name)
+			if resp != nil && resp.Provider == "" {
+				resp.Provider = name
+			}
+			return resp, nil
+		}
+
+		class := Classify(err)
+		if ctx.Err() != nil || !c.switchOn[class] {
+			return nil, err
+		}
+		c.breakers.fail(name, c.now())
+		log.Printf("[Fallback] %s failed (%s): %v", name, class, err)
+		failures = append(failures, fmt.Errorf("%s: %w", name, err))
+	}
+
+	if attempts == 0 {
+		return nil, fmt.Errorf("no provider available: %w", &chainError{failures})
+	}
+	return nil, fmt.Errorf("all providers failed: %w", &chainError{failures})
+}
+
+// chainError collects the failure of every provider in the chain.
+type chainError struct {
+	errs []error
+}
+
+func (e *chainError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *chainError) Unwrap() []error {
+	return e.errs
+}
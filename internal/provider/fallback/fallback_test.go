@@ -0,0 +1,249 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/anthropic"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/openai"
+)
+
+type stubProvider struct {
+	name  string
+	calls int
+	run   func(req *claude.CodeRequest) (*claude.CodeResponse, error)
+}
+
+func (s *stubProvider) Name() string { return s.name }
+
+func (s *stubProvider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	s.calls++
+	return s.run(req)
+}
+
+func failing(name string, err error) *stubProvider {
+	return &stubProvider{name: name, run: func(*claude.CodeRequest) (*claude.CodeResponse, error) { return nil, err }}
+}
+
+func succeeding(name string) *stubProvider {
+	return &stubProvider{name: name, run: func(*claude.CodeRequest) (*claude.CodeResponse, error) {
+		return &claude.CodeResponse{Summary: "done by " + name}, nil
+	}}
+}
+
+type retryableError struct{}
+
+func (retryableError) Error() string   { return "upstream hiccup" }
+func (retryableError) Retryable() bool { return true }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Class
+	}{
+		{&anthropic.APIError{StatusCode: 429, Type: "rate_limit_error"}, ClassRateLimit},
+		{errors.New("Claude CLI error: claude CLI error: Claude AI usage limit reached|1760000000"), ClassRateLimit},
+		{errors.New("Claude CLI error: claude CLI error: API Error: 529 {\"type\":\"overloaded_error\"} (exit status 1)"), ClassServer},
+		{errors.New("codex CLI error: [2025-10-01T10:00:00Z] ERROR: exceeded retry limit, last status: 429 Too Many Requests"), ClassRateLimit},
+		{errors.New("codex CLI error: stream error: unexpected status 401 Unauthorized"), ClassAuth},
+		{fmt.Errorf("anthropic: %w", &anthropic.APIError{StatusCode: 529, Type: "overloaded_error"}), ClassServer},
+		{fmt.Errorf("codex CLI timeout after 10m0s: %w: ", context.DeadlineExceeded), ClassTimeout},
+		{fmt.Errorf("claude CLI execution failed: %w", context.DeadlineExceeded), ClassTimeout},
+		{&openai.APIError{StatusCode: 401, Type: "invalid_request_error", Message: "Incorrect API key"}, ClassAuth},
+		{&openai.APIError{StatusCode: 400, Type: "invalid_request_error", Message: "rate limit of the tool exceeded"}, ClassOther},
+		{&openai.BudgetError{Budget: 1000, Used: 1200}, ClassBudget},
+		{fmt.Errorf("%w, stopped after 4 turns", budget.Status{}), ClassBudget},
+		{fmt.Errorf("wrapped: %w", retryableError{}), ClassServer},
+		{errors.New("failed to parse response: no summary"), ClassOther},
+		{errors.New("claude CLI execution failed: exit status 1 (output preview: // back off on rate limit or quota, timeout after 5s)"), ClassOther},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%q) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+
+	if _, err := ParseClasses([]string{"rate_limit", " Server "}); err != nil {
+		t.Fatalf("ParseClasses() error = %v", err)
+	}
+	if _, err := ParseClasses([]string{"quota"}); err == nil {
+		t.Fatal("ParseClasses() should reject unknown classes")
+	}
+}
+
+func TestChain_SwitchesOnConfiguredClasses(t *testing.T) {
+	primary := failing("claude", &anthropic.APIError{StatusCode: 429, Type: "rate_limit_error", Message: "slow down"})
+	local := succeeding("openai")
+	chain := New(primary, []provider.Provider{local}, Options{})
+
+	resp, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if resp.Provider != "openai" || resp.Summary != "done by openai" {
+		t.Fatalf("response = %+v, want the fallback's response", resp)
+	}
+	if chain.Name() != "claude" {
+		t.Fatalf("Name() = %s, want primary", chain.Name())
+	}
+
+	// Only the primary resumes the session; it belongs to that provider.
+	var sessions []string
+	primary.run = func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		sessions = append(sessions, req.SessionID)
+		return nil, &anthropic.APIError{StatusCode: 429, Type: "rate_limit_error", Message: "slow down"}
+	}
+	local.run = func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		sessions = append(sessions, req.SessionID)
+		return &claude.CodeResponse{}, nil
+	}
+	if _, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir(), SessionID: "s1"}); err != nil || strings.Join(sessions, ",") != "s1," {
+		t.Fatalf("sessions = %q, %v, want s1 for the primary only", sessions, err)
+	}
+
+	// Budget reports name the provider that spent the tokens.
+	var reporters []string
+	primary.run = func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		req.Budget(claude.Usage{OutputTokens: 10})
+		return nil, &anthropic.APIError{StatusCode: 429, Type: "rate_limit_error", Message: "slow down"}
+	}
+	local.run = func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		req.Budget(claude.Usage{OutputTokens: 5})
+		return &claude.CodeResponse{}, nil
+	}
+	record := func(usage claude.Usage) error {
+		reporters = append(reporters, usage.Provider)
+		return nil
+	}
+	if _, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir(), Budget: record}); err != nil || strings.Join(reporters, ",") != "claude,openai" {
+		t.Fatalf("budget reporters = %q, %v, want claude,openai", reporters, err)
+	}
+
+	// Errors outside the rules are returned without trying the fallback.
+	broken := failing("claude", errors.New("failed to parse response"))
+	local.calls = 0
+	chain = New(broken, []provider.Provider{local}, Options{})
+	if _, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()}); err == nil || local.calls != 0 {
+		t.Fatalf("error = %v, fallback calls = %d, want primary error only", err, local.calls)
+	}
+
+	// Custom rules switch on budget errors too.
+	overBudget := failing("openai", &openai.BudgetError{Budget: 5, Used: 10})
+	chain = New(overBudget, []provider.Provider{succeeding("claude")}, Options{SwitchOn: []Class{ClassBudget}})
+	if resp, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()}); err != nil || resp.Provider != "claude" {
+		t.Fatalf("GenerateCode() = %+v, %v, want claude after budget error", resp, err)
+	}
+}
+
+func TestChain_CircuitBreaker(t *testing.T) {
+	primary := failing("claude", &anthropic.APIError{StatusCode: 503, Type: "api_error", Message: "unavailable"})
+	backup := succeeding("codex")
+	chain := New(primary, []provider.Provider{backup}, Options{FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	chain.now = func() time.Time { return now }
+
+	run := func() {
+		t.Helper()
+		if _, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()}); err != nil {
+			t.Fatalf("GenerateCode() error = %v", err)
+		}
+	}
+	run()
+	run()
+	run() // circuit open: primary skipped
+	if primary.calls != 2 || backup.calls != 3 {
+		t.Fatalf("calls = %d/%d, want primary skipped once its circuit opened", primary.calls, backup.calls)
+	}
+
+	// Repository chains share the circuits of the server chain.
+	repoChain := chain.WithPrimary(failing("claude", errors.New("unused")))
+	if _, err := repoChain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()}); err != nil {
+		t.Fatalf("repository chain error = %v", err)
+	}
+
+	// After the cooldown one trial goes through and closes the circuit.
+	now = now.Add(2 * time.Minute)
+	primary.run = succeeding("claude").run
+	run()
+	if primary.calls != 3 {
+		t.Fatalf("primary calls = %d, want a trial after the cooldown", primary.calls)
+	}
+	run()
+	if primary.calls != 4 {
+		t.Fatalf("primary calls = %d, want circuit closed after the trial", primary.calls)
+	}
+
+	// With every circuit open the chain fails without calling anyone.
+	down := New(failing("a", &anthropic.APIError{StatusCode: 500, Type: "api_error", Message: "boom"}), []provider.Provider{failing("b", &openai.APIError{StatusCode: 502, Message: "bad gateway"})}, Options{FailureThreshold: 1})
+	_, err := down.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "all providers failed: a: anthropic API error (status 500, api_error): boom; b: openai API error (status 502, ): bad gateway") {
+		t.Fatalf("error = %v", err)
+	}
+	_, err = down.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "no provider available") {
+		t.Fatalf("error = %v, want open circuits", err)
+	}
+}
+
+func TestChain_RestoresWorkspaceBeforeFallback(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "t@example.com"}, {"config", "user.name", "t"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("kept.txt", "original\n")
+	write("gone.txt", "tracked\n")
+	if out, err := exec.Command("git", "-C", dir, "add", "-A").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v %s", err, out)
+	}
+	if out, err := exec.Command("git", "-C", dir, "commit", "-qm", "init").CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v %s", err, out)
+	}
+	write("draft.txt", "uncommitted work\n") // present before the task, must survive
+
+	primary := &stubProvider{name: "claude", run: func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		write("kept.txt", "half done\n")
+		write("new.txt", "partial\n")
+		os.Remove(filepath.Join(dir, "gone.txt"))
+		return nil, errors.New("Claude CLI error: claude CLI error: Claude AI usage limit reached")
+	}}
+	var seen map[string]string
+	backup := &stubProvider{name: "codex", run: func(req *claude.CodeRequest) (*claude.CodeResponse, error) {
+		seen = map[string]string{}
+		for _, name := range []string{"kept.txt", "gone.txt", "new.txt", "draft.txt"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				data = []byte("<missing>")
+			}
+			seen[name] = string(data)
+		}
+		return &claude.CodeResponse{Summary: "ok"}, nil
+	}}
+
+	chain := New(primary, []provider.Provider{backup}, Options{})
+	if _, err := chain.GenerateCode(context.Background(), &claude.CodeRequest{RepoPath: dir}); err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	want := map[string]string{"kept.txt": "original\n", "gone.txt": "tracked\n", "new.txt": "<missing>", "draft.txt": "uncommitted work\n"}
+	for name, content := range want {
+		if seen[name] != content {
+			t.Errorf("%s = %q before fallback, want %q", name, seen[name], content)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package fallback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// snapshot records every file of the working tree, tracked or not, as a git
+// tree object. It works on a private index, so HEAD, the index and an
+// in-progress rebase stay untouched.
+func snapshot(ctx context.Context, dir string) (string, error) {
+	index, cleanup, err := tempIndex(ctx, dir, true)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if _, err := git(ctx, dir, index, "add", "-A"); err != nil {
+		return "", err
+	}
+	return git(ctx, dir, index, "write-tree")
+}
+
+// restore returns the working tree to a snapshot: files a failed attempt
+// changed or deleted are checked out again and files it added are removed.
+func restore(ctx context.Context, dir, tree string) error {
+	index, cleanup, err := tempIndex(ctx, dir, false)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err := git(ctx, dir, index, "read-tree", tree); err != nil {
+		return err
+	}
+	added, err := git(ctx, dir, index, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(added, "\x00") {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	_, err = git(ctx, dir, index, "checkout-index", "--all", "--force")
+	return err
+}
+
+// tempIndex returns the path of a private index file. With seed it starts as
+// a copy of the repository index, so unchanged files are not hashed again.
+func tempIndex(ctx context.Context, dir string, seed bool) (string, func(), error) {
+	tmp, err := os.MkdirTemp("", "swe-fallback-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	index := filepath.Join(tmp, "index")
+
+	if seed {
+		if current, err := git(ctx, dir, "", "rev-parse", "--path-format=absolute", "--git-path", "index"); err == nil {
+			if data, err := os.ReadFile(current); err == nil {
+				_ = os.WriteFile(index, data, 0o600)
+			}
+		}
+	}
+	return index, cleanup, nil
+}
+
+func git(ctx context.Context, dir, index string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if index != "" {
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w (%s)", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
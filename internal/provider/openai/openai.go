@@ -0,0 +1,483 @@
+// Package openai implements a provider for OpenAI-compatible Chat Completions
+// endpoints (OpenAI, vLLM, llama.cpp server, Ollama). It streams completions
+// and runs function-calling tools against the task's working directory.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/provider/shared"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+const (
+	// DefaultBaseURL is the public OpenAI API endpoint.
+	DefaultBaseURL = "https://api.openai.com/v1"
+
+	defaultMaxTurns = 50
+	requestTimeout  = 10 * time.Minute
+)
+
+// toolInstructions tells the model how to work with the function tools.
+const toolInstructions = `
+
+## Tools
+You can inspect and edit the repository with the provided functions. Prefer write_file for edits; you do not need to repeat edited files as <file> blocks. Do not commit or push, the service does that. When you are done, reply with a <summary> of the changes without calling further functions.`
+
+var promptManager = prompt.NewManager()
+
+// Provider calls a Chat Completions endpoint and executes tool calls locally.
+type Provider struct {
+	apiKey      string
+	baseURL     string
+	model       string
+	tokenBudget int
+	maxTurns    int
+	commands    []string
+	client      *http.Client
+	sandbox     *sandbox.Sandbox
+}
+
+// NewProvider creates a Chat Completions provider. An empty baseURL uses
+// DefaultBaseURL; local servers usually need no apiKey.
+func NewProvider(apiKey, baseURL, model string) *Provider {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Provider{
+		apiKey:   apiKey,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		model:    model,
+		maxTurns: defaultMaxTurns,
+		client:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "openai"
+}
+
+// WithSandbox runs commands started by the run_command tool inside sb.
+func (p *Provider) WithSandbox(sb *sandbox.Sandbox) *Provider {
+	p.sandbox = sb
+	return p
+}
+
+// WithCommands sets the programs the run_command tool may start. An empty
+// list uses shared.DefaultCommands.
+func (p *Provider) WithCommands(commands []string) *Provider {
+	p.commands = commands
+	return p
+}
+
+// WithTokenBudget stops a task once prompt and completion tokens of all turns
+// together exceed budget. 0 disables the guard.
+func (p *Provider) WithTokenBudget(budget int) *Provider {
+	p.tokenBudget = budget
+	return p
+}
+
+// WithMaxTurns limits the number of model calls per task.
+func (p *Provider) WithMaxTurns(turns int) *Provider {
+	if turns > 0 {
+		p.maxTurns = turns
+	}
+	return p
+}
+
+// APIError is an error response of the endpoint.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("openai API error (status %d, %s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Retryable reports whether the request may succeed later: rate limits and
+// server errors.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// BudgetError reports that a task used up its token budget.
+type BudgetError struct {
+	Budget int
+	Used   int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("token budget exceeded: used %d of %d tokens", e.Used, e.Budget)
+}
+
+type functionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type toolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function functionCall `json:"function"`
+}
+
+type chatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type functionDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type toolDefinition struct {
+	Type     string             `json:"type"`
+	Function functionDefinition `json:"function"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatRequest struct {
+	Model         string           `json:"model"`
+	Messages      []chatMessage    `json:"messages"`
+	Tools         []toolDefinition `json:"tools,omitempty"`
+	Stream        bool             `json:"stream"`
+	StreamOptions *streamOptions   `json:"stream_options,omitempty"`
+}
+
+// Usage is the token usage reported by the endpoint.
+type Usage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"` // part of PromptTokens
+	} `json:"prompt_tokens_details"`
+}
+
+// Total returns prompt plus completion tokens.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// usage converts the Chat Completions usage of a task. Cached prompt tokens
+// are reported separately from the uncached input.
+func (p *Provider) usage(u Usage, turns int, duration time.Duration) claude.Usage {
+	cached := u.PromptTokensDetails.CachedTokens
+	return claude.Usage{
+		Model:           p.model,
+		InputTokens:     u.PromptTokens - cached,
+		OutputTokens:    u.CompletionTokens,
+		CacheReadTokens: cached,
+		Turns:           turns,
+		Duration:        duration,
+	}
+}
+
+// completion is an assistant turn assembled from a stream or a plain response.
+type completion struct {
+	message chatMessage
+	usage   *Usage
+}
+
+// GenerateCode runs the agent loop until the model stops calling tools.
+func (p *Provider) GenerateCode(ctx context.Context, req *claude.CodeRequest) (*claude.CodeResponse, error) {
+	log.Printf("[OpenAI] Starting code generation (model %s at %s, prompt length: %d chars)", p.model, p.baseURL, len(req.Prompt))
+
+	if req.RepoPath == "" {
+		return nil, fmt.Errorf("repository path is required")
+	}
+	if _, err := os.Stat(req.RepoPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("repository path does not exist: %s", req.RepoPath)
+	}
+
+	files, err := promptManager.ListRepoFiles(req.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+	systemPrompt := promptManager.BuildDefaultSystemPrompt(files, req.Context) + toolInstructions
+	userPrompt := promptManager.BuildUserPrompt(req.Prompt)
+
+	toolbox := shared.NewToolbox(req.RepoPath, p.commands, p.sandbox)
+	if disallowed := req.Context["disallowed_tools"]; disallowed != "" {
+		toolbox.Disable(strings.Split(disallowed, ",")...)
+	}
+	var tools []toolDefinition
+	for _, tool := range toolbox.Tools() {
+		tools = append(tools, toolDefinition{Type: "function", Function: functionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Schema}})
+	}
+
+	ctx, cancel := p.sandbox.WithTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	messages := []chatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}
+	var usage Usage
+	var finalText string
+	turns := 0
+	for {
+		if turns >= p.maxTurns {
+			return nil, fmt.Errorf("openai: no final answer after %d turns", p.maxTurns)
+		}
+		turns++
+
+		result, err := p.complete(ctx, messages, tools)
+		if err != nil {
+			return nil, err
+		}
+		if result.usage != nil {
+			usage.PromptTokens += result.usage.PromptTokens
+			usage.CompletionTokens += result.usage.CompletionTokens
+			usage.PromptTokensDetails.CachedTokens += result.usage.PromptTokensDetails.CachedTokens
+		} else {
+			// Some servers omit usage in streams; estimate four characters per token.
+			usage.PromptTokens += estimateTokens(messages)
+			usage.CompletionTokens += estimateTokens([]chatMessage{result.message})
+		}
+		if p.tokenBudget > 0 && usage.Total() > p.tokenBudget {
+			return nil, &BudgetError{Budget: p.tokenBudget, Used: usage.Total()}
+		}
+		if err := req.CheckBudget(p.usage(usage, turns, time.Since(start))); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, result.message)
+		if len(result.message.ToolCalls) == 0 {
+			finalText = result.message.Content
+			break
+		}
+		for _, call := range result.message.ToolCalls {
+			output, err := toolbox.Run(ctx, call.Function.Name, json.RawMessage(call.Function.Arguments))
+			if err != nil {
+				output = "Error: " + err.Error()
+			}
+			if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
+				log.Printf("[OpenAI] Tool %s: error=%v", call.Function.Name, err)
+			}
+			messages = append(messages, chatMessage{Role: "tool", ToolCallID: call.ID, Content: output})
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	duration := time.Since(start)
+	log.Printf("[OpenAI] Finished after %d turns in %v: prompt=%d completion=%d tokens",
+		turns, duration.Round(time.Millisecond), usage.PromptTokens, usage.CompletionTokens)
+
+	written := toolbox.Written()
+	response, err := parseResponse(finalText)
+	if err != nil {
+		if len(written) == 0 {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		// Edits were made through tools; the closing text is optional.
+		response = &claude.CodeResponse{Summary: "Updated " + strings.Join(written, ", ")}
+	}
+	response.Usage = p.usage(usage, turns, duration)
+	response.SystemPrompt, response.UserPrompt, response.RawResponse = systemPrompt, userPrompt, finalText
+
+	log.Printf("[OpenAI] %d files written through tools, %d file blocks in response", len(written), len(response.Files))
+	return response, nil
+}
+
+// complete sends one streaming Chat Completions request. Servers that ignore
+// stream and answer with a plain JSON completion are handled as well.
+func (p *Provider) complete(ctx context.Context, messages []chatMessage, tools []toolDefinition) (*completion, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:         p.model,
+		Messages:      messages,
+		Tools:         tools,
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Type: "http_error", Message: truncate(string(data), 500)}
+		var envelope struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &envelope) == nil && envelope.Error.Message != "" {
+			apiErr.Type, apiErr.Message = envelope.Error.Type, envelope.Error.Message
+		}
+		return nil, apiErr
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var plain struct {
+			Choices []struct {
+				Message chatMessage `json:"message"`
+			} `json:"choices"`
+			Usage *Usage `json:"usage"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&plain); err != nil {
+			return nil, fmt.Errorf("failed to parse openai response: %w", err)
+		}
+		if len(plain.Choices) == 0 {
+			return nil, fmt.Errorf("openai response has no choices")
+		}
+		message := plain.Choices[0].Message
+		message.Role = "assistant"
+		return &completion{message: message, usage: plain.Usage}, nil
+	}
+	return readStream(resp.Body)
+}
+
+// streamChunk is one server-sent event of a streamed completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// readStream assembles content and tool calls from "data:" events. Tool call
+// names and arguments arrive in fragments keyed by index.
+func readStream(r io.Reader) (*completion, error) {
+	var content strings.Builder
+	calls := make(map[int]*toolCall)
+	result := &completion{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if chunk.Usage != nil {
+			result.usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			for _, fragment := range choice.Delta.ToolCalls {
+				call, ok := calls[fragment.Index]
+				if !ok {
+					call = &toolCall{Type: "function"}
+					calls[fragment.Index] = call
+				}
+				if fragment.ID != "" {
+					call.ID = fragment.ID
+				}
+				call.Function.Name += fragment.Function.Name
+				call.Function.Arguments += fragment.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	result.message = chatMessage{Role: "assistant", Content: content.String()}
+	for _, index := range indexes {
+		call := calls[index]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", index)
+		}
+		result.message.ToolCalls = append(result.message.ToolCalls, *call)
+	}
+	return result, nil
+}
+
+func estimateTokens(messages []chatMessage) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.Content)
+		for _, call := range m.ToolCalls {
+			chars += len(call.Function.Name) + len(call.Function.Arguments)
+		}
+	}
+	return chars/4 + 1
+}
+
+func parseResponse(text string) (*claude.CodeResponse, error) {
+	parsed, err := shared.ParseResponse("OpenAI", text)
+	if err != nil {
+		return nil, err
+	}
+	response := &claude.CodeResponse{Summary: parsed.Summary}
+	for _, file := range parsed.Files {
+		response.Files = append(response.Files, claude.FileChange{Path: file.Path, Content: file.Content, Edits: file.Edits})
+	}
+	for _, comment := range parsed.Comments {
+		response.Comments = append(response.Comments, claude.ReviewComment(comment))
+	}
+	return response, nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
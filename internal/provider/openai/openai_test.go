@@ -0,0 +1,160 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+// streamServer answers each request with the next list of SSE events and
+// records the decoded requests.
+func streamServer(t *testing.T, turns ...[]string) (*httptest.Server, *[]chatRequest) {
+	t.Helper()
+	var requests []chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, req)
+		if len(requests) > len(turns) {
+			t.Errorf("unexpected request #%d", len(requests))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range turns[len(requests)-1] {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestGenerateCode_StreamedToolCalls(t *testing.T) {
+	repo := t.TempDir()
+	server, requests := streamServer(t,
+		[]string{
+			`{"choices":[{"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","function":{"name":"write_","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"file","arguments":"{\"path\":\"hello.txt\","}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"content\":\"hi\\n\"}"}}]}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":100,"completion_tokens":20}}`,
+		},
+		[]string{
+			`{"choices":[{"delta":{"content":"<summary>Add "}}]}`,
+			`{"choices":[{"delta":{"content":"hello.txt</summary>"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":150,"completion_tokens":10,"prompt_tokens_details":{"cached_tokens":90}}}`,
+		},
+	)
+
+	p := NewProvider("", server.URL+"/v1", "qwen2.5-coder")
+	resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "Add hello", RepoPath: repo})
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if resp.Summary != "Add hello.txt" {
+		t.Fatalf("Summary = %q", resp.Summary)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "hello.txt")); string(data) != "hi\n" {
+		t.Fatalf("hello.txt = %q, want streamed tool call applied", data)
+	}
+	if u := resp.Usage; u.Model != "qwen2.5-coder" || u.InputTokens != 160 || u.CacheReadTokens != 90 || u.OutputTokens != 30 || u.Turns != 2 {
+		t.Fatalf("Usage = %+v, want cached tokens split from the prompt tokens", u)
+	}
+
+	first := (*requests)[0]
+	if first.Model != "qwen2.5-coder" || !first.Stream || len(first.Tools) != 5 || first.Messages[0].Role != "system" {
+		t.Fatalf("first request = %+v", first)
+	}
+	second := (*requests)[1].Messages
+	call, result := second[len(second)-2], second[len(second)-1]
+	if len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Name != "write_file" || call.ToolCalls[0].ID != "call_a" {
+		t.Fatalf("assistant message = %+v", call)
+	}
+	if result.Role != "tool" || result.ToolCallID != "call_a" || !strings.Contains(result.Content, "hello.txt") {
+		t.Fatalf("tool message = %+v", result)
+	}
+}
+
+func TestGenerateCode_TokenBudget(t *testing.T) {
+	loop := []string{
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c","function":{"name":"list_dir","arguments":"{\"path\":\".\"}"}}]}}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":600,"completion_tokens":10}}`,
+	}
+	server, requests := streamServer(t, loop, loop)
+
+	p := NewProvider("key", server.URL+"/v1", "gpt-4o").WithTokenBudget(1000)
+	_, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "x", RepoPath: t.TempDir()})
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Used != 1220 {
+		t.Fatalf("error = %v, want budget error after 1220 tokens", err)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("requests = %d, want loop stopped at the second turn", len(*requests))
+	}
+}
+
+func TestGenerateCode_BudgetCallback(t *testing.T) {
+	loop := []string{
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c","function":{"name":"list_dir","arguments":"{\"path\":\".\"}"}}]}}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":600,"completion_tokens":10,"prompt_tokens_details":{"cached_tokens":100}}}`,
+	}
+	server, requests := streamServer(t, loop, loop)
+
+	capErr := errors.New("over budget")
+	var last claude.Usage
+	req := &claude.CodeRequest{Prompt: "x", RepoPath: t.TempDir(), Budget: func(u claude.Usage) error {
+		last = u
+		return capErr
+	}}
+	_, err := NewProvider("key", server.URL+"/v1", "gpt-4o").GenerateCode(context.Background(), req)
+	if !errors.Is(err, capErr) || len(*requests) != 1 {
+		t.Fatalf("error = %v after %d requests, want the budget error after the first turn", err, len(*requests))
+	}
+	if last != (claude.Usage{Model: "gpt-4o", InputTokens: 500, CacheReadTokens: 100, OutputTokens: 10, Turns: 1, Duration: last.Duration}) {
+		t.Fatalf("usage = %+v", last)
+	}
+}
+
+func TestGenerateCode_PlainJSONAndErrors(t *testing.T) {
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status != 0 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":{"type":"rate_limit_exceeded","message":"slow down"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"<summary>Looks fine</summary>"}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "gpt-4o")
+	resp, err := p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "Review", RepoPath: t.TempDir()})
+	if err != nil || resp.Summary != "Looks fine" {
+		t.Fatalf("GenerateCode() = %+v, %v", resp, err)
+	}
+
+	status = http.StatusTooManyRequests
+	_, err = p.GenerateCode(context.Background(), &claude.CodeRequest{Prompt: "Review", RepoPath: t.TempDir()})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "rate_limit_exceeded" || !apiErr.Retryable() {
+		t.Fatalf("error = %v, want retryable rate limit", err)
+	}
+}
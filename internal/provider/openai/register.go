@@ -0,0 +1,42 @@
+package openai
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+func init() {
+	provider.Register(provider.Registration{
+		Name: "openai",
+		Settings: []provider.Setting{
+			{Key: "API_KEY", Env: []string{"OPENAI_API_KEY"}, Description: "API key, optional for local servers"},
+			{Key: "BASE_URL", Env: []string{"OPENAI_BASE_URL"}, Description: "Chat Completions endpoint, e.g. http://localhost:11434/v1"},
+			{Key: "MODEL", Env: []string{"OPENAI_MODEL"}, Required: true, Description: "Model name"},
+			{Key: "TOKEN_BUDGET", Env: []string{"OPENAI_TOKEN_BUDGET"}, Default: "2000000", Description: "Prompt plus completion tokens per task, 0 = unlimited"},
+			{Key: "TOOL_COMMANDS", Env: []string{"TOOL_ALLOWED_COMMANDS"}, Description: "Programs run_command may start, comma-separated"},
+			{Key: "MAX_TURNS", Default: fmt.Sprint(defaultMaxTurns), Description: "Model calls per task"},
+		},
+		Validate: func(s provider.Settings) error {
+			if s.Get("API_KEY") == "" && s.Get("BASE_URL") == "" {
+				return fmt.Errorf("API_KEY or BASE_URL is required")
+			}
+			if budget, err := strconv.Atoi(s.Get("TOKEN_BUDGET")); err != nil || budget < 0 {
+				return fmt.Errorf("TOKEN_BUDGET must be a non-negative number (got %q)", s.Get("TOKEN_BUDGET"))
+			}
+			if s.Int("MAX_TURNS") <= 0 {
+				return fmt.Errorf("MAX_TURNS must be a positive number (got %q)", s.Get("MAX_TURNS"))
+			}
+			return nil
+		},
+		New: func(s provider.Settings, sb *sandbox.Sandbox) (provider.Provider, error) {
+			return NewProvider(s.Get("API_KEY"), s.Get("BASE_URL"), s.Get("MODEL")).
+				WithTokenBudget(s.Int("TOKEN_BUDGET")).
+				WithCommands(s.List("TOOL_COMMANDS")).
+				WithMaxTurns(s.Int("MAX_TURNS")).
+				WithSandbox(sb), nil
+		},
+	})
+}
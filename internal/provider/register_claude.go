@@ -0,0 +1,22 @@
+package provider
+
+import (
+	"github.com/cexll/swe/internal/provider/claude"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+// The Claude CLI provider registers here rather than in its own package:
+// package claude defines the request and response types this package imports.
+func init() {
+	Register(Registration{
+		Name: "claude",
+		Settings: []Setting{
+			{Key: "API_KEY", Env: []string{"ANTHROPIC_API_KEY"}, Required: true, Description: "Anthropic API key passed to the claude CLI"},
+			{Key: "MODEL", Env: []string{"CLAUDE_MODEL"}, Default: "claude-3-5-sonnet-20241022", Description: "Model name"},
+			{Key: "SESSION_DIR", Env: []string{"SESSION_DIR"}, Default: "./data/sessions", Description: "Directory keeping session transcripts for follow-up tasks"},
+		},
+		New: func(s Settings, sb *sandbox.Sandbox) (Provider, error) {
+			return claude.NewProvider(s.Get("API_KEY"), s.Get("MODEL")).WithSandbox(sb).WithSessionDir(s.Get("SESSION_DIR")), nil
+		},
+	})
+}
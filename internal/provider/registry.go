@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+// Setting describes a configuration key of a provider. Its value is read from
+// PROVIDER_<NAME>_<KEY>, then from the Env fallbacks, then Default.
+type Setting struct {
+	Key         string   // e.g. "API_KEY"
+	Env         []string // older variable names, e.g. "ANTHROPIC_API_KEY"
+	Default     string
+	Required    bool
+	Description string
+}
+
+// Settings holds resolved provider settings by key.
+type Settings map[string]string
+
+// Get returns the value of key, "" when unset.
+func (s Settings) Get(key string) string {
+	return s[key]
+}
+
+// Int returns the value of key as an integer, 0 when unset or invalid.
+// Registrations reject invalid numbers in Validate.
+func (s Settings) Int(key string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s[key]))
+	return n
+}
+
+// List returns the comma-separated value of key without empty entries.
+func (s Settings) List(key string) []string {
+	var out []string
+	for _, item := range strings.Split(s[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Registration describes a provider implementation.
+type Registration struct {
+	Name     string
+	Settings []Setting
+	// Validate checks settings beyond Required keys. Optional.
+	Validate func(Settings) error
+	// New builds the provider from validated settings.
+	New func(s Settings, sb *sandbox.Sandbox) (Provider, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register makes a provider available by name. Provider packages call it from
+// init; registering a name twice panics.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r.Name == "" || r.New == nil {
+		panic("provider: Register requires a name and a constructor")
+	}
+	if _, dup := registry[r.Name]; dup {
+		panic("provider: Register called twice for " + r.Name)
+	}
+	registry[r.Name] = r
+}
+
+// Names returns the registered provider names, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) (Registration, error) {
+	registryMu.RLock()
+	r, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return Registration{}, fmt.Errorf("unknown provider: %s (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+	return r, nil
+}
+
+// EnvKey returns the generic variable name of a provider setting.
+func EnvKey(provider, key string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(provider))
+	return "PROVIDER_" + name + "_" + key
+}
+
+// LoadSettings resolves and validates the settings of a provider. getenv is
+// usually os.Getenv.
+func LoadSettings(name string, getenv func(string) string) (Settings, error) {
+	r, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	settings := r.resolve(getenv)
+	if err := r.check(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// ValidateSettings checks settings that were not resolved by LoadSettings,
+// e.g. ones built in code. Unset keys take their defaults.
+func ValidateSettings(name string, settings Settings) error {
+	r, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	return r.check(r.complete(settings))
+}
+
+// resolve reads every setting from PROVIDER_<NAME>_<KEY>, the Env fallbacks
+// and the default, in that order.
+func (r Registration) resolve(getenv func(string) string) Settings {
+	settings := make(Settings, len(r.Settings))
+	for _, setting := range r.Settings {
+		value := getenv(EnvKey(r.Name, setting.Key))
+		for _, env := range setting.Env {
+			if value != "" {
+				break
+			}
+			value = getenv(env)
+		}
+		if value == "" {
+			value = setting.Default
+		}
+		settings[setting.Key] = value
+	}
+	return settings
+}
+
+// complete returns a copy of settings with defaults for unset keys.
+func (r Registration) complete(settings Settings) Settings {
+	out := make(Settings, len(settings)+len(r.Settings))
+	for key, value := range settings {
+		out[key] = value
+	}
+	for _, setting := range r.Settings {
+		if out[setting.Key] == "" {
+			out[setting.Key] = setting.Default
+		}
+	}
+	return out
+}
+
+// check enforces required settings and the registration's own validation.
+func (r Registration) check(settings Settings) error {
+	for _, setting := range r.Settings {
+		if setting.Required && strings.TrimSpace(settings[setting.Key]) == "" {
+			names := append([]string{EnvKey(r.Name, setting.Key)}, setting.Env...)
+			return fmt.Errorf("%s: %s is required", r.Name, strings.Join(names, " or "))
+		}
+	}
+	if r.Validate != nil {
+		if err := r.Validate(settings); err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
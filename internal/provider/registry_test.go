@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+func TestLoadSettings_Precedence(t *testing.T) {
+	env := map[string]string{
+		"PROVIDER_CLAUDE_API_KEY": "generic-key",
+		"ANTHROPIC_API_KEY":       "legacy-key",
+		"CLAUDE_MODEL":            "legacy-model",
+	}
+	settings, err := LoadSettings("claude", func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+	if settings.Get("API_KEY") != "generic-key" {
+		t.Fatalf("API_KEY = %q, want PROVIDER_CLAUDE_API_KEY to win", settings.Get("API_KEY"))
+	}
+	if settings.Get("MODEL") != "legacy-model" {
+		t.Fatalf("MODEL = %q, want fallback variable", settings.Get("MODEL"))
+	}
+
+	delete(env, "CLAUDE_MODEL")
+	settings, _ = LoadSettings("claude", func(key string) string { return env[key] })
+	if settings.Get("MODEL") != "claude-3-5-sonnet-20241022" {
+		t.Fatalf("MODEL = %q, want default", settings.Get("MODEL"))
+	}
+
+	_, err = LoadSettings("claude", func(string) string { return "" })
+	if err == nil || !strings.Contains(err.Error(), "PROVIDER_CLAUDE_API_KEY or ANTHROPIC_API_KEY is required") {
+		t.Fatalf("LoadSettings() error = %v, want missing key", err)
+	}
+}
+
+func TestSettingsHelpers(t *testing.T) {
+	s := Settings{"N": " 12 ", "BAD": "x", "LIST": " go, ,make,"}
+	if s.Int("N") != 12 || s.Int("BAD") != 0 || s.Int("MISSING") != 0 {
+		t.Fatalf("Int() = %d, %d, %d", s.Int("N"), s.Int("BAD"), s.Int("MISSING"))
+	}
+	if got := strings.Join(s.List("LIST"), ","); got != "go,make" {
+		t.Fatalf("List() = %q", got)
+	}
+	if EnvKey("my-llm", "BASE_URL") != "PROVIDER_MY_LLM_BASE_URL" {
+		t.Fatalf("EnvKey() = %q", EnvKey("my-llm", "BASE_URL"))
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register() should panic for a duplicate name")
+		}
+	}()
+	Register(Registration{Name: "claude", New: func(Settings, *sandbox.Sandbox) (Provider, error) { return nil, nil }})
+}
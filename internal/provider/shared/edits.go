@@ -0,0 +1,410 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Edit replaces lines of an existing file. Edits come from search/replace
+// blocks and unified diff hunks, so a response does not have to repeat
+// whole files to change a few lines.
+type Edit struct {
+	Search  string // lines to replace; empty inserts at Line or fills a new file
+	Replace string
+	Line    int // first line of Search in the original file when known (diff hunks), 0 otherwise
+}
+
+// EditFailure is an edit that could not be applied.
+type EditFailure struct {
+	Edit   int // 1-based position among the edits of the file
+	Line   int // line hint of the edit, 0 when unknown
+	Reason string
+}
+
+// EditError lists the edits of a file that could not be applied. The file
+// is left unchanged when any of them fails.
+type EditError struct {
+	Path     string
+	Edits    int
+	Failures []EditFailure
+}
+
+func (e *EditError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d of %d edits failed", e.Path, len(e.Failures), e.Edits)
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "; edit %d", f.Edit)
+		if f.Line > 0 {
+			fmt.Fprintf(&b, " (line %d)", f.Line)
+		}
+		b.WriteString(": " + f.Reason)
+	}
+	return b.String()
+}
+
+// ApplyEdits applies edits in order to content. Search text is matched on
+// whole lines, exactly first and then ignoring whitespace; the line hint
+// picks between several matches. Edits whose replacement is already in
+// place (see isApplied) are skipped. Every failing edit is reported.
+func ApplyEdits(path, content string, edits []Edit) (string, error) {
+	lines := splitLines(content)
+	var failures []EditFailure
+	offset := 0 // lines added by earlier edits, to move line hints
+	for i, edit := range edits {
+		search, replace := splitLines(edit.Search), splitLines(edit.Replace)
+		hint := 0
+		if edit.Line > 0 {
+			hint = max(edit.Line+offset, 1)
+		}
+		if isApplied(lines, search, replace, hint) {
+			continue
+		}
+		start, err := locate(lines, search, hint)
+		if err != nil {
+			failures = append(failures, EditFailure{Edit: i + 1, Line: edit.Line, Reason: err.Error()})
+			continue
+		}
+		lines = append(lines[:start], append(append([]string{}, replace...), lines[start+len(search):]...)...)
+		offset += len(replace) - len(search)
+	}
+	if len(failures) > 0 {
+		return "", &EditError{Path: path, Edits: len(edits), Failures: failures}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// isApplied reports whether an edit was already made: its replacement is at
+// the hint, or the search text occurs only inside copies of the replacement
+// and at least once. A replacement that merely appears somewhere else does
+// not count, so such edits still fail in locate.
+func isApplied(lines, search, replace []string, hint int) bool {
+	if len(replace) == 0 || len(lines) == 0 {
+		return false
+	}
+	atHint := func(at int) bool {
+		return at >= 0 && at+len(replace) <= len(lines) && linesMatch(lines[at:at+len(replace)], replace, fuzzyLine)
+	}
+	if len(search) == 0 {
+		return hint > 0 && atHint(hint-1)
+	}
+	replaced := findAll(lines, replace, fuzzyLine)
+	if len(replaced) == 0 {
+		return false
+	}
+	found := findAll(lines, search, fuzzyLine)
+	for _, s := range found {
+		inside := false
+		for _, r := range replaced {
+			if s >= r && s+len(search) <= r+len(replace) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	return len(found) > 0 || (hint > 0 && atHint(hint-1))
+}
+
+// locate returns the index of the lines matching search.
+func locate(lines, search []string, hint int) (int, error) {
+	if len(search) == 0 {
+		switch {
+		case hint > 0:
+			return min(hint-1, len(lines)), nil
+		case len(lines) == 0:
+			return 0, nil
+		default:
+			return 0, fmt.Errorf("empty search text, but the file is not empty")
+		}
+	}
+
+	for _, equal := range []func(a, b string) bool{exactLine, fuzzyLine} {
+		matches := findAll(lines, search, equal)
+		switch {
+		case len(matches) == 1:
+			return matches[0], nil
+		case len(matches) > 1 && hint > 0:
+			return nearest(matches, hint-1), nil
+		case len(matches) > 1:
+			at := make([]string, len(matches))
+			for i, m := range matches {
+				at[i] = strconv.Itoa(m + 1)
+			}
+			return 0, fmt.Errorf("search text matches %d places (lines %s)", len(matches), strings.Join(at, ", "))
+		}
+	}
+
+	best, bestCount := -1, 0
+	for i := 0; i < len(lines); i++ {
+		count := 0
+		for j := 0; j < len(search) && i+j < len(lines); j++ {
+			if fuzzyLine(lines[i+j], search[j]) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("search text not found (%d lines, starting %q)", len(search), truncate(search[0], 60))
+	}
+	return 0, fmt.Errorf("search text not found; closest match at line %d has %d of %d lines matching", best+1, bestCount, len(search))
+}
+
+// findAll returns the indexes of the lines matching search.
+func findAll(lines, search []string, equal func(a, b string) bool) []int {
+	var matches []int
+	for i := 0; i+len(search) <= len(lines); i++ {
+		if linesMatch(lines[i:i+len(search)], search, equal) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func linesMatch(lines, search []string, equal func(a, b string) bool) bool {
+	for i := range search {
+		if !equal(lines[i], search[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func exactLine(a, b string) bool {
+	return a == b
+}
+
+// fuzzyLine compares lines ignoring indentation, trailing whitespace, line
+// endings and runs of spaces.
+func fuzzyLine(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+func nearest(matches []int, target int) int {
+	best := matches[0]
+	for _, m := range matches[1:] {
+		if abs(m-target) < abs(best-target) {
+			best = m
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// splitLines splits text into lines without their newlines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+var (
+	editBlockRegex  = regexp.MustCompile(`(?s)<edit\s+path=["']([^"']+)["']>(.*?)</edit>`)
+	patchTagRegex   = regexp.MustCompile(`(?s)<patch>\n?(.*?)</patch>`)
+	hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@`)
+	searchMarker    = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarker   = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker   = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// extractEdits parses search/replace blocks and <patch> unified diffs,
+// merged into one change per file in the order the files first appear.
+func extractEdits(providerLabel, response string) []FileChange {
+	var files []FileChange
+	index := make(map[string]int)
+	add := func(path string, edits []Edit) {
+		path = strings.TrimSpace(path)
+		if path == "" || len(edits) == 0 {
+			return
+		}
+		if i, ok := index[path]; ok {
+			files[i].Edits = append(files[i].Edits, edits...)
+			return
+		}
+		index[path] = len(files)
+		files = append(files, FileChange{Path: path, Edits: edits})
+	}
+
+	for _, match := range editBlockRegex.FindAllStringSubmatch(response, -1) {
+		add(match[1], parseSearchReplace(providerLabel, match[1], match[2]))
+	}
+
+	// Diffs elsewhere in a response, e.g. in a fence, often show changes a
+	// CLI already made to the workspace, so only <patch> is applied.
+	for _, match := range patchTagRegex.FindAllStringSubmatch(response, -1) {
+		for _, file := range parseUnifiedDiff(providerLabel, match[1]) {
+			add(file.Path, file.Edits)
+		}
+	}
+	return files
+}
+
+// parseSearchReplace parses the blocks of an <edit>:
+//
+//	<<<<<<< SEARCH
+//	lines of the current file
+//	=======
+//	replacement lines
+//	>>>>>>> REPLACE
+func parseSearchReplace(providerLabel, path, body string) []Edit {
+	var (
+		edits           []Edit
+		search, replace []string
+		state           int // 0 outside a block, 1 in search, 2 in replace
+	)
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimRight(line, "\r")
+		switch {
+		case state == 0 && searchMarker.MatchString(trimmed):
+			state, search, replace = 1, nil, nil
+		case state == 1 && dividerMarker.MatchString(trimmed):
+			state = 2
+		case state == 2 && replaceMarker.MatchString(trimmed):
+			edits = append(edits, Edit{Search: strings.Join(search, "\n"), Replace: strings.Join(replace, "\n")})
+			state = 0
+		case state == 1:
+			search = append(search, line)
+		case state == 2:
+			replace = append(replace, line)
+		}
+	}
+	if state != 0 {
+		logPlaceholder(providerLabel, "Ignoring unterminated search/replace block for %s", path)
+	}
+	return edits
+}
+
+// parseUnifiedDiff parses `diff -u` output. Hunk line counts are not
+// trusted: a hunk ends at the next hunk, file header or unrelated line.
+// Deleted files are not supported and skipped.
+func parseUnifiedDiff(providerLabel, diff string) []FileChange {
+	var (
+		files   []FileChange
+		current *FileChange
+		newFile bool // current is created by the diff
+		hunk    *Edit
+		search  []string
+		replace []string
+	)
+	flush := func() {
+		if hunk != nil && current != nil {
+			hunk.Search, hunk.Replace = strings.Join(search, "\n"), strings.Join(replace, "\n")
+			current.Edits = append(current.Edits, *hunk)
+		}
+		hunk, search, replace = nil, nil, nil
+	}
+
+	lines := strings.Split(diff, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			flush()
+			oldPath, newPath := diffPath(line[4:]), diffPath(strings.TrimRight(lines[i+1], "\r")[4:])
+			i++
+			current = nil
+			if newPath == "/dev/null" {
+				logPlaceholder(providerLabel, "Ignoring deletion of %s: deleting files is not supported", oldPath)
+				continue
+			}
+			files = append(files, FileChange{Path: newPath})
+			current, newFile = &files[len(files)-1], oldPath == "/dev/null"
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if strings.HasPrefix(line, "@@") {
+			flush()
+			hunk = &Edit{}
+			// New files have no line hint, so they fail if the file exists.
+			if m := hunkHeaderRegex.FindStringSubmatch(line); m != nil && !newFile {
+				start, _ := strconv.Atoi(m[1])
+				hunk.Line = start
+				if m[2] == "0" {
+					// Pure insertion after line start.
+					hunk.Line = start + 1
+				}
+			}
+			continue
+		}
+		if hunk == nil {
+			continue
+		}
+
+		switch {
+		case line == "":
+			// Blank context lines often lose their leading space.
+			search, replace = append(search, ""), append(replace, "")
+		case line[0] == ' ':
+			search, replace = append(search, line[1:]), append(replace, line[1:])
+		case line[0] == '-':
+			search = append(search, line[1:])
+		case line[0] == '+':
+			replace = append(replace, line[1:])
+		case line[0] == '\\':
+			// "\ No newline at end of file"
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	// A trailing blank line of the block is not part of the last hunk.
+	for i := range files {
+		if n := len(files[i].Edits); n > 0 {
+			last := &files[i].Edits[n-1]
+			for strings.HasSuffix(last.Search, "\n") && strings.HasSuffix(last.Replace, "\n") {
+				last.Search, last.Replace = strings.TrimSuffix(last.Search, "\n"), strings.TrimSuffix(last.Replace, "\n")
+			}
+		}
+	}
+
+	var withEdits []FileChange
+	for _, file := range files {
+		if len(file.Edits) > 0 {
+			withEdits = append(withEdits, file)
+		}
+	}
+	return withEdits
+}
+
+// diffPath returns the path of a file header without the a/ or b/ prefix
+// and a trailing timestamp.
+func diffPath(header string) string {
+	path := strings.TrimSpace(header)
+	if i := strings.IndexByte(path, '\t'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "/dev/null" {
+		return path
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
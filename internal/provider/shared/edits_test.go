@@ -0,0 +1,123 @@
+package shared
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEdits(t *testing.T) {
+	const content = "func a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n"
+	tests := []struct {
+		name  string
+		edits []Edit
+		want  string
+		err   string
+	}{
+		{
+			name:  "exact",
+			edits: []Edit{{Search: "func b() {\n\treturn", Replace: "func b() {\n\tlog()\n\treturn"}},
+			want:  "func a() {\n\treturn\n}\n\nfunc b() {\n\tlog()\n\treturn\n}\n",
+		},
+		{
+			name:  "whitespace differences",
+			edits: []Edit{{Search: "  func a()  {", Replace: "func a() error {"}},
+			want:  "func a() error {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n",
+		},
+		{
+			name:  "line hint picks the nearest match",
+			edits: []Edit{{Search: "\treturn", Replace: "\treturn nil", Line: 5}},
+			want:  "func a() {\n\treturn\n}\n\nfunc b() {\n\treturn nil\n}\n",
+		},
+		{
+			name: "hints move with earlier edits",
+			edits: []Edit{
+				{Search: "func a() {", Replace: "// a\nfunc a() {", Line: 1},
+				{Search: "\treturn", Replace: "\treturn nil", Line: 6},
+			},
+			want: "// a\nfunc a() {\n\treturn\n}\n\nfunc b() {\n\treturn nil\n}\n",
+		},
+		{
+			name:  "insertion",
+			edits: []Edit{{Replace: "// end", Line: 8}},
+			want:  content + "// end\n",
+		},
+		{
+			name: "already applied",
+			edits: []Edit{
+				{Search: "func a() {\n\treturn 1", Replace: "func a() {\n\treturn", Line: 1},
+				{Search: "func b() {", Replace: "func b() {\n\treturn", Line: 5},
+				{Replace: "\treturn", Line: 6},
+			},
+			want: content,
+		},
+		{
+			name:  "replacement elsewhere is not applied",
+			edits: []Edit{{Search: "\treturn errors.New(\"x\")", Replace: "\treturn"}},
+			err:   "edit 1: search text not found",
+		},
+		{
+			name:  "ambiguous",
+			edits: []Edit{{Search: "\treturn", Replace: "\treturn nil"}},
+			err:   "edit 1: search text matches 2 places (lines 2, 6)",
+		},
+		{
+			name: "every failure is reported",
+			edits: []Edit{
+				{Search: "func c() {", Replace: ""},
+				{Search: "func a() {", Replace: "func A() {"},
+				{Search: "func b() {\n\tpanic()\n}", Replace: "", Line: 5},
+			},
+			err: "f.go: 2 of 3 edits failed; edit 1: search text not found (1 lines, starting \"func c() {\"); " +
+				"edit 3 (line 5): search text not found; closest match at line 5 has 2 of 3 lines matching",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyEdits("f.go", content, tt.edits)
+			if tt.err != "" {
+				var editErr *EditError
+				if !errors.As(err, &editErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ApplyEdits() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ApplyEdits() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if got, err := ApplyEdits("new.go", "", []Edit{{Replace: "package x"}}); err != nil || got != "package x\n" {
+		t.Fatalf("ApplyEdits() on a new file = %q, %v", got, err)
+	}
+}
+
+func TestParseResponse_Edits(t *testing.T) {
+	response := "<edit path=\"main.go\">\n<<<<<<< SEARCH\n\tprintln(\"a\")\n=======\n\tprintln(\"b\")\n>>>>>>> REPLACE\n</edit>\n\n" +
+		"I changed:\n```diff\n--- a/cli.go\n+++ b/cli.go\n@@ -1 +1 @@\n-return 1\n+return 2\n```\n\n" +
+		"<patch>\n--- a/main.go\n+++ b/main.go\n@@ -10,3 +10,4 @@ func main() {\n x := 1\n-y := 2\n+y := 3\n+z := 4\n\n" +
+		"--- /dev/null\n+++ b/docs/new.md\n@@ -0,0 +1,2 @@\n+# New\n+text\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-old\n</patch>\n\n" +
+		"<file path=\"README.md\">\n<content>\nSee ```diff\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n```\n</content>\n</file>\n" +
+		"<summary>Update main</summary>"
+
+	result, err := ParseResponse("test", response)
+	if err != nil {
+		t.Fatalf("ParseResponse() error = %v", err)
+	}
+	want := []FileChange{
+		{Path: "README.md", Content: "See ```diff\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n```"},
+		{Path: "main.go", Edits: []Edit{
+			{Search: "\tprintln(\"a\")", Replace: "\tprintln(\"b\")"},
+			{Search: "x := 1\ny := 2", Replace: "x := 1\ny := 3\nz := 4", Line: 10},
+		}},
+		{Path: "docs/new.md", Edits: []Edit{{Replace: "# New\ntext"}}},
+	}
+	if !reflect.DeepEqual(result.Files, want) {
+		t.Fatalf("files = %#v\nwant %#v", result.Files, want)
+	}
+	if result.Summary != "Update main" {
+		t.Fatalf("summary = %q", result.Summary)
+	}
+}
@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxLineBytes bounds a buffered partial line. Longer lines are dropped: the
+// streaming parsers only need the small event lines, and the full output is
+// kept elsewhere.
+const maxLineBytes = 4 << 20
+
+// LineWriter calls a function for every complete line written to it, so the
+// JSON events of a streaming CLI can be handled while the command runs.
+type LineWriter struct {
+	mu       sync.Mutex
+	fn       func(line []byte)
+	buf      []byte
+	skipping bool
+}
+
+// NewLineWriter returns a writer passing each line, without its newline, to fn.
+func NewLineWriter(fn func(line []byte)) *LineWriter {
+	return &LineWriter{fn: fn}
+}
+
+// Write implements io.Writer. It never fails, so a slow or broken event
+// handler cannot stop the command.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buffer(p)
+			break
+		}
+		w.buffer(p[:i])
+		if !w.skipping {
+			w.fn(bytes.TrimRight(w.buf, "\r"))
+		}
+		w.buf, w.skipping = w.buf[:0], false
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Flush passes a final line without a trailing newline.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 && !w.skipping {
+		w.fn(bytes.TrimRight(w.buf, "\r"))
+	}
+	w.buf, w.skipping = w.buf[:0], false
+}
+
+func (w *LineWriter) buffer(p []byte) {
+	if w.skipping {
+		return
+	}
+	if len(w.buf)+len(p) > maxLineBytes {
+		w.buf, w.skipping = w.buf[:0], true
+		return
+	}
+	w.buf = append(w.buf, p...)
+}
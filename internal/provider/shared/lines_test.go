@@ -0,0 +1,24 @@
+package shared
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := NewLineWriter(func(line []byte) { lines = append(lines, string(line)) })
+
+	for _, chunk := range []string{`{"a":`, "1}\r\n{\"b\"", ":2}\n\n", strings.Repeat("x", maxLineBytes+1), "\nlast"} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+	w.Flush()
+
+	want := []string{`{"a":1}`, `{"b":2}`, "", "last"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("lines = %q, want %q (oversized line dropped)", lines, want)
+	}
+}
@@ -4,19 +4,33 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-// FileChange captures a single file edit extracted from a provider response.
+// FileChange captures a single file edit extracted from a provider response:
+// the whole new content, or edits to apply to the current file.
 type FileChange struct {
 	Path    string
 	Content string
+	Edits   []Edit
+}
+
+// ReviewComment is an inline pull request comment extracted from a provider response.
+// Line is the last commented line of the head revision, StartLine the first one
+// of a multi-line comment. Suggestions are already rendered into Body.
+type ReviewComment struct {
+	Path      string
+	Line      int
+	StartLine int
+	Body      string
 }
 
 // ParseResult contains the structured content extracted from a provider response.
 type ParseResult struct {
-	Files   []FileChange
-	Summary string
+	Files    []FileChange
+	Comments []ReviewComment
+	Summary  string
 }
 
 var (
@@ -30,6 +44,11 @@ var (
 		"entire updated file content here",
 	}
 
+	fileBlockRegex     = regexp.MustCompile(`(?s)<file\s+path=["']([^"']+)["']>\s*<content>\s*(.*?)\s*</content>\s*</file>`)
+	reviewCommentRegex = regexp.MustCompile(`(?s)<review_comment\s+([^>]*)>(.*?)</review_comment>`)
+	attributeRegex     = regexp.MustCompile(`(\w+)=["']([^"']*)["']`)
+	suggestionRegex    = regexp.MustCompile(`(?s)<suggestion>\n?(.*?)</suggestion>`)
+
 	placeholderSummaries = map[string]struct{}{
 		"brief description of changes made":     {},
 		"add user authentication to handler.go": {},
@@ -63,10 +82,19 @@ func ParseResponse(providerLabel, response string) (*ParseResult, error) {
 	}
 
 	files := extractXMLFileBlocks(text)
+	// Whole files may contain diffs themselves, e.g. in documentation.
+	files = append(files, extractEdits(providerLabel, fileBlockRegex.ReplaceAllString(text, ""))...)
 	if len(files) == 0 {
 		files = append(files, extractMarkdownFileBlocks(text)...)
 	}
 	files = filterPlaceholderFiles(providerLabel, files)
+	comments := extractReviewComments(text)
+	if len(comments) > 0 {
+		// Keep the comment markup out of a summary taken from the whole response.
+		if rest := strings.TrimSpace(reviewCommentRegex.ReplaceAllString(text, "")); rest != "" {
+			text = rest
+		}
+	}
 
 	hasFiles := len(files) > 0
 	summary := extractSummary(text, hasFiles)
@@ -90,6 +118,7 @@ func ParseResponse(providerLabel, response string) (*ParseResult, error) {
 	}
 
 	result.Files = files
+	result.Comments = comments
 	result.Summary = summary
 	return result, nil
 }
@@ -108,8 +137,7 @@ func ContainsPermissionRequest(text string) bool {
 func extractXMLFileBlocks(response string) []FileChange {
 	var files []FileChange
 
-	fileRegex := regexp.MustCompile(`(?s)<file\s+path=["']([^"']+)["']>\s*<content>\s*(.*?)\s*</content>\s*</file>`)
-	fileMatches := fileRegex.FindAllStringSubmatch(response, -1)
+	fileMatches := fileBlockRegex.FindAllStringSubmatch(response, -1)
 
 	for _, match := range fileMatches {
 		if len(match) >= 3 {
@@ -128,6 +156,40 @@ func extractXMLFileBlocks(response string) []FileChange {
 	return files
 }
 
+// extractReviewComments parses <review_comment path="..." line="N"> blocks. A
+// nested <suggestion> becomes a GitHub suggestion replacing the commented lines.
+func extractReviewComments(response string) []ReviewComment {
+	var comments []ReviewComment
+
+	for _, match := range reviewCommentRegex.FindAllStringSubmatch(response, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range attributeRegex.FindAllStringSubmatch(match[1], -1) {
+			attrs[attr[1]] = attr[2]
+		}
+
+		comment := ReviewComment{Path: strings.TrimSpace(attrs["path"])}
+		comment.Line, _ = strconv.Atoi(attrs["line"])
+		comment.StartLine, _ = strconv.Atoi(attrs["start_line"])
+		if comment.Path == "" || comment.Line <= 0 || isPlaceholderPath(comment.Path) {
+			continue
+		}
+		if comment.StartLine >= comment.Line {
+			comment.StartLine = 0
+		}
+
+		body := suggestionRegex.ReplaceAllStringFunc(match[2], func(block string) string {
+			code := suggestionRegex.FindStringSubmatch(block)[1]
+			return "\n```suggestion\n" + strings.TrimSuffix(code, "\n") + "\n```\n"
+		})
+		comment.Body = strings.TrimSpace(body)
+		if comment.Body != "" {
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments
+}
+
 func extractMarkdownFileBlocks(response string) []FileChange {
 	var files []FileChange
 
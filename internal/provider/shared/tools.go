@@ -0,0 +1,427 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+const (
+	maxReadBytes    = 256 << 10
+	maxCommandBytes = 64 << 10
+	maxGrepMatches  = 200
+	commandTimeout  = 5 * time.Minute
+)
+
+// DefaultCommands are the programs run_command may start when no allow-list is
+// configured. Interpreters and make, which run any code given on the command
+// line, are left out; build and test runners still run repository code.
+var DefaultCommands = []string{"go", "gofmt", "pytest", "cargo"}
+
+// toolEnv are the server variables run_command passes on: no credentials,
+// only what build tools need to find their toolchains, caches and proxies.
+var toolEnv = []string{
+	"PATH", "HOME", "TMPDIR", "LANG", "LC_*", "TERM", "TZ",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOPROXY", "GOFLAGS", "GOPRIVATE", "GOTOOLCHAIN",
+	"CARGO_HOME", "RUSTUP_HOME",
+}
+
+// Tool describes a tool offered to a model. Schema is the JSON schema of its input.
+type Tool struct {
+	Name        string
+	Description string
+	Schema      map[string]any
+}
+
+// Toolbox runs model tool calls inside a repository checkout. Paths are
+// relative to the root and may not leave it; run_command only starts programs
+// from the allow-list, with a scrubbed environment in every sandbox mode.
+type Toolbox struct {
+	root     string
+	commands map[string]bool
+	disabled map[string]bool
+	sandbox  *sandbox.Sandbox
+	written  map[string]bool
+}
+
+// NewToolbox creates a toolbox for root. An empty commands list uses DefaultCommands.
+func NewToolbox(root string, commands []string, sb *sandbox.Sandbox) *Toolbox {
+	if len(commands) == 0 {
+		commands = DefaultCommands
+	}
+	allowed := make(map[string]bool, len(commands))
+	for _, c := range commands {
+		allowed[strings.TrimSpace(c)] = true
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return &Toolbox{
+		root:     root,
+		commands: allowed,
+		disabled: make(map[string]bool),
+		sandbox:  sb,
+		written:  make(map[string]bool),
+	}
+}
+
+// Disable removes tools by name. The Claude CLI names "Bash", "Write" and
+// "Edit" from DISALLOWED_TOOLS map to run_command and write_file.
+func (t *Toolbox) Disable(names ...string) {
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "Bash", "run_command":
+			t.disabled["run_command"] = true
+		case "Write", "Edit", "MultiEdit", "write_file":
+			t.disabled["write_file"] = true
+		case "Read", "read_file":
+			t.disabled["read_file"] = true
+		case "LS", "list_dir":
+			t.disabled["list_dir"] = true
+		case "Grep", "grep":
+			t.disabled["grep"] = true
+		}
+	}
+}
+
+// Tools returns the enabled tools in a stable order.
+func (t *Toolbox) Tools() []Tool {
+	all := []Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a file of the repository. Paths are relative to the repository root.",
+			Schema:      objectSchema(map[string]any{"path": stringProp("File path relative to the repository root")}, "path"),
+		},
+		{
+			Name:        "write_file",
+			Description: "Create or overwrite a file with the complete new content.",
+			Schema: objectSchema(map[string]any{
+				"path":    stringProp("File path relative to the repository root"),
+				"content": stringProp("Complete file content"),
+			}, "path", "content"),
+		},
+		{
+			Name:        "list_dir",
+			Description: "List a directory of the repository. Directories end with a slash.",
+			Schema:      objectSchema(map[string]any{"path": stringProp("Directory relative to the repository root, \".\" for the root")}, "path"),
+		},
+		{
+			Name:        "grep",
+			Description: "Search files for a regular expression (Go syntax). Returns path:line: text matches.",
+			Schema: objectSchema(map[string]any{
+				"pattern": stringProp("Regular expression"),
+				"path":    stringProp("Optional directory or file to search, defaults to the repository root"),
+			}, "pattern"),
+		},
+		{
+			Name:        "run_command",
+			Description: "Run a program in the repository root without a shell. Allowed programs: " + strings.Join(t.allowedCommands(), ", ") + ".",
+			Schema: objectSchema(map[string]any{
+				"command": stringProp("Program name"),
+				"args":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Arguments"},
+			}, "command"),
+		},
+	}
+
+	tools := make([]Tool, 0, len(all))
+	for _, tool := range all {
+		if !t.disabled[tool.Name] {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// Run executes a tool call. The returned error is meant to be reported back to
+// the model as a failed tool result, not to abort the conversation.
+func (t *Toolbox) Run(ctx context.Context, name string, input json.RawMessage) (string, error) {
+	if t.disabled[name] {
+		return "", fmt.Errorf("tool %s is disabled", name)
+	}
+
+	var args struct {
+		Path    string   `json:"path"`
+		Content string   `json:"content"`
+		Pattern string   `json:"pattern"`
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input for %s: %w", name, err)
+		}
+	}
+
+	switch name {
+	case "read_file":
+		return t.readFile(args.Path)
+	case "write_file":
+		return t.writeFile(args.Path, args.Content)
+	case "list_dir":
+		return t.listDir(args.Path)
+	case "grep":
+		return t.grep(args.Pattern, args.Path)
+	case "run_command":
+		return t.runCommand(ctx, args.Command, args.Args)
+	default:
+		return "", fmt.Errorf("unknown tool %s", name)
+	}
+}
+
+// Written returns the files changed through write_file, sorted.
+func (t *Toolbox) Written() []string {
+	files := make([]string, 0, len(t.written))
+	for path := range t.written {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// resolve maps a model supplied path to an absolute path inside the root.
+// Symlinks are followed for the existing part of the path so they cannot
+// point outside the checkout.
+func (t *Toolbox) resolve(path string) (string, string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimSpace(path)))
+	if rel == "" || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path %q must be relative to the repository root", path)
+	}
+	if rel == ".git" || strings.HasPrefix(rel, ".git"+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path %q is inside .git", path)
+	}
+
+	root := t.root
+	abs := filepath.Join(root, rel)
+
+	existing := abs
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path %q leaves the repository", path)
+	}
+	return abs, filepath.ToSlash(rel), nil
+}
+
+func (t *Toolbox) readFile(path string) (string, error) {
+	abs, _, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxReadBytes {
+		return string(data[:maxReadBytes]) + fmt.Sprintf("\n[truncated: file has %d bytes]", len(data)), nil
+	}
+	return string(data), nil
+}
+
+func (t *Toolbox) writeFile(path, content string) (string, error) {
+	abs, rel, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(abs); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a directory", rel)
+		}
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(abs, []byte(content), mode); err != nil {
+		return "", err
+	}
+	t.written[rel] = true
+	return fmt.Sprintf("Wrote %d bytes to %s", len(content), rel), nil
+}
+
+func (t *Toolbox) listDir(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+	var abs string
+	if filepath.Clean(path) == "." {
+		abs = t.root
+	} else {
+		var err error
+		if abs, _, err = t.resolve(path); err != nil {
+			return "", err
+		}
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == ".git" {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, name)
+	}
+	if len(lines) == 0 {
+		return "(empty directory)", nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (t *Toolbox) grep(pattern, path string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	start := t.root
+	if strings.TrimSpace(path) != "" && filepath.Clean(path) != "." {
+		if start, _, err = t.resolve(path); err != nil {
+			return "", err
+		}
+	}
+
+	var matches []string
+	errLimit := fmt.Errorf("match limit reached")
+	walkErr := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			return nil
+		}
+		rel, _ := filepath.Rel(t.root, p)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxReadBytes)
+		for line := 1; scanner.Scan(); line++ {
+			if re.Match(scanner.Bytes()) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(rel), line, scanner.Text()))
+				if len(matches) >= maxGrepMatches {
+					return errLimit
+				}
+			}
+		}
+		return nil
+	})
+	if walkErr != nil && walkErr != errLimit {
+		return "", walkErr
+	}
+	if len(matches) == 0 {
+		return "No matches", nil
+	}
+	if walkErr == errLimit {
+		matches = append(matches, fmt.Sprintf("[stopped after %d matches]", maxGrepMatches))
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+func (t *Toolbox) runCommand(ctx context.Context, command string, args []string) (string, error) {
+	if !t.commands[command] || strings.ContainsAny(command, `/\`) {
+		return "", fmt.Errorf("command %q is not allowed (allowed: %s)", command, strings.Join(t.allowedCommands(), ", "))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	ctx, cancelSandbox := t.sandbox.WithTimeout(ctx)
+	defer cancelSandbox()
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = t.root
+	var buf bytes.Buffer
+	out := t.sandbox.LimitOutput(&limitBuffer{buf: &buf, max: maxCommandBytes})
+	cmd.Stdout, cmd.Stderr = out, out
+
+	// The command may run code the model wrote, so it gets toolEnv only,
+	// whatever the sandbox mode; Wrap then applies the sandbox on top.
+	cmd.Env = sandbox.FilterEnv(os.Environ(), toolEnv)
+	cleanup, err := t.sandbox.Wrap(cmd)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	err = cmd.Run()
+	output := buf.String()
+	if buf.Len() >= maxCommandBytes {
+		output += "\n[output truncated]"
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v\n%s", command, err, output)
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	return output, nil
+}
+
+func (t *Toolbox) allowedCommands() []string {
+	commands := make([]string, 0, len(t.commands))
+	for c := range t.commands {
+		commands = append(commands, c)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// limitBuffer keeps the first max bytes and discards the rest without failing
+// the writer, so a chatty command still runs to completion.
+type limitBuffer struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (l *limitBuffer) Write(p []byte) (int, error) {
+	if room := l.max - l.buf.Len(); room > 0 {
+		l.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+func stringProp(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
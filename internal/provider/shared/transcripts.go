@@ -0,0 +1,136 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// transcriptRetention is how long saved transcripts are kept. Sessions idle
+// for longer start over.
+const transcriptRetention = 30 * 24 * time.Hour
+
+// ErrNoTranscript is returned when a session has no transcript to copy.
+var ErrNoTranscript = errors.New("no transcript")
+
+// Transcripts keeps copies of CLI session transcripts. The sandbox HOME the
+// CLIs write them to is removed after every run, and each task runs in a new
+// working directory, so a session can only be resumed from a copy.
+type Transcripts struct {
+	Dir string // empty keeps nothing
+}
+
+// Save copies the transcript of session id, found under root, to the same
+// relative path in the directory and drops expired transcripts.
+func (t Transcripts) Save(root, id string) error {
+	if t.Dir == "" {
+		return nil
+	}
+	src, err := findTranscript(root, id)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, src)
+	if err != nil {
+		return err
+	}
+	if err := CopyFile(src, filepath.Join(t.Dir, rel)); err != nil {
+		return err
+	}
+	t.prune(time.Now().Add(-transcriptRetention))
+	return nil
+}
+
+// Find returns the saved transcript of session id and its path relative to
+// the directory.
+func (t Transcripts) Find(id string) (path, rel string, err error) {
+	if t.Dir == "" {
+		return "", "", fmt.Errorf("%w for session %s: transcripts are not kept", ErrNoTranscript, id)
+	}
+	if path, err = findTranscript(t.Dir, id); err != nil {
+		return "", "", err
+	}
+	rel, err = filepath.Rel(t.Dir, path)
+	return path, rel, err
+}
+
+// prune removes transcripts last saved before cutoff.
+func (t Transcripts) prune(cutoff time.Time) {
+	_ = filepath.WalkDir(t.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// findTranscript returns the file under root named after session id. Codex
+// prefixes the ID with a timestamp, Claude uses it as is.
+func findTranscript(root, id string) (string, error) {
+	if !ValidSessionID(id) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	suffix := id + ".jsonl"
+	var found string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), suffix) {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if found == "" {
+		return "", fmt.Errorf("%w for session %s in %s", ErrNoTranscript, id, root)
+	}
+	return found, nil
+}
+
+// ValidSessionID reports whether id is safe to use in file names. Session
+// IDs of the CLIs are UUIDs.
+func ValidSessionID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// CopyFile copies src to dst, creating the directories of dst.
+func CopyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o600)
+}
+
+// Getenv returns key from the environment of a command. Commands with a nil
+// environment inherit the server's, so key is read from there.
+func Getenv(env []string, key string) string {
+	if env == nil {
+		return os.Getenv(key)
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(env[i], key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package shared
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTranscripts_SaveFind(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "sessions", "2026", "10", "18", "rollout-2026-10-18T10-00-00-abc-123.jsonl")
+	if err := os.MkdirAll(filepath.Dir(src), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte(`{"type":"session_meta"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := Transcripts{Dir: filepath.Join(t.TempDir(), "codex")}
+	expired := filepath.Join(tr.Dir, "old.jsonl")
+	if err := CopyFile(src, expired); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+	old := time.Now().Add(-transcriptRetention - time.Hour)
+	os.Chtimes(expired, old, old)
+
+	if err := tr.Save(root, "abc-123"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	path, rel, err := tr.Find("abc-123")
+	if err != nil || rel != filepath.Join("sessions", "2026", "10", "18", "rollout-2026-10-18T10-00-00-abc-123.jsonl") {
+		t.Fatalf("Find() = %q, %q, %v, want the relative path kept", path, rel, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"type":"session_meta"}` {
+		t.Fatalf("saved transcript = %q", data)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatal("expired transcript was not pruned")
+	}
+
+	if _, _, err := tr.Find("other"); !errors.Is(err, ErrNoTranscript) {
+		t.Fatalf("Find(other) error = %v, want ErrNoTranscript", err)
+	}
+	if err := tr.Save(root, "../abc-123"); err == nil {
+		t.Fatal("Save() accepted a session ID with a path")
+	}
+	if err := (Transcripts{}).Save(root, "abc-123"); err != nil {
+		t.Fatalf("Save() without a directory error = %v", err)
+	}
+}
+
+func TestGetenv(t *testing.T) {
+	t.Setenv("SWE_TEST_HOME", "/server")
+	if got := Getenv(nil, "SWE_TEST_HOME"); got != "/server" {
+		t.Fatalf("Getenv(nil) = %q, want the server environment", got)
+	}
+	env := []string{"SWE_TEST_HOME=/a", "PATH=/bin", "SWE_TEST_HOME=/b"}
+	if got := Getenv(env, "SWE_TEST_HOME"); got != "/b" {
+		t.Fatalf("Getenv() = %q, want the last value", got)
+	}
+	if got := Getenv([]string{}, "SWE_TEST_HOME"); got != "" {
+		t.Fatalf("Getenv(empty) = %q", got)
+	}
+}
@@ -0,0 +1,201 @@
+package repoconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cexll/swe/internal/naming"
+	"github.com/cexll/swe/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// Path is the repository configuration file, read from the default branch.
+const Path = ".swe-agent.yml"
+
+// maxVerifyCommands bounds how many verification commands a repository may run.
+const maxVerifyCommands = 10
+
+// Review follow-up round limits: the default when enabled without max_rounds,
+// and the largest value a repository may configure.
+const (
+	defaultReviewRounds = 3
+	maxReviewRounds     = 10
+)
+
+var (
+	teamPattern  = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*/)?[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	loginPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*(\[bot\])?$`)
+)
+
+// Config holds per-repository settings. Every field is optional; zero values
+// fall back to the server configuration.
+type Config struct {
+	Provider           string      `yaml:"provider"`
+	Model              string      `yaml:"model"`
+	TriggerKeywords    []string    `yaml:"trigger_keywords"`
+	AllowedUsers       []string    `yaml:"allowed_users"`
+	AllowedTeams       []string    `yaml:"allowed_teams"`
+	DisallowedTools    []string    `yaml:"disallowed_tools"`
+	Split              SplitConfig `yaml:"split"`
+	Verify             []string    `yaml:"verify"`
+	BranchTemplate     string      `yaml:"branch_template"`
+	CommitTemplate     string      `yaml:"commit_template"`
+	PRTitleTemplate    string      `yaml:"pr_title_template"`
+	PRBodyTemplate     string      `yaml:"pr_body_template"`
+	PromptInstructions string      `yaml:"prompt_instructions"`
+
+	ReviewFollowup ReviewFollowupConfig `yaml:"review_followup"`
+}
+
+// SplitConfig overrides the multi-PR split thresholds.
+type SplitConfig struct {
+	MaxFiles int `yaml:"max_files"`
+	MaxLines int `yaml:"max_lines"`
+}
+
+// ReviewFollowupConfig lets the agent address "changes requested" reviews on
+// its own pull requests without a trigger comment.
+type ReviewFollowupConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	MaxRounds int  `yaml:"max_rounds"` // follow-up tasks per pull request branch
+}
+
+// Parse decodes and validates a configuration file. Unknown keys are rejected
+// so typos surface as errors instead of being silently ignored.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", Path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", Path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks field values and collects every problem into one error.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Provider != "" && !slices.Contains(provider.Names(), c.Provider) {
+		problems = append(problems, fmt.Sprintf("provider: unsupported value %q (supported: %s)", c.Provider, strings.Join(provider.Names(), ", ")))
+	}
+	if c.Model != "" && strings.ContainsAny(c.Model, " \t\n") {
+		problems = append(problems, fmt.Sprintf("model: invalid value %q", c.Model))
+	}
+	for _, keyword := range c.TriggerKeywords {
+		if strings.TrimSpace(keyword) == "" || strings.ContainsAny(keyword, " \t\n") {
+			problems = append(problems, fmt.Sprintf("trigger_keywords: invalid keyword %q", keyword))
+		}
+	}
+	for _, user := range c.AllowedUsers {
+		if !loginPattern.MatchString(user) {
+			problems = append(problems, fmt.Sprintf("allowed_users: invalid login %q", user))
+		}
+	}
+	for _, team := range c.AllowedTeams {
+		if !teamPattern.MatchString(team) {
+			problems = append(problems, fmt.Sprintf("allowed_teams: invalid team %q (expected slug or org/slug)", team))
+		}
+	}
+	for _, tool := range c.DisallowedTools {
+		if strings.TrimSpace(tool) == "" || strings.Contains(tool, ",") {
+			problems = append(problems, fmt.Sprintf("disallowed_tools: invalid tool %q", tool))
+		}
+	}
+	if c.Split.MaxFiles < 0 || c.Split.MaxLines < 0 {
+		problems = append(problems, "split: max_files and max_lines must not be negative")
+	}
+	if len(c.Verify) > maxVerifyCommands {
+		problems = append(problems, fmt.Sprintf("verify: at most %d commands are allowed", maxVerifyCommands))
+	}
+	for _, command := range c.Verify {
+		if strings.TrimSpace(command) == "" {
+			problems = append(problems, "verify: empty command")
+		}
+	}
+	if c.ReviewFollowup.MaxRounds < 0 || c.ReviewFollowup.MaxRounds > maxReviewRounds {
+		problems = append(problems, fmt.Sprintf("review_followup: max_rounds must be between 0 and %d", maxReviewRounds))
+	}
+	if err := c.Templates().Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Templates returns the naming templates set by the repository.
+func (c *Config) Templates() naming.Templates {
+	if c == nil {
+		return naming.Templates{}
+	}
+	return naming.Templates{
+		Branch:  c.BranchTemplate,
+		Commit:  c.CommitTemplate,
+		PRTitle: c.PRTitleTemplate,
+		PRBody:  c.PRBodyTemplate,
+	}
+}
+
+// Triggers returns the repository trigger keywords, or fallback when none are set.
+func (c *Config) Triggers(fallback string) []string {
+	if c == nil || len(c.TriggerKeywords) == 0 {
+		return []string{fallback}
+	}
+	return c.TriggerKeywords
+}
+
+// RestrictsUsers reports whether only listed users or teams may trigger tasks.
+func (c *Config) RestrictsUsers() bool {
+	return c != nil && (len(c.AllowedUsers) > 0 || len(c.AllowedTeams) > 0)
+}
+
+// AllowsUser reports whether the login is listed in allowed_users (case-insensitive).
+func (c *Config) AllowsUser(login string) bool {
+	if c == nil {
+		return false
+	}
+	for _, user := range c.AllowedUsers {
+		if strings.EqualFold(user, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitThresholds returns the split thresholds, falling back to the given defaults.
+func (c *Config) SplitThresholds(defaultFiles, defaultLines int) (int, int) {
+	if c == nil {
+		return defaultFiles, defaultLines
+	}
+	files, lines := defaultFiles, defaultLines
+	if c.Split.MaxFiles > 0 {
+		files = c.Split.MaxFiles
+	}
+	if c.Split.MaxLines > 0 {
+		lines = c.Split.MaxLines
+	}
+	return files, lines
+}
+
+// ReviewRounds returns how many review follow-ups a pull request branch may
+// get, or 0 when review follow-ups are disabled.
+func (c *Config) ReviewRounds() int {
+	if c == nil || !c.ReviewFollowup.Enabled {
+		return 0
+	}
+	if c.ReviewFollowup.MaxRounds > 0 {
+		return c.ReviewFollowup.MaxRounds
+	}
+	return defaultReviewRounds
+}
@@ -0,0 +1,117 @@
+package repoconfig
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/cexll/swe/internal/provider/all"
+)
+
+func TestParse_FullConfig(t *testing.T) {
+	cfg, err := Parse([]byte(`
+provider: codex
+model: gpt-5-codex
+trigger_keywords: ["/code", "@swe"]
+allowed_users: [alice, 'deploy-bot[bot]']
+allowed_teams: [acme/maintainers, reviewers]
+disallowed_tools: [Bash, WebFetch]
+split:
+  max_files: 4
+  max_lines: 150
+verify:
+  - go build ./...
+  - go test ./...
+branch_template: "feature/{{.Number}}"
+commit_template: "fix: {{.Summary}}"
+prompt_instructions: |
+  Always run gofmt.
+review_followup:
+  enabled: true
+  max_rounds: 2
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.Provider != "codex" || cfg.Model != "gpt-5-codex" {
+		t.Fatalf("provider/model = %s/%s", cfg.Provider, cfg.Model)
+	}
+	if got := cfg.Triggers("/default"); len(got) != 2 || got[1] != "@swe" {
+		t.Fatalf("Triggers() = %v", got)
+	}
+	if !cfg.RestrictsUsers() || !cfg.AllowsUser("ALICE") || cfg.AllowsUser("mallory") {
+		t.Fatal("allowed_users not applied")
+	}
+	if files, lines := cfg.SplitThresholds(8, 300); files != 4 || lines != 150 {
+		t.Fatalf("SplitThresholds() = %d, %d", files, lines)
+	}
+	if tpl := cfg.Templates(); tpl.Branch != "feature/{{.Number}}" || tpl.Commit != "fix: {{.Summary}}" {
+		t.Fatalf("Templates() = %+v", tpl)
+	}
+	if len(cfg.Verify) != 2 || !strings.Contains(cfg.PromptInstructions, "gofmt") {
+		t.Fatalf("verify/instructions not parsed: %+v", cfg)
+	}
+	if got := cfg.ReviewRounds(); got != 2 {
+		t.Fatalf("ReviewRounds() = %d, want 2", got)
+	}
+}
+
+func TestParse_EmptyFileUsesDefaults(t *testing.T) {
+	cfg, err := Parse([]byte("# nothing configured\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := cfg.Triggers("/code"); len(got) != 1 || got[0] != "/code" {
+		t.Fatalf("Triggers() = %v, want fallback", got)
+	}
+	if cfg.RestrictsUsers() {
+		t.Fatal("RestrictsUsers() = true for empty config")
+	}
+	if files, lines := cfg.SplitThresholds(8, 300); files != 8 || lines != 300 {
+		t.Fatalf("SplitThresholds() = %d, %d, want defaults", files, lines)
+	}
+	if got := cfg.ReviewRounds(); got != 0 {
+		t.Fatalf("ReviewRounds() = %d, want 0 when disabled", got)
+	}
+	cfg.ReviewFollowup.Enabled = true
+	if got := cfg.ReviewRounds(); got != defaultReviewRounds {
+		t.Fatalf("ReviewRounds() = %d, want default %d", got, defaultReviewRounds)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"unknown key", "triger_keywords: [/code]\n", "field triger_keywords not found"},
+		{"wrong type", "split: 5\n", "cannot unmarshal"},
+		{"bad provider", "provider: gemini\n", "provider: unsupported value"},
+		{"keyword with space", "trigger_keywords: ['/do it']\n", "trigger_keywords: invalid keyword"},
+		{"bad team", "allowed_teams: ['acme/team/extra']\n", "allowed_teams: invalid team"},
+		{"negative split", "split:\n  max_lines: -1\n", "split: max_files and max_lines must not be negative"},
+		{"bad template", "branch_template: '{{.Number'\n", "branch template:"},
+		{"bad commit template", "commit_template: '{{if}}'\n", "commit template:"},
+		{"empty verify", "verify: ['']\n", "verify: empty command"},
+		{"too many review rounds", "review_followup:\n  max_rounds: 50\n", "review_followup: max_rounds must be between 0 and 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			if err == nil {
+				t.Fatal("Parse() error = nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), Path) {
+				t.Fatalf("Parse() error = %q, want prefix %s and %q", err, Path, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilConfigHelpers(t *testing.T) {
+	var cfg *Config
+	if cfg.RestrictsUsers() || cfg.AllowsUser("a") {
+		t.Fatal("nil config must not restrict users")
+	}
+	if got := cfg.Triggers("/code"); got[0] != "/code" {
+		t.Fatalf("Triggers() = %v", got)
+	}
+}
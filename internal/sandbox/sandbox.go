@@ -0,0 +1,290 @@
+// Package sandbox runs provider CLIs with a scrubbed environment, a throwaway
+// HOME, resource limits and optionally a bubblewrap mount namespace.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes
+const (
+	ModeOff   = "off"   // scrubbed environment with the server HOME, no limits
+	ModeEnv   = "env"   // scrubbed environment, temp HOME, resource limits
+	ModeBwrap = "bwrap" // ModeEnv plus a bubblewrap namespace restricted to the workdir
+)
+
+// ErrOutputLimit reports that a process wrote more than MaxOutputBytes.
+var ErrOutputLimit = errors.New("sandbox: output limit exceeded")
+
+// defaultAllowEnv are passed through from the server environment in every mode.
+var defaultAllowEnv = []string{
+	"PATH", "LANG", "LC_ALL", "LC_CTYPE", "TERM", "TZ",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+}
+
+// hostEnv are also passed through in ModeOff, where the server HOME is kept,
+// so that CLI logins and configuration keep working.
+var hostEnv = []string{"HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME"}
+
+// systemPaths are mounted read-only in bubblewrap mode when they exist.
+var systemPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt"}
+
+// Config describes the sandbox.
+type Config struct {
+	Mode           string
+	AllowEnv       []string      // extra variables passed through; a trailing * matches a prefix
+	CPUSeconds     int           // RLIMIT_CPU, 0 = unlimited
+	MemoryMB       int           // RLIMIT_AS, 0 = unlimited
+	Timeout        time.Duration // wall-clock limit, 0 = caller's deadline only
+	MaxOutputBytes int64         // stdout+stderr cap, 0 = unlimited
+}
+
+// Sandbox wraps provider commands. A nil *Sandbox behaves like ModeOff
+// without extra allowed variables.
+type Sandbox struct {
+	cfg Config
+}
+
+// New validates cfg and returns a sandbox. ModeOff only keeps AllowEnv.
+func New(cfg Config) (*Sandbox, error) {
+	switch cfg.Mode {
+	case "", ModeOff:
+		return &Sandbox{cfg: Config{Mode: ModeOff, AllowEnv: cfg.AllowEnv}}, nil
+	case ModeEnv:
+	case ModeBwrap:
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return nil, fmt.Errorf("sandbox mode bwrap requires bubblewrap: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sandbox mode %q (supported: off, env, bwrap)", cfg.Mode)
+	}
+	if cfg.CPUSeconds < 0 || cfg.MemoryMB < 0 || cfg.Timeout < 0 || cfg.MaxOutputBytes < 0 {
+		return nil, fmt.Errorf("sandbox limits must not be negative")
+	}
+	return &Sandbox{cfg: cfg}, nil
+}
+
+// Mode reports the active mode.
+func (s *Sandbox) Mode() string {
+	if s == nil || s.cfg.Mode == "" {
+		return ModeOff
+	}
+	return s.cfg.Mode
+}
+
+// WithTimeout applies the wall-clock limit to ctx.
+func (s *Sandbox) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s == nil || s.cfg.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.cfg.Timeout)
+}
+
+// LimitOutput caps how much a process may write to w.
+func (s *Sandbox) LimitOutput(w io.Writer) io.Writer {
+	if s == nil || s.cfg.MaxOutputBytes <= 0 {
+		return w
+	}
+	return &limitedWriter{w: w, remaining: s.cfg.MaxOutputBytes}
+}
+
+// Wrap rewrites cmd to run inside the sandbox. passEnv names variables the
+// provider needs (API keys, endpoints); they are taken from cmd.Env when set,
+// otherwise from the server environment. Every mode scrubs the environment;
+// ModeOff keeps the server HOME and changes nothing else. The returned cleanup
+// removes the temp HOME and must be called after the command finishes.
+func (s *Sandbox) Wrap(cmd *exec.Cmd, passEnv ...string) (func(), error) {
+	if s.Mode() == ModeOff {
+		var allow []string
+		if s != nil {
+			allow = s.cfg.AllowEnv
+		}
+		cmd.Env = FilterEnv(commandEnv(cmd), append(append(append(append([]string{}, defaultAllowEnv...), hostEnv...), allow...), passEnv...))
+		return func() {}, nil
+	}
+
+	home, err := os.MkdirTemp("", "swe-sandbox-home-")
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: failed to create home: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(home) }
+	tmp := filepath.Join(home, "tmp")
+	if err := os.Mkdir(tmp, 0o700); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("sandbox: failed to create tmp: %w", err)
+	}
+
+	allow := append(append(append([]string{}, defaultAllowEnv...), s.cfg.AllowEnv...), passEnv...)
+	cmd.Env = append(FilterEnv(commandEnv(cmd), allow),
+		"HOME="+home,
+		"TMPDIR="+tmp,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"XDG_CACHE_HOME="+filepath.Join(home, ".cache"),
+	)
+
+	args := append([]string{cmd.Path}, cmd.Args[1:]...)
+	if limits := s.ulimitScript(); limits != "" {
+		args = append([]string{"/bin/sh", "-c", limits + `exec "$0" "$@"`}, args...)
+	}
+	if s.cfg.Mode == ModeBwrap {
+		args = append(s.bwrapArgs(cmd.Dir, home), args...)
+	}
+
+	if args[0] != cmd.Path {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("sandbox: %w", err)
+		}
+		cmd.Path = path
+		cmd.Args = args
+	}
+	return cleanup, nil
+}
+
+func (s *Sandbox) ulimitScript() string {
+	var b strings.Builder
+	if s.cfg.CPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d || exit 126; ", s.cfg.CPUSeconds)
+	}
+	if s.cfg.MemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d || exit 126; ", s.cfg.MemoryMB*1024)
+	}
+	return b.String()
+}
+
+// bwrapArgs mounts system directories read-only and only the workdir, its git
+// directory and the temp HOME writable. Network stays available for provider APIs.
+func (s *Sandbox) bwrapArgs(workdir, home string) []string {
+	args := []string{"bwrap", "--die-with-parent", "--unshare-all", "--share-net", "--new-session"}
+	for _, p := range systemPaths {
+		args = append(args, "--ro-bind-try", p, p)
+	}
+	args = append(args, "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp")
+	args = append(args, "--bind", home, home)
+	if workdir != "" {
+		args = append(args, "--bind", workdir, workdir)
+		if commonDir, gitDir := gitDirs(workdir); commonDir != "" && !within(commonDir, workdir) {
+			// Worktrees from the workspace cache keep objects in the shared mirror.
+			// Its hooks and config stay read-only, so nothing the task plants there
+			// runs in later tasks or in the server's own git commands.
+			args = append(args, "--ro-bind", commonDir, commonDir)
+			for _, dir := range []string{filepath.Join(commonDir, "objects"), filepath.Join(commonDir, "refs"), gitDir} {
+				if dir != commonDir && within(dir, commonDir) {
+					args = append(args, "--bind", dir, dir)
+				}
+			}
+		}
+		args = append(args, "--chdir", workdir)
+	}
+	return append(args, "--")
+}
+
+// gitDirs returns the common git directory of workdir and its own git
+// directory, which differ for worktrees.
+func gitDirs(workdir string) (commonDir, gitDir string) {
+	cmd := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-common-dir", "--git-dir")
+	cmd.Dir = workdir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return "", ""
+	}
+	return lines[0], lines[1]
+}
+
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// commandEnv returns the environment cmd would run with.
+func commandEnv(cmd *exec.Cmd) []string {
+	if cmd.Env == nil {
+		return os.Environ()
+	}
+	return cmd.Env
+}
+
+// FilterEnv keeps only the allowed variables. A trailing * in allow matches a prefix.
+// Later duplicates win, matching exec.Cmd semantics.
+func FilterEnv(env, allow []string) []string {
+	var out []string
+	seen := make(map[string]int)
+	for _, kv := range env {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || !allowed(key, allow) {
+			continue
+		}
+		if i, dup := seen[key]; dup {
+			out[i] = kv
+			continue
+		}
+		seen[key] = len(out)
+		out = append(out, kv)
+	}
+	return out
+}
+
+func allowed(key string, allow []string) bool {
+	for _, a := range allow {
+		a = strings.TrimSpace(a)
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if prefix != "" && strings.HasPrefix(key, prefix) {
+				return true
+			}
+			continue
+		}
+		if key == a {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputExceeded reports whether w is a LimitOutput writer whose budget ran out.
+// The child usually dies of SIGPIPE first, so callers check this to report the real cause.
+func OutputExceeded(w io.Writer) bool {
+	l, ok := w.(*limitedWriter)
+	if !ok {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.exceeded
+}
+
+// limitedWriter forwards writes until the budget is spent, then fails so
+// os/exec closes the pipe and the process stops.
+type limitedWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if int64(len(p)) > l.remaining {
+		n, _ := l.w.Write(p[:l.remaining])
+		l.remaining = 0
+		l.exceeded = true
+		return n, ErrOutputLimit
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
@@ -0,0 +1,77 @@
+package secretscan
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseUnifiedDiff extracts added lines from `git diff` output.
+// Only "+" lines are returned; context and removed lines are ignored.
+func ParseUnifiedDiff(diff string) []AddedLine {
+	var (
+		lines   []AddedLine
+		current string
+		lineNo  int
+	)
+
+	for _, raw := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(raw, "diff --git "):
+			current = ""
+			lineNo = 0
+		case strings.HasPrefix(raw, "+++ "):
+			target := strings.TrimPrefix(raw, "+++ ")
+			if target == "/dev/null" {
+				current = ""
+				continue
+			}
+			current = strings.TrimPrefix(target, "b/")
+		case strings.HasPrefix(raw, "--- "):
+			continue
+		case strings.HasPrefix(raw, "@@"):
+			lineNo = parseHunkStart(raw)
+		case strings.HasPrefix(raw, "+"):
+			if current == "" {
+				continue
+			}
+			lines = append(lines, AddedLine{Path: current, Line: lineNo, Text: raw[1:]})
+			lineNo++
+		case strings.HasPrefix(raw, "-"), strings.HasPrefix(raw, "\\"):
+			continue
+		default:
+			if lineNo > 0 {
+				lineNo++
+			}
+		}
+	}
+
+	return lines
+}
+
+// parseHunkStart returns the first new-file line number of a hunk header
+// such as "@@ -10,2 +12,3 @@".
+func parseHunkStart(header string) int {
+	idx := strings.Index(header, "+")
+	if idx == -1 {
+		return 0
+	}
+	rest := header[idx+1:]
+	end := strings.IndexAny(rest, ", @")
+	if end == -1 {
+		end = len(rest)
+	}
+	n, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// FileLines converts the content of a new file into added lines.
+func FileLines(path, content string) []AddedLine {
+	var lines []AddedLine
+	for i, text := range strings.Split(content, "\n") {
+		lines = append(lines, AddedLine{Path: path, Line: i + 1, Text: text})
+	}
+	return lines
+}
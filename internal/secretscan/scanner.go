@@ -0,0 +1,350 @@
+package secretscan
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+// Rule describes a built-in secret pattern.
+type Rule struct {
+	ID          string
+	Description string
+	Pattern     *regexp.Regexp
+}
+
+// AddedLine is a single line introduced by a change set.
+type AddedLine struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Finding reports a potential secret detected in a change set.
+// Match never contains the full secret value.
+type Finding struct {
+	RuleID string
+	Path   string
+	Line   int
+	Match  string
+}
+
+// String renders the finding in a form that is safe to show to users.
+func (f Finding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d [%s] %s", f.Path, f.Line, f.RuleID, f.Match)
+	}
+	return fmt.Sprintf("%s [%s] %s", f.Path, f.RuleID, f.Match)
+}
+
+var builtinRules = []Rule{
+	{
+		ID:          "private-key",
+		Description: "PEM encoded private key",
+		Pattern:     regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		ID:          "github-token",
+		Description: "GitHub personal, OAuth, app or refresh token",
+		Pattern:     regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	},
+	{
+		ID:          "aws-access-key",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`),
+	},
+	{
+		ID:          "aws-secret-key",
+		Description: "AWS secret access key assignment",
+		Pattern:     regexp.MustCompile(`(?i)aws_?secret_?access_?key\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})["']?`),
+	},
+	{
+		ID:          "anthropic-key",
+		Description: "Anthropic API key",
+		Pattern:     regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{20,}`),
+	},
+	{
+		ID:          "openai-key",
+		Description: "OpenAI API key",
+		Pattern:     regexp.MustCompile(`\bsk-[A-Za-z0-9]{20}T3BlbkFJ[A-Za-z0-9]{20}\b|\bsk-(?:proj|svcacct|admin)-[A-Za-z0-9_\-]{40,}`),
+	},
+	{
+		ID:          "slack-token",
+		Description: "Slack token",
+		Pattern:     regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	},
+}
+
+// genericAssignment matches "<secret-like name> = <value>" so that high-entropy values
+// are only reported when they look like credentials.
+var genericAssignment = regexp.MustCompile(`(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|credential|auth)[A-Za-z0-9_\-]*["']?\s*[:=]\s*["']?([A-Za-z0-9+/=_\-\.]{20,})["']?`)
+
+// sensitiveFileGlobs lists file names that should never be committed by the agent.
+var sensitiveFileGlobs = []string{
+	".env",
+	".env.*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"id_rsa",
+	"id_dsa",
+	"id_ecdsa",
+	"id_ed25519",
+	".npmrc",
+	".pypirc",
+	".netrc",
+}
+
+// sensitiveFileExceptions are template files that commonly match sensitiveFileGlobs.
+var sensitiveFileExceptions = []string{
+	".env.example",
+	".env.sample",
+	".env.template",
+	".env.dist",
+}
+
+// Scanner checks change sets for secrets.
+type Scanner struct {
+	rules            []Rule
+	allow            AllowList
+	known            []string
+	entropyThreshold float64
+}
+
+// New creates a scanner with the built-in rules.
+func New() *Scanner {
+	return &Scanner{
+		rules:            builtinRules,
+		entropyThreshold: 4.0,
+	}
+}
+
+// WithAllowList returns a copy of the scanner that ignores findings matching the allow list.
+func (s *Scanner) WithAllowList(allow AllowList) *Scanner {
+	clone := *s
+	clone.allow = allow
+	return &clone
+}
+
+// WithKnownSecrets returns a copy of the scanner that also reports exact occurrences
+// of the provided values (installation tokens, API keys from the environment, ...).
+// Values shorter than 8 characters are ignored to avoid noisy matches.
+func (s *Scanner) WithKnownSecrets(values ...string) *Scanner {
+	clone := *s
+	clone.known = append([]string(nil), s.known...)
+	for _, value := range values {
+		for _, candidate := range knownSecretCandidates(value) {
+			clone.known = append(clone.known, candidate)
+		}
+	}
+	// Longest first so that redaction replaces the whole value before any substring.
+	sort.SliceStable(clone.known, func(i, j int) bool {
+		return len(clone.known[i]) > len(clone.known[j])
+	})
+	return &clone
+}
+
+func knownSecretCandidates(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	// Multi-line values (PEM keys) are matched line by line since diffs are line based.
+	if strings.Contains(value, "\n") {
+		var out []string
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) < 20 || strings.HasPrefix(line, "-----") {
+				continue
+			}
+			out = append(out, line)
+		}
+		return out
+	}
+
+	if len(value) < 8 {
+		return nil
+	}
+	return []string{value}
+}
+
+// Scan inspects added lines and returns all findings not covered by the allow list.
+func (s *Scanner) Scan(lines []AddedLine) []Finding {
+	var findings []Finding
+	seenPaths := make(map[string]struct{})
+
+	for _, line := range lines {
+		if _, seen := seenPaths[line.Path]; !seen {
+			seenPaths[line.Path] = struct{}{}
+			if f, ok := s.checkPath(line.Path); ok {
+				findings = append(findings, f)
+			}
+		}
+		findings = append(findings, s.checkLine(line)...)
+	}
+
+	return findings
+}
+
+// ScanPaths reports sensitive files by name only (e.g. binary files whose content is not scanned).
+func (s *Scanner) ScanPaths(paths []string) []Finding {
+	var findings []Finding
+	for _, p := range paths {
+		if f, ok := s.checkPath(p); ok {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (s *Scanner) checkPath(filePath string) (Finding, bool) {
+	if !isSensitiveFile(filePath) {
+		return Finding{}, false
+	}
+	f := Finding{RuleID: "sensitive-file", Path: filePath, Match: path.Base(filePath)}
+	if s.allow.Allows(f, "") {
+		return Finding{}, false
+	}
+	return f, true
+}
+
+func (s *Scanner) checkLine(line AddedLine) []Finding {
+	var findings []Finding
+	reported := make(map[string]struct{})
+	report := func(ruleID, value string) {
+		if _, dup := reported[value]; dup {
+			return
+		}
+		reported[value] = struct{}{}
+		f := Finding{RuleID: ruleID, Path: line.Path, Line: line.Line, Match: mask(value)}
+		if s.allow.Allows(f, value) {
+			return
+		}
+		findings = append(findings, f)
+	}
+
+	for _, known := range s.known {
+		if strings.Contains(line.Text, known) {
+			report("known-secret", known)
+		}
+	}
+
+	matchedBuiltin := false
+	for _, rule := range s.rules {
+		for _, match := range rule.Pattern.FindAllStringSubmatch(line.Text, -1) {
+			value := match[0]
+			if len(match) > 1 && match[1] != "" {
+				value = match[1]
+			}
+			report(rule.ID, value)
+			matchedBuiltin = true
+		}
+	}
+
+	if !matchedBuiltin {
+		for _, match := range genericAssignment.FindAllStringSubmatch(line.Text, -1) {
+			value := match[1]
+			if looksLikePlaceholder(value) {
+				continue
+			}
+			if shannonEntropy(value) >= s.entropyThreshold {
+				report("high-entropy", value)
+			}
+		}
+	}
+
+	return findings
+}
+
+// Redact replaces every detected secret, including high-entropy credential
+// assignments, and known secret values in text.
+func (s *Scanner) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+
+	for _, known := range s.known {
+		text = strings.ReplaceAll(text, known, redactedPlaceholder)
+	}
+
+	for _, rule := range s.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			sub := rule.Pattern.FindStringSubmatch(match)
+			if len(sub) > 1 && sub[1] != "" {
+				return strings.Replace(match, sub[1], redactedPlaceholder, 1)
+			}
+			return redactedPlaceholder
+		})
+	}
+
+	// The same high-entropy check as checkLine, so a value the scan blocked
+	// cannot be echoed back in a comment.
+	text = genericAssignment.ReplaceAllStringFunc(text, func(match string) string {
+		value := genericAssignment.FindStringSubmatch(match)[1]
+		if looksLikePlaceholder(value) || shannonEntropy(value) < s.entropyThreshold {
+			return match
+		}
+		return strings.Replace(match, value, redactedPlaceholder, 1)
+	})
+
+	return text
+}
+
+func isSensitiveFile(filePath string) bool {
+	base := path.Base(strings.ReplaceAll(filePath, "\\", "/"))
+	for _, exception := range sensitiveFileExceptions {
+		if base == exception {
+			return false
+		}
+	}
+	for _, glob := range sensitiveFileGlobs {
+		if ok, _ := path.Match(glob, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mask keeps a short prefix so users can locate the value without exposing it.
+func mask(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 8 {
+		return "****"
+	}
+	return string(runes[:4]) + "****"
+}
+
+func looksLikePlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, marker := range []string{"example", "placeholder", "your-", "your_", "xxxx", "changeme", "dummy", "${", "<"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
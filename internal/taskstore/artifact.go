@@ -0,0 +1,194 @@
+package taskstore
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ArtifactKind identifies an output kept for a task.
+type ArtifactKind string
+
+const (
+	ArtifactDiff         ArtifactKind = "diff"          // final unified diff of the changes
+	ArtifactResponse     ArtifactKind = "response"      // raw provider response
+	ArtifactSystemPrompt ArtifactKind = "system_prompt" // rendered system prompt
+	ArtifactUserPrompt   ArtifactKind = "user_prompt"   // rendered user prompt
+	ArtifactVerification ArtifactKind = "verification"  // output of the repository verify commands
+)
+
+var artifactFileNames = map[ArtifactKind]string{
+	ArtifactDiff:         "changes.diff",
+	ArtifactResponse:     "response.txt",
+	ArtifactSystemPrompt: "system-prompt.md",
+	ArtifactUserPrompt:   "user-prompt.md",
+	ArtifactVerification: "verification.log",
+}
+
+// FileName is the name used when the artifact is downloaded.
+func (k ArtifactKind) FileName() string {
+	if name, ok := artifactFileNames[k]; ok {
+		return name
+	}
+	return string(k) + ".txt"
+}
+
+// Valid reports whether k is a known artifact kind.
+func (k ArtifactKind) Valid() bool {
+	_, ok := artifactFileNames[k]
+	return ok
+}
+
+// Artifact is a blob stored for a task. Each task keeps at most one artifact per kind.
+type Artifact struct {
+	TaskID    string
+	Kind      ArtifactKind
+	SHA256    string // digest of the stored data
+	Size      int64  // stored size in bytes
+	Truncated bool   // data was cut to ArtifactRetention.MaxArtifactBytes
+	CreatedAt time.Time
+	Data      []byte
+}
+
+// Name returns the download file name of the artifact.
+func (a *Artifact) Name() string {
+	return a.Kind.FileName()
+}
+
+// ArtifactRetention bounds how much artifact data the store keeps. Zero values disable a limit.
+type ArtifactRetention struct {
+	MaxAge           time.Duration // artifacts older than this are deleted
+	MaxTotalBytes    int64         // oldest artifacts are deleted once the total exceeds this
+	MaxArtifactBytes int64         // larger artifacts are truncated
+}
+
+// SetArtifactRetention sets the limits applied whenever an artifact is saved.
+func (s *Store) SetArtifactRetention(r ArtifactRetention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = r
+}
+
+// SaveArtifact stores data as the artifact of the given kind, replacing an earlier one,
+// and then applies the retention limits.
+func (s *Store) SaveArtifact(taskID string, kind ArtifactKind, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	truncated := false
+	if max := s.retention.MaxArtifactBytes; max > 0 && int64(len(data)) > max {
+		data, truncated = data[:max], true
+	}
+	sum := sha256.Sum256(data)
+
+	_, err := s.db.Exec(`
+		INSERT INTO artifacts (task_id, kind, sha256, size, truncated, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id, kind) DO UPDATE SET
+			sha256 = excluded.sha256, size = excluded.size, truncated = excluded.truncated,
+			data = excluded.data, created_at = excluded.created_at
+	`, taskID, kind, hex.EncodeToString(sum[:]), len(data), truncated, data, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save artifact: %w", err)
+	}
+
+	if err := s.pruneArtifacts(); err != nil {
+		log.Printf("Error pruning artifacts: %v", err)
+	}
+	return nil
+}
+
+// ListArtifacts returns the artifacts of a task ordered by kind.
+// Data is not loaded; use GetArtifact for the content.
+func (s *Store) ListArtifacts(taskID string) []*Artifact {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT task_id, kind, sha256, size, truncated, created_at
+		FROM artifacts WHERE task_id = ? ORDER BY kind
+	`, taskID)
+	if err != nil {
+		log.Printf("Error listing artifacts for task %s: %v", taskID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var artifacts []*Artifact
+	for rows.Next() {
+		a := &Artifact{}
+		if err := rows.Scan(&a.TaskID, &a.Kind, &a.SHA256, &a.Size, &a.Truncated, &a.CreatedAt); err != nil {
+			log.Printf("Error scanning artifact for task %s: %v", taskID, err)
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts
+}
+
+// GetArtifact returns the artifact of the given kind including its data.
+func (s *Store) GetArtifact(taskID string, kind ArtifactKind) (*Artifact, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a := &Artifact{}
+	err := s.db.QueryRow(`
+		SELECT task_id, kind, sha256, size, truncated, created_at, data
+		FROM artifacts WHERE task_id = ? AND kind = ?
+	`, taskID, kind).Scan(&a.TaskID, &a.Kind, &a.SHA256, &a.Size, &a.Truncated, &a.CreatedAt, &a.Data)
+	if err == sql.ErrNoRows {
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting artifact %s for task %s: %v", kind, taskID, err)
+		return nil, false
+	}
+	return a, true
+}
+
+// pruneArtifacts deletes expired artifacts and then the oldest ones until the
+// total size fits. Callers must hold s.mu.
+func (s *Store) pruneArtifacts() error {
+	if s.retention.MaxAge > 0 {
+		if _, err := s.db.Exec(`DELETE FROM artifacts WHERE created_at < ?`, time.Now().Add(-s.retention.MaxAge)); err != nil {
+			return err
+		}
+	}
+	if s.retention.MaxTotalBytes <= 0 {
+		return nil
+	}
+
+	var total int64
+	if err := s.db.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM artifacts`).Scan(&total); err != nil {
+		return err
+	}
+	if total <= s.retention.MaxTotalBytes {
+		return nil
+	}
+
+	rows, err := s.db.Query(`SELECT id, size FROM artifacts ORDER BY created_at ASC, id ASC`)
+	if err != nil {
+		return err
+	}
+	var victims []int64
+	for rows.Next() && total > s.retention.MaxTotalBytes {
+		var id, size int64
+		if err := rows.Scan(&id, &size); err != nil {
+			rows.Close()
+			return err
+		}
+		victims = append(victims, id)
+		total -= size
+	}
+	rows.Close()
+
+	for _, id := range victims {
+		if _, err := s.db.Exec(`DELETE FROM artifacts WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
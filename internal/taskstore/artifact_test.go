@@ -0,0 +1,90 @@
+package taskstore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newArtifactStore(t *testing.T, taskIDs ...string) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, id := range taskIDs {
+		if err := store.Create(&Task{ID: id, Title: id, Status: StatusPending, RepoOwner: "owner", RepoName: "repo", IssueNumber: 1, Actor: "user"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestArtifact_SaveListGet(t *testing.T) {
+	store := newArtifactStore(t, "task-1")
+
+	if err := store.SaveArtifact("task-1", ArtifactDiff, []byte("old")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+	diff := []byte("diff --git a/x b/x\n+new\n")
+	if err := store.SaveArtifact("task-1", ArtifactDiff, diff); err != nil {
+		t.Fatalf("SaveArtifact() replace error = %v", err)
+	}
+	if err := store.SaveArtifact("task-1", ArtifactResponse, []byte("raw")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+
+	list := store.ListArtifacts("task-1")
+	if len(list) != 2 || list[0].Kind != ArtifactDiff || list[1].Kind != ArtifactResponse {
+		t.Fatalf("ListArtifacts() = %+v, want diff and response", list)
+	}
+	if list[0].Data != nil || list[0].Size != int64(len(diff)) || len(list[0].SHA256) != 64 {
+		t.Fatalf("listed artifact = %+v", list[0])
+	}
+
+	got, ok := store.GetArtifact("task-1", ArtifactDiff)
+	if !ok || string(got.Data) != string(diff) || got.Name() != "changes.diff" {
+		t.Fatalf("GetArtifact() = %+v, %v", got, ok)
+	}
+	if _, ok := store.GetArtifact("task-1", ArtifactVerification); ok {
+		t.Fatal("GetArtifact() found an artifact that was never saved")
+	}
+}
+
+func TestArtifact_RetentionLimits(t *testing.T) {
+	store := newArtifactStore(t, "old", "new")
+	store.SetArtifactRetention(ArtifactRetention{MaxTotalBytes: 10, MaxArtifactBytes: 6})
+
+	if err := store.SaveArtifact("old", ArtifactResponse, []byte("123456789")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+	a, ok := store.GetArtifact("old", ArtifactResponse)
+	if !ok || !a.Truncated || string(a.Data) != "123456" {
+		t.Fatalf("truncated artifact = %+v", a)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if err := store.SaveArtifact("new", ArtifactDiff, []byte(strings.Repeat("x", 6))); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+	if _, ok := store.GetArtifact("old", ArtifactResponse); ok {
+		t.Fatal("oldest artifact should be pruned once the total size is exceeded")
+	}
+	if _, ok := store.GetArtifact("new", ArtifactDiff); !ok {
+		t.Fatal("newest artifact should be kept")
+	}
+
+	store.SetArtifactRetention(ArtifactRetention{MaxAge: 200 * time.Millisecond})
+	time.Sleep(250 * time.Millisecond)
+	if err := store.SaveArtifact("old", ArtifactUserPrompt, []byte("prompt")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+	if _, ok := store.GetArtifact("new", ArtifactDiff); ok {
+		t.Fatal("expired artifact should be pruned")
+	}
+	if _, ok := store.GetArtifact("old", ArtifactUserPrompt); !ok {
+		t.Fatal("fresh artifact should be kept")
+	}
+}
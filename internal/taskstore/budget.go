@@ -0,0 +1,144 @@
+package taskstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// BudgetScope is what a budget is charged to.
+type BudgetScope string
+
+const (
+	ScopeInstallation BudgetScope = "installation" // subject is the GitHub App installation ID
+	ScopeRepo         BudgetScope = "repo"         // subject is owner/name
+	ScopeUser         BudgetScope = "user"         // subject is the login of the triggering user
+)
+
+// BudgetPeriod is the window a budget applies to. Periods start at midnight UTC.
+type BudgetPeriod string
+
+const (
+	PeriodDay   BudgetPeriod = "day"
+	PeriodMonth BudgetPeriod = "month"
+)
+
+// AnySubject is the subject of a budget that applies to every subject of its
+// scope without a budget of its own.
+const AnySubject = "*"
+
+// Budget caps the spend of one subject per period. A zero limit is unlimited.
+type Budget struct {
+	Scope     BudgetScope
+	Subject   string
+	Period    BudgetPeriod
+	MaxUSD    float64
+	MaxTokens int
+}
+
+// Validate checks the scope, period and limits.
+func (b Budget) Validate() error {
+	switch b.Scope {
+	case ScopeInstallation, ScopeRepo, ScopeUser:
+	default:
+		return fmt.Errorf("invalid budget scope %q (supported: installation, repo, user)", b.Scope)
+	}
+	switch b.Period {
+	case PeriodDay, PeriodMonth:
+	default:
+		return fmt.Errorf("invalid budget period %q (supported: day, month)", b.Period)
+	}
+	if b.Subject == "" {
+		return fmt.Errorf("budget subject is required (use %q for every %s)", AnySubject, b.Scope)
+	}
+	if b.MaxUSD < 0 || b.MaxTokens < 0 {
+		return fmt.Errorf("budget limits must not be negative")
+	}
+	if b.MaxUSD == 0 && b.MaxTokens == 0 {
+		return fmt.Errorf("budget needs a USD or token limit")
+	}
+	return nil
+}
+
+// SetBudget inserts or replaces the budget of a subject and period.
+func (s *Store) SetBudget(b Budget) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO budgets (scope, subject, period, max_usd, max_tokens, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(scope, subject, period) DO UPDATE SET
+			max_usd = excluded.max_usd, max_tokens = excluded.max_tokens, updated_at = excluded.updated_at
+	`, b.Scope, b.Subject, b.Period, b.MaxUSD, b.MaxTokens, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save budget: %w", err)
+	}
+	return nil
+}
+
+// DeleteBudget removes a budget. It reports whether one existed.
+func (s *Store) DeleteBudget(scope BudgetScope, subject string, period BudgetPeriod) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.Exec(`DELETE FROM budgets WHERE scope = ? AND subject = ? AND period = ?`, scope, subject, period)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete budget: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListBudgets returns all budgets ordered by scope, subject and period.
+func (s *Store) ListBudgets() ([]Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT scope, subject, period, max_usd, max_tokens FROM budgets ORDER BY scope, subject, period`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.Scope, &b.Subject, &b.Period, &b.MaxUSD, &b.MaxTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// Spend sums the cost and tokens of the subject's tasks created since the given time.
+func (s *Store) Spend(scope BudgetScope, subject string, since time.Time) (usd float64, tokens int, err error) {
+	var where string
+	switch scope {
+	case ScopeInstallation:
+		where = `CAST(installation_id AS TEXT) = ?`
+	case ScopeRepo:
+		where = `repo_owner || '/' || repo_name = ?`
+	case ScopeUser:
+		where = `actor = ?`
+	default:
+		return 0, 0, fmt.Errorf("invalid budget scope %q", scope)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	err = s.db.QueryRow(`
+		SELECT COALESCE(SUM(cost_usd), 0),
+			COALESCE(SUM(input_tokens + output_tokens + cache_read_tokens + cache_write_tokens), 0)
+		FROM tasks WHERE `+where+` AND created_at >= ?
+	`, subject, since.In(time.Local)).Scan(&usd, &tokens)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum spend: %w", err)
+	}
+	return usd, tokens, nil
+}
@@ -0,0 +1,104 @@
+package taskstore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBudget_SetListDelete(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.SetBudget(Budget{Scope: ScopeUser, Subject: AnySubject, Period: PeriodDay, MaxUSD: 5}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if err := store.SetBudget(Budget{Scope: ScopeRepo, Subject: "owner/repo", Period: PeriodMonth, MaxUSD: 50}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	// Setting the same subject and period replaces the limits.
+	if err := store.SetBudget(Budget{Scope: ScopeRepo, Subject: "owner/repo", Period: PeriodMonth, MaxTokens: 1000}); err != nil {
+		t.Fatalf("SetBudget() update error = %v", err)
+	}
+
+	budgets, err := store.ListBudgets()
+	if err != nil {
+		t.Fatalf("ListBudgets() error = %v", err)
+	}
+	want := []Budget{
+		{Scope: ScopeRepo, Subject: "owner/repo", Period: PeriodMonth, MaxTokens: 1000},
+		{Scope: ScopeUser, Subject: AnySubject, Period: PeriodDay, MaxUSD: 5},
+	}
+	if len(budgets) != len(want) || budgets[0] != want[0] || budgets[1] != want[1] {
+		t.Fatalf("ListBudgets() = %+v, want %+v", budgets, want)
+	}
+
+	if deleted, err := store.DeleteBudget(ScopeUser, AnySubject, PeriodDay); err != nil || !deleted {
+		t.Fatalf("DeleteBudget() = %v, %v", deleted, err)
+	}
+	if deleted, _ := store.DeleteBudget(ScopeUser, AnySubject, PeriodDay); deleted {
+		t.Fatal("DeleteBudget() reported a missing budget as deleted")
+	}
+
+	for _, bad := range []Budget{
+		{Scope: "org", Subject: "x", Period: PeriodDay, MaxUSD: 1},
+		{Scope: ScopeUser, Subject: "x", Period: "week", MaxUSD: 1},
+		{Scope: ScopeUser, Period: PeriodDay, MaxUSD: 1},
+		{Scope: ScopeUser, Subject: "x", Period: PeriodDay},
+		{Scope: ScopeUser, Subject: "x", Period: PeriodDay, MaxUSD: -1},
+	} {
+		if err := store.SetBudget(bad); err == nil {
+			t.Errorf("SetBudget(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestBudget_Spend(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	tasks := []*Task{
+		{ID: "t1", RepoOwner: "owner", RepoName: "repo", Actor: "alice", InstallationID: 42},
+		{ID: "t2", RepoOwner: "owner", RepoName: "repo", Actor: "bob", InstallationID: 42},
+		{ID: "t3", RepoOwner: "owner", RepoName: "other", Actor: "alice", InstallationID: 7},
+	}
+	for i, task := range tasks {
+		task.Title, task.Status, task.IssueNumber = "t", StatusCompleted, 1
+		if err := store.Create(task); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		store.AddUsage(task.ID, Usage{InputTokens: 100 * (i + 1), OutputTokens: 10, CostUSD: float64(i + 1)})
+	}
+	if got, _ := store.Get("t1"); got.InstallationID != 42 {
+		t.Fatalf("InstallationID = %d, want 42", got.InstallationID)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	cases := []struct {
+		scope   BudgetScope
+		subject string
+		usd     float64
+		tokens  int
+	}{
+		{ScopeInstallation, "42", 3, 320},
+		{ScopeRepo, "owner/repo", 3, 320},
+		{ScopeUser, "alice", 4, 420},
+		{ScopeUser, "carol", 0, 0},
+	}
+	for _, tc := range cases {
+		usd, tokens, err := store.Spend(tc.scope, tc.subject, since)
+		if err != nil || usd != tc.usd || tokens != tc.tokens {
+			t.Errorf("Spend(%s, %s) = %v, %d, %v, want %v, %d", tc.scope, tc.subject, usd, tokens, err, tc.usd, tc.tokens)
+		}
+	}
+
+	if usd, _, _ := store.Spend(ScopeUser, "alice", time.Now().Add(time.Minute)); usd != 0 {
+		t.Errorf("Spend() after all tasks = %v, want 0", usd)
+	}
+}
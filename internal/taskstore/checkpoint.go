@@ -0,0 +1,78 @@
+package taskstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Phase identifies how far a task got before it failed.
+type Phase string
+
+const (
+	PhaseCloned    Phase = "cloned"    // workspace cloned and branch prepared
+	PhaseGenerated Phase = "generated" // provider response applied to the workspace
+	PhaseCommitted Phase = "committed" // changes committed locally, push pending
+)
+
+// Checkpoint records the last completed phase of a task so a retry can resume there.
+type Checkpoint struct {
+	TaskID      string
+	Phase       Phase
+	Workdir     string // workspace kept for the retry, if any
+	Branch      string
+	IsNewBranch bool
+	Response    string // provider response (JSON)
+	Patch       string // binary diff of the generated changes against the base
+	CommitSHA   string
+	UpdatedAt   time.Time
+}
+
+// SaveCheckpoint inserts or replaces the checkpoint of a task.
+func (s *Store) SaveCheckpoint(cp *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp.UpdatedAt = time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO checkpoints (task_id, phase, workdir, branch, is_new_branch, response, patch, commit_sha, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET
+			phase = excluded.phase, workdir = excluded.workdir, branch = excluded.branch,
+			is_new_branch = excluded.is_new_branch, response = excluded.response, patch = excluded.patch,
+			commit_sha = excluded.commit_sha, updated_at = excluded.updated_at
+	`, cp.TaskID, cp.Phase, cp.Workdir, cp.Branch, cp.IsNewBranch, cp.Response, cp.Patch, cp.CommitSHA, cp.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// GetCheckpoint returns the checkpoint of a task.
+func (s *Store) GetCheckpoint(taskID string) (*Checkpoint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cp := &Checkpoint{}
+	err := s.db.QueryRow(`
+		SELECT task_id, phase, workdir, branch, is_new_branch, response, patch, commit_sha, updated_at
+		FROM checkpoints WHERE task_id = ?
+	`, taskID).Scan(&cp.TaskID, &cp.Phase, &cp.Workdir, &cp.Branch, &cp.IsNewBranch, &cp.Response, &cp.Patch, &cp.CommitSHA, &cp.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting checkpoint for task %s: %v", taskID, err)
+		return nil, false
+	}
+	return cp, true
+}
+
+// DeleteCheckpoint removes the checkpoint of a finished task.
+func (s *Store) DeleteCheckpoint(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.db.Exec(`DELETE FROM checkpoints WHERE task_id = ?`, taskID)
+}
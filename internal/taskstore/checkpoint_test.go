@@ -0,0 +1,49 @@
+package taskstore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint_SaveGetDelete(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, ok := store.GetCheckpoint("task-1"); ok {
+		t.Fatal("GetCheckpoint() found a checkpoint before any was saved")
+	}
+
+	cp := &Checkpoint{TaskID: "task-1", Phase: PhaseCloned, Workdir: "/tmp/w", Branch: "swe/issue-1", IsNewBranch: true}
+	if err := store.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+
+	cp.Phase = PhaseCommitted
+	cp.Response = `{"summary":"done"}`
+	cp.Patch = "diff --git a/x b/x\n"
+	cp.CommitSHA = "abc123"
+	if err := store.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint() update error = %v", err)
+	}
+
+	got, ok := store.GetCheckpoint("task-1")
+	if !ok {
+		t.Fatal("GetCheckpoint() not found")
+	}
+	if got.Phase != PhaseCommitted || got.Workdir != "/tmp/w" || got.Branch != "swe/issue-1" || !got.IsNewBranch ||
+		got.Response != cp.Response || got.Patch != cp.Patch || got.CommitSHA != "abc123" || got.UpdatedAt.IsZero() {
+		t.Fatalf("GetCheckpoint() = %+v", got)
+	}
+
+	if err := store.SaveCheckpoint(&Checkpoint{TaskID: "task-2", Phase: "pushed"}); err == nil {
+		t.Fatal("SaveCheckpoint() accepted an unknown phase")
+	}
+
+	store.DeleteCheckpoint("task-1")
+	if _, ok := store.GetCheckpoint("task-1"); ok {
+		t.Fatal("checkpoint not deleted")
+	}
+}
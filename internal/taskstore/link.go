@@ -0,0 +1,129 @@
+package taskstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// LinkState is the state of a pull request opened from a task branch.
+type LinkState string
+
+const (
+	LinkPending LinkState = "pending" // branch pushed, no pull request seen yet
+	LinkOpen    LinkState = "open"
+	LinkMerged  LinkState = "merged"
+	LinkClosed  LinkState = "closed" // closed without merging
+)
+
+// PRLink ties a branch pushed by a task to the issue it came from and the
+// pull request opened from it.
+type PRLink struct {
+	Repo        string
+	Branch      string
+	TaskID      string
+	IssueNumber int
+	CommentID   int // tracking comment on the issue
+	PRNumber    int
+	PRURL       string
+	State       LinkState
+	// ReviewRounds counts review follow-up tasks; only ClaimReviewRound changes it.
+	ReviewRounds int
+	UpdatedAt    time.Time
+}
+
+// SaveLink inserts or replaces the link of a branch.
+func (s *Store) SaveLink(link *PRLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if link.State == "" {
+		link.State = LinkPending
+	}
+	link.UpdatedAt = time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO pr_links (repo, branch, task_id, issue_number, comment_id, pr_number, pr_url, state, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(repo, branch) DO UPDATE SET
+			task_id = excluded.task_id, issue_number = excluded.issue_number, comment_id = excluded.comment_id,
+			pr_number = excluded.pr_number, pr_url = excluded.pr_url, state = excluded.state,
+			updated_at = excluded.updated_at
+	`, link.Repo, link.Branch, link.TaskID, link.IssueNumber, link.CommentID, link.PRNumber, link.PRURL, link.State, link.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save PR link: %w", err)
+	}
+	return nil
+}
+
+// FindLink returns the link of a branch in a repository.
+func (s *Store) FindLink(repo, branch string) (*PRLink, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link := &PRLink{}
+	err := s.db.QueryRow(`
+		SELECT repo, branch, task_id, issue_number, comment_id, pr_number, pr_url, state, review_rounds, updated_at
+		FROM pr_links WHERE repo = ? AND branch = ?
+	`, repo, branch).Scan(&link.Repo, &link.Branch, &link.TaskID, &link.IssueNumber, &link.CommentID, &link.PRNumber, &link.PRURL, &link.State, &link.ReviewRounds, &link.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting PR link for %s@%s: %v", repo, branch, err)
+		return nil, false
+	}
+	return link, true
+}
+
+// ListLinks returns the links of a task ordered by branch.
+func (s *Store) ListLinks(taskID string) []*PRLink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT repo, branch, task_id, issue_number, comment_id, pr_number, pr_url, state, review_rounds, updated_at
+		FROM pr_links WHERE task_id = ? ORDER BY branch
+	`, taskID)
+	if err != nil {
+		log.Printf("Error listing PR links for task %s: %v", taskID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var links []*PRLink
+	for rows.Next() {
+		link := &PRLink{}
+		if err := rows.Scan(&link.Repo, &link.Branch, &link.TaskID, &link.IssueNumber, &link.CommentID, &link.PRNumber, &link.PRURL, &link.State, &link.ReviewRounds, &link.UpdatedAt); err != nil {
+			log.Printf("Error scanning PR link for task %s: %v", taskID, err)
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// ClaimReviewRound counts one more review follow-up for a branch unless max
+// rounds were already used. It returns the claimed round, or false when the
+// branch has no link or the limit is reached.
+func (s *Store) ClaimReviewRound(repo, branch string, max int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.Exec(`
+		UPDATE pr_links SET review_rounds = review_rounds + 1, updated_at = ?
+		WHERE repo = ? AND branch = ? AND review_rounds < ?
+	`, time.Now(), repo, branch, max)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim review round: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, false, err
+	}
+
+	var round int
+	if err := s.db.QueryRow(`SELECT review_rounds FROM pr_links WHERE repo = ? AND branch = ?`, repo, branch).Scan(&round); err != nil {
+		return 0, false, fmt.Errorf("failed to read review round: %w", err)
+	}
+	return round, true, nil
+}
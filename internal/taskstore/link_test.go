@@ -0,0 +1,135 @@
+package taskstore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLink_SaveFindList(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Create(&Task{ID: "task-1", Title: "t", Status: StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	link := &PRLink{Repo: "owner/repo", Branch: "swe/issue-7", TaskID: "task-1", IssueNumber: 7, CommentID: 55}
+	if err := store.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink() error = %v", err)
+	}
+	if link.State != LinkPending {
+		t.Fatalf("State = %q, want pending by default", link.State)
+	}
+	if err := store.SaveLink(&PRLink{Repo: "owner/repo", Branch: "swe/docs-7", TaskID: "task-1", IssueNumber: 7}); err != nil {
+		t.Fatalf("SaveLink() error = %v", err)
+	}
+
+	link.PRNumber, link.PRURL, link.State = 12, "https://github.com/owner/repo/pull/12", LinkMerged
+	if err := store.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink() update error = %v", err)
+	}
+
+	got, ok := store.FindLink("owner/repo", "swe/issue-7")
+	if !ok || got.TaskID != "task-1" || got.CommentID != 55 || got.PRNumber != 12 || got.State != LinkMerged || got.UpdatedAt.IsZero() {
+		t.Fatalf("FindLink() = %+v, %v", got, ok)
+	}
+	if _, ok := store.FindLink("other/repo", "swe/issue-7"); ok {
+		t.Fatal("FindLink() matched a branch of another repository")
+	}
+	if links := store.ListLinks("task-1"); len(links) != 2 || links[0].Branch != "swe/docs-7" {
+		t.Fatalf("ListLinks() = %+v", links)
+	}
+
+	if err := store.SaveLink(&PRLink{Repo: "owner/repo", Branch: "x", TaskID: "task-1", State: "draft"}); err == nil {
+		t.Fatal("SaveLink() accepted an unknown state")
+	}
+}
+
+func TestLink_ClaimReviewRound(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Create(&Task{ID: "task-1", Title: "t", Status: StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	link := &PRLink{Repo: "owner/repo", Branch: "swe/issue-7", TaskID: "task-1", IssueNumber: 7}
+	if err := store.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink() error = %v", err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		round, ok, err := store.ClaimReviewRound("owner/repo", "swe/issue-7", 2)
+		if err != nil || !ok || round != want {
+			t.Fatalf("ClaimReviewRound() = %d, %v, %v; want round %d", round, ok, err, want)
+		}
+	}
+	if _, ok, err := store.ClaimReviewRound("owner/repo", "swe/issue-7", 2); ok || err != nil {
+		t.Fatalf("ClaimReviewRound() beyond limit = %v, %v", ok, err)
+	}
+	if _, ok, _ := store.ClaimReviewRound("owner/repo", "unknown", 2); ok {
+		t.Fatal("ClaimReviewRound() claimed a branch without link")
+	}
+
+	// Saving the link again must not reset the counter.
+	link.State = LinkOpen
+	if err := store.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink() error = %v", err)
+	}
+	if got, _ := store.FindLink("owner/repo", "swe/issue-7"); got.ReviewRounds != 2 {
+		t.Fatalf("ReviewRounds = %d, want 2", got.ReviewRounds)
+	}
+}
+
+func TestNewStore_MigratesTaskStatuses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, stmt := range []string{
+		`CREATE TABLE tasks (
+			id TEXT PRIMARY KEY, title TEXT NOT NULL,
+			status TEXT NOT NULL CHECK(status IN ('pending','running','completed','failed')),
+			repo_owner TEXT NOT NULL, repo_name TEXT NOT NULL, issue_number INTEGER NOT NULL,
+			actor TEXT NOT NULL, created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`,
+		`CREATE TABLE logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, task_id TEXT NOT NULL, timestamp DATETIME NOT NULL,
+			level TEXT NOT NULL, message TEXT NOT NULL,
+			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO tasks VALUES ('old', 't', 'completed', 'o', 'r', 1, 'a', ?, ?)`, now, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO logs (task_id, timestamp, level, message) VALUES ('old', ?, 'info', 'kept')`, now); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	defer store.Close()
+
+	store.UpdateStatus("old", StatusMerged)
+	task, ok := store.Get("old")
+	if !ok || task.Status != StatusMerged {
+		t.Fatalf("Get() = %+v, want merged status after migration", task)
+	}
+	if len(task.Logs) != 1 || task.Logs[0].Message != "kept" {
+		t.Fatalf("logs = %+v, want existing log kept", task.Logs)
+	}
+}
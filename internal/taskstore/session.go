@@ -0,0 +1,69 @@
+package taskstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Session is the provider session of the latest task on an issue or pull
+// request. Follow-up tasks on the same number resume it.
+type Session struct {
+	Repo      string
+	Number    int
+	Provider  string
+	SessionID string
+	UpdatedAt time.Time
+}
+
+// SaveSession inserts or replaces the session of an issue or pull request.
+func (s *Store) SaveSession(session *Session) error {
+	if session.Repo == "" || session.Number == 0 || session.Provider == "" || session.SessionID == "" {
+		return fmt.Errorf("session needs a repository, number, provider and session ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.UpdatedAt = time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (repo, number, provider, session_id, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(repo, number) DO UPDATE SET
+			provider = excluded.provider, session_id = excluded.session_id, updated_at = excluded.updated_at
+	`, session.Repo, session.Number, session.Provider, session.SessionID, session.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns the session of an issue or pull request, or nil when
+// there is none.
+func (s *Store) GetSession(repo string, number int) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session := &Session{}
+	err := s.db.QueryRow(`
+		SELECT repo, number, provider, session_id, updated_at FROM sessions WHERE repo = ? AND number = ?
+	`, repo, number).Scan(&session.Repo, &session.Number, &session.Provider, &session.SessionID, &session.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// DeleteSession forgets the session of an issue or pull request.
+func (s *Store) DeleteSession(repo string, number int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE repo = ? AND number = ?`, repo, number); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package taskstore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSession_SaveGetDelete(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if got, err := store.GetSession("owner/repo", 7); err != nil || got != nil {
+		t.Fatalf("GetSession() = %+v, %v, want none", got, err)
+	}
+
+	if err := store.SaveSession(&Session{Repo: "owner/repo", Number: 7, Provider: "claude", SessionID: "s1"}); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+	if err := store.SaveSession(&Session{Repo: "owner/repo", Number: 7, Provider: "codex", SessionID: "s2"}); err != nil {
+		t.Fatalf("SaveSession() replace error = %v", err)
+	}
+	if err := store.SaveSession(&Session{Repo: "owner/repo", Number: 8, Provider: "claude"}); err == nil {
+		t.Fatal("SaveSession() accepted an empty session ID")
+	}
+
+	got, err := store.GetSession("owner/repo", 7)
+	if err != nil || got == nil || got.Provider != "codex" || got.SessionID != "s2" || got.UpdatedAt.IsZero() {
+		t.Fatalf("GetSession() = %+v, %v, want the replaced session", got, err)
+	}
+	if got, _ := store.GetSession("other/repo", 7); got != nil {
+		t.Fatalf("GetSession() matched another repository: %+v", got)
+	}
+
+	if err := store.DeleteSession("owner/repo", 7); err != nil {
+		t.Fatalf("DeleteSession() error = %v", err)
+	}
+	if got, _ := store.GetSession("owner/repo", 7); got != nil {
+		t.Fatalf("GetSession() after delete = %+v", got)
+	}
+}
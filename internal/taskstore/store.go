@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,19 +18,41 @@ const (
 	StatusRunning   TaskStatus = "running"
 	StatusCompleted TaskStatus = "completed"
 	StatusFailed    TaskStatus = "failed"
+	StatusMerged    TaskStatus = "merged"    // 任务创建的 PR 已合并
+	StatusAbandoned TaskStatus = "abandoned" // 任务创建的 PR 已关闭且未合并
 )
 
 type Task struct {
-	ID          string
-	Title       string
-	Status      TaskStatus
-	RepoOwner   string
-	RepoName    string
-	IssueNumber int
-	Actor       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Logs        []LogEntry
+	ID             string
+	Title          string
+	Status         TaskStatus
+	RepoOwner      string
+	RepoName       string
+	IssueNumber    int
+	Actor          string
+	InstallationID int64  // GitHub App installation that received the event, 0 when unknown
+	Provider       string // provider that produced the change, empty until generated
+	Usage          Usage  // provider resource use, zero until generated
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Logs           []LogEntry
+}
+
+// Usage 记录 provider 的 token 用量、耗时和费用
+type Usage struct {
+	Model            string
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Turns            int
+	Duration         time.Duration
+	CostUSD          float64
+}
+
+// Tokens 返回输入、输出和缓存 token 总数
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
 }
 
 type LogEntry struct {
@@ -39,8 +62,9 @@ type LogEntry struct {
 }
 
 type Store struct {
-	db *sql.DB
-	mu sync.RWMutex // 保护并发数据库访问
+	db        *sql.DB
+	mu        sync.RWMutex      // 保护并发数据库访问
+	retention ArtifactRetention // 任务产物保留策略
 }
 
 // createTables 创建数据库表结构和索引
@@ -49,11 +73,21 @@ func createTables(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS tasks (
 		id           TEXT PRIMARY KEY,
 		title        TEXT NOT NULL,
-		status       TEXT NOT NULL CHECK(status IN ('pending','running','completed','failed')),
+		status       TEXT NOT NULL CHECK(status IN ('pending','running','completed','failed','merged','abandoned')),
 		repo_owner   TEXT NOT NULL,
 		repo_name    TEXT NOT NULL,
 		issue_number INTEGER NOT NULL,
 		actor        TEXT NOT NULL,
+		provider     TEXT NOT NULL DEFAULT '',
+		installation_id    INTEGER NOT NULL DEFAULT 0,
+		model              TEXT NOT NULL DEFAULT '',
+		input_tokens       INTEGER NOT NULL DEFAULT 0,
+		output_tokens      INTEGER NOT NULL DEFAULT 0,
+		cache_read_tokens  INTEGER NOT NULL DEFAULT 0,
+		cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+		turns              INTEGER NOT NULL DEFAULT 0,
+		duration_ms        INTEGER NOT NULL DEFAULT 0,
+		cost_usd           REAL NOT NULL DEFAULT 0,
 		created_at   DATETIME NOT NULL,
 		updated_at   DATETIME NOT NULL
 	);
@@ -67,12 +101,176 @@ func createTables(db *sql.DB) error {
 		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS checkpoints (
+		task_id       TEXT PRIMARY KEY,
+		phase         TEXT NOT NULL CHECK(phase IN ('cloned','generated','committed')),
+		workdir       TEXT NOT NULL DEFAULT '',
+		branch        TEXT NOT NULL DEFAULT '',
+		is_new_branch INTEGER NOT NULL DEFAULT 0,
+		response      TEXT NOT NULL DEFAULT '',
+		patch         TEXT NOT NULL DEFAULT '',
+		commit_sha    TEXT NOT NULL DEFAULT '',
+		updated_at    DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS artifacts (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id    TEXT NOT NULL,
+		kind       TEXT NOT NULL,
+		sha256     TEXT NOT NULL,
+		size       INTEGER NOT NULL,
+		truncated  INTEGER NOT NULL DEFAULT 0,
+		data       BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (task_id, kind),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS pr_links (
+		repo         TEXT NOT NULL,
+		branch       TEXT NOT NULL,
+		task_id      TEXT NOT NULL,
+		issue_number INTEGER NOT NULL,
+		comment_id   INTEGER NOT NULL DEFAULT 0,
+		pr_number    INTEGER NOT NULL DEFAULT 0,
+		pr_url       TEXT NOT NULL DEFAULT '',
+		state        TEXT NOT NULL CHECK(state IN ('pending','open','merged','closed')),
+		review_rounds INTEGER NOT NULL DEFAULT 0,
+		updated_at   DATETIME NOT NULL,
+		PRIMARY KEY (repo, branch),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS budgets (
+		scope      TEXT NOT NULL CHECK(scope IN ('installation','repo','user')),
+		subject    TEXT NOT NULL,
+		period     TEXT NOT NULL CHECK(period IN ('day','month')),
+		max_usd    REAL NOT NULL DEFAULT 0,
+		max_tokens INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (scope, subject, period)
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		repo       TEXT NOT NULL,
+		number     INTEGER NOT NULL,
+		provider   TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (repo, number)
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to execute schema: %w", err)
+	}
+	if err := migrateTaskStatuses(db); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "pr_links", "review_rounds", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "tasks", "provider", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "tasks", "installation_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, column := range usageColumns {
+		if err := addColumnIfMissing(db, "tasks", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	indexes := `
 	CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at DESC);
 	CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
 	CREATE INDEX IF NOT EXISTS idx_logs_task_id ON logs(task_id);
+	CREATE INDEX IF NOT EXISTS idx_artifacts_created_at ON artifacts(created_at);
+	CREATE INDEX IF NOT EXISTS idx_pr_links_task_id ON pr_links(task_id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_actor ON tasks(actor, created_at);
+	CREATE INDEX IF NOT EXISTS idx_tasks_repo ON tasks(repo_owner, repo_name, created_at);
 	`
-	if _, err := db.Exec(schema); err != nil {
-		return fmt.Errorf("failed to execute schema: %w", err)
+	if _, err := db.Exec(indexes); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+	return nil
+}
+
+// migrateTaskStatuses 重建旧版 tasks 表，使状态约束包含 merged/abandoned
+// SQLite 无法修改 CHECK 约束，只能按官方流程新建表、复制数据后替换
+func migrateTaskStatuses(db *sql.DB) error {
+	var ddl string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'tasks'`).Scan(&ddl); err != nil {
+		return fmt.Errorf("failed to inspect tasks table: %w", err)
+	}
+	if strings.Contains(ddl, "'abandoned'") {
+		return nil
+	}
+
+	// 关闭外键，避免删除旧表时级联删除日志（该 PRAGMA 在事务内无效）
+	if _, err := db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer db.Exec("PRAGMA foreign_keys = ON")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []string{
+		`CREATE TABLE tasks_new (
+			id           TEXT PRIMARY KEY,
+			title        TEXT NOT NULL,
+			status       TEXT NOT NULL CHECK(status IN ('pending','running','completed','failed','merged','abandoned')),
+			repo_owner   TEXT NOT NULL,
+			repo_name    TEXT NOT NULL,
+			issue_number INTEGER NOT NULL,
+			actor        TEXT NOT NULL,
+			created_at   DATETIME NOT NULL,
+			updated_at   DATETIME NOT NULL
+		)`,
+		`INSERT INTO tasks_new SELECT id, title, status, repo_owner, repo_name, issue_number, actor, created_at, updated_at FROM tasks`,
+		`DROP TABLE tasks`,
+		`ALTER TABLE tasks_new RENAME TO tasks`,
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to migrate tasks table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// addColumnIfMissing 为旧版数据库补充新增列
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect %s table: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
 	}
 	return nil
 }
@@ -131,9 +329,9 @@ func (s *Store) Create(task *Task) error {
 
 	// 插入任务
 	_, err = tx.Exec(`
-		INSERT INTO tasks (id, title, status, repo_owner, repo_name, issue_number, actor, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, task.ID, task.Title, task.Status, task.RepoOwner, task.RepoName, task.IssueNumber, task.Actor, task.CreatedAt, task.UpdatedAt)
+		INSERT INTO tasks (id, title, status, repo_owner, repo_name, issue_number, actor, installation_id, provider, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, task.ID, task.Title, task.Status, task.RepoOwner, task.RepoName, task.IssueNumber, task.Actor, task.InstallationID, task.Provider, task.CreatedAt, task.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert task: %w", err)
 	}
@@ -158,12 +356,7 @@ func (s *Store) Get(id string) (*Task, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	task := &Task{}
-	err := s.db.QueryRow(`
-		SELECT id, title, status, repo_owner, repo_name, issue_number, actor, created_at, updated_at
-		FROM tasks WHERE id = ?
-	`, id).Scan(&task.ID, &task.Title, &task.Status, &task.RepoOwner, &task.RepoName, &task.IssueNumber, &task.Actor, &task.CreatedAt, &task.UpdatedAt)
-
+	task, err := scanTask(s.db.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id))
 	if err == sql.ErrNoRows {
 		return nil, false
 	}
@@ -177,6 +370,38 @@ func (s *Store) Get(id string) (*Task, bool) {
 	return task, true
 }
 
+// taskColumns 是 scanTask 读取的列
+const taskColumns = `id, title, status, repo_owner, repo_name, issue_number, actor, installation_id, provider,
+	model, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, turns, duration_ms, cost_usd,
+	created_at, updated_at`
+
+// usageColumns 是旧数据库需要补充的用量列
+var usageColumns = []struct{ name, definition string }{
+	{"model", "TEXT NOT NULL DEFAULT ''"},
+	{"input_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"output_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"cache_read_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"cache_write_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"turns", "INTEGER NOT NULL DEFAULT 0"},
+	{"duration_ms", "INTEGER NOT NULL DEFAULT 0"},
+	{"cost_usd", "REAL NOT NULL DEFAULT 0"},
+}
+
+// scanTask 读取一行 taskColumns
+func scanTask(row interface{ Scan(...any) error }) (*Task, error) {
+	task := &Task{}
+	var durationMS int64
+	err := row.Scan(&task.ID, &task.Title, &task.Status, &task.RepoOwner, &task.RepoName, &task.IssueNumber, &task.Actor, &task.InstallationID, &task.Provider,
+		&task.Usage.Model, &task.Usage.InputTokens, &task.Usage.OutputTokens, &task.Usage.CacheReadTokens, &task.Usage.CacheWriteTokens,
+		&task.Usage.Turns, &durationMS, &task.Usage.CostUSD,
+		&task.CreatedAt, &task.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	task.Usage.Duration = time.Duration(durationMS) * time.Millisecond
+	return task, nil
+}
+
 // loadLogs 加载任务的所有日志（按时间升序）
 func (s *Store) loadLogs(taskID string) []LogEntry {
 	rows, err := s.db.Query(`
@@ -208,10 +433,7 @@ func (s *Store) List() []*Task {
 	defer s.mu.RUnlock()
 
 	// 只查询 tasks 表（性能优化：不加载日志）
-	rows, err := s.db.Query(`
-		SELECT id, title, status, repo_owner, repo_name, issue_number, actor, created_at, updated_at
-		FROM tasks ORDER BY created_at DESC
-	`)
+	rows, err := s.db.Query(`SELECT ` + taskColumns + ` FROM tasks ORDER BY created_at DESC`)
 	if err != nil {
 		log.Printf("Error listing tasks: %v", err)
 		return nil
@@ -220,8 +442,7 @@ func (s *Store) List() []*Task {
 
 	var tasks []*Task
 	for rows.Next() {
-		task := &Task{}
-		err := rows.Scan(&task.ID, &task.Title, &task.Status, &task.RepoOwner, &task.RepoName, &task.IssueNumber, &task.Actor, &task.CreatedAt, &task.UpdatedAt)
+		task, err := scanTask(rows)
 		if err != nil {
 			log.Printf("Error scanning task: %v", err)
 			continue
@@ -245,6 +466,37 @@ func (s *Store) UpdateStatus(id string, status TaskStatus) {
 	}
 }
 
+// AddUsage 累加 provider 的用量和费用：重试、恢复和冲突解决的每次运行都会计入，预算统计不会漏算
+func (s *Store) AddUsage(id string, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		UPDATE tasks SET model = CASE WHEN ? = '' THEN model ELSE ? END,
+			input_tokens = input_tokens + ?, output_tokens = output_tokens + ?,
+			cache_read_tokens = cache_read_tokens + ?, cache_write_tokens = cache_write_tokens + ?,
+			turns = turns + ?, duration_ms = duration_ms + ?, cost_usd = cost_usd + ?, updated_at = ?
+		WHERE id = ?
+	`, usage.Model, usage.Model, usage.InputTokens, usage.OutputTokens, usage.CacheReadTokens, usage.CacheWriteTokens,
+		usage.Turns, usage.Duration.Milliseconds(), usage.CostUSD, time.Now(), id)
+	if err != nil {
+		log.Printf("Error updating usage for task %s: %v", id, err)
+	}
+}
+
+// SetProvider 记录生成变更的 provider（启用回退链时可能不是默认 provider）
+func (s *Store) SetProvider(id, provider string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		UPDATE tasks SET provider = ?, updated_at = ? WHERE id = ?
+	`, provider, time.Now(), id)
+	if err != nil {
+		log.Printf("Error updating provider for task %s: %v", id, err)
+	}
+}
+
 // AddLog 添加任务日志（事务保证日志插入和时间戳更新一致性）
 func (s *Store) AddLog(id string, level, message string) {
 	s.mu.Lock()
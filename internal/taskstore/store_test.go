@@ -154,6 +154,61 @@ func TestSQLiteStore_UpdateStatus(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_SetProvider(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Create(&Task{ID: "task-p", Title: "Provider", Status: StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 4, Actor: "user"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	store.SetProvider("task-p", "codex")
+
+	retrieved, _ := store.Get("task-p")
+	if retrieved.Provider != "codex" {
+		t.Errorf("Provider = %q, want codex", retrieved.Provider)
+	}
+	if listed := store.List(); len(listed) != 1 || listed[0].Provider != "codex" {
+		t.Errorf("List() = %+v, want provider recorded", listed)
+	}
+}
+
+func TestSQLiteStore_AddUsage(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Create(&Task{ID: "task-u", Title: "Usage", Status: StatusRunning, RepoOwner: "owner", RepoName: "repo", IssueNumber: 5, Actor: "user"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	usage := Usage{Model: "gpt-5-codex", InputTokens: 1200, OutputTokens: 300, CacheReadTokens: 5000, Turns: 4, Duration: 90 * time.Second, CostUSD: 0.0123}
+	store.AddUsage("task-u", usage)
+
+	retrieved, _ := store.Get("task-u")
+	if retrieved.Usage != usage {
+		t.Errorf("Usage = %+v, want %+v", retrieved.Usage, usage)
+	}
+	if listed := store.List(); len(listed) != 1 || listed[0].Usage != usage || listed[0].Usage.Tokens() != 6500 {
+		t.Errorf("List() = %+v, want usage recorded", listed)
+	}
+
+	// A retry adds to what earlier attempts spent.
+	store.AddUsage("task-u", Usage{InputTokens: 100, Turns: 1, Duration: time.Second, CostUSD: 0.5})
+	retrieved, _ = store.Get("task-u")
+	want := usage
+	want.InputTokens += 100
+	want.Turns++
+	want.Duration += time.Second
+	want.CostUSD += 0.5
+	if retrieved.Usage != want {
+		t.Errorf("Usage after retry = %+v, want %+v", retrieved.Usage, want)
+	}
+}
+
 func TestSQLiteStore_AddLog(t *testing.T) {
 	tmpDB := filepath.Join(t.TempDir(), "test.db")
 	store, err := NewStore(tmpDB)
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// maxInlineDiffBytes is the largest diff rendered on the task page; bigger diffs are download-only.
+const maxInlineDiffBytes = 512 * 1024
+
+// DiffLine is one line of a rendered diff with its highlighting class.
+type DiffLine struct {
+	Class string
+	Text  string
+}
+
+// DownloadArtifact serves a task artifact as an attachment.
+func (h *Handler) DownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	vars := mux.Vars(r)
+	kind := taskstore.ArtifactKind(vars["kind"])
+	if !kind.Valid() {
+		http.NotFound(w, r)
+		return
+	}
+
+	artifact, ok := h.store.GetArtifact(vars["id"], kind)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if kind == taskstore.ArtifactDiff {
+		contentType = "text/x-diff; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Name()))
+	w.Header().Set("Content-Length", strconv.Itoa(len(artifact.Data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(artifact.Data)
+}
+
+// taskDiff returns the task's diff split into highlighted lines, or nil when
+// there is none or it is too large to show inline.
+func (h *Handler) taskDiff(taskID string) []DiffLine {
+	artifact, ok := h.store.GetArtifact(taskID, taskstore.ArtifactDiff)
+	if !ok || len(artifact.Data) > maxInlineDiffBytes {
+		return nil
+	}
+	return highlightDiff(string(artifact.Data))
+}
+
+func highlightDiff(diff string) []DiffLine {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	result := make([]DiffLine, 0, len(lines))
+	inHeader := false
+	for _, line := range lines {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			class, inHeader = "diff-file", true
+		case strings.HasPrefix(line, "@@"):
+			class, inHeader = "diff-hunk", false
+		case inHeader:
+			class = "diff-meta" // index, mode, ---/+++ and "Binary files differ" lines
+		case strings.HasPrefix(line, "+"):
+			class = "diff-add"
+		case strings.HasPrefix(line, "-"):
+			class = "diff-del"
+		}
+		result = append(result, DiffLine{Class: class, Text: line})
+	}
+	return result
+}
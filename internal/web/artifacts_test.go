@@ -0,0 +1,89 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func newArtifactHandler(t *testing.T, detailTpl string) (*Handler, *taskstore.Store) {
+	t.Helper()
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Create(&taskstore.Task{ID: "task-123", Title: "demo", Status: taskstore.StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 1, Actor: "user"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	return &Handler{store: store, templates: newTemplates("ok", detailTpl, t)}, store
+}
+
+func TestHandler_DownloadArtifact(t *testing.T) {
+	handler, store := newArtifactHandler(t, "")
+	if err := store.SaveArtifact("task-123", taskstore.ArtifactDiff, []byte("diff --git a/x b/x\n")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+
+	tests := []struct {
+		kind string
+		code int
+	}{
+		{"diff", http.StatusOK},
+		{"response", http.StatusNotFound},
+		{"../etc", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-123/artifacts/"+tt.kind, nil), map[string]string{"id": "task-123", "kind": tt.kind})
+		rr := httptest.NewRecorder()
+		handler.DownloadArtifact(rr, req)
+		if rr.Code != tt.code {
+			t.Fatalf("%s: status = %d, want %d", tt.kind, rr.Code, tt.code)
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="changes.diff"` {
+			t.Fatalf("Content-Disposition = %q", got)
+		}
+		if rr.Body.String() != "diff --git a/x b/x\n" {
+			t.Fatalf("body = %q", rr.Body.String())
+		}
+	}
+}
+
+func TestHandler_TaskDetail_ShowsArtifacts(t *testing.T) {
+	handler, store := newArtifactHandler(t, "{{range .Artifacts}}{{.Name}};{{end}}|{{range .Diff}}{{.Class}};{{end}}")
+	if err := store.SaveArtifact("task-123", taskstore.ArtifactDiff, []byte("diff --git a/x b/x\n@@ -1 +1 @@\n-a\n+b\n")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+	if err := store.SaveArtifact("task-123", taskstore.ArtifactVerification, []byte("$ make test\n")); err != nil {
+		t.Fatalf("SaveArtifact() error = %v", err)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-123", nil), map[string]string{"id": "task-123"})
+	rr := httptest.NewRecorder()
+	handler.TaskDetail(rr, req)
+
+	if want := "changes.diff;verification.log;|diff-file;diff-hunk;diff-del;diff-add;"; rr.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rr.Body.String(), want)
+	}
+}
+
+func TestHighlightDiff(t *testing.T) {
+	diff := "diff --git a/x b/x\nindex 1..2 100644\n--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n keep\n-old\n+new\ndiff --git a/y b/y\nBinary files a/y and b/y differ\n"
+	var classes []string
+	for _, line := range highlightDiff(diff) {
+		classes = append(classes, line.Class)
+	}
+	want := []string{"diff-file", "diff-meta", "diff-meta", "diff-meta", "diff-hunk", "", "diff-del", "diff-add", "diff-file", "diff-meta"}
+	if !reflect.DeepEqual(classes, want) {
+		t.Fatalf("classes = %q, want %q", classes, want)
+	}
+}
@@ -53,7 +53,9 @@ func (h *Handler) TaskDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "detail.html", map[string]interface{}{
-		"Task": task,
+		"Task":      task,
+		"Artifacts": h.store.ListArtifacts(taskID),
+		"Diff":      h.taskDiff(taskID),
 	}); err != nil {
 		http.Error(w, "template rendering error", http.StatusInternalServerError)
 	}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+)
+
+// WithBudgets rejects new tasks whose installation, repository or user has
+// used up a budget.
+func (h *Handler) WithBudgets(budgets *budget.Enforcer) *Handler {
+	h.budgets = budgets
+	return h
+}
+
+// rejectOverBudget answers the webhook and explains on the issue when a
+// budget of the task is used up. Like verifyPermission it fails open when
+// the spend cannot be looked up.
+func (h *Handler) rejectOverBudget(w http.ResponseWriter, task *Task) bool {
+	if h.budgets == nil {
+		return false
+	}
+
+	exceeded, err := h.budgets.Exceeded(budget.Subjects{Installation: task.InstallationID, Repo: task.Repo, User: task.Username})
+	if err != nil {
+		log.Printf("Warning: Failed to check budgets for %s: %v (allowing request)", task.Repo, err)
+		return false
+	}
+	if exceeded == nil {
+		return false
+	}
+
+	log.Printf("Rejecting task for %s#%d by %s: %s", task.Repo, task.Number, task.Username, exceeded.Describe())
+	h.commentOverBudget(task, exceeded)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Budget exceeded"))
+	return true
+}
+
+func (h *Handler) commentOverBudget(task *Task, exceeded *budget.Status) {
+	if h.appAuth == nil {
+		return
+	}
+	token, err := h.appAuth.GetInstallationToken(task.Repo)
+	if err != nil {
+		log.Printf("Warning: Failed to get installation token for budget comment: %v", err)
+		return
+	}
+	body := fmt.Sprintf("@%s this task was not started: the %s. The budget resets at %s UTC.",
+		task.Username, exceeded.Describe(), exceeded.Resets.UTC().Format("2006-01-02 15:04"))
+	if err := github.CreateComment(task.Repo, task.Number, body, token.Token); err != nil {
+		log.Printf("Warning: Failed to post budget comment on %s#%d: %v", task.Repo, task.Number, err)
+	}
+}
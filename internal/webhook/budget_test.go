@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandleIssueComment_OverBudget(t *testing.T) {
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.SetBudget(taskstore.Budget{Scope: taskstore.ScopeUser, Subject: taskstore.AnySubject, Period: taskstore.PeriodDay, MaxUSD: 2}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+
+	mockGH := github.NewMockGHClient()
+	github.SetGHClient(mockGH)
+	t.Cleanup(func() { github.SetGHClient(github.NewRealGHClient()) })
+
+	dispatcher := &mockDispatcher{}
+	h := NewHandler("secret", "/code", dispatcher, store, &mockAppAuth{}).
+		WithRepoConfigLoader(nil).
+		WithBudgets(budget.New(store))
+
+	post := func(commentID int64) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(IssueCommentEvent{
+			Action:       "created",
+			Issue:        Issue{Number: 3, Title: "Bug"},
+			Comment:      Comment{ID: commentID, Body: "/code fix it", User: User{Login: "alice"}},
+			Repository:   Repository{FullName: "owner/repo", DefaultBranch: "main"},
+			Installation: Installation{ID: 42},
+		})
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(payload)
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		req.Header.Set("X-GitHub-Event", "issue_comment")
+		w := httptest.NewRecorder()
+		h.Handle(w, req)
+		return w
+	}
+
+	if w := post(1); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %q, want the first task queued", w.Code, w.Body.String())
+	}
+	task := dispatcher.lastTask
+	if task == nil || task.InstallationID != 42 {
+		t.Fatalf("task = %+v, want installation 42", task)
+	}
+	if stored, ok := store.Get(task.ID); !ok || stored.InstallationID != 42 {
+		t.Fatalf("stored task = %+v, want installation 42", stored)
+	}
+	store.AddUsage(task.ID, taskstore.Usage{InputTokens: 1000, CostUSD: 2.5})
+
+	w := post(2)
+	if w.Code != http.StatusOK || w.Body.String() != "Budget exceeded" {
+		t.Fatalf("status = %d, body %q, want the task rejected", w.Code, w.Body.String())
+	}
+	if dispatcher.enqueueCalls != 1 {
+		t.Fatalf("enqueueCalls = %d, want 1", dispatcher.enqueueCalls)
+	}
+	if len(store.List()) != 1 {
+		t.Fatal("rejected task must not be stored")
+	}
+	if len(mockGH.CreateCommentCalls) != 1 {
+		t.Fatalf("CreateComment calls = %d, want 1", len(mockGH.CreateCommentCalls))
+	}
+	comment := mockGH.CreateCommentCalls[0]
+	if comment.Number != 3 || comment.Token != "mock-token" ||
+		!strings.Contains(comment.Body, "@alice this task was not started: the user alice has used $2.50 of its $2.00 daily budget") {
+		t.Fatalf("comment = %+v", comment)
+	}
+}
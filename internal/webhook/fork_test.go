@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cexll/swe/internal/repoconfig"
+)
+
+const forkReviewPayload = `{
+	"action": "created",
+	"comment": {"id": 77, "body": "/code fix the typo", "user": {"login": "%s", "type": "User"}},
+	"pull_request": {
+		"number": 9,
+		"title": "Fix docs",
+		"state": "open",
+		"maintainer_can_modify": true,
+		"base": {"ref": "main"},
+		"head": {"ref": "patch-1", "repo": {"full_name": "contributor/repo"}}
+	},
+	"repository": {"full_name": "owner/repo", "default_branch": "main"}
+}`
+
+func newForkHandler(dispatcher *mockDispatcher) *Handler {
+	return NewHandler("secret", "/code", dispatcher, nil, &stubAuthProvider{owner: "maintainer"}).
+		WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) { return nil, nil })
+}
+
+func postForkReview(t *testing.T, h *Handler, login string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(forkReviewPayload, login))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", "pull_request_review_comment")
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+	return w
+}
+
+func TestPullRequestUnmarshal_ForkHead(t *testing.T) {
+	var event PullRequestReviewCommentEvent
+	if err := json.Unmarshal([]byte(fmt.Sprintf(forkReviewPayload, "maintainer")), &event); err != nil {
+		t.Fatal(err)
+	}
+	pr := event.PullRequest
+	if pr.Head.Ref != "patch-1" || pr.Base.Ref != "main" || !pr.MaintainerCanModify || pr.Number != 9 {
+		t.Fatalf("PullRequest = %+v", pr)
+	}
+	if got := pr.ForkRepo("owner/repo"); got != "contributor/repo" {
+		t.Fatalf("ForkRepo() = %q", got)
+	}
+	if got := pr.ForkRepo("Contributor/Repo"); got != "" {
+		t.Fatalf("same-repo PR reported as fork: %q", got)
+	}
+	if got := (PullRequest{}).ForkRepo("owner/repo"); got != "" {
+		t.Fatalf("PR without head repo reported as fork: %q", got)
+	}
+}
+
+func TestHandleWebhook_ForkReviewComment(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	h := newForkHandler(dispatcher)
+
+	if w := postForkReview(t, h, "maintainer"); w.Code != http.StatusAccepted {
+		t.Fatalf("Status = %d, body %q", w.Code, w.Body.String())
+	}
+	task := dispatcher.lastTask
+	if task == nil || !task.IsFork() || task.HeadRepo != "contributor/repo" || !task.MaintainerCanModify || task.PRBranch != "patch-1" {
+		t.Fatalf("task = %+v", task)
+	}
+}
+
+func TestHandleWebhook_ForkReviewComment_IgnoresOpenPermissionMode(t *testing.T) {
+	t.Setenv("ALLOW_ALL_USERS", "true")
+	dispatcher := &mockDispatcher{}
+	h := newForkHandler(dispatcher)
+
+	w := postForkReview(t, h, "contributor")
+	if w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("fork author without write access was accepted: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestVerifyForkPermission_FailsClosed(t *testing.T) {
+	h := &Handler{appAuth: &stubAuthProvider{err: errors.New("boom")}}
+	if h.verifyForkPermission("owner/repo", "anyone") {
+		t.Fatal("verifyForkPermission should deny when the permission lookup fails")
+	}
+}
+
+const forkIssueCommentPayload = `{
+	"action": "created",
+	"issue": {"number": 9, "title": "Fix docs", "state": "open", "pull_request": {"url": "https://api.github.com/repos/owner/repo/pulls/9"}},
+	"comment": {"id": 78, "body": "/code fix the typo", "user": {"login": "%s", "type": "User"}},
+	"repository": {"full_name": "owner/repo", "default_branch": "main"}
+}`
+
+func postForkIssueComment(t *testing.T, h *Handler, login string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(forkIssueCommentPayload, login))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+	return w
+}
+
+func forkPullRequest(repo string, number int) (*PullRequest, error) {
+	var event PullRequestReviewCommentEvent
+	if err := json.Unmarshal([]byte(fmt.Sprintf(forkReviewPayload, "maintainer")), &event); err != nil {
+		return nil, err
+	}
+	return &event.PullRequest, nil
+}
+
+func TestHandleWebhook_ForkIssueComment(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	h := newForkHandler(dispatcher).WithPullRequestLoader(forkPullRequest)
+
+	if w := postForkIssueComment(t, h, "maintainer"); w.Code != http.StatusAccepted {
+		t.Fatalf("Status = %d, body %q", w.Code, w.Body.String())
+	}
+	task := dispatcher.lastTask
+	if task == nil || !task.IsPR || task.HeadRepo != "contributor/repo" || !task.MaintainerCanModify || task.PRBranch != "patch-1" || task.PRState != "open" || task.Branch != "main" {
+		t.Fatalf("task = %+v", task)
+	}
+}
+
+func TestHandleWebhook_ForkIssueComment_IgnoresOpenPermissionMode(t *testing.T) {
+	t.Setenv("ALLOW_ALL_USERS", "true")
+	for name, loader := range map[string]PullRequestLoader{
+		"fork":       forkPullRequest,
+		"load error": func(string, int) (*PullRequest, error) { return nil, errors.New("boom") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			dispatcher := &mockDispatcher{}
+			h := newForkHandler(dispatcher).WithPullRequestLoader(loader)
+
+			w := postForkIssueComment(t, h, "contributor")
+			if w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 0 {
+				t.Fatalf("commenter without write access was accepted: %d %q", w.Code, w.Body.String())
+			}
+		})
+	}
+}
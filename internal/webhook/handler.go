@@ -13,8 +13,11 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/cexll/swe/internal/budget"
 	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/repoconfig"
 	"github.com/cexll/swe/internal/taskstore"
 )
 
@@ -24,6 +27,7 @@ type Task struct {
 	Repo          string
 	Number        int
 	Branch        string
+	DefaultBranch string // Repository default branch (source of .swe-agent.yml)
 	Prompt        string
 	PromptSummary string
 	IssueTitle    string
@@ -31,9 +35,17 @@ type Task struct {
 	IsPR          bool
 	PRBranch      string // PR's source branch (if it's a PR)
 	PRState       string // PR state: "open" or "closed"
-	Username      string // User who triggered the task
-	Attempt       int    // Current attempt number (managed by dispatcher)
-	PromptContext map[string]string
+	HeadRepo      string // PR head repository when the PR comes from a fork ("" otherwise)
+	// MaintainerCanModify reports "Allow edits from maintainers" on fork PRs
+	MaintainerCanModify bool
+	Username            string // User who triggered the task
+	InstallationID      int64  // GitHub App installation that received the event (0 if unknown)
+	Attempt             int    // Current attempt number (managed by dispatcher)
+	ReviewRound         int    // Review follow-up round (0 unless started by a "changes requested" review)
+	ReviewCommentID     int64  // Root comment of the review thread that triggered the task
+	NewSession          bool   // Instruction started with --new-session: do not resume the provider session
+	PromptContext       map[string]string
+	RepoConfig          *repoconfig.Config // Repository config (loaded by executor after clone)
 }
 
 // TaskIDComponents 封装 Task ID 组成部分（支持可选字段）
@@ -61,9 +73,16 @@ type Handler struct {
 	dispatcher     TaskDispatcher
 	issueDeduper   *commentDeduper
 	reviewDeduper  *commentDeduper
+	reviewsDeduper *commentDeduper // submitted reviews (pull_request_review)
 	store          *taskstore.Store
 	appAuth        github.AuthProvider
 	githubClient   *GitHubClient // GitHub API 客户端（用于查询 PR 关联 Issue）
+
+	repoConfigLoader  RepoConfigLoader  // 读取仓库级 .swe-agent.yml
+	pullRequestLoader PullRequestLoader // 读取 PR 评论所属的 PR（head 分支与仓库）
+	repoConfigs       *repoConfigCache
+	pullRequests      PullRequestListener // 接收 PR 生命周期事件（合并/关闭/更新）
+	budgets           *budget.Enforcer    // 拒绝预算已用完的新任务
 }
 
 // NewHandler creates a new webhook handler
@@ -74,16 +93,23 @@ func NewHandler(webhookSecret, triggerKeyword string, dispatcher TaskDispatcher,
 		log.Println("GitHub client initialized for Task ID enrichment")
 	}
 
-	return &Handler{
+	h := &Handler{
 		webhookSecret:  webhookSecret,
 		triggerKeyword: triggerKeyword,
 		dispatcher:     dispatcher,
 		issueDeduper:   newCommentDeduper(12 * time.Hour),
 		reviewDeduper:  newCommentDeduper(12 * time.Hour),
+		reviewsDeduper: newCommentDeduper(12 * time.Hour),
 		store:          store,
 		appAuth:        appAuth,
 		githubClient:   client,
+		repoConfigs:    newRepoConfigCache(),
+	}
+	if client != nil {
+		h.repoConfigLoader = client.defaultRepoConfigLoader
+		h.pullRequestLoader = client.defaultPullRequestLoader
 	}
+	return h
 }
 
 // Handle handles GitHub webhook events (issue comments, review comments, etc.)
@@ -117,6 +143,10 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		h.handleIssueComment(w, payload)
 	case "pull_request_review_comment":
 		h.handleReviewComment(w, payload)
+	case "pull_request":
+		h.handlePullRequest(w, payload)
+	case "pull_request_review":
+		h.handlePullRequestReview(w, payload)
 	default:
 		log.Printf("Ignoring unsupported event type: %s", eventType)
 		w.WriteHeader(http.StatusOK)
@@ -149,16 +179,42 @@ func (h *Handler) handleIssueComment(w http.ResponseWriter, payload []byte) {
 		return
 	}
 
-	// 5. Check if comment contains trigger keyword
-	if !strings.Contains(event.Comment.Body, h.triggerKeyword) {
-		log.Printf("Comment does not contain trigger keyword '%s'", h.triggerKeyword)
+	// 5. Check if comment contains trigger keyword (repository config may override)
+	repoCfg, cfgErr := h.loadRepoConfig(event.Repository.FullName, event.Repository.DefaultBranch)
+	trigger, triggered := matchTrigger(event.Comment.Body, repoCfg.Triggers(h.triggerKeyword))
+	if !triggered {
+		log.Printf("Comment does not contain trigger keyword '%s'", strings.Join(repoCfg.Triggers(h.triggerKeyword), "', '"))
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("No trigger keyword found"))
 		return
 	}
+	if h.rejectRepoConfigError(w, event.Repository.FullName, event.Issue.Number, event.Comment.User.Login, cfgErr) {
+		return
+	}
 
-	// 5.1 Verify permission: check if user is the app installer
-	if !h.verifyPermission(event.Repository.FullName, event.Comment.User.Login) {
+	// 5.1 Comments on pull requests do not say where the head lives; load the
+	// PR so fork PRs get the same handling as review comments. A PR that
+	// cannot be loaded is treated like a fork for the permission check.
+	isPR := event.Issue.PullRequest != nil
+	var pr *PullRequest
+	forkRepo := ""
+	if isPR {
+		var err error
+		if pr, err = h.loadPullRequest(event.Repository.FullName, event.Issue.Number); err != nil {
+			log.Printf("Warning: Failed to load PR #%d of %s: %v", event.Issue.Number, event.Repository.FullName, err)
+		} else {
+			forkRepo = pr.ForkRepo(event.Repository.FullName)
+		}
+	}
+
+	// 5.2 Verify permission: check if user is the app installer. Fork PRs always
+	// require real write access, see verifyForkPermission.
+	permitted := h.verifyPermission(event.Repository.FullName, event.Comment.User.Login)
+	if isPR && (pr == nil || forkRepo != "") {
+		permitted = h.verifyForkPermission(event.Repository.FullName, event.Comment.User.Login)
+	}
+	if !permitted ||
+		!h.verifyRepoAllowList(event.Repository.FullName, event.Comment.User.Login, repoCfg) {
 		log.Printf("Permission denied: user %s is not the app installer", event.Comment.User.Login)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Permission denied"))
@@ -174,16 +230,14 @@ func (h *Handler) handleIssueComment(w http.ResponseWriter, payload []byte) {
 	}
 
 	// 6. Extract prompt from comment
-	customInstruction, found := extractPrompt(event.Comment.Body, h.triggerKeyword)
+	customInstruction, found := extractPrompt(event.Comment.Body, trigger)
 	if !found {
 		log.Printf("No prompt found after trigger keyword")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("No prompt found"))
 		return
 	}
-
-	// 7. Check if this is a PR or issue
-	isPR := event.Issue.PullRequest != nil
+	customInstruction, newSession := extractNewSessionFlag(customInstruction)
 
 	prompt := buildPrompt(event.Issue.Title, event.Issue.Body, customInstruction)
 	promptSummary := buildPromptSummary(event.Issue.Title, customInstruction, isPR)
@@ -217,17 +271,33 @@ func (h *Handler) handleIssueComment(w http.ResponseWriter, payload []byte) {
 
 	// 9. Create task
 	task := &Task{
-		ID:            h.generateTaskID(components),
-		Repo:          event.Repository.FullName,
-		Number:        event.Issue.Number,
-		Branch:        event.Repository.DefaultBranch,
-		Prompt:        prompt,
-		PromptSummary: promptSummary,
-		IssueTitle:    event.Issue.Title,
-		IssueBody:     event.Issue.Body,
-		IsPR:          isPR,
-		Username:      event.Comment.User.Login,
-		PromptContext: buildPromptContextForIssue(event, h.triggerKeyword, isPR),
+		ID:             h.generateTaskID(components),
+		Repo:           event.Repository.FullName,
+		Number:         event.Issue.Number,
+		Branch:         event.Repository.DefaultBranch,
+		DefaultBranch:  event.Repository.DefaultBranch,
+		Prompt:         prompt,
+		PromptSummary:  promptSummary,
+		IssueTitle:     event.Issue.Title,
+		IssueBody:      event.Issue.Body,
+		IsPR:           isPR,
+		Username:       event.Comment.User.Login,
+		InstallationID: event.Installation.ID,
+		NewSession:     newSession,
+		PromptContext:  buildPromptContextForIssue(event, trigger, isPR),
+	}
+	if pr != nil {
+		task.PRBranch = pr.Head.Ref
+		task.PRState = pr.State
+		task.HeadRepo = forkRepo
+		task.MaintainerCanModify = pr.MaintainerCanModify
+		if pr.Base.Ref != "" {
+			task.Branch = pr.Base.Ref
+		}
+	}
+
+	if h.rejectOverBudget(w, task) {
+		return
 	}
 
 	h.createStoreTask(task)
@@ -263,16 +333,28 @@ func (h *Handler) handleReviewComment(w http.ResponseWriter, payload []byte) {
 		return
 	}
 
-	// Check trigger keyword
-	if !strings.Contains(event.Comment.Body, h.triggerKeyword) {
-		log.Printf("Review comment does not contain trigger keyword '%s'", h.triggerKeyword)
+	// Check trigger keyword (repository config may override)
+	repoCfg, cfgErr := h.loadRepoConfig(event.Repository.FullName, event.Repository.DefaultBranch)
+	trigger, triggered := matchTrigger(event.Comment.Body, repoCfg.Triggers(h.triggerKeyword))
+	if !triggered {
+		log.Printf("Review comment does not contain trigger keyword '%s'", strings.Join(repoCfg.Triggers(h.triggerKeyword), "', '"))
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("No trigger keyword found"))
 		return
 	}
+	if h.rejectRepoConfigError(w, event.Repository.FullName, event.PullRequest.Number, event.Comment.User.Login, cfgErr) {
+		return
+	}
 
-	// Verify permission: check if user is the app installer
-	if !h.verifyPermission(event.Repository.FullName, event.Comment.User.Login) {
+	// Verify permission: check if user is the app installer. Fork PRs always
+	// require real write access, see verifyForkPermission.
+	forkRepo := event.PullRequest.ForkRepo(event.Repository.FullName)
+	permitted := h.verifyPermission(event.Repository.FullName, event.Comment.User.Login)
+	if forkRepo != "" {
+		permitted = h.verifyForkPermission(event.Repository.FullName, event.Comment.User.Login)
+	}
+	if !permitted ||
+		!h.verifyRepoAllowList(event.Repository.FullName, event.Comment.User.Login, repoCfg) {
 		log.Printf("Permission denied: user %s is not the app installer", event.Comment.User.Login)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Permission denied"))
@@ -286,13 +368,14 @@ func (h *Handler) handleReviewComment(w http.ResponseWriter, payload []byte) {
 		return
 	}
 
-	customInstruction, found := extractPrompt(event.Comment.Body, h.triggerKeyword)
+	customInstruction, found := extractPrompt(event.Comment.Body, trigger)
 	if !found {
 		log.Printf("No prompt found after trigger keyword in review comment")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("No prompt found"))
 		return
 	}
+	customInstruction, newSession := extractNewSessionFlag(customInstruction)
 
 	prompt := buildPrompt(event.PullRequest.Title, event.PullRequest.Body, customInstruction)
 	promptSummary := buildPromptSummary(event.PullRequest.Title, customInstruction, true)
@@ -323,19 +406,34 @@ func (h *Handler) handleReviewComment(w http.ResponseWriter, payload []byte) {
 	}
 
 	task := &Task{
-		ID:            h.generateTaskID(components),
-		Repo:          event.Repository.FullName,
-		Number:        event.PullRequest.Number,
-		Branch:        branch,
-		Prompt:        prompt,
-		PromptSummary: promptSummary,
-		IssueTitle:    event.PullRequest.Title,
-		IssueBody:     event.PullRequest.Body,
-		IsPR:          true,
-		PRBranch:      event.PullRequest.Head.Ref,
-		PRState:       event.PullRequest.State,
-		Username:      event.Comment.User.Login,
-		PromptContext: buildPromptContextForReview(event, h.triggerKeyword),
+		ID:             h.generateTaskID(components),
+		Repo:           event.Repository.FullName,
+		Number:         event.PullRequest.Number,
+		Branch:         branch,
+		DefaultBranch:  event.Repository.DefaultBranch,
+		Prompt:         prompt,
+		PromptSummary:  promptSummary,
+		IssueTitle:     event.PullRequest.Title,
+		IssueBody:      event.PullRequest.Body,
+		IsPR:           true,
+		PRBranch:       event.PullRequest.Head.Ref,
+		PRState:        event.PullRequest.State,
+		HeadRepo:       forkRepo,
+		Username:       event.Comment.User.Login,
+		InstallationID: event.Installation.ID,
+		PromptContext:  buildPromptContextForReview(event, trigger),
+
+		ReviewCommentID:     event.Comment.ID,
+		MaintainerCanModify: event.PullRequest.MaintainerCanModify,
+		NewSession:          newSession,
+	}
+	// Replies can only be posted to the root comment of a thread.
+	if event.Comment.InReplyToID != 0 {
+		task.ReviewCommentID = event.Comment.InReplyToID
+	}
+
+	if h.rejectOverBudget(w, task) {
+		return
 	}
 
 	h.createStoreTask(task)
@@ -400,6 +498,31 @@ func (h *Handler) verifyPermission(repo, username string) bool {
 	return true
 }
 
+// verifyForkPermission is verifyPermission for fork PRs. The PR author controls the
+// code being edited, so ALLOW_ALL_USERS does not apply and failed lookups deny.
+func (h *Handler) verifyForkPermission(repo, username string) bool {
+	if h.appAuth == nil {
+		log.Printf("Warning: No app auth provider configured, allowing all users")
+		return true
+	}
+
+	hasPermission, err := h.appAuth.CheckUserPermission(repo, username)
+	if err != nil {
+		log.Printf("Permission check failed for fork PR: user=%s repo=%s: %v", username, repo, err)
+		return false
+	}
+	if !hasPermission {
+		log.Printf("Permission check failed: user=%s does not have write permission to repo=%s (fork PR)", username, repo)
+		return false
+	}
+	return true
+}
+
+// IsFork reports whether the task targets a PR opened from a fork.
+func (t *Task) IsFork() bool {
+	return t.HeadRepo != ""
+}
+
 func (h *Handler) createStoreTask(task *Task) {
 	if h.store == nil {
 		return
@@ -407,13 +530,14 @@ func (h *Handler) createStoreTask(task *Task) {
 
 	owner, name := splitRepo(task.Repo)
 	storeTask := &taskstore.Task{
-		ID:          task.ID,
-		Title:       task.IssueTitle,
-		Status:      taskstore.StatusPending,
-		RepoOwner:   owner,
-		RepoName:    name,
-		IssueNumber: task.Number,
-		Actor:       task.Username,
+		ID:             task.ID,
+		Title:          task.IssueTitle,
+		Status:         taskstore.StatusPending,
+		RepoOwner:      owner,
+		RepoName:       name,
+		IssueNumber:    task.Number,
+		Actor:          task.Username,
+		InstallationID: task.InstallationID,
 	}
 	if err := h.store.Create(storeTask); err != nil {
 		log.Printf("Failed to create task in store: %v", err)
@@ -463,6 +587,19 @@ func extractPrompt(body, triggerKeyword string) (string, bool) {
 	return remaining, true
 }
 
+// newSessionFlag at the start of an instruction starts a new provider
+// session instead of resuming the one of earlier tasks on the same number.
+const newSessionFlag = "--new-session"
+
+// extractNewSessionFlag strips a leading --new-session from an instruction.
+func extractNewSessionFlag(instruction string) (string, bool) {
+	rest, ok := strings.CutPrefix(instruction, newSessionFlag)
+	if !ok || (rest != "" && !unicode.IsSpace(rune(rest[0]))) {
+		return instruction, false
+	}
+	return strings.TrimSpace(rest), true
+}
+
 // KISS: no execution mode classifier; resolve via prompt design only
 
 // buildPrompt builds the final prompt by treating the trigger instruction as the primary directive
@@ -110,6 +110,12 @@ func initGitRepo(t *testing.T) string {
 		}
 	}
 
+	// The executor reads the repository config from the default branch by
+	// GitHub URL; serve it from the repository itself.
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	runGit(t, "", "git", "config", "--file", global, "url."+dir+".insteadOf", "https://github.com/cexll/swe-agent.git")
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+
 	return dir
 }
 
@@ -178,7 +184,7 @@ func TestIssueWebhookEndToEndProviders(t *testing.T) {
 				},
 			}
 
-			handler := webhookpkg.NewHandler(secret, trigger, dispatcher, store, auth)
+			handler := webhookpkg.NewHandler(secret, trigger, dispatcher, store, auth).WithRepoConfigLoader(nil)
 
 			event := &webhookpkg.IssueCommentEvent{
 				Action: "created",
@@ -309,7 +315,7 @@ func TestIssueWebhookE2EWithDispatcher(t *testing.T) {
 		disp.Shutdown(ctx)
 	})
 
-	handler := webhookpkg.NewHandler(secret, trigger, disp, store, auth)
+	handler := webhookpkg.NewHandler(secret, trigger, disp, store, auth).WithRepoConfigLoader(nil)
 
 	event := &webhookpkg.IssueCommentEvent{
 		Action: "created",
@@ -382,11 +388,15 @@ func TestPRReviewWebhookE2EWithDispatcher(t *testing.T) {
 	finalSummary := "在 feature/workflow 分支更新 README 内容并同步进度。"
 	finalBodyCh := make(chan string, 1)
 
+	// Review comment tasks keep their tracking comment in the review thread.
 	mockGH := github.NewMockGHClient()
-	mockGH.CreateCommentFunc = func(repo string, number int, body, token string) (int, error) {
+	mockGH.ReplyToReviewFunc = func(repo string, number int, commentID int64, body, token string) (int, error) {
+		if commentID != 5501 {
+			t.Errorf("tracking reply posted to comment %d, want thread 5501", commentID)
+		}
 		return 3030, nil
 	}
-	mockGH.UpdateCommentFunc = func(repo string, commentID int, body, token string) error {
+	mockGH.UpdateReviewFunc = func(repo string, commentID int, body, token string) error {
 		if strings.Contains(body, finalSummary) &&
 			strings.Contains(body, "`feature/workflow`") &&
 			strings.Contains(body, "SWE Agent finished @cexll's task") {
@@ -440,7 +450,7 @@ func TestPRReviewWebhookE2EWithDispatcher(t *testing.T) {
 		disp.Shutdown(ctx)
 	})
 
-	handler := webhookpkg.NewHandler(secret, trigger, disp, store, auth)
+	handler := webhookpkg.NewHandler(secret, trigger, disp, store, auth).WithRepoConfigLoader(nil)
 
 	event := &webhookpkg.PullRequestReviewCommentEvent{
 		Action: "created",
@@ -494,7 +504,12 @@ func TestPRReviewWebhookE2EWithDispatcher(t *testing.T) {
 		t.Fatal("timeout waiting for PR review dispatcher to finish")
 	}
 
+	// The tracking comment is finalized just before the task status is stored.
 	entry := getTaskByNumber(t, store, 99)
+	for deadline := time.Now().Add(2 * time.Second); entry.Status != taskstore.StatusCompleted && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		entry = getTaskByNumber(t, store, 99)
+	}
 	if entry.Status != taskstore.StatusCompleted {
 		t.Fatalf("store entry status = %s, want %s", entry.Status, taskstore.StatusCompleted)
 	}
@@ -552,6 +567,11 @@ func setupRemoteWithPR(t *testing.T) (string, string) {
 	runGit(t, seed, "git", "commit", "-m", "seed feature branch")
 	runGit(t, seed, "git", "push", "-u", "origin", "feature/workflow")
 
+	// The executor fetches and pushes by GitHub URL; serve it from the local remote.
+	global := filepath.Join(root, "gitconfig")
+	runGit(t, "", "git", "config", "--file", global, "url."+remote+".insteadOf", "https://github.com/cexll/swe-agent.git")
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+
 	initialHash := runGit(t, seed, "git", "rev-parse", "feature/workflow")
 	return remote, initialHash
 }
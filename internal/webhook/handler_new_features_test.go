@@ -60,7 +60,7 @@ func TestHandler_PermissionVerification(t *testing.T) {
 				},
 			}
 
-			handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth)
+			handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth).WithRepoConfigLoader(nil)
 
 			event := &IssueCommentEvent{
 				Action: "created",
@@ -124,7 +124,7 @@ func TestHandler_PermissionVerification_AuthError(t *testing.T) {
 		},
 	}
 
-	handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth)
+	handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth).WithRepoConfigLoader(nil)
 
 	event := &IssueCommentEvent{
 		Action: "created",
@@ -198,7 +198,7 @@ func TestHandler_PRBranchInfo(t *testing.T) {
 			// Create new handler and dispatcher for each test to avoid duplicate checks
 			dispatcher := &mockDispatcher{}
 			mockAuth := &mockAppAuth{}
-			handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth)
+			handler := NewHandler(secret, "/code", dispatcher, nil, mockAuth).WithRepoConfigLoader(nil)
 
 			dispatcher.lastTask = nil
 
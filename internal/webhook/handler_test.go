@@ -123,6 +123,34 @@ func TestExtractPrompt(t *testing.T) {
 	}
 }
 
+func TestExtractNewSessionFlag(t *testing.T) {
+	tests := []struct {
+		instruction string
+		want        string
+		newSession  bool
+	}{
+		{"--new-session fix the typo", "fix the typo", true},
+		{"--new-session\nstart over", "start over", true},
+		{"--new-session", "", true},
+		{"--new-sessions please", "--new-sessions please", false},
+		{"fix it --new-session", "fix it --new-session", false},
+	}
+	for _, tt := range tests {
+		got, newSession := extractNewSessionFlag(tt.instruction)
+		if got != tt.want || newSession != tt.newSession {
+			t.Errorf("extractNewSessionFlag(%q) = %q, %v, want %q, %v", tt.instruction, got, newSession, tt.want, tt.newSession)
+		}
+	}
+
+	dispatcher := &mockDispatcher{}
+	handler := NewHandler("secret", "/code", dispatcher, nil, nil).WithRepoConfigLoader(nil)
+	postIssueComment(t, handler, "secret", 1, "/code --new-session redo the parser", "alice")
+	task := dispatcher.lastTask
+	if task == nil || !task.NewSession || strings.Contains(task.Prompt, "--new-session") || !strings.HasPrefix(task.Prompt, "redo the parser") {
+		t.Fatalf("task = %+v, want a new session and the flag stripped", task)
+	}
+}
+
 func TestHandleWebhook_IssueComment(t *testing.T) {
 	secret := "test-webhook-secret"
 	triggerKeyword := "/code"
@@ -255,7 +283,7 @@ func TestHandleWebhook_IssueComment(t *testing.T) {
 			mockAuth := &mockAppAuth{GetInstallationOwnerFunc: func(repo string) (string, error) {
 				return "testuser", nil
 			}}
-			handler := NewHandler(secret, triggerKeyword, dispatcher, nil, mockAuth)
+			handler := NewHandler(secret, triggerKeyword, dispatcher, nil, mockAuth).WithRepoConfigLoader(nil)
 
 			payload, err := json.Marshal(tt.event)
 			if err != nil {
@@ -434,7 +462,7 @@ func TestHandleWebhook_IssueComment_PermissionDenied(t *testing.T) {
 		CheckUserPermissionFunc: func(repo, username string) (bool, error) {
 			return username == "installer-user", nil
 		},
-	})
+	}).WithRepoConfigLoader(nil)
 
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-Hub-Signature-256", signature)
@@ -488,7 +516,7 @@ func TestHandleWebhook_IssueComment_FailOpenOnAuthError(t *testing.T) {
 		CheckUserPermissionFunc: func(repo, username string) (bool, error) {
 			return false, errors.New("boom")
 		},
-	})
+	}).WithRepoConfigLoader(nil)
 
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-Hub-Signature-256", signature)
@@ -618,7 +646,7 @@ func TestHandleWebhook_IssueComment_QueueClosed(t *testing.T) {
 		CheckUserPermissionFunc: func(repo, username string) (bool, error) {
 			return username == "installer-user", nil
 		},
-	})
+	}).WithRepoConfigLoader(nil)
 
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-Hub-Signature-256", signature)
@@ -642,9 +670,10 @@ func TestHandleWebhook_ReviewComment(t *testing.T) {
 	event := &PullRequestReviewCommentEvent{
 		Action: "created",
 		Comment: ReviewComment{
-			ID:   10,
-			Body: "/code run linters",
-			User: User{Login: "reviewer", Type: "User"},
+			ID:          10,
+			InReplyToID: 7,
+			Body:        "/code run linters",
+			User:        User{Login: "reviewer", Type: "User"},
 		},
 		PullRequest: PullRequest{
 			Number: 42,
@@ -700,6 +729,10 @@ func TestHandleWebhook_ReviewComment(t *testing.T) {
 		t.Errorf("Task.Branch = %s, want feature", dispatcher.lastTask.Branch)
 	}
 
+	if dispatcher.lastTask.ReviewCommentID != 7 {
+		t.Errorf("Task.ReviewCommentID = %d, want thread root 7", dispatcher.lastTask.ReviewCommentID)
+	}
+
 	expectedSummary := "**PR:** Improve performance\n\n**Instruction:**\nrun linters"
 	if dispatcher.lastTask.PromptSummary != expectedSummary {
 		t.Errorf("PromptSummary = %q, want %q", dispatcher.lastTask.PromptSummary, expectedSummary)
@@ -783,7 +816,7 @@ func TestHandleWebhook_ReviewComment_PermissionDenied(t *testing.T) {
 		CheckUserPermissionFunc: func(repo, username string) (bool, error) {
 			return username == "installer", nil
 		},
-	})
+	}).WithRepoConfigLoader(nil)
 
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-Hub-Signature-256", signature)
@@ -1063,7 +1096,7 @@ func TestHandleWebhook_ReviewComment_DefaultBranchFallback(t *testing.T) {
 		CheckUserPermissionFunc: func(repo, username string) (bool, error) {
 			return username == "reviewer", nil
 		},
-	})
+	}).WithRepoConfigLoader(nil)
 
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
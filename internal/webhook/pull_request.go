@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// PullRequestUpdate describes a lifecycle change of a pull request.
+type PullRequestUpdate struct {
+	Repo   string
+	Number int
+	URL    string
+	Branch string // head branch in Repo
+	Action string // opened, reopened, synchronize or closed
+	Merged bool
+}
+
+// PullRequestListener follows pull requests opened from branches the agent pushed.
+// Updates for unrelated branches must be ignored by the listener.
+type PullRequestListener interface {
+	PullRequestChanged(update PullRequestUpdate)
+}
+
+// WithPullRequestListener forwards pull_request events to l.
+func (h *Handler) WithPullRequestListener(l PullRequestListener) *Handler {
+	h.pullRequests = l
+	return h
+}
+
+// PullRequestLoader fetches a pull request by number.
+type PullRequestLoader func(repo string, number int) (*PullRequest, error)
+
+// WithPullRequestLoader replaces how pull requests are fetched (useful for testing).
+func (h *Handler) WithPullRequestLoader(loader PullRequestLoader) *Handler {
+	h.pullRequestLoader = loader
+	return h
+}
+
+// loadPullRequest returns the pull request an issue comment was posted on.
+// Such comments do not carry the head branch or repository.
+func (h *Handler) loadPullRequest(repo string, number int) (*PullRequest, error) {
+	if h.pullRequestLoader == nil {
+		return nil, fmt.Errorf("no GitHub client configured")
+	}
+	return h.pullRequestLoader(repo, number)
+}
+
+// GetPullRequest fetches a pull request through the REST API.
+func (c *GitHubClient) GetPullRequest(ctx context.Context, repo string, number int) (*PullRequest, error) {
+	token, err := c.authProvider.GetInstallationToken(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation token: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "gh", "api", fmt.Sprintf("repos/%s/pulls/%d", repo, number),
+		"--header", fmt.Sprintf("Authorization: Bearer %s", token.Token),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("gh api failed: %w (output: %s)", err, output)
+	}
+
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request: %w", err)
+	}
+	return &pr, nil
+}
+
+// defaultPullRequestLoader fetches pull requests through the GitHub API with a short timeout.
+func (c *GitHubClient) defaultPullRequestLoader(repo string, number int) (*PullRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return c.GetPullRequest(ctx, repo, number)
+}
+
+func (h *Handler) handlePullRequest(w http.ResponseWriter, payload []byte) {
+	var event PullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("Error parsing event: %v", err)
+		http.Error(w, "Error parsing event", http.StatusBadRequest)
+		return
+	}
+
+	switch event.Action {
+	case "opened", "reopened", "synchronize", "closed":
+	default:
+		log.Printf("Ignoring pull_request action: %s", event.Action)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Pull request action ignored"))
+		return
+	}
+
+	// Agent branches live in the base repository; fork heads cannot be ours.
+	if h.pullRequests == nil || event.PullRequest.ForkRepo(event.Repository.FullName) != "" {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Pull request ignored"))
+		return
+	}
+
+	number := event.PullRequest.Number
+	if number == 0 {
+		number = event.Number
+	}
+	update := PullRequestUpdate{
+		Repo:   event.Repository.FullName,
+		Number: number,
+		URL:    event.PullRequest.URL,
+		Branch: event.PullRequest.Head.Ref,
+		Action: event.Action,
+		Merged: event.Action == "closed" && event.PullRequest.Merged,
+	}
+	// Updating comments and labels may take several API calls; reply to GitHub first.
+	go h.pullRequests.PullRequestChanged(update)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Pull request update accepted"))
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const pullRequestPayload = `{
+	"action": "%s",
+	"number": 12,
+	"pull_request": {
+		"number": 12,
+		"html_url": "https://github.com/owner/repo/pull/12",
+		"state": "closed",
+		"merged": %t,
+		"base": {"ref": "main"},
+		"head": {"ref": "swe/issue-7", "repo": {"full_name": "%s"}}
+	},
+	"repository": {"full_name": "owner/repo", "default_branch": "main"}
+}`
+
+type recordingPRListener struct {
+	updates chan PullRequestUpdate
+}
+
+func (l *recordingPRListener) PullRequestChanged(update PullRequestUpdate) {
+	l.updates <- update
+}
+
+func postPullRequest(t *testing.T, h *Handler, action string, merged bool, headRepo string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(pullRequestPayload, action, merged, headRepo))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+	return w
+}
+
+func TestHandleWebhook_PullRequestLifecycle(t *testing.T) {
+	listener := &recordingPRListener{updates: make(chan PullRequestUpdate, 1)}
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil).WithPullRequestListener(listener)
+
+	if w := postPullRequest(t, h, "closed", true, "owner/repo"); w.Code != http.StatusAccepted {
+		t.Fatalf("Status = %d, body %q", w.Code, w.Body.String())
+	}
+	select {
+	case got := <-listener.updates:
+		want := PullRequestUpdate{Repo: "owner/repo", Number: 12, URL: "https://github.com/owner/repo/pull/12", Branch: "swe/issue-7", Action: "closed", Merged: true}
+		if got != want {
+			t.Fatalf("update = %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listener was not notified")
+	}
+
+	for _, tc := range []struct{ action, headRepo string }{
+		{"labeled", "owner/repo"},
+		{"closed", "contributor/repo"},
+	} {
+		if w := postPullRequest(t, h, tc.action, false, tc.headRepo); w.Code != http.StatusOK {
+			t.Fatalf("%s from %s: status = %d, want ignored", tc.action, tc.headRepo, w.Code)
+		}
+	}
+	select {
+	case got := <-listener.updates:
+		t.Fatalf("unexpected update %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/repoconfig"
+)
+
+// repoConfigTTL bounds how long a fetched repository config is reused.
+const repoConfigTTL = time.Minute
+
+// RepoConfigLoader fetches the repository config from the given ref.
+// It returns (nil, nil) when the repository has no config file.
+type RepoConfigLoader func(repo, ref string) (*repoconfig.Config, error)
+
+// teamMembershipChecker is implemented by auth providers that can resolve team membership.
+type teamMembershipChecker interface {
+	IsTeamMember(repo, team, username string) (bool, error)
+}
+
+type repoConfigEntry struct {
+	cfg     *repoconfig.Config
+	expires time.Time
+}
+
+// repoConfigCache memoizes repository configs to avoid an API call per comment.
+type repoConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]repoConfigEntry
+	now     func() time.Time
+}
+
+func newRepoConfigCache() *repoConfigCache {
+	return &repoConfigCache{entries: make(map[string]repoConfigEntry), now: time.Now}
+}
+
+// WithRepoConfigLoader replaces how repository configs are fetched (useful for testing).
+func (h *Handler) WithRepoConfigLoader(loader RepoConfigLoader) *Handler {
+	h.repoConfigLoader = loader
+	h.repoConfigs = newRepoConfigCache()
+	return h
+}
+
+// loadRepoConfig returns the repository config, or nil when there is none.
+// Fetch and parse errors are returned and not cached: the config may restrict
+// who can trigger tasks, so callers deny the trigger rather than fall back to
+// server defaults.
+func (h *Handler) loadRepoConfig(repo, ref string) (*repoconfig.Config, error) {
+	if h.repoConfigLoader == nil {
+		return nil, nil
+	}
+
+	key := repo + "@" + ref
+	h.repoConfigs.mu.Lock()
+	entry, ok := h.repoConfigs.entries[key]
+	h.repoConfigs.mu.Unlock()
+	if ok && h.repoConfigs.now().Before(entry.expires) {
+		return entry.cfg, nil
+	}
+
+	cfg, err := h.repoConfigLoader(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	h.repoConfigs.mu.Lock()
+	h.repoConfigs.entries[key] = repoConfigEntry{cfg: cfg, expires: h.repoConfigs.now().Add(repoConfigTTL)}
+	h.repoConfigs.mu.Unlock()
+	return cfg, nil
+}
+
+// rejectRepoConfigError answers the webhook and explains on the issue when
+// the repository config could not be loaded. It returns false when err is nil.
+func (h *Handler) rejectRepoConfigError(w http.ResponseWriter, repo string, number int, username string, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	log.Printf("Denying trigger on %s#%d by %s: cannot load %s: %v", repo, number, username, repoconfig.Path, err)
+	if h.appAuth != nil {
+		if token, tokenErr := h.appAuth.GetInstallationToken(repo); tokenErr != nil {
+			log.Printf("Warning: Failed to get installation token for config comment: %v", tokenErr)
+		} else {
+			body := fmt.Sprintf("@%s this task was not started: `%s` could not be loaded, so its access rules cannot be checked. Fix the file or try again later.", username, repoconfig.Path)
+			if commentErr := github.CreateComment(repo, number, body, token.Token); commentErr != nil {
+				log.Printf("Warning: Failed to post config comment on %s#%d: %v", repo, number, commentErr)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Repository config unavailable"))
+	return true
+}
+
+// matchTrigger returns the keyword that appears first in body.
+func matchTrigger(body string, keywords []string) (string, bool) {
+	best, bestIdx := "", -1
+	for _, keyword := range keywords {
+		if keyword == "" {
+			continue
+		}
+		if idx := strings.Index(body, keyword); idx != -1 && (bestIdx == -1 || idx < bestIdx) {
+			best, bestIdx = keyword, idx
+		}
+	}
+	return best, bestIdx != -1
+}
+
+// verifyRepoAllowList enforces allowed_users/allowed_teams from the repository config.
+// Unlike verifyPermission it fails closed: an unverifiable team membership denies the request.
+func (h *Handler) verifyRepoAllowList(repo, username string, cfg *repoconfig.Config) bool {
+	if !cfg.RestrictsUsers() || cfg.AllowsUser(username) {
+		return true
+	}
+
+	checker, ok := h.appAuth.(teamMembershipChecker)
+	if !ok {
+		log.Printf("Permission check failed: user=%s not in allowed_users and team membership cannot be verified", username)
+		return false
+	}
+
+	for _, team := range cfg.AllowedTeams {
+		member, err := checker.IsTeamMember(repo, team, username)
+		if err != nil {
+			log.Printf("Warning: Failed to check team %s membership for %s: %v", team, username, err)
+			continue
+		}
+		if member {
+			log.Printf("Permission check passed: user=%s is a member of team %s", username, team)
+			return true
+		}
+	}
+
+	log.Printf("Permission check failed: user=%s is not allowed by %s", username, repoconfig.Path)
+	return false
+}
+
+// GetRepoConfig fetches and parses the repository config from ref via the contents API.
+func (c *GitHubClient) GetRepoConfig(ctx context.Context, repo, ref string) (*repoconfig.Config, error) {
+	token, err := c.authProvider.GetInstallationToken(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation token: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("repos/%s/contents/%s", repo, repoconfig.Path)
+	if ref != "" {
+		endpoint += "?ref=" + ref
+	}
+
+	cmd := exec.CommandContext(ctx, "gh", "api", endpoint,
+		"--header", "Accept: application/vnd.github.raw",
+		"--header", fmt.Sprintf("Authorization: Bearer %s", token.Token),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "HTTP 404") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gh api failed: %w (output: %s)", err, output)
+	}
+
+	return repoconfig.Parse(output)
+}
+
+// defaultRepoConfigLoader fetches configs through the GitHub API with a short timeout.
+func (c *GitHubClient) defaultRepoConfigLoader(repo, ref string) (*repoconfig.Config, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return c.GetRepoConfig(ctx, repo, ref)
+}
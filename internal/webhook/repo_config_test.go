@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/repoconfig"
+)
+
+type teamAuthProvider struct {
+	stubAuthProvider
+	members map[string]bool // "team/user"
+	err     error
+}
+
+func (a *teamAuthProvider) IsTeamMember(repo, team, username string) (bool, error) {
+	if a.err != nil {
+		return false, a.err
+	}
+	return a.members[team+"/"+username], nil
+}
+
+func postIssueComment(t *testing.T, handler *Handler, secret string, commentID int64, body, user string) *httptest.ResponseRecorder {
+	t.Helper()
+	event := &IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 7, Title: "Repo config", Body: "Body"},
+		Comment:    Comment{ID: commentID, Body: body, User: User{Login: user, Type: "User"}},
+		Repository: Repository{FullName: "owner/repo", DefaultBranch: "main"},
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+	return w
+}
+
+func TestHandler_RepoConfigTriggerKeywords(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	var refs []string
+	handler := NewHandler("secret", "/code", dispatcher, nil, nil).
+		WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) {
+			refs = append(refs, repo+"@"+ref)
+			return &repoconfig.Config{TriggerKeywords: []string{"@swe"}}, nil
+		})
+
+	w := postIssueComment(t, handler, "secret", 1, "/code ignored now", "alice")
+	if dispatcher.enqueueCalls != 0 || !strings.Contains(w.Body.String(), "No trigger keyword found") {
+		t.Fatalf("server trigger should be replaced by repo triggers, got %q", w.Body.String())
+	}
+
+	postIssueComment(t, handler, "secret", 2, "please @swe fix the tests", "alice")
+	if dispatcher.enqueueCalls != 1 {
+		t.Fatalf("enqueueCalls = %d, want 1", dispatcher.enqueueCalls)
+	}
+	task := dispatcher.lastTask
+	if task.DefaultBranch != "main" || !strings.Contains(task.Prompt, "fix the tests") {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if !strings.Contains(task.PromptContext["trigger_context"], "@swe") {
+		t.Fatalf("trigger_context = %q, want matched repo keyword", task.PromptContext["trigger_context"])
+	}
+
+	if len(refs) != 1 || refs[0] != "owner/repo@main" {
+		t.Fatalf("loader calls = %v, want one cached fetch of owner/repo@main", refs)
+	}
+}
+
+func TestHandler_RepoConfigLoaderErrorDenies(t *testing.T) {
+	mockGH := github.NewMockGHClient()
+	github.SetGHClient(mockGH)
+	t.Cleanup(func() { github.SetGHClient(github.NewRealGHClient()) })
+
+	calls := 0
+	dispatcher := &mockDispatcher{}
+	handler := NewHandler("secret", "/code", dispatcher, nil, &mockAppAuth{}).
+		WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) {
+			calls++
+			return nil, errors.New("context deadline exceeded")
+		})
+
+	// The config may restrict who can trigger, so the task is not started.
+	w := postIssueComment(t, handler, "secret", 3, "/code do it", "alice")
+	if dispatcher.enqueueCalls != 0 || w.Body.String() != "Repository config unavailable" {
+		t.Fatalf("enqueueCalls = %d, body %q, want the trigger denied", dispatcher.enqueueCalls, w.Body.String())
+	}
+	if len(mockGH.CreateCommentCalls) != 1 || !strings.Contains(mockGH.CreateCommentCalls[0].Body, "@alice this task was not started: `.swe-agent.yml` could not be loaded") {
+		t.Fatalf("comments = %+v, want an explanation on the issue", mockGH.CreateCommentCalls)
+	}
+
+	// Errors are not cached; comments without a trigger are ignored as before.
+	w = postIssueComment(t, handler, "secret", 4, "looks good", "alice")
+	if w.Body.String() != "No trigger keyword found" || calls != 2 {
+		t.Fatalf("body %q after %d loads, want the config fetched again", w.Body.String(), calls)
+	}
+}
+
+func TestHandler_RepoConfigAllowedUsersAndTeams(t *testing.T) {
+	cfg := &repoconfig.Config{AllowedUsers: []string{"alice"}, AllowedTeams: []string{"maintainers"}}
+	auth := &teamAuthProvider{
+		stubAuthProvider: stubAuthProvider{err: errors.New("permission API down")},
+		members:          map[string]bool{"maintainers/bob": true},
+	}
+	dispatcher := &mockDispatcher{}
+	handler := NewHandler("secret", "/code", dispatcher, nil, auth).
+		WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) { return cfg, nil })
+
+	postIssueComment(t, handler, "secret", 10, "/code a", "alice")
+	postIssueComment(t, handler, "secret", 11, "/code b", "bob")
+	w := postIssueComment(t, handler, "secret", 12, "/code c", "mallory")
+
+	if dispatcher.enqueueCalls != 2 {
+		t.Fatalf("enqueueCalls = %d, want alice and bob only", dispatcher.enqueueCalls)
+	}
+	if !strings.Contains(w.Body.String(), "Permission denied") {
+		t.Fatalf("mallory response = %q, want permission denied", w.Body.String())
+	}
+}
+
+func TestVerifyRepoAllowList_FailsClosed(t *testing.T) {
+	cfg := &repoconfig.Config{AllowedTeams: []string{"maintainers"}}
+
+	h := &Handler{appAuth: &stubAuthProvider{}}
+	if h.verifyRepoAllowList("owner/repo", "bob", cfg) {
+		t.Fatal("expected deny when team membership cannot be checked")
+	}
+
+	h = &Handler{appAuth: &teamAuthProvider{err: errors.New("403")}}
+	if h.verifyRepoAllowList("owner/repo", "bob", cfg) {
+		t.Fatal("expected deny when team API fails")
+	}
+
+	if !h.verifyRepoAllowList("owner/repo", "bob", nil) {
+		t.Fatal("expected allow without repository config")
+	}
+}
+
+func TestRepoConfigCacheExpires(t *testing.T) {
+	calls := 0
+	h := (&Handler{}).WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) {
+		calls++
+		return nil, nil
+	})
+	now := time.Now()
+	h.repoConfigs.now = func() time.Time { return now }
+
+	h.loadRepoConfig("owner/repo", "main")
+	h.loadRepoConfig("owner/repo", "main")
+	now = now.Add(repoConfigTTL + time.Second)
+	h.loadRepoConfig("owner/repo", "main")
+
+	if calls != 2 {
+		t.Fatalf("loader calls = %d, want 2", calls)
+	}
+}
+
+func TestMatchTrigger(t *testing.T) {
+	got, ok := matchTrigger("hey @swe and /code", []string{"/code", "@swe"})
+	if !ok || got != "@swe" {
+		t.Fatalf("matchTrigger() = %q, %v, want earliest keyword @swe", got, ok)
+	}
+	if _, ok := matchTrigger("nothing here", []string{"/code", ""}); ok {
+		t.Fatal("matchTrigger() should not match")
+	}
+}
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handlePullRequestReview starts a follow-up task when a reviewer requests
+// changes on a pull request the agent opened. Repositories opt in through
+// review_followup in .swe-agent.yml, which also bounds the number of rounds.
+func (h *Handler) handlePullRequestReview(w http.ResponseWriter, payload []byte) {
+	var event PullRequestReviewEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("Error parsing pull request review event: %v", err)
+		http.Error(w, "Error parsing event", http.StatusBadRequest)
+		return
+	}
+
+	if event.Action != "submitted" || !strings.EqualFold(event.Review.State, "changes_requested") {
+		log.Printf("Ignoring pull_request_review action: %s (state %s)", event.Action, event.Review.State)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Review ignored"))
+		return
+	}
+
+	if event.Review.User.Type == "Bot" {
+		log.Printf("Ignoring review from bot: %s", event.Review.User.Login)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Bot review ignored"))
+		return
+	}
+
+	repo := event.Repository.FullName
+	branch := event.PullRequest.Head.Ref
+	// Follow-ups are opt-in, so a config that cannot be loaded disables them.
+	repoCfg, err := h.loadRepoConfig(repo, event.Repository.DefaultBranch)
+	if err != nil {
+		log.Printf("Warning: cannot load repository config for %s: %v", repo, err)
+	}
+	maxRounds := repoCfg.ReviewRounds()
+	if maxRounds == 0 || h.store == nil {
+		log.Printf("Review follow-up disabled for %s", repo)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Review follow-up disabled"))
+		return
+	}
+
+	// Only branches the agent pushed get follow-ups; fork heads cannot be ours.
+	link, ok := h.store.FindLink(repo, branch)
+	if !ok || event.PullRequest.State != "open" || event.PullRequest.ForkRepo(repo) != "" {
+		log.Printf("Ignoring review on %s#%d: branch %s was not created by the agent", repo, event.PullRequest.Number, branch)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Review ignored"))
+		return
+	}
+
+	reviewer := event.Review.User.Login
+	if !h.verifyPermission(repo, reviewer) || !h.verifyRepoAllowList(repo, reviewer, repoCfg) {
+		log.Printf("Permission denied: reviewer %s may not start follow-up tasks", reviewer)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Permission denied"))
+		return
+	}
+
+	if !h.reviewsDeduper.markIfNew(event.Review.ID) {
+		log.Printf("Ignoring duplicate review: id=%d", event.Review.ID)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Duplicate review ignored"))
+		return
+	}
+
+	if h.rejectOverBudget(w, &Task{Repo: repo, Number: event.PullRequest.Number, Username: reviewer, InstallationID: event.Installation.ID}) {
+		return
+	}
+
+	round, claimed, err := h.store.ClaimReviewRound(repo, branch, maxRounds)
+	if err != nil {
+		log.Printf("Failed to claim review round for %s@%s: %v", repo, branch, err)
+		http.Error(w, "Failed to start review follow-up", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		log.Printf("Review follow-up limit (%d) reached for %s@%s", maxRounds, repo, branch)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Review follow-up limit reached"))
+		return
+	}
+
+	instruction := reviewFollowupInstruction(reviewer, event.Review.Body)
+	pr := event.PullRequest
+	components := TaskIDComponents{
+		Repo:      repo,
+		PRNumber:  &pr.Number,
+		Timestamp: time.Now().UnixNano(),
+	}
+	if link.IssueNumber > 0 && link.IssueNumber != pr.Number {
+		components.IssueNumber = &link.IssueNumber
+	}
+
+	task := &Task{
+		ID:             h.generateTaskID(components),
+		Repo:           repo,
+		Number:         pr.Number,
+		Branch:         pr.Base.Ref,
+		DefaultBranch:  event.Repository.DefaultBranch,
+		Prompt:         buildPrompt(pr.Title, pr.Body, instruction),
+		PromptSummary:  buildPromptSummary(pr.Title, fmt.Sprintf("Address review feedback from @%s (round %d of %d)", reviewer, round, maxRounds), true),
+		IssueTitle:     pr.Title,
+		IssueBody:      pr.Body,
+		IsPR:           true,
+		PRBranch:       branch,
+		PRState:        pr.State,
+		Username:       reviewer,
+		InstallationID: event.Installation.ID,
+		ReviewRound:    round,
+		PromptContext:  buildPromptContextForReviewFollowup(event, instruction),
+	}
+	if task.Branch == "" {
+		task.Branch = event.Repository.DefaultBranch
+	}
+
+	h.createStoreTask(task)
+
+	log.Printf("Received review follow-up: repo=%s, number=%d, reviewID=%d, round=%d/%d", repo, pr.Number, event.Review.ID, round, maxRounds)
+
+	h.enqueueTask(w, task, task.Prompt)
+}
+
+// reviewFollowupInstruction is the directive of a review follow-up task. The
+// executor appends the unresolved review threads to the prompt.
+func reviewFollowupInstruction(reviewer, reviewBody string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "@%s requested changes on this pull request. Address the unresolved review threads listed below with commits on this branch.", reviewer)
+	if body := strings.TrimSpace(reviewBody); body != "" {
+		builder.WriteString("\n\nReview summary:\n")
+		builder.WriteString(body)
+	}
+	return builder.String()
+}
+
+func buildPromptContextForReviewFollowup(event PullRequestReviewEvent, instruction string) map[string]string {
+	branch := event.PullRequest.Base.Ref
+	if branch == "" {
+		branch = event.Repository.DefaultBranch
+	}
+
+	return map[string]string{
+		"issue_title":          event.PullRequest.Title,
+		"issue_body":           event.PullRequest.Body,
+		"event_name":           "pull_request_review",
+		"event_type":           "REVIEW",
+		"trigger_username":     event.Review.User.Login,
+		"trigger_display_name": event.Review.User.Login,
+		"trigger_comment":      instruction,
+		"trigger_context":      "pull request review requesting changes",
+		"repository":           event.Repository.FullName,
+		"base_branch":          branch,
+		"is_pr":                "true",
+		"pr_number":            strconv.Itoa(event.PullRequest.Number),
+	}
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/repoconfig"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+const reviewPayload = `{
+	"action": "submitted",
+	"review": {"id": %d, "body": "Please handle errors", "state": "%s", "user": {"login": "alice", "type": "User"}},
+	"pull_request": {
+		"number": 12,
+		"title": "Fix login",
+		"state": "open",
+		"base": {"ref": "main"},
+		"head": {"ref": "%s", "repo": {"full_name": "owner/repo"}}
+	},
+	"repository": {"full_name": "owner/repo", "default_branch": "main"}
+}`
+
+func postReview(t *testing.T, h *Handler, reviewID int64, state, branch string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(reviewPayload, reviewID, state, branch))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", "pull_request_review")
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+	return w
+}
+
+func newReviewHandler(t *testing.T, cfg *repoconfig.Config) (*Handler, *mockDispatcher) {
+	t.Helper()
+	store, err := taskstore.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Create(&taskstore.Task{ID: "task-1", Title: "t", Status: taskstore.StatusCompleted, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7, Actor: "a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := store.SaveLink(&taskstore.PRLink{Repo: "owner/repo", Branch: "swe/issue-7", TaskID: "task-1", IssueNumber: 7}); err != nil {
+		t.Fatalf("SaveLink() error = %v", err)
+	}
+
+	dispatcher := &mockDispatcher{}
+	h := NewHandler("secret", "/code", dispatcher, store, nil).
+		WithRepoConfigLoader(func(repo, ref string) (*repoconfig.Config, error) { return cfg, nil })
+	return h, dispatcher
+}
+
+func TestHandlePullRequestReview_StartsFollowup(t *testing.T) {
+	cfg := &repoconfig.Config{ReviewFollowup: repoconfig.ReviewFollowupConfig{Enabled: true, MaxRounds: 1}}
+	h, dispatcher := newReviewHandler(t, cfg)
+
+	if w := postReview(t, h, 100, "changes_requested", "swe/issue-7"); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+	}
+	task := dispatcher.lastTask
+	if task == nil || task.ReviewRound != 1 || task.Number != 12 || task.PRBranch != "swe/issue-7" || task.PRState != "open" || task.Username != "alice" {
+		t.Fatalf("task = %+v", task)
+	}
+	if !strings.HasPrefix(task.ID, "owner-repo-issue-7-pr-12-") {
+		t.Fatalf("task ID = %q, want linked issue", task.ID)
+	}
+	if !strings.Contains(task.Prompt, "@alice requested changes") || !strings.Contains(task.Prompt, "Please handle errors") {
+		t.Fatalf("prompt = %q", task.Prompt)
+	}
+	if task.PromptContext["event_name"] != "pull_request_review" {
+		t.Fatalf("context = %+v", task.PromptContext)
+	}
+
+	if w := postReview(t, h, 100, "changes_requested", "swe/issue-7"); !strings.Contains(w.Body.String(), "Duplicate review") {
+		t.Fatalf("redelivery: %q", w.Body.String())
+	}
+	if w := postReview(t, h, 101, "changes_requested", "swe/issue-7"); !strings.Contains(w.Body.String(), "limit reached") {
+		t.Fatalf("second round: %q", w.Body.String())
+	}
+	if dispatcher.enqueueCalls != 1 {
+		t.Fatalf("enqueueCalls = %d, want 1", dispatcher.enqueueCalls)
+	}
+}
+
+func TestHandlePullRequestReview_Ignored(t *testing.T) {
+	enabled := &repoconfig.Config{ReviewFollowup: repoconfig.ReviewFollowupConfig{Enabled: true}}
+	tests := []struct {
+		name, state, branch string
+		cfg                 *repoconfig.Config
+	}{
+		{"approved", "approved", "swe/issue-7", enabled},
+		{"disabled", "changes_requested", "swe/issue-7", nil},
+		{"foreign branch", "changes_requested", "feature/manual", enabled},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dispatcher := newReviewHandler(t, tt.cfg)
+			if w := postReview(t, h, int64(200+i), tt.state, tt.branch); w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want ignored", w.Code)
+			}
+			if dispatcher.enqueueCalls != 0 {
+				t.Fatal("review must not start a task")
+			}
+		})
+	}
+}
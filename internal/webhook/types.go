@@ -1,21 +1,53 @@
 package webhook
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // GitHub webhook event types
 
 type IssueCommentEvent struct {
-	Action     string     `json:"action"`
-	Issue      Issue      `json:"issue"`
-	Comment    Comment    `json:"comment"`
-	Repository Repository `json:"repository"`
-	Sender     User       `json:"sender"`
+	Action       string       `json:"action"`
+	Issue        Issue        `json:"issue"`
+	Comment      Comment      `json:"comment"`
+	Repository   Repository   `json:"repository"`
+	Sender       User         `json:"sender"`
+	Installation Installation `json:"installation"`
 }
 
 type PullRequestReviewCommentEvent struct {
-	Action      string        `json:"action"`
-	Comment     ReviewComment `json:"comment"`
-	PullRequest PullRequest   `json:"pull_request"`
-	Repository  Repository    `json:"repository"`
-	Sender      User          `json:"sender"`
+	Action       string        `json:"action"`
+	Comment      ReviewComment `json:"comment"`
+	PullRequest  PullRequest   `json:"pull_request"`
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation Installation  `json:"installation"`
+}
+
+type PullRequestEvent struct {
+	Action       string       `json:"action"`
+	Number       int          `json:"number"`
+	PullRequest  PullRequest  `json:"pull_request"`
+	Repository   Repository   `json:"repository"`
+	Sender       User         `json:"sender"`
+	Installation Installation `json:"installation"`
+}
+
+type PullRequestReviewEvent struct {
+	Action       string       `json:"action"`
+	Review       Review       `json:"review"`
+	PullRequest  PullRequest  `json:"pull_request"`
+	Repository   Repository   `json:"repository"`
+	Sender       User         `json:"sender"`
+	Installation Installation `json:"installation"`
+}
+
+type Review struct {
+	ID    int64  `json:"id"`
+	Body  string `json:"body"`
+	State string `json:"state"` // "approved", "changes_requested" or "commented"
+	User  User   `json:"user"`
 }
 
 type Issue struct {
@@ -35,11 +67,12 @@ type Comment struct {
 }
 
 type ReviewComment struct {
-	ID       int64  `json:"id"`
-	Body     string `json:"body"`
-	User     User   `json:"user"`
-	Path     string `json:"path"`
-	DiffHunk string `json:"diff_hunk"`
+	ID          int64  `json:"id"`
+	InReplyToID int64  `json:"in_reply_to_id"`
+	Body        string `json:"body"`
+	User        User   `json:"user"`
+	Path        string `json:"path"`
+	DiffHunk    string `json:"diff_hunk"`
 }
 
 type Repository struct {
@@ -54,12 +87,48 @@ type PullRequest struct {
 	Title  string `json:"title"`
 	Body   string `json:"body"`
 	State  string `json:"state"` // "open" or "closed"
+	Merged bool   `json:"merged"`
+	URL    string `json:"html_url"`
 	Base   struct {
 		Ref string `json:"ref"`
 	} `json:"base"`
 	Head struct {
 		Ref string `json:"ref"` // Source branch name
 	} `json:"head"`
+	HeadRepo            *Repository `json:"-"`                     // head.repo, nil when the fork was deleted
+	MaintainerCanModify bool        `json:"maintainer_can_modify"` // "Allow edits from maintainers" on fork PRs
+}
+
+// UnmarshalJSON also decodes head.repo into HeadRepo.
+func (pr *PullRequest) UnmarshalJSON(data []byte) error {
+	type plain PullRequest
+	var aux struct {
+		plain
+		Head struct {
+			Ref  string      `json:"ref"`
+			Repo *Repository `json:"repo"`
+		} `json:"head"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*pr = PullRequest(aux.plain)
+	pr.Head.Ref = aux.Head.Ref
+	pr.HeadRepo = aux.Head.Repo
+	return nil
+}
+
+// ForkRepo returns the head repository when the PR comes from a fork of base, or "".
+func (pr PullRequest) ForkRepo(base string) string {
+	if pr.HeadRepo == nil || pr.HeadRepo.FullName == "" || strings.EqualFold(pr.HeadRepo.FullName, base) {
+		return ""
+	}
+	return pr.HeadRepo.FullName
+}
+
+// Installation is the GitHub App installation that received the event.
+type Installation struct {
+	ID int64 `json:"id"`
 }
 
 type User struct {
@@ -0,0 +1,367 @@
+// Package workspace keeps a bare mirror per repository and hands out per-task
+// git worktrees, so tasks fetch incrementally instead of cloning from scratch.
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/sandbox"
+)
+
+// lastUsedFile is touched in a mirror every time it is used; its mtime drives LRU eviction.
+const lastUsedFile = "swe-last-used"
+
+// mirrorRefspec keeps upstream branches under refs/remotes/origin, leaving
+// refs/heads free for task branches.
+const mirrorRefspec = "+refs/heads/*:refs/remotes/origin/*"
+
+// gitEnvAllow are the only server variables mirror commands see; the server
+// environment also holds the app key and provider API keys.
+var gitEnvAllow = []string{"PATH", "HOME", "GIT_*"}
+
+var unsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Options configures the cache.
+type Options struct {
+	Dir      string // cache root; mirrors and worktrees live below it
+	MaxBytes int64  // disk quota for all mirrors, 0 = unlimited
+	MaxRepos int    // maximum number of mirrors kept, 0 = unlimited
+	BaseURL  string // git host, defaults to https://github.com
+}
+
+// Cache manages repository mirrors and task worktrees.
+type Cache struct {
+	opts Options
+	now  func() time.Time
+
+	mu    sync.Mutex
+	repos map[string]*repoState
+}
+
+// repoState serializes mirror updates and tracks worktrees in use.
+type repoState struct {
+	mu     sync.Mutex
+	active int
+}
+
+// New creates a cache rooted at opts.Dir. Worktrees left behind by a previous
+// process are removed; their mirror metadata is pruned on next use.
+func New(opts Options) (*Cache, error) {
+	if strings.TrimSpace(opts.Dir) == "" {
+		return nil, fmt.Errorf("workspace cache directory is required")
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = "https://github.com"
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	c := &Cache{opts: opts, now: time.Now, repos: make(map[string]*repoState)}
+	if err := os.RemoveAll(c.worktreesDir()); err != nil {
+		return nil, fmt.Errorf("failed to clear stale worktrees: %w", err)
+	}
+	for _, dir := range []string{c.mirrorsDir(), c.worktreesDir(), c.hooksDir()} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.Chmod(c.hooksDir(), 0o555); err != nil {
+		return nil, fmt.Errorf("failed to protect %s: %w", c.hooksDir(), err)
+	}
+	return c, nil
+}
+
+// Clone matches executor.CloneFunc: it updates the repository mirror and returns a
+// fresh worktree checked out (detached) at origin/<branch>. The cleanup function
+// removes the worktree; the mirror is kept for the next task.
+func (c *Cache) Clone(repo, branch, token string) (string, func(), error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", nil, fmt.Errorf("invalid repo format: %s (expected owner/repo)", repo)
+	}
+
+	state := c.state(repo)
+	state.mu.Lock()
+	mirror, err := c.ensureMirror(repo, token)
+	if err != nil {
+		state.mu.Unlock()
+		return "", nil, err
+	}
+
+	ref := "refs/remotes/origin/" + branch
+	if _, err := git(mirror, nil, "rev-parse", "--verify", "-q", ref); err != nil {
+		state.mu.Unlock()
+		return "", nil, fmt.Errorf("branch %s not found in %s", branch, repo)
+	}
+
+	workdir := filepath.Join(c.worktreesDir(), fmt.Sprintf("%s-%s-%s-%d", sanitize(owner), sanitize(name), sanitize(branch), c.now().UnixNano()))
+	if _, err := git(mirror, nil, "worktree", "add", "--detach", workdir, ref); err != nil {
+		state.mu.Unlock()
+		return "", nil, err
+	}
+	state.active++
+	state.mu.Unlock()
+
+	// No credentials are stored in the worktree: the agent can read its git
+	// directory, so later fetches and pushes pass the token per command.
+
+	c.evict(repo)
+
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() { c.release(repo, mirror, workdir) })
+	}
+	return workdir, cleanup, nil
+}
+
+// ensureMirror creates the bare mirror if needed and fetches all branches.
+// Callers must hold the repository lock.
+func (c *Cache) ensureMirror(repo, token string) (string, error) {
+	mirror := c.mirrorPath(repo)
+	if _, err := os.Stat(filepath.Join(mirror, "HEAD")); os.IsNotExist(err) {
+		if err := c.initMirror(repo, mirror); err != nil {
+			_ = os.RemoveAll(mirror)
+			return "", err
+		}
+	}
+
+	if err := c.protectMirror(repo, mirror); err != nil {
+		return "", err
+	}
+	if _, err := git(mirror, nil, "worktree", "prune"); err != nil {
+		return "", err
+	}
+	if _, err := git(mirror, github.GitAuthEnv(token), "fetch", "--prune", "--no-tags", c.remoteURL(repo), mirrorRefspec); err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", repo, err)
+	}
+
+	now := c.now()
+	marker := filepath.Join(mirror, lastUsedFile)
+	if err := os.WriteFile(marker, nil, 0o644); err == nil {
+		_ = os.Chtimes(marker, now, now)
+	}
+	return mirror, nil
+}
+
+func (c *Cache) initMirror(repo, mirror string) error {
+	log.Printf("Creating workspace mirror for %s", repo)
+	if err := os.MkdirAll(mirror, 0o755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	_, err := git(mirror, nil, "init", "-q", "--bare")
+	return err
+}
+
+// protectMirror rebuilds the mirror's config from scratch on every use. Tasks
+// share it through their worktrees and may rewrite it, so nothing planted there
+// (filters, fsmonitor or ssh commands, URL rewrites, a new remote URL) survives
+// into the next fetch or worktree add. Hooks point at an empty read-only
+// directory, and reflogs are off because the sandbox mounts logs/ read-only.
+func (c *Cache) protectMirror(repo, mirror string) error {
+	files := []struct {
+		name     string
+		settings [][2]string
+	}{
+		{"config", [][2]string{
+			{"core.repositoryformatversion", "1"},
+			{"extensions.worktreeConfig", "true"},
+			{"core.hooksPath", c.hooksDir()},
+			{"core.logAllRefUpdates", "false"},
+			{"remote.origin.url", c.remoteURL(repo)},
+			{"remote.origin.fetch", mirrorRefspec},
+		}},
+		// worktreeConfig keeps core.bare out of the config the worktrees share.
+		{"config.worktree", [][2]string{{"core.bare", "true"}}},
+	}
+	for _, f := range files {
+		tmp := filepath.Join(mirror, f.name+".new")
+		_ = os.Remove(tmp)
+		for _, kv := range f.settings {
+			if _, err := git(mirror, nil, "config", "--file", tmp, kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(tmp, filepath.Join(mirror, f.name)); err != nil {
+			return fmt.Errorf("failed to reset mirror %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// release removes a worktree and deletes local branches no other worktree uses,
+// so stale task branches never leak into later tasks.
+func (c *Cache) release(repo, mirror, workdir string) {
+	state := c.state(repo)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if err := c.protectMirror(repo, mirror); err != nil {
+		log.Printf("Warning: failed to reset mirror config for %s: %v", repo, err)
+	}
+	if _, err := git(mirror, nil, "worktree", "remove", "--force", workdir); err != nil {
+		log.Printf("Warning: failed to remove worktree %s: %v", workdir, err)
+		_ = os.RemoveAll(workdir)
+		_, _ = git(mirror, nil, "worktree", "prune")
+	}
+	if state.active > 0 {
+		state.active--
+	}
+
+	inUse := make(map[string]bool)
+	if out, err := git(mirror, nil, "worktree", "list", "--porcelain"); err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			if ref, ok := strings.CutPrefix(line, "branch "); ok {
+				inUse[ref] = true
+			}
+		}
+	}
+	out, err := git(mirror, nil, "for-each-ref", "--format=%(refname)", "refs/heads/")
+	if err != nil {
+		return
+	}
+	for _, ref := range strings.Fields(out) {
+		if !inUse[ref] {
+			if _, err := git(mirror, nil, "branch", "-D", strings.TrimPrefix(ref, "refs/heads/")); err != nil {
+				log.Printf("Warning: failed to delete branch %s in %s: %v", ref, repo, err)
+			}
+		}
+	}
+}
+
+type mirrorInfo struct {
+	repo     string
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// evict removes least recently used idle mirrors until the quotas are met.
+// The mirror of the repository that was just used is never evicted.
+func (c *Cache) evict(current string) {
+	if c.opts.MaxRepos <= 0 && c.opts.MaxBytes <= 0 {
+		return
+	}
+
+	mirrors := c.listMirrors()
+	var total int64
+	for _, m := range mirrors {
+		total += m.size
+	}
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].lastUsed.Before(mirrors[j].lastUsed) })
+
+	count := len(mirrors)
+	for _, m := range mirrors {
+		overRepos := c.opts.MaxRepos > 0 && count > c.opts.MaxRepos
+		overBytes := c.opts.MaxBytes > 0 && total > c.opts.MaxBytes
+		if !overRepos && !overBytes {
+			return
+		}
+		if m.repo == current {
+			continue
+		}
+
+		state := c.state(m.repo)
+		if !state.mu.TryLock() {
+			continue
+		}
+		if state.active > 0 {
+			state.mu.Unlock()
+			continue
+		}
+		err := os.RemoveAll(m.path)
+		state.mu.Unlock()
+		if err != nil {
+			log.Printf("Warning: failed to evict mirror %s: %v", m.repo, err)
+			continue
+		}
+
+		log.Printf("Evicted workspace mirror %s (%d bytes)", m.repo, m.size)
+		count--
+		total -= m.size
+	}
+}
+
+func (c *Cache) listMirrors() []mirrorInfo {
+	var mirrors []mirrorInfo
+	owners, _ := os.ReadDir(c.mirrorsDir())
+	for _, owner := range owners {
+		repos, _ := os.ReadDir(filepath.Join(c.mirrorsDir(), owner.Name()))
+		for _, r := range repos {
+			if !strings.HasSuffix(r.Name(), ".git") {
+				continue
+			}
+			path := filepath.Join(c.mirrorsDir(), owner.Name(), r.Name())
+			info := mirrorInfo{
+				repo: owner.Name() + "/" + strings.TrimSuffix(r.Name(), ".git"),
+				path: path,
+				size: dirSize(path),
+			}
+			if st, err := os.Stat(filepath.Join(path, lastUsedFile)); err == nil {
+				info.lastUsed = st.ModTime()
+			}
+			mirrors = append(mirrors, info)
+		}
+	}
+	return mirrors
+}
+
+func (c *Cache) state(repo string) *repoState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.repos[repo]
+	if !ok {
+		s = &repoState{}
+		c.repos[repo] = s
+	}
+	return s
+}
+
+func (c *Cache) mirrorsDir() string   { return filepath.Join(c.opts.Dir, "mirrors") }
+func (c *Cache) worktreesDir() string { return filepath.Join(c.opts.Dir, "worktrees") }
+func (c *Cache) hooksDir() string     { return filepath.Join(c.opts.Dir, "hooks") }
+
+func (c *Cache) remoteURL(repo string) string { return fmt.Sprintf("%s/%s.git", c.opts.BaseURL, repo) }
+
+func (c *Cache) mirrorPath(repo string) string {
+	owner, name, _ := strings.Cut(repo, "/")
+	return filepath.Join(c.mirrorsDir(), owner, name+".git")
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func sanitize(s string) string {
+	s = strings.Trim(unsafeChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(sandbox.FilterEnv(os.Environ(), gitEnvAllow), "GIT_TERMINAL_PROMPT=0"), env...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w\nOutput: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
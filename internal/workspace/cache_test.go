@@ -0,0 +1,331 @@
+package workspace
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newUpstream creates <root>/<repo>.git with a main branch of n commits and returns a seed clone.
+func newUpstream(t *testing.T, root, repo string, n int) string {
+	t.Helper()
+	bare := filepath.Join(root, repo+".git")
+	if err := os.MkdirAll(bare, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, bare, "init", "-q", "--bare")
+
+	seed := t.TempDir()
+	runGit(t, seed, "init", "-q", "-b", "main")
+	runGit(t, seed, "config", "user.name", "Test")
+	runGit(t, seed, "config", "user.email", "test@example.com")
+	runGit(t, seed, "remote", "add", "origin", bare)
+	for i := 0; i < n; i++ {
+		commitFile(t, seed, "file.txt", fmt.Sprintf("v%d\n", i))
+	}
+	runGit(t, seed, "push", "-q", "origin", "main")
+	return seed
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-q", "-m", "update "+name)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, nil, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out)
+}
+
+func newTestCache(t *testing.T, upstream string, opts Options) *Cache {
+	t.Helper()
+	opts.Dir = t.TempDir()
+	opts.BaseURL = upstream
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestCacheClone_WorktreeWithFullHistory(t *testing.T) {
+	upstream := t.TempDir()
+	seed := newUpstream(t, upstream, "acme/api", 3)
+	cache := newTestCache(t, upstream, Options{})
+
+	workdir, cleanup, err := cache.Clone("acme/api", "main", "ghs_secret")
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if got := runGit(t, workdir, "rev-list", "--count", "origin/main"); got != "3" {
+		t.Fatalf("origin/main history = %s commits, want 3 (not shallow)", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(workdir, "file.txt"))
+	if string(data) != "v2\n" {
+		t.Fatalf("file.txt = %q", data)
+	}
+
+	// Task-local config must not leak into the shared mirror.
+	runGit(t, workdir, "checkout", "-q", "-b", "swe/issue-1")
+	runGit(t, workdir, "config", "--worktree", "core.sparseCheckout", "true")
+	mirror := cache.mirrorPath("acme/api")
+	shared, _ := os.ReadFile(filepath.Join(mirror, "config"))
+	if strings.Contains(string(shared), "sparseCheckout") || strings.Contains(string(shared), "extraHeader") {
+		t.Fatalf("mirror config leaked task settings:\n%s", shared)
+	}
+	// The token is never written to disk, where the agent could read it.
+	encoded := base64.StdEncoding.EncodeToString([]byte("x-access-token:ghs_secret"))
+	for _, dir := range []string{mirror, workdir} {
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if data, _ := os.ReadFile(path); err == nil && !d.IsDir() && strings.Contains(string(data), encoded) {
+				t.Fatalf("token stored in %s", path)
+			}
+			return nil
+		})
+	}
+
+	cleanup()
+	cleanup()
+	if _, err := os.Stat(workdir); !os.IsNotExist(err) {
+		t.Fatalf("worktree not removed: %v", err)
+	}
+	if out := runGit(t, mirror, "for-each-ref", "refs/heads/"); out != "" {
+		t.Fatalf("task branches left in mirror: %s", out)
+	}
+
+	// A second task fetches incrementally and sees the new upstream commit.
+	commitFile(t, seed, "file.txt", "v3\n")
+	runGit(t, seed, "push", "-q", "origin", "main")
+	workdir, cleanup, err = cache.Clone("acme/api", "main", "")
+	if err != nil {
+		t.Fatalf("second Clone() error = %v", err)
+	}
+	defer cleanup()
+	data, _ = os.ReadFile(filepath.Join(workdir, "file.txt"))
+	if string(data) != "v3\n" {
+		t.Fatalf("file.txt after fetch = %q", data)
+	}
+}
+
+func TestCacheClone_IgnoresHooksPlantedInMirror(t *testing.T) {
+	upstream := t.TempDir()
+	newUpstream(t, upstream, "acme/api", 1)
+	cache := newTestCache(t, upstream, Options{})
+
+	workdir, cleanup, err := cache.Clone("acme/api", "main", "")
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	// A task writes a hook into the shared mirror; later worktree adds must not run it.
+	mirror := cache.mirrorPath("acme/api")
+	marker := filepath.Join(t.TempDir(), "ran")
+	if err := os.WriteFile(filepath.Join(mirror, "hooks", "post-checkout"), []byte("#!/bin/sh\ntouch "+marker+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+
+	workdir, cleanup, err = cache.Clone("acme/api", "main", "")
+	if err != nil {
+		t.Fatalf("second Clone() error = %v", err)
+	}
+	defer cleanup()
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("hook planted in the mirror ran")
+	}
+	if got := runGit(t, workdir, "config", "core.hooksPath"); got != cache.hooksDir() {
+		t.Fatalf("core.hooksPath = %q, want %q", got, cache.hooksDir())
+	}
+	if entries, _ := os.ReadDir(cache.hooksDir()); len(entries) != 0 {
+		t.Fatalf("hooks directory is not empty: %v", entries)
+	}
+}
+
+func TestCacheClone_ResetsConfigPlantedInMirror(t *testing.T) {
+	upstream := t.TempDir()
+	seed := newUpstream(t, upstream, "acme/api", 1)
+	cache := newTestCache(t, upstream, Options{})
+
+	_, cleanup, err := cache.Clone("acme/api", "main", "")
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	// A task rewrites the shared config to run code or redirect the next fetch.
+	decoy := t.TempDir()
+	newUpstream(t, decoy, "acme/api", 3)
+	marker := filepath.Join(t.TempDir(), "ran")
+	mirror := cache.mirrorPath("acme/api")
+	runGit(t, mirror, "config", "core.fsmonitor", "touch "+marker+"; false")
+	runGit(t, mirror, "config", "remote.origin.url", filepath.Join(decoy, "acme/api.git"))
+	runGit(t, mirror, "config", "url."+decoy+"/.insteadOf", upstream+"/")
+	cleanup()
+
+	commitFile(t, seed, "file.txt", "next\n")
+	runGit(t, seed, "push", "-q", "origin", "main")
+	workdir, cleanup, err := cache.Clone("acme/api", "main", "")
+	if err != nil {
+		t.Fatalf("second Clone() error = %v", err)
+	}
+	defer cleanup()
+	runGit(t, workdir, "status", "--porcelain")
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("fsmonitor planted in the mirror ran")
+	}
+	if got, want := runGit(t, workdir, "rev-parse", "HEAD"), runGit(t, seed, "rev-parse", "HEAD"); got != want {
+		t.Fatalf("worktree HEAD = %s, want upstream %s", got, want)
+	}
+	if out, _ := git(mirror, nil, "config", "--get-regexp", `^(core\.fsmonitor|url\.)`); out != "" {
+		t.Fatalf("planted config survived: %s", out)
+	}
+}
+
+func TestCacheClone_Errors(t *testing.T) {
+	upstream := t.TempDir()
+	newUpstream(t, upstream, "acme/api", 1)
+	cache := newTestCache(t, upstream, Options{})
+
+	if _, _, err := cache.Clone("acme", "main", ""); err == nil || !strings.Contains(err.Error(), "invalid repo format") {
+		t.Fatalf("Clone() error = %v, want invalid repo format", err)
+	}
+	if _, _, err := cache.Clone("acme/api", "missing", ""); err == nil || !strings.Contains(err.Error(), "branch missing not found") {
+		t.Fatalf("Clone() error = %v, want missing branch", err)
+	}
+	if _, _, err := cache.Clone("acme/other", "main", ""); err == nil || !strings.Contains(err.Error(), "failed to fetch") {
+		t.Fatalf("Clone() error = %v, want fetch failure", err)
+	}
+	if _, err := os.Stat(cache.mirrorPath("acme/other")); err != nil {
+		t.Fatalf("mirror of unreachable repo should be kept for retry: %v", err)
+	}
+}
+
+func TestCacheClone_ConcurrentTasksSameRepo(t *testing.T) {
+	upstream := t.TempDir()
+	newUpstream(t, upstream, "acme/api", 2)
+	cache := newTestCache(t, upstream, Options{})
+
+	const tasks = 4
+	var wg sync.WaitGroup
+	dirs := make([]string, tasks)
+	errs := make([]error, tasks)
+	for i := 0; i < tasks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			workdir, cleanup, err := cache.Clone("acme/api", "main", "")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer cleanup()
+			dirs[i] = workdir
+			branch := fmt.Sprintf("swe/issue-%d", i)
+			if _, err := git(workdir, nil, "checkout", "-q", "-b", branch); err != nil {
+				errs[i] = err
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("task %d error = %v", i, err)
+		}
+		if seen[dirs[i]] {
+			t.Fatalf("worktree %s handed out twice", dirs[i])
+		}
+		seen[dirs[i]] = true
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsedIdleMirror(t *testing.T) {
+	upstream := t.TempDir()
+	for _, repo := range []string{"acme/a", "acme/b", "acme/c"} {
+		newUpstream(t, upstream, repo, 1)
+	}
+	cache := newTestCache(t, upstream, Options{MaxRepos: 2})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	_, cleanupA, err := cache.Clone("acme/a", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	_, cleanupB, err := cache.Clone("acme/b", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanupB()
+
+	// acme/a is older but still in use, so acme/b is evicted instead.
+	now = now.Add(time.Minute)
+	_, cleanupC, err := cache.Clone("acme/c", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupC()
+	cleanupA()
+
+	for repo, want := range map[string]bool{"acme/a": true, "acme/b": false, "acme/c": true} {
+		_, err := os.Stat(cache.mirrorPath(repo))
+		if exists := err == nil; exists != want {
+			t.Errorf("mirror %s exists = %v, want %v", repo, exists, want)
+		}
+	}
+}
+
+func TestCacheEvictsOverDiskQuota(t *testing.T) {
+	upstream := t.TempDir()
+	newUpstream(t, upstream, "acme/a", 1)
+	newUpstream(t, upstream, "acme/b", 1)
+	cache := newTestCache(t, upstream, Options{MaxBytes: 1})
+
+	_, cleanup, err := cache.Clone("acme/a", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	_, cleanup, err = cache.Clone("acme/b", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	if _, err := os.Stat(cache.mirrorPath("acme/a")); !os.IsNotExist(err) {
+		t.Fatalf("acme/a should be evicted over quota, stat err = %v", err)
+	}
+	if _, err := os.Stat(cache.mirrorPath("acme/b")); err != nil {
+		t.Fatalf("current mirror must be kept: %v", err)
+	}
+}
+
+func TestNewRemovesStaleWorktrees(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "worktrees", "leftover")
+	if err := os.MkdirAll(stale, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{Dir: dir}); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale worktree kept: %v", err)
+	}
+	if _, err := New(Options{}); err == nil {
+		t.Fatal("New() without directory should fail")
+	}
+}